@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds each request callAPI sends, so that a server that
+// stops answering fails the command instead of hanging it.
+const requestTimeout = 30 * time.Second
+
+// apiRequest sends a request to the Helios API, authenticating with
+// apiToken. It is cancelled with ctx.
+func apiRequest(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+apiToken)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// apiError is an error status the API responded with.
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return "API server returned an error:\n" + e.Body
+}
+
+// transient reports whether err, returned by callAPI, may not happen again:
+// the API could not be reached or did not answer in time, or responded with
+// a server error or 429 Too Many Requests.
+func transient(err error) bool {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// callAPI sends a request with request, if not nil, as its JSON body, and
+// decodes the JSON response body into response, if not nil. It returns an
+// *apiError if the API responded with an error status. The request must
+// complete within requestTimeout.
+func callAPI(method, endpoint string, request, response any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return callAPIContext(ctx, method, endpoint, request, response)
+}
+
+// callAPIContext is callAPI with a request cancelled with ctx instead.
+func callAPIContext(ctx context.Context, method, endpoint string, request, response any) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request JSON: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	resp, err := apiRequest(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to send request to API server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return &apiError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// page is a page of a list returned by the API. NextCursor is empty on the
+// last page.
+type page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// printFollowHint tells the user how to follow a deployment that was just
+// queued.
+func printFollowHint(apiURL, id string) {
+	fmt.Printf("\nFollow the deployment with:\n  helios-cli status --watch --api %s %s\n", apiURL, id)
+	fmt.Printf("or its logs with:\n  helios-cli logs --follow --api %s %s\n", apiURL, id)
+}
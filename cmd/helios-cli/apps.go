@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// application mirrors the application resource returned by the API.
+type application struct {
+	ID             string    `json:"id"`
+	ProjectID      string    `json:"project_id"`
+	Name           string    `json:"name"`
+	GitRepository  string    `json:"git_repository"`
+	GitBranch      string    `json:"git_branch"`
+	CurrentBackend string    `json:"current_backend"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// runAppsCreate implements `helios-cli apps create <name>`.
+func runAppsCreate(args []string) {
+	fs, apiURL := newFlagSet("apps create", "apps create [flags] <name>\n\nCreates an application and deploys the head of its branch.")
+	var gitRepo, gitBranch, projectID string
+	fs.StringVar(&gitRepo, "repo", "", "The git repository URL to deploy (required).")
+	fs.StringVar(&gitBranch, "branch", "main", "The git branch to deploy.")
+	fs.StringVar(&projectID, "project", "", "The ID of the project to create the application in (required).")
+	name := parseArgs(fs, args, 1)[0]
+
+	if gitRepo == "" || projectID == "" {
+		fmt.Fprintln(os.Stderr, "Error: The --repo and --project flags are required.")
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	request := map[string]string{
+		"project_id":     projectID,
+		"name":           name,
+		"git_repository": gitRepo,
+		"git_branch":     gitBranch,
+	}
+	var accepted struct {
+		ID           string `json:"id"`
+		DeploymentID string `json:"deployment_id"`
+		Status       string `json:"status"`
+	}
+	if err := callAPI(http.MethodPost, *apiURL+"/applications", request, &accepted); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	printResult(accepted, []string{accepted.ID}, func() {
+		fmt.Printf("Created application %s (%s).\n", name, accepted.ID)
+		fmt.Printf("Deployment %s of %s is %s.\n", accepted.DeploymentID, gitBranch, accepted.Status)
+		printFollowHint(*apiURL, accepted.DeploymentID)
+	})
+}
+
+// runAppsList implements `helios-cli apps list --project <id>`.
+func runAppsList(args []string) {
+	fs, apiURL := newFlagSet("apps list", "apps list [flags]")
+	var projectID, name, cursor string
+	var limit int
+	fs.StringVar(&projectID, "project", "", "The ID of the project whose applications to list (required).")
+	fs.StringVar(&name, "name", "", "Only list applications whose name contains this value.")
+	fs.IntVar(&limit, "limit", 20, "The maximum number of applications to list.")
+	fs.StringVar(&cursor, "cursor", "", "The cursor printed by a previous list, to show the next page.")
+	parseArgs(fs, args, 0)
+
+	if projectID == "" {
+		fmt.Fprintln(os.Stderr, "Error: The --project flag is required.")
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	var apps page[application]
+	if err := callAPI(http.MethodGet, *apiURL+"/projects/"+projectID+"/applications?"+listQuery(name, cursor, limit), nil, &apps); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	ids := make([]string, len(apps.Items))
+	for i, a := range apps.Items {
+		ids[i] = a.ID
+	}
+	printResult(apps, ids, func() {
+		if len(apps.Items) == 0 {
+			fmt.Println("No applications.")
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tREPOSITORY\tBRANCH\tBACKEND")
+		for _, a := range apps.Items {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.ID, a.Name, a.GitRepository, a.GitBranch, a.CurrentBackend)
+		}
+		tw.Flush()
+		printNextPage("applications", apps.NextCursor)
+	})
+}
+
+// runAppsShow implements `helios-cli apps show <application-id>`.
+func runAppsShow(args []string) {
+	fs, apiURL := newFlagSet("apps show", "apps show [flags] <application-id>")
+	var limit int
+	fs.IntVar(&limit, "deployments", 5, "The number of latest deployments to show.")
+	id := parseArgs(fs, args, 1)[0]
+
+	var a application
+	if err := callAPI(http.MethodGet, *apiURL+"/applications/"+id, nil, &a); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	var deployments page[deployment]
+	if err := callAPI(http.MethodGet, *apiURL+"/applications/"+id+"/deployments?"+listQuery("", "", limit), nil, &deployments); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	shown := struct {
+		application
+		Deployments []deployment `json:"deployments"`
+	}{a, deployments.Items}
+	printResult(shown, []string{a.ID}, func() {
+		fmt.Printf("ID:         %s\n", a.ID)
+		fmt.Printf("Name:       %s\n", a.Name)
+		fmt.Printf("Project:    %s\n", a.ProjectID)
+		fmt.Printf("Repository: %s\n", a.GitRepository)
+		fmt.Printf("Branch:     %s\n", a.GitBranch)
+		fmt.Printf("Backend:    %s\n", a.CurrentBackend)
+		fmt.Printf("Created at: %s\n", a.CreatedAt.Format(time.RFC3339))
+
+		fmt.Println("\nDeployments:")
+		if len(deployments.Items) == 0 {
+			fmt.Println("  None.")
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  ID\tSTATUS\tCOMMIT\tCREATED AT")
+		for _, d := range deployments.Items {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", d.ID, d.Status, d.GitCommitSHA, d.CreatedAt.Format(time.RFC3339))
+		}
+		tw.Flush()
+	})
+}
+
+// runAppsDelete implements `helios-cli apps delete <application-id>`.
+func runAppsDelete(args []string) {
+	fs, apiURL := newFlagSet("apps delete", "apps delete [flags] <application-id>\n\nDeletes an application and its deployment history. Requires the admin role.")
+	var yes bool
+	fs.BoolVar(&yes, "yes", false, "Do not ask for confirmation.")
+	id := parseArgs(fs, args, 1)[0]
+
+	var a application
+	if err := callAPI(http.MethodGet, *apiURL+"/applications/"+id, nil, &a); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if !yes && !confirm(fmt.Sprintf("Delete application %s (%s) and its deployment history?", a.Name, a.ID)) {
+		fmt.Fprintln(os.Stderr, "Not deleted.")
+		os.Exit(exitFailure)
+	}
+
+	if err := callAPI(http.MethodDelete, *apiURL+"/applications/"+id, nil, nil); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	printResult(a, []string{a.ID}, func() {
+		fmt.Printf("Deleted application %s (%s).\n", a.Name, a.ID)
+	})
+}
+
+// confirm asks a yes/no question on the terminal and reports whether it was
+// answered yes. The question goes to standard error, like other prompts, so
+// that it does not end up in the output of a script.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// runCancel implements `helios-cli cancel <deployment-id>`.
+func runCancel(args []string) {
+	fs, apiURL := newFlagSet("cancel", "cancel [flags] <deployment-id>\n\nAsks the workers to stop a deployment that has not finished.")
+	id := parseArgs(fs, args, 1)[0]
+
+	var d deployment
+	if err := callAPI(http.MethodPost, *apiURL+"/deployments/"+id+"/cancel", nil, &d); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	printResult(d, []string{d.ID}, func() {
+		fmt.Printf("Cancelling deployment %s, which is %s.\n", d.ID, d.Status)
+		printFollowHint(*apiURL, d.ID)
+	})
+}
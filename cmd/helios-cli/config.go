@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cliConfig is the configuration file of helios-cli. It holds one context
+// per API server the user logged in to.
+type cliConfig struct {
+	CurrentContext string                 `json:"current_context,omitempty"`
+	Contexts       map[string]*cliContext `json:"contexts"`
+}
+
+// cliContext is an API server and the token to authenticate to it with.
+type cliContext struct {
+	API   string `json:"api"`
+	Email string `json:"email,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// apiToken authenticates requests to the API. parseArgs sets it from the
+// selected context or HELIOS_TOKEN.
+var apiToken string
+
+// configPath returns the path of the configuration file: HELIOS_CONFIG if
+// set, otherwise helios/config.json in the user's config directory.
+func configPath() (string, error) {
+	if path := os.Getenv("HELIOS_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %w", err)
+	}
+	return filepath.Join(dir, "helios", "config.json"), nil
+}
+
+// loadConfig reads the configuration file. A missing file is an empty
+// configuration.
+func loadConfig() (*cliConfig, error) {
+	cfg := &cliConfig{Contexts: map[string]*cliContext{}}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = map[string]*cliContext{}
+	}
+	return cfg, nil
+}
+
+// save writes the configuration file. It holds tokens, so only the user can
+// read it.
+func (c *cliConfig) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	// Replace the file in one step, so it is never left half written.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// useContext points a command whose flags are parsed at the API of the
+// context named by --context, or the current context, unless --api was
+// given. Requests carry the context's token if they go to its API;
+// HELIOS_TOKEN overrides it.
+func useContext(fs *flag.FlagSet) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	name := fs.Lookup("context").Value.String()
+	ctx, ok := cfg.Contexts[name]
+	if name == "" {
+		ctx, ok = cfg.Contexts[cfg.CurrentContext]
+	} else if !ok {
+		return fmt.Errorf("unknown context %q; list the contexts with 'helios-cli context list'", name)
+	}
+
+	api := fs.Lookup("api").Value
+	switch {
+	case api.String() != "":
+		api.Set(normalizeAPIURL(api.String()))
+	case ok:
+		api.Set(ctx.API)
+	default:
+		api.Set(defaultAPIURL)
+	}
+
+	if ok && api.String() == ctx.API {
+		apiToken = ctx.Token
+	}
+	if token := os.Getenv("HELIOS_TOKEN"); token != "" {
+		apiToken = token
+	}
+	return nil
+}
+
+// normalizeAPIURL strips the trailing slash of an API URL, so that paths
+// can be appended to it and contexts compare equal.
+func normalizeAPIURL(url string) string {
+	return strings.TrimRight(url, "/")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestConfig makes cfg the configuration file for the test.
+func writeTestConfig(t *testing.T, cfg *cliConfig) {
+	t.Helper()
+	t.Setenv("HELIOS_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	require.NoError(t, cfg.save())
+}
+
+func TestUseContext(t *testing.T) {
+	cfg := &cliConfig{
+		CurrentContext: "staging",
+		Contexts: map[string]*cliContext{
+			"staging":    {API: "https://staging.helios.example.com", Token: "staging-token"},
+			"production": {API: "https://helios.example.com", Token: "production-token"},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		config        *cliConfig
+		args          []string
+		envToken      string
+		expectedAPI   string
+		expectedToken string
+		expectedErr   string
+	}{
+		{
+			name:          "Successful Case - Current context",
+			config:        cfg,
+			expectedAPI:   "https://staging.helios.example.com",
+			expectedToken: "staging-token",
+		},
+		{
+			name:          "Successful Case - Named context",
+			config:        cfg,
+			args:          []string{"--context", "production"},
+			expectedAPI:   "https://helios.example.com",
+			expectedToken: "production-token",
+		},
+		{
+			name:          "Successful Case - API of the context",
+			config:        cfg,
+			args:          []string{"--api", "https://staging.helios.example.com/"},
+			expectedAPI:   "https://staging.helios.example.com",
+			expectedToken: "staging-token",
+		},
+		{
+			name:        "Successful Case - Other API gets no token",
+			config:      cfg,
+			args:        []string{"--api", "https://other.example.com"},
+			expectedAPI: "https://other.example.com",
+		},
+		{
+			name:          "Successful Case - HELIOS_TOKEN overrides the token",
+			config:        cfg,
+			envToken:      "ci-token",
+			expectedAPI:   "https://staging.helios.example.com",
+			expectedToken: "ci-token",
+		},
+		{
+			name:        "Successful Case - No contexts",
+			config:      &cliConfig{},
+			expectedAPI: defaultAPIURL,
+		},
+		{
+			name:        "Failure Case - Unknown context",
+			config:      cfg,
+			args:        []string{"--context", "qa"},
+			expectedErr: `unknown context "qa"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writeTestConfig(t, tc.config)
+			t.Setenv("HELIOS_TOKEN", tc.envToken)
+			apiToken = ""
+			t.Cleanup(func() { apiToken = "" })
+			fs, apiURL := newFlagSet("test", "test")
+			fs.Init("test", flag.ContinueOnError)
+			require.NoError(t, fs.Parse(tc.args))
+
+			err := useContext(fs)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedAPI, *apiURL)
+			assert.Equal(t, tc.expectedToken, apiToken)
+		})
+	}
+}
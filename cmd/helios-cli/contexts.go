@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// contextSummary is how commands print a context. The token is never
+// printed.
+type contextSummary struct {
+	Name    string `json:"name"`
+	API     string `json:"api"`
+	Email   string `json:"email"`
+	Current bool   `json:"current"`
+}
+
+// runContextList implements `helios-cli context list`.
+func runContextList(args []string) {
+	fs := newLocalFlagSet("context list", "context list [flags]\n\nLists the contexts saved by 'helios-cli login'. The current one is marked with *.")
+	parseFlags(fs, args, 0)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	contexts := make([]contextSummary, 0, len(names))
+	for _, name := range names {
+		ctx := cfg.Contexts[name]
+		contexts = append(contexts, contextSummary{Name: name, API: ctx.API, Email: ctx.Email, Current: name == cfg.CurrentContext})
+	}
+
+	printResult(contexts, names, func() {
+		if len(contexts) == 0 {
+			fmt.Println("No contexts. Log in with 'helios-cli login' to create one.")
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CURRENT\tNAME\tAPI\tUSER")
+		for _, c := range contexts {
+			current := ""
+			if c.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, c.Name, c.API, c.Email)
+		}
+		tw.Flush()
+	})
+}
+
+// runContextUse implements `helios-cli context use <name>`.
+func runContextUse(args []string) {
+	fs := newLocalFlagSet("context use", "context use [flags] <name>\n\nMakes a context the current one, so that commands talk to its API server.")
+	parseFlags(fs, args, 1)
+	name := fs.Arg(0)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	ctx, ok := cfg.Contexts[name]
+	if !ok {
+		log.Fatalf("FATAL: Unknown context %q; list the contexts with 'helios-cli context list'", name)
+	}
+	cfg.CurrentContext = name
+	if err := cfg.save(); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	summary := contextSummary{Name: name, API: ctx.API, Email: ctx.Email, Current: true}
+	printResult(summary, []string{name}, func() {
+		fmt.Printf("Switched to context %q (%s).\n", name, ctx.API)
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// deadLetter mirrors the dead letter resource returned by the API.
+type deadLetter struct {
+	Sequence   uint64    `json:"sequence"`
+	Subject    string    `json:"subject"`
+	Consumer   string    `json:"consumer"`
+	Reason     string    `json:"reason"`
+	Deliveries uint64    `json:"deliveries"`
+	FailedAt   time.Time `json:"failed_at"`
+	Data       []byte    `json:"data"`
+}
+
+// runDeadLettersList implements `helios-cli dead-letters list`.
+func runDeadLettersList(args []string) {
+	fs, apiURL := newFlagSet("dead-letters list", "dead-letters list [flags]\n\nDead letters are only available to platform admins.")
+	var subject, cursor string
+	var limit int
+	fs.StringVar(&subject, "subject", "", "Only list events from this subject, e.g. v1.deployment.requested.")
+	fs.IntVar(&limit, "limit", 20, "The maximum number of dead letters to list.")
+	fs.StringVar(&cursor, "cursor", "", "The cursor printed by a previous list, to show the next page.")
+	parseArgs(fs, args, 0)
+
+	query := listQuery("", cursor, limit)
+	if subject != "" {
+		query += "&subject=" + url.QueryEscape(subject)
+	}
+	var letters page[deadLetter]
+	if err := callAPI(http.MethodGet, *apiURL+"/dead-letters?"+query, nil, &letters); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	seqs := make([]string, len(letters.Items))
+	for i, d := range letters.Items {
+		seqs[i] = strconv.FormatUint(d.Sequence, 10)
+	}
+	printResult(letters, seqs, func() {
+		if len(letters.Items) == 0 {
+			fmt.Println("No dead letters.")
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SEQ\tSUBJECT\tCONSUMER\tDELIVERIES\tFAILED AT\tREASON")
+		for _, d := range letters.Items {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\n", d.Sequence, d.Subject, d.Consumer, d.Deliveries, d.FailedAt.Format(time.RFC3339), d.Reason)
+		}
+		tw.Flush()
+		printNextPage("dead letters", letters.NextCursor)
+	})
+}
+
+// runDeadLettersShow implements `helios-cli dead-letters show <seq>`. It
+// prints the payload indented if it is JSON.
+func runDeadLettersShow(args []string) {
+	fs, apiURL := newFlagSet("dead-letters show", "dead-letters show [flags] <seq>")
+	seq := parseSeq(fs, args)
+
+	var d deadLetter
+	if err := callAPI(http.MethodGet, *apiURL+"/dead-letters/"+seq, nil, &d); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	printResult(d, []string{seq}, func() {
+		fmt.Printf("Sequence:   %d\n", d.Sequence)
+		fmt.Printf("Subject:    %s\n", d.Subject)
+		fmt.Printf("Consumer:   %s\n", d.Consumer)
+		fmt.Printf("Deliveries: %d\n", d.Deliveries)
+		fmt.Printf("Failed at:  %s\n", d.FailedAt.Format(time.RFC3339))
+		fmt.Printf("Reason:     %s\n", d.Reason)
+		fmt.Println("Payload:")
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, d.Data, "  ", "  "); err != nil {
+			fmt.Printf("  %s\n", string(d.Data))
+		} else {
+			fmt.Printf("  %s\n", pretty.String())
+		}
+	})
+}
+
+// runDeadLettersReplay implements `helios-cli dead-letters replay <seq>`.
+func runDeadLettersReplay(args []string) {
+	fs, apiURL := newFlagSet("dead-letters replay", "dead-letters replay [flags] <seq>\n\nPublishes a dead-lettered event to its original subject again.")
+	seq := parseSeq(fs, args)
+
+	var d deadLetter
+	if err := callAPI(http.MethodPost, *apiURL+"/dead-letters/"+seq+"/replay", nil, &d); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	printResult(d, []string{seq}, func() {
+		fmt.Printf("Dead letter %d replayed to %s.\n", d.Sequence, d.Subject)
+	})
+}
+
+// parseSeq parses the arguments of a command that takes a dead letter's
+// sequence number, and returns it.
+func parseSeq(fs *flag.FlagSet, args []string) string {
+	seq := parseArgs(fs, args, 1)[0]
+	if _, err := strconv.ParseUint(seq, 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid sequence number %q\n", seq)
+		os.Exit(exitUsage)
+	}
+	return seq
+}
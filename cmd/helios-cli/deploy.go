@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runDeploy implements `helios-cli deploy <application-id>`.
+func runDeploy(args []string) {
+	fs, apiURL := newFlagSet("deploy", "deploy [flags] <application-id>\n\nBuilds and deploys the head of the application's branch as a new deployment.\nWith --wait, exits with status 1 if the deployment fails, is cancelled or\ndoes not finish within --timeout.")
+	var wait bool
+	var timeout, interval time.Duration
+	fs.BoolVar(&wait, "wait", false, "Wait until the deployment succeeds, fails or is cancelled, showing its progress.")
+	fs.DurationVar(&timeout, "timeout", 30*time.Minute, "How long to wait when --wait is set, or 0 to wait as long as it takes. The deployment carries on after it expires.")
+	fs.DurationVar(&interval, "interval", 2*time.Second, "How often to poll when --wait is set.")
+	id := parseArgs(fs, args, 1)[0]
+
+	var d deployment
+	if err := callAPI(http.MethodPost, *apiURL+"/applications/"+id+"/deployments", nil, &d); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if !wait {
+		printResult(d, []string{d.ID}, func() {
+			fmt.Printf("Deployment %s of application %s is %s.\n", d.ID, d.ApplicationID, d.Status)
+			printFollowHint(*apiURL, d.ID)
+		})
+		return
+	}
+	waitForDeployment(*apiURL, d, interval, timeout)
+}
+
+// waitForDeployment polls the deployment d until it finishes, showing its
+// progress, and prints it. It exits with exitFailure if the deployment does
+// not succeed, or is still running when timeout, unless zero, expires.
+// Polls that fail with a transient error are retried until then, since the
+// deployment carries on meanwhile.
+func waitForDeployment(apiURL string, d deployment, interval, timeout time.Duration) {
+	started := time.Now()
+	deadline := started.Add(timeout)
+	if timeout <= 0 {
+		deadline = time.Time{}
+	}
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	var p *progress
+	if tableOutput() {
+		p = newProgress(d)
+	}
+
+	for !d.finished() {
+		wait := interval
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				log.Fatalf("FATAL: Deployment %s is still %s after %s. It carries on; follow it with 'helios-cli status --watch %s'.", d.ID, d.Status, timeout, d.ID)
+			}
+			wait = min(wait, time.Until(deadline))
+		}
+		time.Sleep(wait)
+		var polled deployment
+		if err := pollDeployment(ctx, apiURL, d.ID, &polled); err != nil {
+			switch {
+			case ctx.Err() != nil:
+				// The timeout expired, which is reported above.
+			case !transient(err):
+				log.Fatalf("FATAL: %v", err)
+			default:
+				log.Printf("Failed to poll deployment %s, retrying: %v", d.ID, err)
+			}
+			continue
+		}
+		d = polled
+		if p != nil {
+			p.update(d)
+		}
+	}
+
+	took := time.Since(started).Round(time.Second)
+	printResult(d, []string{d.ID}, func() {
+		if d.Status == "succeeded" {
+			fmt.Printf("\nDeployment %s succeeded in %s.\n", d.ID, took)
+			return
+		}
+		fmt.Printf("\nDeployment %s %s after %s.\n", d.ID, d.Status, took)
+		if d.FailureReason != "" {
+			fmt.Printf("Reason: %s\n", d.FailureReason)
+		}
+	})
+	if d.Status != "succeeded" {
+		if !tableOutput() {
+			log.Printf("Deployment %s %s: %s", d.ID, d.Status, d.FailureReason)
+		}
+		os.Exit(exitFailure)
+	}
+}
+
+// pollDeployment gets the deployment id into d, within requestTimeout and
+// before ctx expires.
+func pollDeployment(ctx context.Context, apiURL, id string, d *deployment) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	return callAPIContext(ctx, http.MethodGet, apiURL+"/deployments/"+id, nil, d)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subprocessEnv is set in the test binary that runSubprocess runs.
+const subprocessEnv = "HELIOS_CLI_TEST_SUBPROCESS"
+
+// runSubprocess runs fn, which may exit, in a new process of the test
+// binary running only the current test, and returns that process's exit
+// code, standard output and standard error, and true. In that process, it
+// runs fn and returns false, and the caller returns without checking
+// anything.
+func runSubprocess(t *testing.T, fn func()) (int, string, string, bool) {
+	t.Helper()
+	if os.Getenv(subprocessEnv) != "" {
+		fn()
+		return 0, "", "", false
+	}
+
+	parts := strings.Split(t.Name(), "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	cmd := exec.Command(os.Args[0], "-test.run="+strings.Join(parts, "/"))
+	cmd.Env = append(os.Environ(), subprocessEnv+"=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), stdout.String(), stderr.String(), true
+	}
+	require.NoError(t, err)
+	return 0, stdout.String(), stderr.String(), true
+}
+
+// pollResponse is how the test API server answers a poll: with the
+// deployment in status, with an error status code, or by dropping the
+// connection.
+type pollResponse struct {
+	status string
+	code   int
+	drop   bool
+}
+
+// newPollServer returns an API server that answers the polls of the
+// deployment dep-1 with responses in turn, repeating the last one.
+func newPollServer(t *testing.T, responses []pollResponse) *httptest.Server {
+	var mu sync.Mutex
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/deployments/dep-1" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		resp := responses[min(polls, len(responses)-1)]
+		polls++
+		mu.Unlock()
+
+		switch {
+		case resp.drop:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		case resp.code != 0:
+			http.Error(w, http.StatusText(resp.code), resp.code)
+		default:
+			d := deployment{ID: "dep-1", ApplicationID: "app-1", Status: resp.status}
+			if resp.status == "failed" {
+				d.FailureReason = "build failed: exit status 1"
+			}
+			json.NewEncoder(w).Encode(d)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWaitForDeployment(t *testing.T) {
+	testCases := []struct {
+		name           string
+		responses      []pollResponse
+		timeout        time.Duration
+		expectedExit   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name:           "Successful Case - Succeeds",
+			responses:      []pollResponse{{status: "building"}, {status: "deploying"}, {status: "succeeded"}},
+			expectedStdout: "Deployment dep-1 succeeded",
+		},
+		{
+			name:           "Successful Case - Retries server errors",
+			responses:      []pollResponse{{code: http.StatusServiceUnavailable}, {code: http.StatusTooManyRequests}, {code: http.StatusBadGateway}, {status: "succeeded"}},
+			expectedStdout: "Deployment dep-1 succeeded",
+			expectedStderr: "Failed to poll deployment dep-1, retrying",
+		},
+		{
+			name:           "Successful Case - Retries dropped connections",
+			responses:      []pollResponse{{drop: true}, {status: "succeeded"}},
+			expectedStdout: "Deployment dep-1 succeeded",
+			expectedStderr: "Failed to poll deployment dep-1, retrying",
+		},
+		{
+			name:           "Failure Case - Deployment fails",
+			responses:      []pollResponse{{status: "building"}, {status: "failed"}},
+			expectedExit:   exitFailure,
+			expectedStdout: "Reason: build failed: exit status 1",
+		},
+		{
+			name:           "Failure Case - Client error is not retried",
+			responses:      []pollResponse{{code: http.StatusNotFound}, {status: "succeeded"}},
+			expectedExit:   exitFailure,
+			expectedStderr: "FATAL: API server returned an error",
+		},
+		{
+			name:           "Failure Case - Timeout",
+			responses:      []pollResponse{{status: "building"}},
+			timeout:        50 * time.Millisecond,
+			expectedExit:   exitFailure,
+			expectedStderr: "Deployment dep-1 is still building",
+		},
+		{
+			name:           "Failure Case - Server errors until the timeout",
+			responses:      []pollResponse{{code: http.StatusInternalServerError}},
+			timeout:        50 * time.Millisecond,
+			expectedExit:   exitFailure,
+			expectedStderr: "Deployment dep-1 is still pending",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newPollServer(t, tc.responses)
+			d := deployment{ID: "dep-1", ApplicationID: "app-1", Status: "pending"}
+
+			code, stdout, stderr, parent := runSubprocess(t, func() {
+				waitForDeployment(server.URL, d, time.Millisecond, tc.timeout)
+			})
+			if !parent {
+				return
+			}
+
+			assert.Equal(t, tc.expectedExit, code, "stdout:\n%s\nstderr:\n%s", stdout, stderr)
+			assert.Contains(t, stdout, tc.expectedStdout)
+			assert.Contains(t, stderr, tc.expectedStderr)
+		})
+	}
+}
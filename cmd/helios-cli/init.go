@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"helios/pkg/heliosfile"
+)
+
+// scaffold is the result of `helios-cli init`.
+type scaffold struct {
+	File      string   `json:"file"`
+	Detected  []string `json:"detected"`
+	Services  []string `json:"services"`
+	Databases []string `json:"databases"`
+}
+
+// databaseClients lists, for each database type, the client libraries whose
+// use in a dependency file makes init declare a database of that type.
+var databaseClients = map[string][]string{
+	"postgresql": {"github.com/jackc/pgx", "github.com/lib/pq", "pg", "postgres", "psycopg", "psycopg2", "psycopg2-binary", "asyncpg"},
+	"mysql":      {"github.com/go-sql-driver/mysql", "mysql", "mysql2", "mysqlclient", "pymysql"},
+	"redis":      {"github.com/redis/go-redis", "github.com/go-redis/redis", "redis", "ioredis"},
+}
+
+// databaseEnv is the database init declares for each type, and the variable
+// its URL is provided to the services in.
+var databaseEnv = []struct {
+	dbType, name, variable string
+}{
+	{"postgresql", "db", "DATABASE_URL"},
+	{"mysql", "db", "DATABASE_URL"},
+	{"redis", "redis", "REDIS_URL"},
+}
+
+// runInit implements `helios-cli init`.
+func runInit(args []string) {
+	fs := newLocalFlagSet("init", "init [flags]\n\nWrites a Heliosfile for the repository in the current directory, based on\nits Dockerfile, Procfile and dependency files.")
+	var file, name string
+	var force bool
+	fs.StringVar(&file, "file", heliosfile.FileName, "The Heliosfile to write.")
+	fs.StringVar(&name, "name", "", "The name of the application. Defaults to the name of the directory.")
+	fs.BoolVar(&force, "force", false, "Overwrite the Heliosfile if it exists.")
+	parseFlags(fs, args, 0)
+
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if name == "" {
+		if name = dnsLabel(filepath.Base(dir)); name == "" {
+			name = "app"
+		}
+	}
+	if _, err := os.Stat(file); err == nil && !force {
+		log.Fatalf("FATAL: %s already exists; overwrite it with --force", file)
+	}
+
+	f, detected, err := detectHeliosfile(dir, name)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# Written by helios-cli init. Check it with 'helios-cli validate'.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err = enc.Encode(f)
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		log.Fatalf("FATAL: Failed to encode Heliosfile: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Procfile commands are copied as they are, so they may not split into
+	// words; nothing is written that would not deploy.
+	if result := validate(file, data); !result.Valid {
+		log.Fatalf("FATAL: The detected Heliosfile is invalid: %s", result.Errors[0].String(file))
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		log.Fatalf("FATAL: Failed to write Heliosfile: %v", err)
+	}
+
+	result := scaffold{File: file, Detected: detected, Services: f.ServiceNames(), Databases: append([]string{}, f.DatabaseNames()...)}
+	printResult(result, []string{file}, func() {
+		if len(detected) > 0 {
+			fmt.Printf("Detected %s.\n", strings.Join(detected, ", "))
+		}
+		fmt.Printf("Wrote %s with services %s", file, strings.Join(result.Services, ", "))
+		if len(result.Databases) > 0 {
+			fmt.Printf(" and databases %s", strings.Join(result.Databases, ", "))
+		}
+		fmt.Println(".")
+		fmt.Println("\nReview it, then check it with:\n  helios-cli validate")
+	})
+}
+
+// detectHeliosfile returns the manifest for the repository in dir, and the
+// files it was based on:
+//
+//   - A Dockerfile is built instead of using Buildpacks, and the first port
+//     it exposes is the port of the web service.
+//   - Each process of a Procfile is a service; only web serves HTTP.
+//     Otherwise there is a single web service.
+//   - The database clients that go.mod, package.json, requirements.txt,
+//     pyproject.toml and Gemfile depend on each declare a database, whose
+//     URL is provided to every service.
+func detectHeliosfile(dir, name string) (*heliosfile.Heliosfile, []string, error) {
+	f := heliosfile.Default(name)
+	var detected []string
+
+	port := heliosfile.DefaultHTTPPort
+	dockerfile, err := readOptional(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if dockerfile != nil {
+		detected = append(detected, "Dockerfile")
+		f.Build = &heliosfile.Build{Dockerfile: "Dockerfile"}
+		if exposed, ok := exposedPort(dockerfile); ok {
+			port = exposed
+		}
+	}
+
+	procfile, err := readOptional(filepath.Join(dir, "Procfile"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if processes := parseProcfile(procfile); len(processes) > 0 {
+		detected = append(detected, "Procfile")
+		f.Services = map[string]heliosfile.Service{}
+		for process, command := range processes {
+			if process == "web" {
+				f.Services[process] = heliosfile.Service{Command: command, HTTPPort: port}
+			} else {
+				f.Services[process] = heliosfile.Service{Command: command}
+			}
+		}
+	} else {
+		f.Services = map[string]heliosfile.Service{"web": {HTTPPort: port}}
+	}
+
+	dbTypes := map[string]bool{}
+	for _, dependencyFile := range []string{"go.mod", "package.json", "requirements.txt", "pyproject.toml", "Gemfile"} {
+		data, err := readOptional(filepath.Join(dir, dependencyFile))
+		if err != nil {
+			return nil, nil, err
+		}
+		if data == nil {
+			continue
+		}
+		detected = append(detected, dependencyFile)
+		for _, dependency := range dependencies(dependencyFile, data) {
+			for dbType, clients := range databaseClients {
+				if contains(clients, dependency) {
+					dbTypes[dbType] = true
+				}
+			}
+		}
+	}
+
+	for _, db := range databaseEnv {
+		if !dbTypes[db.dbType] || f.Databases[db.name].Type != "" {
+			continue
+		}
+		if _, ok := f.Services[db.name]; ok {
+			continue
+		}
+		if f.Databases == nil {
+			f.Databases = map[string]heliosfile.Database{}
+		}
+		f.Databases[db.name] = heliosfile.Database{Type: db.dbType}
+		for serviceName, s := range f.Services {
+			if s.Env == nil {
+				s.Env = map[string]string{}
+			}
+			s.Env[db.variable] = "${" + db.name + ".URL}"
+			f.Services[serviceName] = s
+		}
+	}
+	return f, detected, nil
+}
+
+// readOptional reads a file, returning nil if it does not exist.
+func readOptional(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	return data, nil
+}
+
+// exposePort matches the first port of an EXPOSE instruction.
+var exposePort = regexp.MustCompile(`(?im)^\s*EXPOSE\s+(\d+)`)
+
+// exposedPort returns the first port a Dockerfile exposes.
+func exposedPort(dockerfile []byte) (int, bool) {
+	m := exposePort.FindSubmatch(dockerfile)
+	if m == nil {
+		return 0, false
+	}
+	port, err := strconv.Atoi(string(m[1]))
+	return port, err == nil && port >= 1 && port <= 65535
+}
+
+// parseProcfile returns the command of each process of a Procfile, by
+// process name made a valid service name.
+func parseProcfile(data []byte) map[string]string {
+	processes := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		process, command, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name := dnsLabel(process); name != "" {
+			processes[name] = strings.TrimSpace(command)
+		}
+	}
+	return processes
+}
+
+// gemName matches a dependency of a Gemfile.
+var gemName = regexp.MustCompile(`(?m)^\s*gem\s+["']([^"']+)["']`)
+
+// dependencies returns the names of the packages a dependency file depends
+// on. It only reads as much of each format as init needs.
+func dependencies(file string, data []byte) []string {
+	var names []string
+	switch file {
+	case "package.json":
+		var pkg struct {
+			Dependencies map[string]string `json:"dependencies"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			for name := range pkg.Dependencies {
+				names = append(names, name)
+			}
+		}
+	case "Gemfile":
+		for _, m := range gemName.FindAllSubmatch(data, -1) {
+			names = append(names, string(m[1]))
+		}
+	default:
+		// go.mod, requirements.txt and pyproject.toml name one dependency
+		// per line, possibly quoted and followed by a version.
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.FieldsFunc(line, func(r rune) bool {
+				return strings.ContainsRune(" \t\"',=<>~![;", r)
+			})
+			if len(fields) > 0 {
+				names = append(names, strings.ToLower(fields[0]))
+			}
+			if len(fields) > 1 && fields[0] == "require" {
+				names = append(names, fields[1])
+			}
+		}
+	}
+
+	// A module path is matched by its repository, so that major versions
+	// such as github.com/jackc/pgx/v5 match too.
+	for i, name := range names {
+		if parts := strings.Split(name, "/"); len(parts) > 3 && strings.Contains(parts[0], ".") {
+			names[i] = strings.Join(parts[:3], "/")
+		}
+	}
+	return names
+}
+
+// invalidLabel matches the runs of characters a DNS label cannot contain.
+var invalidLabel = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dnsLabel turns s into a lowercase DNS label, as application and service
+// names must be, or returns "" if nothing of s is left.
+func dnsLabel(s string) string {
+	label := invalidLabel.ReplaceAllString(strings.ToLower(s), "-")
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return strings.Trim(label, "-")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
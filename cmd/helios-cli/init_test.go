@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helios/pkg/heliosfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectHeliosfile(t *testing.T) {
+	testCases := []struct {
+		name              string
+		files             map[string]string
+		expectedDetected  []string
+		expectedBuild     *heliosfile.Build
+		expectedServices  map[string]heliosfile.Service
+		expectedDatabases map[string]heliosfile.Database
+	}{
+		{
+			name:             "Successful Case - Empty repository",
+			expectedServices: map[string]heliosfile.Service{"web": {HTTPPort: heliosfile.DefaultHTTPPort}},
+		},
+		{
+			name:             "Successful Case - Dockerfile port",
+			files:            map[string]string{"Dockerfile": "FROM golang:1.24\nexpose 3000/tcp\nEXPOSE 4000\n"},
+			expectedDetected: []string{"Dockerfile"},
+			expectedBuild:    &heliosfile.Build{Dockerfile: "Dockerfile"},
+			expectedServices: map[string]heliosfile.Service{"web": {HTTPPort: 3000}},
+		},
+		{
+			name: "Successful Case - Procfile processes",
+			files: map[string]string{
+				"Dockerfile": "FROM node:22\nEXPOSE 3000\n",
+				"Procfile":   "# Processes\nweb: npm start\nqueue_worker: node worker.js\n",
+			},
+			expectedDetected: []string{"Dockerfile", "Procfile"},
+			expectedBuild:    &heliosfile.Build{Dockerfile: "Dockerfile"},
+			expectedServices: map[string]heliosfile.Service{
+				"web":          {Command: "npm start", HTTPPort: 3000},
+				"queue-worker": {Command: "node worker.js"},
+			},
+		},
+		{
+			name: "Successful Case - Database clients",
+			files: map[string]string{
+				"go.mod":       "module example.com/app\n\nrequire (\n\tgithub.com/jackc/pgx/v5 v5.7.1\n)\n",
+				"package.json": `{"dependencies": {"ioredis": "^5.4.0"}}`,
+			},
+			expectedDetected: []string{"go.mod", "package.json"},
+			expectedServices: map[string]heliosfile.Service{
+				"web": {HTTPPort: heliosfile.DefaultHTTPPort, Env: map[string]string{"DATABASE_URL": "${db.URL}", "REDIS_URL": "${redis.URL}"}},
+			},
+			expectedDatabases: map[string]heliosfile.Database{"db": {Type: "postgresql"}, "redis": {Type: "redis"}},
+		},
+		{
+			name: "Successful Case - Database named like a service",
+			files: map[string]string{
+				"Procfile": "web: bin/rails server\nredis: redis-server\n",
+				"Gemfile":  "source 'https://rubygems.org'\ngem 'redis', '~> 5.0'\n",
+			},
+			expectedDetected: []string{"Procfile", "Gemfile"},
+			expectedServices: map[string]heliosfile.Service{
+				"web":   {Command: "bin/rails server", HTTPPort: heliosfile.DefaultHTTPPort},
+				"redis": {Command: "redis-server"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+
+			f, detected, err := detectHeliosfile(dir, "my-app")
+
+			require.NoError(t, err)
+			assert.Equal(t, "my-app", f.Name)
+			assert.Equal(t, tc.expectedDetected, detected)
+			assert.Equal(t, tc.expectedBuild, f.Build)
+			assert.Equal(t, tc.expectedServices, f.Services)
+			assert.Equal(t, tc.expectedDatabases, f.Databases)
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	// Lines that name no dependency are returned too, and never match a
+	// client, so only the dependencies are checked.
+	testCases := []struct {
+		name     string
+		file     string
+		data     string
+		expected []string
+	}{
+		{
+			name:     "Successful Case - go.mod",
+			file:     "go.mod",
+			data:     "module example.com/app\n\nrequire github.com/lib/pq v1.10.9\n\nrequire (\n\tgithub.com/jackc/pgx/v5 v5.7.1\n)\n",
+			expected: []string{"github.com/lib/pq", "github.com/jackc/pgx"},
+		},
+		{
+			name:     "Successful Case - package.json",
+			file:     "package.json",
+			data:     `{"dependencies": {"pg": "^8.13.0"}, "devDependencies": {"jest": "^29.0.0"}}`,
+			expected: []string{"pg"},
+		},
+		{
+			name:     "Successful Case - requirements.txt",
+			file:     "requirements.txt",
+			data:     "Django>=5.1\npsycopg2-binary==2.9.9\n# Caching\nredis[hiredis]~=5.0\n",
+			expected: []string{"django", "psycopg2-binary", "redis"},
+		},
+		{
+			name:     "Successful Case - pyproject.toml",
+			file:     "pyproject.toml",
+			data:     "dependencies = [\n  \"asyncpg>=0.29\",\n]\n",
+			expected: []string{"asyncpg"},
+		},
+		{
+			name:     "Successful Case - Gemfile",
+			file:     "Gemfile",
+			data:     "source \"https://rubygems.org\"\ngem \"rails\", \"~> 7.2\"\n  gem 'mysql2'\n",
+			expected: []string{"rails", "mysql2"},
+		},
+		{
+			name: "Successful Case - Invalid package.json",
+			file: "package.json",
+			data: `{"dependencies": [`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			names := dependencies(tc.file, []byte(tc.data))
+			if tc.expected == nil {
+				assert.Empty(t, names)
+			} else {
+				assert.Subset(t, names, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDNSLabel(t *testing.T) {
+	testCases := []struct {
+		in, expected string
+	}{
+		{in: "my-app", expected: "my-app"},
+		{in: "My_Cool App", expected: "my-cool-app"},
+		{in: "--api.v2--", expected: "api-v2"},
+		{in: "__", expected: ""},
+		{in: "x123456789012345678901234567890123456789012345678901234567890123456789", expected: "x12345678901234567890123456789012345678901234567890123456789012"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.expected, dnsLabel(tc.in))
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultContextName names the context of a first login without --context.
+const defaultContextName = "default"
+
+// runLogin implements `helios-cli login`.
+func runLogin(args []string) {
+	fs, apiURL := newFlagSet("login", "login [flags]\n\nLogs in with your email and password, and saves a new API token for the API server in a context, which becomes the current context. Log in again with --context and --api to add a context for another server; --api alone must name the server of the current context.")
+	var email string
+	var passwordStdin bool
+	fs.StringVar(&email, "email", "", "Your email. Asked for if not set.")
+	fs.BoolVar(&passwordStdin, "password-stdin", false, "Read the password from standard input instead of asking for it.")
+	parseFlags(fs, args, 0)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	name, api, err := loginContext(cfg, fs.Lookup("context").Value.String(), *apiURL)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	if email == "" {
+		fmt.Fprint(os.Stderr, "Email: ")
+		if email, err = readLine(stdin); err != nil {
+			log.Fatalf("FATAL: Failed to read email: %v", err)
+		}
+	}
+	var password string
+	if passwordStdin {
+		password, err = readLine(stdin)
+	} else {
+		password, err = readPassword(stdin)
+	}
+	if err != nil {
+		log.Fatalf("FATAL: Failed to read password: %v", err)
+	}
+
+	// Each login issues a new token, named after the machine, so it can be
+	// told apart from the tokens of other machines.
+	tokenName := "helios-cli"
+	if host, err := os.Hostname(); err == nil {
+		tokenName += " on " + host
+	}
+	request := map[string]string{"email": email, "password": password, "token_name": tokenName}
+	var issued struct {
+		Token string `json:"token"`
+		User  struct {
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	if err := callAPI(http.MethodPost, api+"/auth/login", request, &issued); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	cfg.Contexts[name] = &cliContext{API: api, Email: issued.User.Email, Token: issued.Token}
+	cfg.CurrentContext = name
+	if err := cfg.save(); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	summary := contextSummary{Name: name, API: api, Email: issued.User.Email, Current: true}
+	printResult(summary, []string{name}, func() {
+		fmt.Printf("Logged in to %s as %s. Context %q is now current.\n", api, issued.User.Email, name)
+	})
+}
+
+// loginContext returns the context a login saves its token in, and the API
+// server it logs in to, given the --context and --api flags. Without
+// --context, the current context is logged in to again, so --api may not
+// name another server: that would replace the context's server and token.
+func loginContext(cfg *cliConfig, contextFlag, apiFlag string) (string, string, error) {
+	name := contextFlag
+	if name == "" {
+		name = cfg.CurrentContext
+	}
+	if name == "" {
+		name = defaultContextName
+	}
+
+	ctx, exists := cfg.Contexts[name]
+	if apiFlag == "" {
+		if exists {
+			return name, ctx.API, nil
+		}
+		return name, defaultAPIURL, nil
+	}
+	api := normalizeAPIURL(apiFlag)
+	if exists && contextFlag == "" && api != ctx.API {
+		return "", "", fmt.Errorf("context %q is for %s; log in to %s with --context <name> to add a context for it", name, ctx.API, api)
+	}
+	return name, api, nil
+}
+
+// readLine reads a line from r without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPassword asks for a password on the terminal. The password is not
+// echoed where stty is available.
+func readPassword(r *bufio.Reader) (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	if err := stty("-echo"); err == nil {
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	return readLine(r)
+}
+
+// stty changes a setting of the terminal on standard input.
+func stty(setting string) error {
+	cmd := exec.Command("stty", setting)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
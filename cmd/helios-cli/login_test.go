@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginContext(t *testing.T) {
+	cfg := &cliConfig{
+		CurrentContext: "default",
+		Contexts: map[string]*cliContext{
+			"default": {API: "https://helios.example.com", Token: "token"},
+		},
+	}
+
+	testCases := []struct {
+		name         string
+		config       *cliConfig
+		contextFlag  string
+		apiFlag      string
+		expectedName string
+		expectedAPI  string
+		expectErr    bool
+	}{
+		{name: "Successful Case - First login", config: &cliConfig{Contexts: map[string]*cliContext{}}, expectedName: defaultContextName, expectedAPI: defaultAPIURL},
+		{name: "Successful Case - First login to a server", config: &cliConfig{Contexts: map[string]*cliContext{}}, apiFlag: "https://helios.example.com/", expectedName: defaultContextName, expectedAPI: "https://helios.example.com"},
+		{name: "Successful Case - Current context again", config: cfg, expectedName: "default", expectedAPI: "https://helios.example.com"},
+		{name: "Successful Case - Current context's server", config: cfg, apiFlag: "https://helios.example.com/", expectedName: "default", expectedAPI: "https://helios.example.com"},
+		{name: "Successful Case - New context", config: cfg, contextFlag: "staging", apiFlag: "https://staging.helios.example.com", expectedName: "staging", expectedAPI: "https://staging.helios.example.com"},
+		{name: "Successful Case - Named context moves server", config: cfg, contextFlag: "default", apiFlag: "https://new.helios.example.com", expectedName: "default", expectedAPI: "https://new.helios.example.com"},
+		{name: "Failure Case - Other server without --context", config: cfg, apiFlag: "https://staging.helios.example.com", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, api, err := loginContext(tc.config, tc.contextFlag, tc.apiFlag)
+
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedName, name)
+			assert.Equal(t, tc.expectedAPI, api)
+		})
+	}
+}
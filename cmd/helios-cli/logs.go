@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// logLine mirrors the deployment log line resource returned by the API.
+type logLine struct {
+	ID       int64     `json:"id"`
+	Source   string    `json:"source"`
+	Level    string    `json:"level"`
+	Message  string    `json:"message"`
+	LoggedAt time.Time `json:"logged_at"`
+}
+
+// print writes the line as "<time> [<source>] <message>", or as a value of
+// its own with -o json or yaml. With --quiet only the message is printed.
+func (l logLine) print() {
+	printStreamed(l, []string{l.Message}, func() {
+		fmt.Printf("%s [%s] %s\n", l.LoggedAt.Local().Format("15:04:05"), l.Source, l.Message)
+	})
+}
+
+// runLogs implements `helios-cli logs <deployment-id>`.
+func runLogs(args []string) {
+	fs, apiURL := newFlagSet("logs", "logs [flags] <deployment-id>\n\nWith --follow, exits with status 1 if the deployment failed or was cancelled.")
+	var follow bool
+	fs.BoolVar(&follow, "follow", false, "Stream new lines until the deployment succeeds, fails or is cancelled.")
+	id := parseArgs(fs, args, 1)[0]
+
+	if follow {
+		followLogs(*apiURL, id)
+		return
+	}
+
+	cursor := ""
+	for {
+		var lines page[logLine]
+		if err := callAPI(http.MethodGet, *apiURL+"/deployments/"+id+"/logs?"+listQuery("", cursor, 100), nil, &lines); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		for _, l := range lines.Items {
+			l.print()
+		}
+		if lines.NextCursor == "" {
+			return
+		}
+		cursor = lines.NextCursor
+	}
+}
+
+// followLogs prints the deployment's lines as the API streams them, until the
+// stream ends with the finished deployment. If the connection drops, it
+// reconnects and resumes after the last line printed. Only the table output
+// ends with the deployment's status, so that the other formats print nothing
+// but lines; why a deployment failed then goes to standard error.
+func followLogs(apiURL, id string) {
+	cursor := ""
+	for {
+		d, last, err := streamLogs(apiURL, id, cursor)
+		if last != "" {
+			cursor = last
+		}
+		if d != nil {
+			failed := d.Status == "failed" || d.Status == "cancelled"
+			switch {
+			case tableOutput():
+				fmt.Printf("Deployment %s: %s\n", d.ID, d.Status)
+				if failed && d.FailureReason != "" {
+					fmt.Printf("Reason: %s\n", d.FailureReason)
+				}
+			case failed:
+				log.Printf("Deployment %s %s: %s", d.ID, d.Status, d.FailureReason)
+			}
+			if failed {
+				os.Exit(exitFailure)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("Lost the log stream, reconnecting: %v", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// streamLogs reads one Server-Sent Events stream of the deployment's lines
+// after cursor, printing each line. It returns the deployment if the stream
+// ended normally, and the ID of the last line printed.
+func streamLogs(apiURL, id, cursor string) (*deployment, string, error) {
+	query := url.Values{}
+	query.Set("follow", "true")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	// The stream lasts as long as the deployment, so it is not bounded by
+	// requestTimeout.
+	resp, err := apiRequest(context.Background(), http.MethodGet, apiURL+"/deployments/"+id+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		log.Fatalf("FATAL: API server returned an error:\n%s", string(body))
+	}
+
+	// Events are separated by a blank line; only the fields the API sends
+	// are read.
+	var last, eventID, name, data string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			eventID = value
+		case "event":
+			name = value
+		case "data":
+			data = value
+		case "":
+			switch name {
+			case "log":
+				var l logLine
+				if err := json.Unmarshal([]byte(data), &l); err != nil {
+					return nil, last, fmt.Errorf("failed to decode log line: %w", err)
+				}
+				l.print()
+				last = eventID
+			case "end":
+				var d deployment
+				if err := json.Unmarshal([]byte(data), &d); err != nil {
+					return nil, last, fmt.Errorf("failed to decode deployment: %w", err)
+				}
+				return &d, last, nil
+			}
+			eventID, name, data = "", "", ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, last, err
+	}
+	return nil, last, io.ErrUnexpectedEOF
+}
@@ -1,71 +1,168 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
 )
 
-func main() {
-	// --- Define and parse command-line flags ---
-	var gitRepo, gitBranch, apiURL, appName string
+// Exit codes shared by all commands. Requests the API rejects, and
+// deployments that fail or are cancelled, exit with exitFailure; command
+// lines that cannot be parsed exit with exitUsage, like the flag package.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// defaultAPIURL is the API server used when --api is not set.
+const defaultAPIURL = "http://localhost:8080"
+
+// command is a subcommand of helios-cli. A group, such as apps, has
+// subcommands instead of run.
+type command struct {
+	name        string
+	summary     string
+	run         func(args []string)
+	subcommands []command
+}
 
-	flag.StringVar(&gitRepo, "repo", "", "The git repository URL to deploy (required).")
-	flag.StringVar(&gitBranch, "branch", "main", "The git branch to deploy.")
-	flag.StringVar(&appName, "name", "my-app", "The name of the application.")
-	flag.StringVar(&apiURL, "api", "http://localhost:8080", "The URL of the Helios API server.")
-	flag.Parse()
+// commands lists the subcommands of helios-cli in the order they are shown
+// in the help.
+var commands = []command{
+	{name: "login", summary: "Log in to an API server and save the token in a context", run: runLogin},
+	{name: "context", summary: "Manage the API servers you logged in to", subcommands: []command{
+		{name: "list", summary: "List your contexts", run: runContextList},
+		{name: "use", summary: "Switch to another context", run: runContextUse},
+	}},
+	{name: "projects", summary: "Manage projects", subcommands: []command{
+		{name: "create", summary: "Create a project", run: runProjectsCreate},
+		{name: "list", summary: "List your projects", run: runProjectsList},
+	}},
+	{name: "apps", summary: "Manage applications", subcommands: []command{
+		{name: "create", summary: "Create an application and deploy it", run: runAppsCreate},
+		{name: "list", summary: "List the applications of a project", run: runAppsList},
+		{name: "show", summary: "Show an application and its latest deployments", run: runAppsShow},
+		{name: "delete", summary: "Delete an application", run: runAppsDelete},
+	}},
+	{name: "deploy", summary: "Deploy the head of an application's branch", run: runDeploy},
+	{name: "status", summary: "Show the status of a deployment", run: runStatus},
+	{name: "logs", summary: "Show the build and deploy logs of a deployment", run: runLogs},
+	{name: "init", summary: "Write a Heliosfile for the repository in the current directory", run: runInit},
+	{name: "validate", summary: "Check a Heliosfile without deploying it", run: runValidate},
+	{name: "plan", summary: "Show what deploying a Heliosfile would change", run: runPlan},
+	{name: "rollback", summary: "Roll an application back to an earlier deployment", run: runRollback},
+	{name: "cancel", summary: "Cancel a deployment", run: runCancel},
+	{name: "dead-letters", summary: "Inspect and replay events that could not be processed", subcommands: []command{
+		{name: "list", summary: "List dead-lettered events, oldest first", run: runDeadLettersList},
+		{name: "show", summary: "Show a dead-lettered event and its payload", run: runDeadLettersShow},
+		{name: "replay", summary: "Publish a dead-lettered event to its original subject again", run: runDeadLettersReplay},
+	}},
+}
 
-	if gitRepo == "" {
-		fmt.Println("Error: The --repo flag is required.")
-		flag.Usage()
-		os.Exit(1)
+func main() {
+	// Errors go to standard error as a single "FATAL: ..." line, so that
+	// standard output only carries what a command printed.
+	log.SetFlags(0)
+	dispatch("helios-cli", commands, os.Args[1:])
+}
+
+// dispatch runs the command of cmds named by args[0] with the remaining
+// arguments. name is the command line so far, for the help.
+func dispatch(name string, cmds []command, args []string) {
+	if len(args) == 0 {
+		printUsage(os.Stderr, name, cmds)
+		os.Exit(exitUsage)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout, name, cmds)
+		return
 	}
 
-	// --- Construct the request to the API server ---
-	requestBody, err := json.Marshal(map[string]string{
-		"name":            appName,
-		"git_repository":  gitRepo,
-		"git_branch":      gitBranch,
-	})
-	if err != nil {
-		log.Fatalf("FATAL: Failed to marshal request JSON: %v", err)
+	for _, c := range cmds {
+		if c.name != args[0] {
+			continue
+		}
+		if c.run != nil {
+			c.run(args[1:])
+		} else {
+			dispatch(name+" "+c.name, c.subcommands, args[1:])
+		}
+		return
 	}
 
-	// --- Make the HTTP POST request ---
-	log.Printf("Sending deployment request for repo %s to %s...", gitRepo, apiURL)
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	printUsage(os.Stderr, name, cmds)
+	os.Exit(exitUsage)
+}
 
-	resp, err := http.Post(apiURL+"/applications", "application/json", bytes.NewBuffer(requestBody))
-	if err != nil {
-		log.Fatalf("FATAL: Failed to send request to API server: %v", err)
+// printUsage lists cmds, with the subcommands of groups, as the commands of
+// name.
+func printUsage(w io.Writer, name string, cmds []command) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", name)
+	for _, c := range cmds {
+		if c.run != nil {
+			fmt.Fprintf(w, "  %-20s %s\n", c.name, c.summary)
+			continue
+		}
+		for _, s := range c.subcommands {
+			fmt.Fprintf(w, "  %-20s %s\n", c.name+" "+s.name, s.summary)
+		}
 	}
-	defer resp.Body.Close()
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags and arguments of a command.\n", name)
+	fmt.Fprintln(w, "Every command takes -o table|json|yaml, and --quiet to only print IDs.")
+	fmt.Fprintln(w, "Requests go to the API of the current context and carry its token; HELIOS_TOKEN overrides the token.")
+}
 
-	// --- Process the response ---
-	log.Printf("Received response with status code: %d", resp.StatusCode)
+// newFlagSet returns the flags of the command name, with the --api,
+// --context, --output and --quiet flags every command takes. usage is the
+// command's usage line, optionally followed by a description.
+func newFlagSet(name, usage string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	apiURL := fs.String("api", "", "The URL of the Helios API server. Defaults to the API of the context, or "+defaultAPIURL+".")
+	fs.String("context", "", "The context to use instead of the current one.")
+	addOutputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: helios-cli "+usage)
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+	return fs, apiURL
+}
 
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("FATAL: Failed to read response body: %v", err)
+// newLocalFlagSet returns the flags of the command name, for commands that
+// work on local files only and so take no --api or --context.
+func newLocalFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	addOutputFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: helios-cli "+usage)
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
 	}
+	return fs
+}
 
-	if resp.StatusCode >= 400 {
-		log.Fatalf("FATAL: API server returned an error:\n%s", string(responseBody))
+// parseArgs parses args into fs, selects the context to talk to, and
+// returns the arguments. If there are not exactly n arguments, it prints the
+// usage and exits with exitUsage.
+func parseArgs(fs *flag.FlagSet, args []string, n int) []string {
+	parseFlags(fs, args, n)
+	if err := useContext(fs); err != nil {
+		log.Fatalf("FATAL: %v", err)
 	}
+	return fs.Args()
+}
 
-	// --- Print the successful response ---
-	fmt.Println("\nDeployment request accepted by Helios:")
-	// Pretty print the JSON response
-	var prettyJSON bytes.Buffer
-	if err := json.Indent(&prettyJSON, responseBody, "", "  "); err != nil {
-		// If indent fails, just print the raw response
-		fmt.Println(string(responseBody))
-	} else {
-		fmt.Println(prettyJSON.String())
+// parseFlags parses args into fs. If there are not exactly n arguments, or
+// the flags are invalid, it prints the usage and exits with exitUsage.
+func parseFlags(fs *flag.FlagSet, args []string, n int) {
+	fs.Parse(args)
+	if fs.NArg() != n {
+		fs.Usage()
+		os.Exit(exitUsage)
 	}
-}
\ No newline at end of file
+	checkOutputFlags(fs)
+}
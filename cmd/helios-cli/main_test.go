@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatch(t *testing.T) {
+	var ran string
+	var ranArgs []string
+	record := func(name string) func([]string) {
+		return func(args []string) {
+			ran, ranArgs = name, args
+		}
+	}
+	cmds := []command{
+		{name: "deploy", summary: "Deploy", run: record("deploy")},
+		{name: "apps", summary: "Manage applications", subcommands: []command{
+			{name: "list", summary: "List applications", run: record("apps list")},
+			{name: "show", summary: "Show an application", run: record("apps show")},
+		}},
+	}
+
+	testCases := []struct {
+		name         string
+		args         []string
+		expectedRun  string
+		expectedArgs []string
+		expectedHelp string
+	}{
+		{
+			name:         "Successful Case - Command",
+			args:         []string{"deploy", "--wait", "app-1"},
+			expectedRun:  "deploy",
+			expectedArgs: []string{"--wait", "app-1"},
+		},
+		{
+			name:         "Successful Case - Subcommand",
+			args:         []string{"apps", "show", "app-1"},
+			expectedRun:  "apps show",
+			expectedArgs: []string{"app-1"},
+		},
+		{
+			name:         "Successful Case - Help",
+			args:         []string{"help"},
+			expectedHelp: "  apps list            List applications\n",
+		},
+		{
+			name:         "Successful Case - Help of a group",
+			args:         []string{"apps", "--help"},
+			expectedHelp: "Usage: helios-cli apps <command>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ran, ranArgs = "", nil
+
+			out := captureStdout(t, func() { dispatch("helios-cli", cmds, tc.args) })
+
+			assert.Equal(t, tc.expectedRun, ran)
+			assert.Equal(t, tc.expectedArgs, ranArgs)
+			if tc.expectedHelp != "" {
+				assert.Contains(t, out, tc.expectedHelp)
+			} else {
+				assert.Empty(t, out)
+			}
+		})
+	}
+}
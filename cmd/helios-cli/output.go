@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The formats of --output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormat and quiet are set by the --output and --quiet flags every
+// command takes.
+var (
+	outputFormat = outputTable
+	quiet        bool
+)
+
+// addOutputFlags adds the --output and --quiet flags, and their short
+// forms, to fs.
+func addOutputFlags(fs *flag.FlagSet) {
+	const outputUsage = "The output format: table, json or yaml."
+	const quietUsage = "Only print the IDs of the resources, one per line."
+	fs.StringVar(&outputFormat, "output", outputTable, outputUsage)
+	fs.StringVar(&outputFormat, "o", outputTable, outputUsage)
+	fs.BoolVar(&quiet, "quiet", false, quietUsage)
+	fs.BoolVar(&quiet, "q", false, quietUsage)
+}
+
+// checkOutputFlags exits with exitUsage if --output names an unknown format.
+func checkOutputFlags(fs *flag.FlagSet) {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML:
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Unknown output format %q\n\n", outputFormat)
+	fs.Usage()
+	os.Exit(exitUsage)
+}
+
+// tableOutput reports whether the output is for people rather than scripts,
+// for commands that print progress as they go.
+func tableOutput() bool {
+	return !quiet && outputFormat == outputTable
+}
+
+// printResult prints what a command returned. With --quiet it prints ids,
+// one per line; with -o json or -o yaml, it prints v, whose JSON field names
+// are the same in both. Otherwise table prints it for people, with any hints
+// about what to do next. Scripts therefore only see v or the IDs on standard
+// output; errors go to standard error.
+func printResult(v any, ids []string, table func()) {
+	switch {
+	case quiet:
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+	case outputFormat == outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			log.Fatalf("FATAL: Failed to encode output: %v", err)
+		}
+	case outputFormat == outputYAML:
+		if err := encodeYAML(os.Stdout, v); err != nil {
+			log.Fatalf("FATAL: Failed to encode output: %v", err)
+		}
+	default:
+		table()
+	}
+}
+
+// encodeYAML writes v as a YAML document with the fields and values it has
+// as JSON, in the same order. YAML is a superset of JSON, so v is encoded as
+// JSON, parsed as YAML and encoded again in block style.
+func encodeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle clears the flow style and quoting that node and its children
+// were parsed from JSON with, so that they are encoded as YAML usually is.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// printStreamed prints one of many values a command streams, such as log
+// lines. With -o json every value is a line of its own (JSON Lines); with
+// -o yaml every value is a document of its own.
+func printStreamed(v any, ids []string, table func()) {
+	switch {
+	case quiet || outputFormat == outputTable:
+		printResult(v, ids, table)
+	case outputFormat == outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			log.Fatalf("FATAL: Failed to encode output: %v", err)
+		}
+	default:
+		fmt.Println("---")
+		printResult(v, ids, table)
+	}
+}
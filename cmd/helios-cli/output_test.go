@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout returns what fn prints to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestPrintResult(t *testing.T) {
+	result := page[deployment]{
+		Items: []deployment{
+			{ID: "dep-1", ApplicationID: "app-1", Status: "failed", FailureReason: "build failed: exit status 1\n"},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		format   string
+		quiet    bool
+		expected string
+	}{
+		{
+			name:     "Successful Case - Table",
+			format:   outputTable,
+			expected: "table\n",
+		},
+		{
+			name:     "Successful Case - Quiet",
+			format:   outputJSON,
+			quiet:    true,
+			expected: "dep-1\n",
+		},
+		{
+			name:   "Successful Case - JSON",
+			format: outputJSON,
+			expected: `{
+  "items": [
+    {
+      "id": "dep-1",
+      "application_id": "app-1",
+      "git_commit_sha": "",
+      "image_uri": "",
+      "status": "failed",
+      "failure_reason": "build failed: exit status 1\n",
+      "rollback_of": "",
+      "created_at": "0001-01-01T00:00:00Z"
+    }
+  ],
+  "next_cursor": ""
+}
+`,
+		},
+		{
+			name:   "Successful Case - YAML keeps the JSON field names and order",
+			format: outputYAML,
+			expected: `items:
+  - id: dep-1
+    application_id: app-1
+    git_commit_sha: ""
+    image_uri: ""
+    status: failed
+    failure_reason: |
+      build failed: exit status 1
+    rollback_of: ""
+    created_at: "0001-01-01T00:00:00Z"
+next_cursor: ""
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outputFormat, quiet = tc.format, tc.quiet
+			t.Cleanup(func() { outputFormat, quiet = outputTable, false })
+
+			out := captureStdout(t, func() {
+				printResult(result, []string{"dep-1"}, func() { fmt.Println("table") })
+			})
+
+			assert.Equal(t, tc.expected, out)
+		})
+	}
+}
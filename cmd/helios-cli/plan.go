@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// plan mirrors the plan returned by the API.
+type plan struct {
+	ApplicationID    string   `json:"application_id"`
+	BaseDeploymentID string   `json:"base_deployment_id"`
+	Changes          []change `json:"changes"`
+}
+
+// change is what a deployment does to one service or database.
+type change struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Fields []struct {
+		Field string `json:"field"`
+		Old   string `json:"old"`
+		New   string `json:"new"`
+	} `json:"fields"`
+}
+
+// actionSymbols prefixes each change in the printed plan.
+var actionSymbols = map[string]string{"add": "+", "update": "~", "remove": "-"}
+
+// runPlan implements `helios-cli plan <application-id>`.
+func runPlan(args []string) {
+	fs, apiURL := newFlagSet("plan", "plan [flags] <application-id>\n\nShows what deploying a Heliosfile would change compared with the last successful deployment.")
+	var file, image string
+	fs.StringVar(&file, "file", "Heliosfile.yml", "The Heliosfile to plan. If the default file does not exist, the Heliosfile of the last successful deployment is planned.")
+	fs.StringVar(&image, "image", "", "The image to plan. If empty, images are not compared.")
+	id := parseArgs(fs, args, 1)[0]
+
+	request := map[string]any{"image_uri": image}
+	manifest, err := readManifest(fs, file)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if manifest != nil {
+		request["manifest"] = *manifest
+	}
+
+	var p plan
+	if err := callAPI(http.MethodPost, *apiURL+"/applications/"+id+"/plan", request, &p); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	printResult(p, nil, func() { printPlan(&p) })
+}
+
+// readManifest reads the Heliosfile to plan. It returns nil if --file was not
+// set and the default file does not exist.
+func readManifest(fs *flag.FlagSet, file string) (*string, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && !flagSet(fs, "file") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Heliosfile: %w", err)
+	}
+	manifest := string(data)
+	return &manifest, nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// printPlan prints each changed service and database with its changed
+// fields, followed by a summary.
+func printPlan(p *plan) {
+	if p.BaseDeploymentID != "" {
+		fmt.Printf("Plan for application %s, compared with deployment %s:\n\n", p.ApplicationID, p.BaseDeploymentID)
+	} else {
+		fmt.Printf("Plan for application %s, which was never deployed successfully:\n\n", p.ApplicationID)
+	}
+
+	counts := map[string]int{}
+	for _, c := range p.Changes {
+		counts[c.Action]++
+		symbol, ok := actionSymbols[c.Action]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%s %s %s\n", symbol, c.Kind, c.Name)
+		for _, f := range c.Fields {
+			switch {
+			case f.Old == "":
+				fmt.Printf("    + %s: %s\n", f.Field, f.New)
+			case f.New == "":
+				fmt.Printf("    - %s: %s\n", f.Field, f.Old)
+			default:
+				fmt.Printf("    ~ %s: %s -> %s\n", f.Field, f.Old, f.New)
+			}
+		}
+	}
+
+	if counts["add"]+counts["update"]+counts["remove"] == 0 {
+		fmt.Println("No changes.")
+		return
+	}
+	fmt.Printf("\nPlan: %d to add, %d to change, %d to remove, %d unchanged.\n", counts["add"], counts["update"], counts["remove"], counts["none"])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// phases are the statuses a deployment goes through before it finishes, in
+// order, with how the progress display names them. Rollbacks skip the build.
+var phases = []struct {
+	status, name string
+}{
+	{"pending", "Queued"},
+	{"building", "Building"},
+	{"deploying", "Deploying"},
+}
+
+// progress displays the phases of a deployment as it is polled. On a
+// terminal the phases are redrawn in place with how long each took; other
+// output, such as a CI job's log, gets a line per phase instead.
+type progress struct {
+	live    bool
+	started map[string]time.Time
+	last    deployment
+	// drawn is the number of lines drawn on the terminal, to redraw over.
+	drawn int
+}
+
+// newProgress starts the display of the deployment d.
+func newProgress(d deployment) *progress {
+	p := &progress{live: isTerminal(os.Stdout), started: map[string]time.Time{}}
+	fmt.Printf("Deployment %s of application %s\n", d.ID, d.ApplicationID)
+	p.started["pending"] = time.Now()
+	p.update(d)
+	return p
+}
+
+// update shows the deployment as it was polled.
+func (p *progress) update(d deployment) {
+	changed := d.Status != p.last.Status
+	if _, ok := p.started[d.Status]; !ok {
+		p.started[d.Status] = time.Now()
+	}
+	p.last = d
+
+	switch {
+	case p.live:
+		p.draw()
+	case changed:
+		elapsed := time.Since(p.started["pending"]).Round(time.Second)
+		fmt.Printf("[%6s] %s\n", elapsed, statusName(d.Status))
+	}
+}
+
+// draw redraws the phases over what was drawn before.
+func (p *progress) draw() {
+	if p.drawn > 0 {
+		fmt.Printf("\033[%dA\033[J", p.drawn)
+	}
+	// The columns are as wide as the widest name and state, so they do not
+	// move between redraws.
+	tw := tabwriter.NewWriter(os.Stdout, len("cancelled"), 4, 2, ' ', 0)
+	for i, ph := range phases {
+		state, took := p.phase(i)
+		if took > 0 {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", ph.name, state, took.Round(time.Second))
+		} else {
+			fmt.Fprintf(tw, "  %s\t%s\t\n", ph.name, state)
+		}
+	}
+	tw.Flush()
+	p.drawn = len(phases)
+}
+
+// phase returns the state of the i-th phase, and how long it took or has
+// been running for.
+func (p *progress) phase(i int) (string, time.Duration) {
+	start, seen := p.started[phases[i].status]
+
+	// A phase ends when a later one starts, or the deployment finishes.
+	var end time.Time
+	laterSeen := false
+	for _, later := range phases[i+1:] {
+		if t, ok := p.started[later.status]; ok {
+			end, laterSeen = t, true
+			break
+		}
+	}
+	if !laterSeen && p.last.finished() {
+		end = p.started[p.last.Status]
+	}
+
+	switch {
+	case !seen && (laterSeen || p.last.Status == "succeeded"):
+		return "skipped", 0
+	case !seen:
+		return "-", 0
+	case end.IsZero():
+		return "running", time.Since(start)
+	case !laterSeen && p.last.Status != "succeeded":
+		// The deployment failed or was cancelled in this phase.
+		return p.last.Status, end.Sub(start)
+	default:
+		return "done", end.Sub(start)
+	}
+}
+
+// statusName is how the progress display names a status. A deployment
+// without a status, which a misbehaving server may return, is Unknown.
+func statusName(status string) string {
+	for _, ph := range phases {
+		if ph.status == status {
+			return ph.name
+		}
+	}
+	if status == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(status[:1]) + status[1:]
+}
+
+// isTerminal reports whether f is a terminal that can be drawn on.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressPhase(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+
+	type state struct {
+		state string
+		took  time.Duration
+	}
+	testCases := []struct {
+		name     string
+		started  map[string]time.Time
+		status   string
+		expected []state
+	}{
+		{
+			name:     "Successful Case - Queued",
+			started:  map[string]time.Time{"pending": at(0)},
+			status:   "pending",
+			expected: []state{{"running", -1}, {"-", 0}, {"-", 0}},
+		},
+		{
+			name:     "Successful Case - Building",
+			started:  map[string]time.Time{"pending": at(0), "building": at(1)},
+			status:   "building",
+			expected: []state{{"done", time.Minute}, {"running", -1}, {"-", 0}},
+		},
+		{
+			name:     "Successful Case - Succeeded",
+			started:  map[string]time.Time{"pending": at(0), "building": at(1), "deploying": at(4), "succeeded": at(5)},
+			status:   "succeeded",
+			expected: []state{{"done", time.Minute}, {"done", 3 * time.Minute}, {"done", time.Minute}},
+		},
+		{
+			name:     "Successful Case - Build missed between polls",
+			started:  map[string]time.Time{"pending": at(0), "deploying": at(4)},
+			status:   "deploying",
+			expected: []state{{"done", 4 * time.Minute}, {"skipped", 0}, {"running", -1}},
+		},
+		{
+			name:     "Successful Case - Rollback skips the build",
+			started:  map[string]time.Time{"pending": at(0), "succeeded": at(2)},
+			status:   "succeeded",
+			expected: []state{{"done", 2 * time.Minute}, {"skipped", 0}, {"skipped", 0}},
+		},
+		{
+			name:     "Failure Case - Build failed",
+			started:  map[string]time.Time{"pending": at(0), "building": at(1), "failed": at(3)},
+			status:   "failed",
+			expected: []state{{"done", time.Minute}, {"failed", 2 * time.Minute}, {"-", 0}},
+		},
+		{
+			name:     "Failure Case - Cancelled while queued",
+			started:  map[string]time.Time{"pending": at(0), "cancelled": at(1)},
+			status:   "cancelled",
+			expected: []state{{"cancelled", time.Minute}, {"-", 0}, {"-", 0}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &progress{started: tc.started, last: deployment{Status: tc.status}}
+
+			for i, expected := range tc.expected {
+				state, took := p.phase(i)
+				assert.Equal(t, expected.state, state, "state of phase %s", phases[i].name)
+				// Running phases took until now; -1 only checks that they
+				// took some time.
+				if expected.took < 0 {
+					assert.Positive(t, took, "duration of phase %s", phases[i].name)
+				} else {
+					assert.Equal(t, expected.took, took, "duration of phase %s", phases[i].name)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   string
+		expected string
+	}{
+		{name: "Successful Case - Phase", status: "building", expected: "Building"},
+		{name: "Successful Case - Other status", status: "cancelled", expected: "Cancelled"},
+		{name: "Successful Case - Empty status", status: "", expected: "Unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, statusName(tc.status))
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// project mirrors the project resource returned by the API.
+type project struct {
+	ID        string    `json:"id"`
+	TeamID    string    `json:"team_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// team mirrors the team resource returned by the API.
+type team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// runProjectsCreate implements `helios-cli projects create <name>`.
+func runProjectsCreate(args []string) {
+	fs, apiURL := newFlagSet("projects create", "projects create [flags] <name>")
+	var teamID string
+	fs.StringVar(&teamID, "team", "", "The ID of the team that owns the project. Defaults to your team if you belong to only one.")
+	name := parseArgs(fs, args, 1)[0]
+
+	if teamID == "" {
+		var err error
+		if teamID, err = onlyTeam(*apiURL); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+	}
+
+	var p project
+	if err := callAPI(http.MethodPost, *apiURL+"/projects", map[string]string{"name": name, "team_id": teamID}, &p); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	printResult(p, []string{p.ID}, func() {
+		fmt.Printf("Created project %s (%s).\n", p.Name, p.ID)
+	})
+}
+
+// onlyTeam returns the ID of the caller's team, or an error if the caller
+// belongs to more than one.
+func onlyTeam(apiURL string) (string, error) {
+	var teams struct {
+		Items []team `json:"items"`
+	}
+	if err := callAPI(http.MethodGet, apiURL+"/teams", nil, &teams); err != nil {
+		return "", err
+	}
+	switch len(teams.Items) {
+	case 0:
+		return "", fmt.Errorf("you do not belong to any team")
+	case 1:
+		return teams.Items[0].ID, nil
+	}
+	return "", fmt.Errorf("you belong to %d teams; choose one with --team", len(teams.Items))
+}
+
+// runProjectsList implements `helios-cli projects list`.
+func runProjectsList(args []string) {
+	fs, apiURL := newFlagSet("projects list", "projects list [flags]")
+	var name, cursor string
+	var limit int
+	fs.StringVar(&name, "name", "", "Only list projects whose name contains this value.")
+	fs.IntVar(&limit, "limit", 20, "The maximum number of projects to list.")
+	fs.StringVar(&cursor, "cursor", "", "The cursor printed by a previous list, to show the next page.")
+	parseArgs(fs, args, 0)
+
+	var projects page[project]
+	if err := callAPI(http.MethodGet, *apiURL+"/projects?"+listQuery(name, cursor, limit), nil, &projects); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	ids := make([]string, len(projects.Items))
+	for i, p := range projects.Items {
+		ids[i] = p.ID
+	}
+	printResult(projects, ids, func() {
+		if len(projects.Items) == 0 {
+			fmt.Println("No projects.")
+			return
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tTEAM\tCREATED AT")
+		for _, p := range projects.Items {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.ID, p.Name, p.TeamID, p.CreatedAt.Format(time.RFC3339))
+		}
+		tw.Flush()
+		printNextPage("projects", projects.NextCursor)
+	})
+}
+
+// listQuery encodes the query parameters of a list request.
+func listQuery(name, cursor string, limit int) string {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(limit))
+	if name != "" {
+		q.Set("name", name)
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	return q.Encode()
+}
+
+// printNextPage tells the user how to list the next page of a list, if
+// there is one.
+func printNextPage(what, cursor string) {
+	if cursor != "" {
+		fmt.Printf("\nMore %s are available; list them with --cursor %s\n", what, cursor)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// runRollback implements `helios-cli rollback <application-id>`.
+func runRollback(args []string) {
+	fs, apiURL := newFlagSet("rollback", "rollback [flags] <application-id>\n\nDeploys the image and Heliosfile of an earlier successful deployment again.")
+	var to string
+	fs.StringVar(&to, "to", "", "The ID of the deployment to roll back to. Defaults to the successful deployment before the current release that is not itself a rollback.")
+	id := parseArgs(fs, args, 1)[0]
+
+	request := map[string]string{}
+	if to != "" {
+		request["deployment_id"] = to
+	}
+	var d deployment
+	if err := callAPI(http.MethodPost, *apiURL+"/applications/"+id+"/rollback", request, &d); err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	printResult(d, []string{d.ID}, func() {
+		fmt.Printf("Rolling back to deployment %s (%s) as deployment %s.\n", d.RollbackOf, d.ImageURI, d.ID)
+		printFollowHint(*apiURL, d.ID)
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// deployment mirrors the deployment resource returned by the API.
+type deployment struct {
+	ID            string    `json:"id"`
+	ApplicationID string    `json:"application_id"`
+	GitCommitSHA  string    `json:"git_commit_sha"`
+	ImageURI      string    `json:"image_uri"`
+	Status        string    `json:"status"`
+	FailureReason string    `json:"failure_reason"`
+	RollbackOf    string    `json:"rollback_of"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// finished reports whether the deployment has reached a terminal status.
+func (d deployment) finished() bool {
+	return d.Status == "succeeded" || d.Status == "failed" || d.Status == "cancelled"
+}
+
+// runStatus implements `helios-cli status <deployment-id>`.
+func runStatus(args []string) {
+	fs, apiURL := newFlagSet("status", "status [flags] <deployment-id>\n\nExits with status 1 if the deployment failed or was cancelled.")
+	var watch bool
+	var interval time.Duration
+	fs.BoolVar(&watch, "watch", false, "Poll until the deployment succeeds, fails or is cancelled.")
+	fs.DurationVar(&interval, "interval", 2*time.Second, "How often to poll when --watch is set.")
+	id := parseArgs(fs, args, 1)[0]
+
+	// With -o json or yaml, only the deployment as it is when the command
+	// returns is printed, so the output is a single value.
+	last := ""
+	for {
+		var d deployment
+		if err := callAPI(http.MethodGet, *apiURL+"/deployments/"+id, nil, &d); err != nil {
+			log.Fatalf("FATAL: %v", err)
+		}
+		if tableOutput() && d.Status != last {
+			fmt.Printf("Deployment %s: %s\n", d.ID, d.Status)
+			last = d.Status
+		}
+		if !watch || d.finished() {
+			printResult(d, []string{d.ID}, func() {
+				if d.FailureReason != "" {
+					fmt.Printf("Reason: %s\n", d.FailureReason)
+				}
+			})
+			if d.Status == "failed" || d.Status == "cancelled" {
+				os.Exit(exitFailure)
+			}
+			return
+		}
+		time.Sleep(interval)
+	}
+}
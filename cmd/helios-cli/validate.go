@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"helios/pkg/heliosfile"
+)
+
+// validation is the result of validating a Heliosfile.
+type validation struct {
+	File      string            `json:"file"`
+	Valid     bool              `json:"valid"`
+	Services  []string          `json:"services"`
+	Databases []string          `json:"databases"`
+	Errors    []validationError `json:"errors"`
+}
+
+// validationError is a problem in a Heliosfile. Line and Column are 1-based;
+// they are zero if the position is unknown.
+type validationError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// String formats the error like a compiler does, as
+// "<file>:<line>:<column>: <message>".
+func (e validationError) String(file string) string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", file, e.Message)
+	case e.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", file, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("%s:%d:%d: %s", file, e.Line, e.Column, e.Message)
+	}
+}
+
+// runValidate implements `helios-cli validate`.
+func runValidate(args []string) {
+	fs := newLocalFlagSet("validate", "validate [flags]\n\nChecks a Heliosfile the way the workers do before deploying it, without\ntalking to the API. Exits with status 1 if it is invalid.")
+	var file string
+	fs.StringVar(&file, "file", heliosfile.FileName, "The Heliosfile to validate.")
+	parseFlags(fs, args, 0)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to read Heliosfile: %v", err)
+	}
+	result := validate(file, data)
+
+	printResult(result, nil, func() {
+		if !result.Valid {
+			for _, e := range result.Errors {
+				fmt.Println(e.String(file))
+			}
+			return
+		}
+		fmt.Printf("%s is valid: %d service(s), %d database(s).\n", file, len(result.Services), len(result.Databases))
+	})
+	if !result.Valid {
+		os.Exit(exitFailure)
+	}
+}
+
+// validate parses the manifest in data and interpolates its env values, as
+// the oal-worker does when it deploys it. Config vars and secrets are kept
+// by the API, which validate does not talk to, so references to them are
+// undefined here.
+func validate(file string, data []byte) validation {
+	result := validation{File: file, Services: []string{}, Databases: []string{}, Errors: []validationError{}}
+	f, err := heliosfile.Parse(data)
+	if err == nil {
+		_, err = heliosfile.Interpolate(f, heliosfile.Variables{})
+	}
+
+	var errs heliosfile.Errors
+	switch {
+	case errors.As(err, &errs):
+		for _, e := range errs {
+			result.Errors = append(result.Errors, validationError{Line: e.Line, Column: e.Column, Message: e.Message})
+		}
+	case err != nil:
+		result.Errors = append(result.Errors, validationError{Message: err.Error()})
+	default:
+		result.Valid = true
+		result.Services = f.ServiceNames()
+		result.Databases = append(result.Databases, f.DatabaseNames()...)
+	}
+	return result
+}
@@ -0,0 +1,15 @@
+-- Transactional Outbox for Helios PaaS
+-- Version: 2
+-- Description: Adds the outbox table. Events are written to it in the same transaction
+-- as the rows they describe and relayed to NATS by the API service.
+
+CREATE TABLE "outbox" (
+  "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  "subject" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "created_at" timestamp NOT NULL DEFAULT (now()),
+  "sent_at" timestamp
+);
+
+-- The relay only ever scans unsent messages, so keep that index small.
+CREATE INDEX ON "outbox" ("created_at") WHERE "sent_at" IS NULL;
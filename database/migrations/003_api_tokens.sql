@@ -0,0 +1,15 @@
+-- API Tokens for Helios PaaS
+-- Version: 3
+-- Description: Adds long-lived personal API tokens. Only a SHA-256 hash of each token is stored.
+
+CREATE TABLE "api_tokens" (
+  "id" uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  "user_id" uuid NOT NULL,
+  "name" varchar NOT NULL,
+  "token_hash" varchar NOT NULL UNIQUE,
+  "created_at" timestamp NOT NULL DEFAULT (now()),
+  "last_used_at" timestamp,
+  CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON "api_tokens" ("user_id");
@@ -0,0 +1,6 @@
+-- Team Roles for Helios PaaS
+-- Version: 4
+-- Description: Adds the read-only 'viewer' role and restricts team_members.role to known roles.
+
+ALTER TABLE "team_members"
+  ADD CONSTRAINT team_members_role_check CHECK ("role" IN ('admin', 'member', 'viewer'));
@@ -0,0 +1,6 @@
+-- Platform Admins for Helios PaaS
+-- Version: 5
+-- Description: Marks users who operate the platform itself, such as inspecting and replaying dead-lettered events.
+
+ALTER TABLE "users"
+  ADD COLUMN "is_admin" boolean NOT NULL DEFAULT false;
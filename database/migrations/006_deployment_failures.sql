@@ -0,0 +1,6 @@
+-- Deployment Failures for Helios PaaS
+-- Version: 6
+-- Description: Records why a deployment failed, as reported by the build-worker or oal-worker.
+
+ALTER TABLE "deployments"
+  ADD COLUMN "failure_reason" text;
@@ -0,0 +1,6 @@
+-- Deployment Manifests for Helios PaaS
+-- Version: 7
+-- Description: Keeps the Heliosfile.yml each deployment was built with, so later deployments can be planned against it.
+
+ALTER TABLE "deployments"
+  ADD COLUMN "manifest" text;
@@ -0,0 +1,6 @@
+-- Deployment Rollbacks for Helios PaaS
+-- Version: 8
+-- Description: Marks deployments that deploy the release of an earlier deployment again instead of building a new one.
+
+ALTER TABLE "deployments"
+  ADD COLUMN "rollback_of" uuid REFERENCES deployments(id) ON DELETE SET NULL;
@@ -0,0 +1,17 @@
+-- Deployment Logs for Helios PaaS
+-- Version: 9
+-- Description: Keeps the log lines the workers publish while they build and deploy,
+-- so users can read and follow them through the API.
+
+CREATE TABLE "deployment_logs" (
+  "id" bigserial PRIMARY KEY,
+  "event_id" uuid UNIQUE NOT NULL,
+  "deployment_id" uuid NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+  "source" varchar NOT NULL,
+  "level" varchar NOT NULL,
+  "message" text NOT NULL,
+  "logged_at" timestamp NOT NULL
+);
+
+-- Lines are read per deployment, in the order they were stored.
+CREATE INDEX ON "deployment_logs" ("deployment_id", "id");
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- Team Invitations for Helios PaaS
+-- Version: 10
+-- Description: Keeps invitations to emails that are not registered yet, so that
+-- inviting someone does not reveal whether they have an account. The user joins
+-- the team when they register.
+
+CREATE TABLE "team_invitations" (
+  "team_id" uuid NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+  "email" varchar NOT NULL,
+  "role" varchar NOT NULL CHECK ("role" IN ('admin', 'member', 'viewer')),
+  "created_at" timestamp NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("team_id", "email")
+);
+
+CREATE INDEX ON "team_invitations" ("email");
@@ -0,0 +1,18 @@
+-- Application Variables for Helios PaaS
+-- Version: 11
+-- Description: Keeps the config vars of applications and the names of their secrets,
+-- which Heliosfiles refer to as ${config.<NAME>} and ${secrets.<NAME>}. Secret values
+-- are not stored; the backends provide them.
+
+CREATE TABLE "application_config_vars" (
+  "application_id" uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  "name" varchar NOT NULL,
+  "value" text NOT NULL,
+  PRIMARY KEY ("application_id", "name")
+);
+
+CREATE TABLE "application_secrets" (
+  "application_id" uuid NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+  "name" varchar NOT NULL,
+  PRIMARY KEY ("application_id", "name")
+);
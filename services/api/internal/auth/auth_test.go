@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mocks ---
+
+// MockTokenStore resolves tokens from an in-memory map of hash to user.
+type MockTokenStore struct {
+	Users map[string]*repository.User
+	Err   error
+}
+
+func (m *MockTokenStore) GetUserByTokenHash(_ context.Context, tokenHash string) (*repository.User, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	u, ok := m.Users[tokenHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+// --- Tests ---
+
+func TestPasswordHashing(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, "correct horse", hash)
+	assert.True(t, CheckPassword(hash, "correct horse"))
+	assert.False(t, CheckPassword(hash, "wrong"))
+}
+
+func TestGenerateToken(t *testing.T) {
+	a, err := GenerateToken()
+	require.NoError(t, err)
+	b, err := GenerateToken()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(a, TokenPrefix))
+	assert.NotEqual(t, a, b)
+	assert.Len(t, HashToken(a), 64)
+	assert.Equal(t, HashToken(a), HashToken(a))
+}
+
+func TestRoleAllows(t *testing.T) {
+	testCases := []struct {
+		role     string
+		required string
+		expected bool
+	}{
+		{repository.RoleAdmin, repository.RoleAdmin, true},
+		{repository.RoleAdmin, repository.RoleViewer, true},
+		{repository.RoleMember, repository.RoleMember, true},
+		{repository.RoleMember, repository.RoleAdmin, false},
+		{repository.RoleViewer, repository.RoleViewer, true},
+		{repository.RoleViewer, repository.RoleMember, false},
+		{"owner", repository.RoleViewer, false},
+		{"", repository.RoleViewer, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.role+"/"+tc.required, func(t *testing.T) {
+			assert.Equal(t, tc.expected, RoleAllows(tc.role, tc.required))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	user := &repository.User{ID: "user-1", Email: "dev@example.com"}
+	store := &MockTokenStore{Users: map[string]*repository.User{HashToken("hel_valid"): user}}
+
+	testCases := []struct {
+		name               string
+		header             string
+		storeErr           error
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", header: "Bearer hel_valid", expectedStatusCode: http.StatusOK},
+		{name: "Successful Case - Lowercase scheme", header: "bearer hel_valid", expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Missing header", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Failure Case - Wrong scheme", header: "Basic hel_valid", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Failure Case - Unknown token", header: "Bearer hel_unknown", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Failure Case - Store error", header: "Bearer hel_valid", storeErr: errors.New("connection refused"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store.Err = tc.storeErr
+			var got *repository.User
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = UserFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/projects", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rr := httptest.NewRecorder()
+
+			Middleware(store, testutil.NewTestLogger())(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+			if tc.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, user, got, "middleware should attach the caller to the context")
+			} else {
+				assert.Nil(t, got, "next handler must not run")
+			}
+			if tc.expectedStatusCode == http.StatusUnauthorized {
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"helios/api/internal/repository"
+
+	"github.com/rs/zerolog"
+)
+
+// TokenStore resolves API token hashes to their owners.
+type TokenStore interface {
+	GetUserByTokenHash(ctx context.Context, tokenHash string) (*repository.User, error)
+}
+
+// Middleware authenticates requests carrying an "Authorization: Bearer
+// <token>" header and attaches the caller to the request context. Requests
+// without a valid token are rejected with 401 Unauthorized.
+func Middleware(store TokenStore, logger zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w, "Missing bearer token")
+				return
+			}
+
+			user, err := store.GetUserByTokenHash(r.Context(), HashToken(token))
+			if errors.Is(err, repository.ErrNotFound) {
+				unauthorized(w, "Invalid token")
+				return
+			}
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to authenticate token")
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+		})
+	}
+}
+
+// bearerToken extracts the token from the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="helios"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
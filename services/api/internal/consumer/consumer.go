@@ -0,0 +1,310 @@
+// Package consumer handles events published by the workers and records their
+// effect on deployments in the database.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"helios/api/internal/repository"
+	"helios/pkg/deadletter"
+	"helios/pkg/events"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+)
+
+// natsMsg defines the interface for a NATS message, allowing for easier testing.
+type natsMsg interface {
+	GetData() []byte
+	Ack() error
+	Nak() error
+	// Term stops redelivery of a message that can never be processed.
+	Term(reason string) error
+}
+
+// natsMsgAdapter adapts a jetstream.Msg to the natsMsg interface. Messages
+// that are terminated, or nakked on their last delivery, are dead-lettered.
+type natsMsgAdapter struct {
+	msg jetstream.Msg
+	dlq *deadletter.Queue
+}
+
+func (a *natsMsgAdapter) GetData() []byte {
+	return a.msg.Data()
+}
+
+func (a *natsMsgAdapter) Ack() error {
+	return a.msg.Ack()
+}
+
+func (a *natsMsgAdapter) Nak() error {
+	if a.dlq == nil {
+		return a.msg.Nak()
+	}
+	return a.dlq.Nak(a.msg)
+}
+
+func (a *natsMsgAdapter) Term(reason string) error {
+	if a.dlq == nil {
+		return a.msg.TermWithReason(reason)
+	}
+	return a.dlq.Term(a.msg, reason)
+}
+
+// Store defines the persistence operations required by the consumer.
+type Store interface {
+	UpdateDeploymentStatus(ctx context.Context, id, status string, update repository.DeploymentUpdate) (*repository.Deployment, error)
+	InsertDeploymentLog(ctx context.Context, eventID string, line events.DeploymentLog) error
+}
+
+// Consumer holds dependencies for the event handlers.
+type Consumer struct {
+	Store     Store
+	Logger    zerolog.Logger
+	Validator *validator.Validate
+	// Timeout bounds the database work done for a single message.
+	Timeout time.Duration
+	// DeadLetters receives messages the consumer gives up on. If nil, they
+	// are terminated without being kept.
+	DeadLetters *deadletter.Queue
+}
+
+// NewConsumer creates a new Consumer.
+func NewConsumer(store Store, logger zerolog.Logger) *Consumer {
+	return &Consumer{
+		Store:     store,
+		Logger:    logger,
+		Validator: validator.New(),
+		Timeout:   10 * time.Second,
+	}
+}
+
+// HandleBuildStarted is the public handler for JetStream messages. It wraps
+// the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleBuildStarted(m jetstream.Msg) {
+	c.handleBuildStartedInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleBuildSucceeded is the public handler for JetStream messages. It wraps
+// the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleBuildSucceeded(m jetstream.Msg) {
+	c.handleBuildSucceededInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleBuildFailed is the public handler for JetStream messages. It wraps
+// the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleBuildFailed(m jetstream.Msg) {
+	c.handleBuildFailedInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleDeploymentStarted is the public handler for JetStream messages. It
+// wraps the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleDeploymentStarted(m jetstream.Msg) {
+	c.handleDeploymentStartedInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleDeploymentSucceeded is the public handler for JetStream messages. It
+// wraps the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleDeploymentSucceeded(m jetstream.Msg) {
+	c.handleDeploymentSucceededInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleDeploymentFailed is the public handler for JetStream messages. It
+// wraps the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleDeploymentFailed(m jetstream.Msg) {
+	c.handleDeploymentFailedInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleDeploymentCancelled is the public handler for JetStream messages. It
+// wraps the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleDeploymentCancelled(m jetstream.Msg) {
+	c.handleDeploymentCancelledInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// HandleDeploymentLog is the public handler for JetStream messages. It wraps
+// the real message and passes it to the testable internal handler.
+func (c *Consumer) HandleDeploymentLog(m jetstream.Msg) {
+	c.handleDeploymentLogInternal(&natsMsgAdapter{msg: m, dlq: c.DeadLetters})
+}
+
+// handleBuildStartedInternal moves the deployment to the building status.
+func (c *Consumer) handleBuildStartedInternal(m natsMsg) {
+	event, ok := decode[events.BuildStarted](c, m, "build started")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusBuilding, repository.DeploymentUpdate{})
+}
+
+// handleBuildSucceededInternal records the built image on the deployment and
+// moves it to the deploying status. The image is recorded even if a later
+// event moved the deployment on first.
+func (c *Consumer) handleBuildSucceededInternal(m natsMsg) {
+	event, ok := decode[events.BuildSucceeded](c, m, "build succeeded")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusDeploying, repository.DeploymentUpdate{
+		GitCommitSHA: &event.Data.GitCommitSHA,
+		ImageURI:     &event.Data.ImageURI,
+		Manifest:     &event.Data.Manifest,
+	})
+}
+
+// handleBuildFailedInternal records why the build failed and moves the
+// deployment to the failed status.
+func (c *Consumer) handleBuildFailedInternal(m natsMsg) {
+	event, ok := decode[events.BuildFailed](c, m, "build failed")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusFailed, repository.DeploymentUpdate{
+		FailureReason: &event.Data.Reason,
+	})
+}
+
+// handleDeploymentStartedInternal moves the deployment to the deploying
+// status. It is usually there already, because build succeeded events move
+// it there too.
+func (c *Consumer) handleDeploymentStartedInternal(m natsMsg) {
+	event, ok := decode[events.DeploymentStarted](c, m, "deployment started")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusDeploying, repository.DeploymentUpdate{
+		ImageURI: &event.Data.ImageURI,
+	})
+}
+
+// handleDeploymentSucceededInternal moves the deployment to the succeeded
+// status.
+func (c *Consumer) handleDeploymentSucceededInternal(m natsMsg) {
+	event, ok := decode[events.DeploymentSucceeded](c, m, "deployment succeeded")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusSucceeded, repository.DeploymentUpdate{
+		ImageURI: &event.Data.ImageURI,
+	})
+}
+
+// handleDeploymentFailedInternal records why the release failed and moves
+// the deployment to the failed status.
+func (c *Consumer) handleDeploymentFailedInternal(m natsMsg) {
+	event, ok := decode[events.DeploymentFailed](c, m, "deployment failed")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusFailed, repository.DeploymentUpdate{
+		FailureReason: &event.Data.Reason,
+	})
+}
+
+// handleDeploymentCancelledInternal moves the deployment to the cancelled
+// status. Deployments that finished before the worker stopped keep their
+// outcome.
+func (c *Consumer) handleDeploymentCancelledInternal(m natsMsg) {
+	event, ok := decode[events.DeploymentCancelled](c, m, "deployment cancelled")
+	if !ok {
+		return
+	}
+
+	c.updateStatus(m, event.Metadata, event.Data.DeploymentID, repository.DeploymentStatusCancelled, repository.DeploymentUpdate{})
+}
+
+// handleDeploymentLogInternal stores a line of a deployment's log. Database
+// errors are nakked for redelivery.
+func (c *Consumer) handleDeploymentLogInternal(m natsMsg) {
+	event, ok := decode[events.DeploymentLog](c, m, "deployment log")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	if err := c.Store.InsertDeploymentLog(ctx, event.ID, event.Data); err != nil {
+		c.Logger.Error().Err(err).
+			Str("event_id", event.ID).
+			Str("deployment_id", event.Data.DeploymentID).
+			Msg("Failed to store deployment log line, nakking message for redelivery")
+		if err := m.Nak(); err != nil {
+			c.Logger.Error().Err(err).Msg("Failed to nak NATS message")
+		}
+		return
+	}
+
+	if err := m.Ack(); err != nil {
+		c.Logger.Error().Err(err).Msg("Failed to acknowledge NATS message")
+	}
+}
+
+// decode unmarshals the message into an envelope and validates its payload.
+// On failure the message is terminated, since redelivering it cannot succeed.
+func decode[T any](c *Consumer, m natsMsg, name string) (events.Envelope[T], bool) {
+	event, err := events.Decode[T](m.GetData())
+	if err != nil {
+		c.Logger.Error().Err(err).Msgf("Could not decode %s event, terminating message", name)
+		c.term(m, "invalid event: "+err.Error())
+		return event, false
+	}
+	if err := c.Validator.Struct(&event.Data); err != nil {
+		c.Logger.Error().Err(err).Msgf("Invalid %s event payload, terminating message", name)
+		c.term(m, "invalid payload: "+err.Error())
+		return event, false
+	}
+	return event, true
+}
+
+// updateStatus applies a status change and settles the message. Rejected
+// transitions are acknowledged, because they come from duplicate or stale
+// events; database errors are nakked for redelivery.
+func (c *Consumer) updateStatus(m natsMsg, meta events.Metadata, deploymentID, status string, update repository.DeploymentUpdate) {
+	log := c.Logger.With().
+		Str("event_id", meta.ID).
+		Str("correlation_id", meta.CorrelationID).
+		Str("deployment_id", deploymentID).
+		Str("status", status).
+		Logger()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	_, err := c.Store.UpdateDeploymentStatus(ctx, deploymentID, status, update)
+	switch {
+	case err == nil:
+		log.Info().Msg("Deployment status updated")
+	case errors.Is(err, repository.ErrInvalidTransition):
+		// Expected with at-least-once delivery, and when two events move a
+		// deployment to the same status.
+		log.Info().Msg("Ignoring stale or duplicate deployment status change")
+	case errors.Is(err, repository.ErrNotFound):
+		log.Warn().Msg("Ignoring status change for unknown deployment")
+	default:
+		log.Error().Err(err).Msg("Failed to update deployment status, nakking message for redelivery")
+		if err := m.Nak(); err != nil {
+			log.Error().Err(err).Msg("Failed to nak NATS message")
+		}
+		return
+	}
+
+	if err := m.Ack(); err != nil {
+		log.Error().Err(err).Msg("Failed to acknowledge NATS message")
+	}
+}
+
+// term terminates a message that can never be processed.
+func (c *Consumer) term(m natsMsg, reason string) {
+	if err := m.Term(reason); err != nil {
+		c.Logger.Error().Err(err).Msg("Failed to terminate NATS message")
+	}
+}
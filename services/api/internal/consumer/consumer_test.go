@@ -0,0 +1,299 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"helios/api/internal/repository"
+	"helios/pkg/events"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mocks ---
+
+// mockNatsMsg is a mock implementation of the natsMsg interface for testing.
+type mockNatsMsg struct {
+	data       []byte
+	acked      bool
+	nakked     bool
+	termed     bool
+	termReason string
+}
+
+func (m *mockNatsMsg) GetData() []byte {
+	return m.data
+}
+
+func (m *mockNatsMsg) Ack() error {
+	m.acked = true
+	return nil
+}
+
+func (m *mockNatsMsg) Nak() error {
+	m.nakked = true
+	return nil
+}
+
+func (m *mockNatsMsg) Term(reason string) error {
+	m.termed = true
+	m.termReason = reason
+	return nil
+}
+
+// mockStore records status updates and log lines and returns the configured
+// error.
+type mockStore struct {
+	err          error
+	deploymentID string
+	status       string
+	update       repository.DeploymentUpdate
+	logs         []events.DeploymentLog
+}
+
+func (m *mockStore) UpdateDeploymentStatus(_ context.Context, id, status string, update repository.DeploymentUpdate) (*repository.Deployment, error) {
+	m.deploymentID, m.status, m.update = id, status, update
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &repository.Deployment{ID: id, Status: status}, nil
+}
+
+func (m *mockStore) InsertDeploymentLog(_ context.Context, _ string, line events.DeploymentLog) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.logs = append(m.logs, line)
+	return nil
+}
+
+// --- Tests ---
+
+func TestHandleBuildSucceededInternal(t *testing.T) {
+	validEvent := events.BuildSucceeded{
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+		Manifest:     "name: shop\nservices:\n  web: {}\n",
+	}
+	validEventData, err := json.Marshal(events.New(events.SubjectBuildSucceeded, validEvent))
+	require.NoError(t, err, "Setup failed: could not marshal valid event")
+	invalidEventData, err := json.Marshal(events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{DeploymentID: "dep-456"}))
+	require.NoError(t, err, "Setup failed: could not marshal invalid event")
+	bareEventData, err := json.Marshal(validEvent)
+	require.NoError(t, err, "Setup failed: could not marshal bare event")
+	futureEvent := events.New(events.SubjectBuildSucceeded, validEvent)
+	futureEvent.Version = events.SchemaVersion + 1
+	futureEventData, err := json.Marshal(futureEvent)
+	require.NoError(t, err, "Setup failed: could not marshal future event")
+
+	testCases := []struct {
+		name         string
+		data         []byte
+		storeErr     error
+		expectUpdate bool
+		expectAck    bool
+		expectNak    bool
+		expectTerm   bool
+	}{
+		{
+			name:         "Successful Case",
+			data:         validEventData,
+			expectUpdate: true,
+			expectAck:    true,
+		},
+		{
+			name:         "Stale event is acknowledged",
+			data:         validEventData,
+			storeErr:     repository.ErrInvalidTransition,
+			expectUpdate: true,
+			expectAck:    true,
+		},
+		{
+			name:         "Database error is nakked",
+			data:         validEventData,
+			storeErr:     errors.New("connection refused"),
+			expectUpdate: true,
+			expectNak:    true,
+		},
+		{
+			name:       "Invalid JSON is terminated",
+			data:       []byte(`{"deployment_id":`),
+			expectTerm: true,
+		},
+		{
+			name:       "Missing fields are terminated",
+			data:       invalidEventData,
+			expectTerm: true,
+		},
+		{
+			name:       "Payload without envelope is terminated",
+			data:       bareEventData,
+			expectTerm: true,
+		},
+		{
+			name:       "Newer schema version is terminated",
+			data:       futureEventData,
+			expectTerm: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockStore{err: tc.storeErr}
+			c := NewConsumer(store, testutil.NewTestLogger())
+			msg := &mockNatsMsg{data: tc.data}
+
+			c.handleBuildSucceededInternal(msg)
+
+			if tc.expectUpdate {
+				assert.Equal(t, validEvent.DeploymentID, store.deploymentID)
+				assert.Equal(t, repository.DeploymentStatusDeploying, store.status)
+				require.NotNil(t, store.update.ImageURI)
+				assert.Equal(t, validEvent.ImageURI, *store.update.ImageURI)
+				require.NotNil(t, store.update.Manifest)
+				assert.Equal(t, validEvent.Manifest, *store.update.Manifest, "the manifest should be kept for planning later deployments")
+			} else {
+				assert.Empty(t, store.status, "consumer should not have updated the deployment")
+			}
+			assert.Equal(t, tc.expectAck, msg.acked, "Message acknowledgement state does not match expectation")
+			assert.Equal(t, tc.expectNak, msg.nakked, "Message nak state does not match expectation")
+			assert.Equal(t, tc.expectTerm, msg.termed, "Message termination state does not match expectation")
+			if tc.expectTerm {
+				assert.NotEmpty(t, msg.termReason, "terminated message should carry a reason for the dead letter")
+			}
+		})
+	}
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	marshal := func(subject string, data any) []byte {
+		b, err := json.Marshal(events.New(subject, data))
+		require.NoError(t, err, "Setup failed: could not marshal event")
+		return b
+	}
+
+	testCases := []struct {
+		name           string
+		handle         func(c *Consumer, m natsMsg)
+		data           []byte
+		expectedStatus string
+		expectedReason string
+		expectTerm     bool
+	}{
+		{
+			name:           "Build started",
+			handle:         (*Consumer).handleBuildStartedInternal,
+			data:           marshal(events.SubjectBuildStarted, events.BuildStarted{DeploymentID: "dep-456", AppID: "app-123"}),
+			expectedStatus: repository.DeploymentStatusBuilding,
+		},
+		{
+			name:           "Build failed",
+			handle:         (*Consumer).handleBuildFailedInternal,
+			data:           marshal(events.SubjectBuildFailed, events.BuildFailed{DeploymentID: "dep-456", AppID: "app-123", Reason: "clone failed"}),
+			expectedStatus: repository.DeploymentStatusFailed,
+			expectedReason: "clone failed",
+		},
+		{
+			name:       "Build failed without a reason is terminated",
+			handle:     (*Consumer).handleBuildFailedInternal,
+			data:       marshal(events.SubjectBuildFailed, events.BuildFailed{DeploymentID: "dep-456", AppID: "app-123"}),
+			expectTerm: true,
+		},
+		{
+			name:           "Deployment started",
+			handle:         (*Consumer).handleDeploymentStartedInternal,
+			data:           marshal(events.SubjectDeploymentStarted, events.DeploymentStarted{DeploymentID: "dep-456", AppID: "app-123", ImageURI: "registry.helios.internal/app-123:a1b2c3d4"}),
+			expectedStatus: repository.DeploymentStatusDeploying,
+		},
+		{
+			name:           "Deployment succeeded",
+			handle:         (*Consumer).handleDeploymentSucceededInternal,
+			data:           marshal(events.SubjectDeploymentSucceeded, events.DeploymentSucceeded{DeploymentID: "dep-456", AppID: "app-123", ImageURI: "registry.helios.internal/app-123:a1b2c3d4"}),
+			expectedStatus: repository.DeploymentStatusSucceeded,
+		},
+		{
+			name:           "Deployment failed",
+			handle:         (*Consumer).handleDeploymentFailedInternal,
+			data:           marshal(events.SubjectDeploymentFailed, events.DeploymentFailed{DeploymentID: "dep-456", AppID: "app-123", Reason: "health check timed out"}),
+			expectedStatus: repository.DeploymentStatusFailed,
+			expectedReason: "health check timed out",
+		},
+		{
+			name:           "Deployment cancelled",
+			handle:         (*Consumer).handleDeploymentCancelledInternal,
+			data:           marshal(events.SubjectDeploymentCancelled, events.DeploymentCancelled{DeploymentID: "dep-456", AppID: "app-123"}),
+			expectedStatus: repository.DeploymentStatusCancelled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockStore{}
+			c := NewConsumer(store, testutil.NewTestLogger())
+			msg := &mockNatsMsg{data: tc.data}
+
+			tc.handle(c, msg)
+
+			assert.Equal(t, tc.expectTerm, msg.termed, "Message termination state does not match expectation")
+			if tc.expectTerm {
+				assert.Empty(t, store.status, "consumer should not have updated the deployment")
+				return
+			}
+			assert.True(t, msg.acked, "message should be acknowledged")
+			assert.Equal(t, "dep-456", store.deploymentID)
+			assert.Equal(t, tc.expectedStatus, store.status)
+			if tc.expectedReason != "" {
+				require.NotNil(t, store.update.FailureReason)
+				assert.Equal(t, tc.expectedReason, *store.update.FailureReason)
+			} else {
+				assert.Nil(t, store.update.FailureReason)
+			}
+		})
+	}
+}
+
+func TestHandleDeploymentLogInternal(t *testing.T) {
+	line := events.DeploymentLog{DeploymentID: "dep-456", AppID: "app-123", Source: events.LogSourceBuild, Level: "info", Message: "Checked out commit a1b2c3d4", Time: time.Now().UTC()}
+	valid, err := json.Marshal(events.New(events.SubjectDeploymentLog, line))
+	require.NoError(t, err, "Setup failed: could not marshal event")
+	invalid, err := json.Marshal(events.New(events.SubjectDeploymentLog, events.DeploymentLog{DeploymentID: "dep-456"}))
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	testCases := []struct {
+		name       string
+		data       []byte
+		storeErr   error
+		expectAck  bool
+		expectNak  bool
+		expectTerm bool
+	}{
+		{name: "Successful Case", data: valid, expectAck: true},
+		{name: "Failure Case - Database error", data: valid, storeErr: errors.New("connection refused"), expectNak: true},
+		{name: "Failure Case - Invalid payload", data: invalid, expectTerm: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockStore{err: tc.storeErr}
+			c := NewConsumer(store, testutil.NewTestLogger())
+			msg := &mockNatsMsg{data: tc.data}
+
+			c.handleDeploymentLogInternal(msg)
+
+			assert.Equal(t, tc.expectAck, msg.acked, "Message acknowledgement state does not match expectation")
+			assert.Equal(t, tc.expectNak, msg.nakked, "Message nak state does not match expectation")
+			assert.Equal(t, tc.expectTerm, msg.termed, "Message termination state does not match expectation")
+			if tc.expectAck {
+				require.Len(t, store.logs, 1)
+				assert.Equal(t, line.Message, store.logs[0].Message)
+			}
+		})
+	}
+}
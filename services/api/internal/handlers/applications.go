@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"helios/api/internal/repository"
+)
+
+// CreateApplicationRequest defines the structure for the application creation request body.
+type CreateApplicationRequest struct {
+	ProjectID     string `json:"project_id" validate:"required,uuid"`
+	Name          string `json:"name" validate:"required"`
+	GitRepository string `json:"git_repository" validate:"required,url"`
+	GitBranch     string `json:"git_branch" validate:"required"`
+}
+
+// CreateApplicationHandler creates a new application and triggers a deployment.
+func (h *APIHandlers) CreateApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reqBody CreateApplicationRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeProject, reqBody.ProjectID, repository.RoleMember) {
+		return
+	}
+
+	app, deployment, err := h.Store.CreateApplication(r.Context(), repository.CreateApplicationParams{
+		ProjectID:     reqBody.ProjectID,
+		Name:          reqBody.Name,
+		GitRepository: reqBody.GitRepository,
+		GitBranch:     reqBody.GitBranch,
+	})
+	if errors.Is(err, repository.ErrProjectNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not create application")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The deployment request event was written to the outbox in the same
+	// transaction; the outbox relay publishes it to NATS.
+	h.Logger.Info().
+		Str("app_id", app.ID).
+		Str("app_name", app.Name).
+		Str("deployment_id", deployment.ID).
+		Msg("Application created and deployment queued")
+
+	// Respond to the client
+	response := map[string]string{
+		"id":            app.ID,
+		"project_id":    app.ProjectID,
+		"name":          app.Name,
+		"deployment_id": deployment.ID,
+		"status":        deployment.Status,
+	}
+	h.writeJSON(w, http.StatusAccepted, response)
+}
+
+// GetApplicationHandler returns a single application.
+func (h *APIHandlers) GetApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleViewer) {
+		return
+	}
+
+	app, err := h.Store.GetApplication(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not get application")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, app)
+}
+
+// UpdateApplicationRequest defines the structure for the application update
+// request body. Omitted fields are left unchanged.
+type UpdateApplicationRequest struct {
+	Name           *string `json:"name" validate:"omitempty,min=1"`
+	GitRepository  *string `json:"git_repository" validate:"omitempty,url"`
+	GitBranch      *string `json:"git_branch" validate:"omitempty,min=1"`
+	CurrentBackend *string `json:"current_backend" validate:"omitempty,oneof=docker_compose k3s dry"`
+}
+
+// UpdateApplicationHandler partially updates an application.
+func (h *APIHandlers) UpdateApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleMember) {
+		return
+	}
+
+	var reqBody UpdateApplicationRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+	if reqBody == (UpdateApplicationRequest{}) {
+		http.Error(w, "Request body must contain at least one field to update", http.StatusBadRequest)
+		return
+	}
+
+	app, err := h.Store.UpdateApplication(r.Context(), id, repository.UpdateApplicationParams{
+		Name:           reqBody.Name,
+		GitRepository:  reqBody.GitRepository,
+		GitBranch:      reqBody.GitBranch,
+		CurrentBackend: reqBody.CurrentBackend,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not update application")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("app_id", app.ID).Msg("Application updated")
+
+	h.writeJSON(w, http.StatusOK, app)
+}
+
+// DeleteApplicationHandler deletes an application and its deployment history.
+func (h *APIHandlers) DeleteApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleAdmin) {
+		return
+	}
+
+	err := h.Store.DeleteApplication(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not delete application")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("app_id", id).Msg("Application deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
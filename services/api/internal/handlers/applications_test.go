@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateApplicationHandler(t *testing.T) {
+	validBody := `{
+		"project_id": "` + testProjectID + `",
+		"name": "my-app",
+		"git_repository": "https://github.com/example/my-app.git",
+		"git_branch": "main"
+	}`
+
+	testCases := []struct {
+		name               string
+		method             string
+		body               io.Reader
+		storeError         error
+		expectedStatusCode int
+	}{
+		{
+			name:               "Successful Case",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(validBody),
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "Failure Case - Invalid JSON",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(`{"name": "my-app",}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "Failure Case - Missing project",
+			method: http.MethodPost,
+			body: bytes.NewBufferString(`{
+				"name": "my-app",
+				"git_repository": "https://github.com/example/my-app.git",
+				"git_branch": "main"
+			}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Unknown project",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(validBody),
+			storeError:         repository.ErrProjectNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Failure Case - Database error",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(validBody),
+			storeError:         errors.New("connection refused"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "Failure Case - Method Not Allowed",
+			method:             http.MethodGet,
+			body:               nil,
+			expectedStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			testLogger := testutil.NewTestLogger()
+			store := newMockStore()
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testLogger)
+
+			req, err := http.NewRequest(tc.method, "/applications", tc.body)
+			require.NoError(t, err, "Could not create request")
+			req = asTestUser(req)
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(handlers.CreateApplicationHandler)
+
+			// Execute
+			handler.ServeHTTP(rr, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+
+			if tc.expectedStatusCode == http.StatusAccepted {
+				var response map[string]string
+				err = json.Unmarshal(rr.Body.Bytes(), &response)
+				require.NoError(t, err, "Could not parse response body")
+				assert.Equal(t, testAppID, response["id"], "handler returned wrong application ID")
+				assert.Equal(t, testDeploymentID, response["deployment_id"], "handler returned wrong deployment ID")
+				assert.Equal(t, "pending", response["status"], "handler returned wrong status")
+			}
+		})
+	}
+}
+
+func TestGetApplicationHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		storeError         error
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testAppID, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Malformed ID", id: "app_67890", expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Database error", id: testAppID, storeError: errors.New("connection refused"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/applications/"+tc.id, nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.GetApplicationHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var app repository.Application
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &app), "Could not parse response body")
+				assert.Equal(t, testAppID, app.ID)
+			}
+		})
+	}
+}
+
+func TestUpdateApplicationHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		body               string
+		role               string
+		expectedStatusCode int
+		expectedBranch     string
+		expectedBackend    string
+	}{
+		{
+			name:               "Successful Case - Partial update",
+			id:                 testAppID,
+			body:               `{"git_branch": "develop"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedBranch:     "develop",
+			expectedBackend:    "docker_compose",
+		},
+		{
+			name:               "Successful Case - Switch backend",
+			id:                 testAppID,
+			body:               `{"current_backend": "k3s"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedBranch:     "main",
+			expectedBackend:    "k3s",
+		},
+		{
+			name:               "Failure Case - Unknown backend",
+			id:                 testAppID,
+			body:               `{"current_backend": "nomad"}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Empty name",
+			id:                 testAppID,
+			body:               `{"name": ""}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - No fields",
+			id:                 testAppID,
+			body:               `{}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Successful Case - Member can deploy",
+			id:                 testAppID,
+			body:               `{"git_branch": "develop"}`,
+			role:               repository.RoleMember,
+			expectedStatusCode: http.StatusOK,
+			expectedBranch:     "develop",
+			expectedBackend:    "docker_compose",
+		},
+		{
+			name:               "Failure Case - Viewer cannot deploy",
+			id:                 testAppID,
+			body:               `{"git_branch": "develop"}`,
+			role:               repository.RoleViewer,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Failure Case - Not found",
+			id:                 testMissingID,
+			body:               `{"git_branch": "develop"}`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPatch, "/applications/"+tc.id, bytes.NewBufferString(tc.body)), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.UpdateApplicationHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var app repository.Application
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &app), "Could not parse response body")
+				assert.Equal(t, tc.expectedBranch, app.GitBranch)
+				assert.Equal(t, tc.expectedBackend, app.CurrentBackend)
+			}
+		})
+	}
+}
+
+func TestDeleteApplicationHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		role               string
+		notMember          bool
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testAppID, expectedStatusCode: http.StatusNoContent},
+		{name: "Failure Case - Member cannot delete", id: testAppID, role: repository.RoleMember, expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Not a team member", id: testAppID, notMember: true, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			store.NotMember = tc.notMember
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodDelete, "/applications/"+tc.id, nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.DeleteApplicationHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
+)
+
+// defaultTokenName names the token issued by a login that does not set one.
+const defaultTokenName = "login"
+
+// RegisterRequest defines the structure for the registration request body.
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// RegisterResponse is returned after a successful registration.
+type RegisterResponse struct {
+	User *repository.User `json:"user"`
+	Team *repository.Team `json:"team"`
+}
+
+// LoginRequest defines the structure for the login request body.
+type LoginRequest struct {
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required"`
+	TokenName string `json:"token_name" validate:"omitempty,max=100"`
+}
+
+// CreateTokenRequest defines the structure for the token creation request body.
+type CreateTokenRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// TokenResponse carries a newly issued API token. The plaintext token is
+// never shown again.
+type TokenResponse struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Token     string           `json:"token"`
+	CreatedAt time.Time        `json:"created_at"`
+	User      *repository.User `json:"user,omitempty"`
+}
+
+// RegisterHandler creates a user account and a personal team that the new
+// user administers.
+func (h *APIHandlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody RegisterRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+
+	hash, err := auth.HashPassword(reqBody.Password)
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not hash password")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user, team, err := h.Store.CreateUser(r.Context(), normalizeEmail(reqBody.Email), hash)
+	if errors.Is(err, repository.ErrEmailTaken) {
+		http.Error(w, "Email already registered", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not create user")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("user_id", user.ID).Str("team_id", team.ID).Msg("User registered")
+
+	h.writeJSON(w, http.StatusCreated, RegisterResponse{User: user, Team: team})
+}
+
+// LoginHandler checks a user's password and issues a new API token.
+func (h *APIHandlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody LoginRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+
+	user, err := h.Store.GetUserByEmail(r.Context(), normalizeEmail(reqBody.Email))
+	if errors.Is(err, repository.ErrNotFound) {
+		auth.CheckDummyPassword(reqBody.Password)
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not look up user")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !auth.CheckPassword(user.PasswordHash, reqBody.Password) {
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	name := reqBody.TokenName
+	if name == "" {
+		name = defaultTokenName
+	}
+	resp, ok := h.issueToken(w, r, user, name)
+	if !ok {
+		return
+	}
+	resp.User = user
+
+	h.Logger.Info().Str("user_id", user.ID).Msg("User logged in")
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateTokenHandler issues an additional API token for the caller.
+func (h *APIHandlers) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody CreateTokenRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+
+	resp, ok := h.issueToken(w, r, user, reqBody.Name)
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, resp)
+}
+
+// ListTokensHandler returns the caller's API tokens, without the tokens
+// themselves.
+func (h *APIHandlers) ListTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.Store.ListAPITokens(r.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error().Err(err).Str("user_id", user.ID).Msg("Could not list API tokens")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: tokens})
+}
+
+// RevokeTokenHandler revokes one of the caller's API tokens, which may be the
+// token of the request itself.
+func (h *APIHandlers) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	err := h.Store.DeleteAPIToken(r.Context(), user.ID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("user_id", user.ID).Msg("Could not revoke API token")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("user_id", user.ID).Str("token_id", id).Msg("API token revoked")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MeHandler returns the authenticated caller.
+func (h *APIHandlers) MeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+// issueToken generates and stores a new API token for user. On failure it
+// writes a 500 response and returns false.
+func (h *APIHandlers) issueToken(w http.ResponseWriter, r *http.Request, user *repository.User, name string) (*TokenResponse, bool) {
+	token, err := auth.GenerateToken()
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not generate API token")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	stored, err := h.Store.CreateAPIToken(r.Context(), user.ID, name, auth.HashToken(token))
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not store API token")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	h.Logger.Info().Str("user_id", user.ID).Str("token_id", stored.ID).Msg("API token issued")
+
+	return &TokenResponse{ID: stored.ID, Name: stored.Name, Token: token, CreatedAt: stored.CreatedAt}, true
+}
+
+// decodeBody decodes and validates a JSON request body into v. On failure it
+// writes a 400 response and returns false.
+func (h *APIHandlers) decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		h.Logger.Warn().Err(err).Msg("Could not decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	if err := h.Validator.Struct(v); err != nil {
+		h.Logger.Warn().Err(err).Msg("Request body validation failed")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// normalizeEmail makes email lookups case-insensitive.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
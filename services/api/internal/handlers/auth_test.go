@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStoreWithUser returns a MockStore with one registered user whose
+// password is "correct horse".
+func newStoreWithUser(t *testing.T) *MockStore {
+	t.Helper()
+	hash, err := auth.HashPassword("correct horse")
+	require.NoError(t, err)
+	store := newMockStore()
+	store.Users = []repository.User{{ID: testUserID, Email: "dev@example.com", PasswordHash: hash}}
+	return store
+}
+
+func TestRegisterHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		body               string
+		storeError         error
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", body: `{"email": "New@Example.com", "password": "long enough"}`, expectedStatusCode: http.StatusCreated},
+		{name: "Failure Case - Email taken", body: `{"email": "dev@example.com", "password": "long enough"}`, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Invalid email", body: `{"email": "not-an-email", "password": "long enough"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Short password", body: `{"email": "new@example.com", "password": "short"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Invalid JSON", body: `{"email":`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Database error", body: `{"email": "new@example.com", "password": "long enough"}`, storeError: errors.New("connection refused"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStoreWithUser(t)
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+
+			handlers.RegisterHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusCreated {
+				var resp RegisterResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), "Could not parse response body")
+				assert.Equal(t, "new@example.com", resp.User.Email, "email should be normalized")
+				assert.Equal(t, testTeamID, resp.Team.ID)
+				assert.NotContains(t, rr.Body.String(), "password", "password hash must not be exposed")
+				assert.True(t, auth.CheckPassword(store.Users[1].PasswordHash, "long enough"), "password should be stored hashed")
+			}
+		})
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		body               string
+		storeError         error
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", body: `{"email": "DEV@example.com", "password": "correct horse"}`, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Wrong password", body: `{"email": "dev@example.com", "password": "wrong"}`, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Failure Case - Unknown user", body: `{"email": "nobody@example.com", "password": "correct horse"}`, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Failure Case - Missing password", body: `{"email": "dev@example.com"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Database error", body: `{"email": "dev@example.com", "password": "correct horse"}`, storeError: errors.New("connection refused"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStoreWithUser(t)
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+
+			handlers.LoginHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var resp TokenResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), "Could not parse response body")
+				assert.True(t, strings.HasPrefix(resp.Token, auth.TokenPrefix))
+				assert.Equal(t, defaultTokenName, resp.Name)
+				assert.Equal(t, testUserID, store.TokenHashes[auth.HashToken(resp.Token)], "only the token hash should be stored")
+			}
+		})
+	}
+}
+
+func TestCreateTokenHandler(t *testing.T) {
+	user := &repository.User{ID: testUserID, Email: "dev@example.com"}
+
+	testCases := []struct {
+		name               string
+		user               *repository.User
+		body               string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", user: user, body: `{"name": "ci"}`, expectedStatusCode: http.StatusCreated},
+		{name: "Failure Case - Missing name", user: user, body: `{}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Unauthenticated", body: `{"name": "ci"}`, expectedStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/tokens", bytes.NewBufferString(tc.body))
+			if tc.user != nil {
+				req = req.WithContext(auth.WithUser(req.Context(), tc.user))
+			}
+			rr := httptest.NewRecorder()
+
+			handlers.CreateTokenHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusCreated {
+				var resp TokenResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), "Could not parse response body")
+				assert.Equal(t, "ci", resp.Name)
+				assert.Contains(t, store.TokenHashes, auth.HashToken(resp.Token))
+			}
+		})
+	}
+}
+
+func TestListTokensHandler(t *testing.T) {
+	store := newMockStore()
+	store.Tokens = []repository.APIToken{
+		{ID: testTokenID, UserID: testUserID, Name: "ci"},
+		{ID: testMissingID, UserID: "someone-else", Name: "laptop"},
+	}
+	handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+	req := asTestUser(httptest.NewRequest(http.MethodGet, "/auth/tokens", nil))
+	rr := httptest.NewRecorder()
+
+	handlers.ListTokensHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "handler returned wrong status code")
+	var resp struct {
+		Items []repository.APIToken `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), "Could not parse response body")
+	require.Len(t, resp.Items, 1, "only the caller's tokens should be listed")
+	assert.Equal(t, testTokenID, resp.Items[0].ID)
+}
+
+func TestRevokeTokenHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		tokenID            string
+		storeError         error
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", tokenID: testTokenID, expectedStatusCode: http.StatusNoContent},
+		{name: "Failure Case - Unknown token", tokenID: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Invalid ID", tokenID: "not-a-uuid", expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Store error", tokenID: testTokenID, storeError: errors.New("db down"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Tokens = []repository.APIToken{{ID: testTokenID, UserID: testUserID, Name: "ci"}}
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodDelete, "/auth/tokens/"+tc.tokenID, nil), "id", tc.tokenID))
+			rr := httptest.NewRecorder()
+
+			handlers.RevokeTokenHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusNoContent {
+				assert.Empty(t, store.Tokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
+	"helios/pkg/deadletter"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeadLetterStore defines the operations on dead-lettered events required by
+// the HTTP handlers.
+type DeadLetterStore interface {
+	List(ctx context.Context, subject string, from uint64, limit int) ([]deadletter.Record, uint64, error)
+	Get(ctx context.Context, seq uint64) (*deadletter.Record, error)
+	Replay(ctx context.Context, seq uint64) (*deadletter.Record, error)
+}
+
+// requireAdmin checks that the caller is a platform admin. Otherwise it
+// writes an error response and returns false.
+func (h *APIHandlers) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if !user.IsAdmin {
+		http.Error(w, "Forbidden: requires a platform admin", http.StatusForbidden)
+		return false
+	}
+	if h.DeadLetters == nil {
+		http.Error(w, "Dead letters are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// pathSeq returns the seq URL parameter. Otherwise it writes a 400 response
+// and returns false.
+func pathSeq(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	seq, err := strconv.ParseUint(chi.URLParam(r, "seq"), 10, 64)
+	if err != nil || seq == 0 {
+		http.Error(w, "Invalid seq: must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return seq, true
+}
+
+// ListDeadLettersHandler returns dead-lettered events, oldest first. The
+// subject query parameter restricts the list to events from one subject.
+func (h *APIHandlers) ListDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	limit := repository.DefaultPageSize
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > repository.MaxPageSize {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", repository.MaxPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var from uint64
+	if s := q.Get("cursor"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		from = n
+	}
+
+	records, next, err := h.DeadLetters.List(r.Context(), q.Get("subject"), from, limit)
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not list dead letters")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := listResponse{Items: records}
+	if next != 0 {
+		resp.NextCursor = strconv.FormatUint(next, 10)
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetDeadLetterHandler returns a single dead-lettered event, including its
+// original payload.
+func (h *APIHandlers) GetDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	seq, ok := pathSeq(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := h.DeadLetters.Get(r.Context(), seq)
+	if errors.Is(err, deadletter.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Uint64("seq", seq).Msg("Could not get dead letter")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, record)
+}
+
+// ReplayDeadLetterHandler publishes a dead-lettered event to its original
+// subject again and removes it from the dead letters.
+func (h *APIHandlers) ReplayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	seq, ok := pathSeq(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := h.DeadLetters.Replay(r.Context(), seq)
+	if errors.Is(err, deadletter.ErrNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Uint64("seq", seq).Msg("Could not replay dead letter")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user, _ := auth.UserFromContext(r.Context())
+	h.Logger.Info().Uint64("seq", seq).Str("subject", record.Subject).Str("user_id", user.ID).Msg("Dead letter replayed")
+
+	h.writeJSON(w, http.StatusAccepted, record)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
+	"helios/pkg/deadletter"
+	"helios/pkg/events"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mocks ---
+
+// MockDeadLetterStore is an in-memory implementation of DeadLetterStore.
+type MockDeadLetterStore struct {
+	Records  []deadletter.Record
+	Replayed []uint64
+	Err      error
+}
+
+func (m *MockDeadLetterStore) List(ctx context.Context, subject string, from uint64, limit int) ([]deadletter.Record, uint64, error) {
+	if m.Err != nil {
+		return nil, 0, m.Err
+	}
+	records := []deadletter.Record{}
+	for _, rec := range m.Records {
+		if rec.Sequence < from || (subject != "" && rec.Subject != subject) {
+			continue
+		}
+		if len(records) == limit {
+			return records, rec.Sequence, nil
+		}
+		records = append(records, rec)
+	}
+	return records, 0, nil
+}
+
+func (m *MockDeadLetterStore) Get(ctx context.Context, seq uint64) (*deadletter.Record, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for i := range m.Records {
+		if m.Records[i].Sequence == seq {
+			return &m.Records[i], nil
+		}
+	}
+	return nil, deadletter.ErrNotFound
+}
+
+func (m *MockDeadLetterStore) Replay(ctx context.Context, seq uint64) (*deadletter.Record, error) {
+	rec, err := m.Get(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	m.Replayed = append(m.Replayed, seq)
+	return rec, nil
+}
+
+func newMockDeadLetterStore() *MockDeadLetterStore {
+	return &MockDeadLetterStore{
+		Records: []deadletter.Record{
+			{Sequence: 3, DeadLetter: events.DeadLetter{Subject: events.SubjectDeploymentRequested, Consumer: "build-workers", Reason: "invalid JSON", Data: []byte(`{`)}},
+			{Sequence: 7, DeadLetter: events.DeadLetter{Subject: events.SubjectBuildSucceeded, Consumer: "oal-workers", Reason: "maximum deliveries (5) exceeded"}},
+			{Sequence: 9, DeadLetter: events.DeadLetter{Subject: events.SubjectDeploymentRequested, Consumer: "build-workers", Reason: "invalid payload"}},
+		},
+	}
+}
+
+// asTestAdmin returns a copy of req authenticated as a platform admin.
+func asTestAdmin(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithUser(req.Context(), &repository.User{ID: testUserID, Email: "dev@example.com", IsAdmin: true}))
+}
+
+// --- Tests ---
+
+func TestListDeadLettersHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		query              string
+		admin              bool
+		storeErr           error
+		expectedStatusCode int
+		expectedSeqs       []uint64
+		expectedCursor     string
+	}{
+		{name: "Successful Case", admin: true, expectedStatusCode: http.StatusOK, expectedSeqs: []uint64{3, 7, 9}},
+		{name: "Successful Case - Filter by subject", query: "?subject=" + events.SubjectDeploymentRequested, admin: true, expectedStatusCode: http.StatusOK, expectedSeqs: []uint64{3, 9}},
+		{name: "Successful Case - Paginated", query: "?limit=1&cursor=4", admin: true, expectedStatusCode: http.StatusOK, expectedSeqs: []uint64{7}, expectedCursor: "9"},
+		{name: "Failure Case - Not a platform admin", expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Invalid limit", query: "?limit=0", admin: true, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Invalid cursor", query: "?cursor=abc", admin: true, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Store error", admin: true, storeErr: errors.New("stream unavailable"), expectedStatusCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockDeadLetterStore()
+			store.Err = tc.storeErr
+			handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+			handlers.DeadLetters = store
+
+			req := httptest.NewRequest(http.MethodGet, "/dead-letters"+tc.query, nil)
+			if tc.admin {
+				req = asTestAdmin(req)
+			} else {
+				req = asTestUser(req)
+			}
+			rr := httptest.NewRecorder()
+
+			handlers.ListDeadLettersHandler(rr, req)
+
+			require.Equal(t, tc.expectedStatusCode, rr.Code, "Handler returned wrong status code")
+			if tc.expectedStatusCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Items      []deadletter.Record `json:"items"`
+				NextCursor string              `json:"next_cursor"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+			seqs := []uint64{}
+			for _, rec := range response.Items {
+				seqs = append(seqs, rec.Sequence)
+			}
+			assert.Equal(t, tc.expectedSeqs, seqs)
+			assert.Equal(t, tc.expectedCursor, response.NextCursor)
+		})
+	}
+}
+
+func TestGetDeadLetterHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		seq                string
+		admin              bool
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", seq: "3", admin: true, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not a platform admin", seq: "3", expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Invalid seq", seq: "abc", admin: true, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Not found", seq: "4", admin: true, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+			handlers.DeadLetters = newMockDeadLetterStore()
+
+			req := httptest.NewRequest(http.MethodGet, "/dead-letters/"+tc.seq, nil)
+			if tc.admin {
+				req = asTestAdmin(req)
+			} else {
+				req = asTestUser(req)
+			}
+			req = withURLParam(req, "seq", tc.seq)
+			rr := httptest.NewRecorder()
+
+			handlers.GetDeadLetterHandler(rr, req)
+
+			require.Equal(t, tc.expectedStatusCode, rr.Code, "Handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var record deadletter.Record
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &record), "Could not parse response body")
+				assert.Equal(t, uint64(3), record.Sequence)
+				assert.Equal(t, "invalid JSON", record.Reason)
+				assert.Equal(t, []byte(`{`), record.Data)
+			}
+		})
+	}
+}
+
+func TestReplayDeadLetterHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		seq                string
+		admin              bool
+		expectedStatusCode int
+		expectReplay       bool
+	}{
+		{name: "Successful Case", seq: "7", admin: true, expectedStatusCode: http.StatusAccepted, expectReplay: true},
+		{name: "Failure Case - Not a platform admin", seq: "7", expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Not found", seq: "8", admin: true, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockDeadLetterStore()
+			handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+			handlers.DeadLetters = store
+
+			req := httptest.NewRequest(http.MethodPost, "/dead-letters/"+tc.seq+"/replay", nil)
+			if tc.admin {
+				req = asTestAdmin(req)
+			} else {
+				req = asTestUser(req)
+			}
+			req = withURLParam(req, "seq", tc.seq)
+			rr := httptest.NewRecorder()
+
+			handlers.ReplayDeadLetterHandler(rr, req)
+
+			require.Equal(t, tc.expectedStatusCode, rr.Code, "Handler returned wrong status code")
+			if tc.expectReplay {
+				assert.Equal(t, []uint64{7}, store.Replayed)
+			} else {
+				assert.Empty(t, store.Replayed)
+			}
+		})
+	}
+}
+
+func TestDeadLettersUnavailable(t *testing.T) {
+	handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+
+	req := asTestAdmin(httptest.NewRequest(http.MethodGet, "/dead-letters", nil))
+	rr := httptest.NewRecorder()
+
+	handlers.ListDeadLettersHandler(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
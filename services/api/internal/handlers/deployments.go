@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"helios/api/internal/repository"
+)
+
+// ListApplicationDeploymentsHandler returns a page of an application's
+// deployments, newest first.
+func (h *APIHandlers) ListApplicationDeploymentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Authorizing also resolves the application, so an unknown application
+	// is a 404 rather than an empty list.
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleViewer) {
+		return
+	}
+
+	deployments, next, err := h.Store.ListDeployments(r.Context(), id, opts)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not list deployments")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: deployments, NextCursor: next})
+}
+
+// GetDeploymentHandler returns a single deployment, including its current
+// status. Clients poll this endpoint to follow a deployment to completion.
+func (h *APIHandlers) GetDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeDeployment, id, repository.RoleViewer) {
+		return
+	}
+
+	deployment, err := h.Store.GetDeployment(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("deployment_id", id).Msg("Could not get deployment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, deployment)
+}
+
+// CreateDeploymentHandler deploys the head of an application's branch again
+// as a new pending deployment.
+func (h *APIHandlers) CreateDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleMember) {
+		return
+	}
+
+	deployment, err := h.Store.CreateDeployment(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not create deployment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The deployment request event was written to the outbox in the same
+	// transaction; the outbox relay publishes it to NATS.
+	h.Logger.Info().
+		Str("app_id", id).
+		Str("deployment_id", deployment.ID).
+		Msg("Deployment queued")
+
+	h.writeJSON(w, http.StatusAccepted, deployment)
+}
+
+// RollbackApplicationRequest defines the structure for the rollback request
+// body. It is optional.
+type RollbackApplicationRequest struct {
+	// DeploymentID is the deployment whose release is deployed again. If
+	// empty, it is the newest successful deployment before the current
+	// release that is not itself a rollback.
+	DeploymentID string `json:"deployment_id" validate:"omitempty,uuid"`
+}
+
+// RollbackApplicationHandler deploys the image and Heliosfile of an earlier
+// successful deployment again, without building them, as a new deployment.
+func (h *APIHandlers) RollbackApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody RollbackApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		h.Logger.Warn().Err(err).Msg("Could not decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.Validator.Struct(&reqBody); err != nil {
+		h.Logger.Warn().Err(err).Msg("Request body validation failed")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleMember) {
+		return
+	}
+
+	deployment, err := h.Store.CreateRollback(r.Context(), id, reqBody.DeploymentID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrNothingToRollBack):
+		http.Error(w, "No earlier successful deployment to roll back to", http.StatusConflict)
+		return
+	case errors.Is(err, repository.ErrInvalidRollbackTarget):
+		http.Error(w, "Can only roll back to an earlier successful deployment", http.StatusConflict)
+		return
+	case err != nil:
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not create rollback")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The rollback request event was written to the outbox in the same
+	// transaction; the outbox relay publishes it to NATS.
+	h.Logger.Info().
+		Str("app_id", id).
+		Str("deployment_id", deployment.ID).
+		Str("rollback_of", deployment.RollbackOf).
+		Msg("Rollback queued")
+
+	h.writeJSON(w, http.StatusAccepted, deployment)
+}
+
+// CancelDeploymentHandler asks the workers to stop a deployment that has not
+// finished. The deployment moves to the cancelled status once a worker has
+// stopped it; if it finishes first, it keeps its outcome.
+func (h *APIHandlers) CancelDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeDeployment, id, repository.RoleMember) {
+		return
+	}
+
+	deployment, err := h.Store.CancelDeployment(r.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrDeploymentFinished):
+		http.Error(w, "Deployment has already finished", http.StatusConflict)
+		return
+	case err != nil:
+		h.Logger.Error().Err(err).Str("deployment_id", id).Msg("Could not cancel deployment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().
+		Str("app_id", deployment.ApplicationID).
+		Str("deployment_id", id).
+		Msg("Cancellation queued")
+
+	h.writeJSON(w, http.StatusAccepted, deployment)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListApplicationDeploymentsHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		query              string
+		expectedStatusCode int
+		expectedCount      int
+	}{
+		{name: "Successful Case", id: testAppID, expectedStatusCode: http.StatusOK, expectedCount: 1},
+		{name: "Failure Case - Unknown application", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Invalid limit", id: testAppID, query: "?limit=0", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/applications/"+tc.id+"/deployments"+tc.query, nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.ListApplicationDeploymentsHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var response struct {
+					Items []repository.Deployment `json:"items"`
+				}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+				assert.Len(t, response.Items, tc.expectedCount)
+			}
+		})
+	}
+}
+
+func TestGetDeploymentHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testDeploymentID, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Malformed ID", id: "dep_1", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/deployments/"+tc.id, nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.GetDeploymentHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var deployment repository.Deployment
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deployment), "Could not parse response body")
+				assert.Equal(t, repository.DeploymentStatusPending, deployment.Status)
+			}
+		})
+	}
+}
+
+func TestRollbackApplicationHandler(t *testing.T) {
+	const (
+		firstID  = "1b2c3d4e-5f60-4718-8293-a4b5c6d7e8f9"
+		secondID = "2c3d4e5f-6071-4829-93a4-b5c6d7e8f901"
+		failedID = "3d4e5f60-7182-4930-a4b5-c6d7e8f90123"
+	)
+	seed := func(store *MockStore) {
+		now := time.Now()
+		store.Deployments = append(store.Deployments,
+			repository.Deployment{ID: firstID, ApplicationID: testAppID, Status: repository.DeploymentStatusSucceeded, ImageURI: "registry.helios.internal/my-app:1", CreatedAt: now.Add(-2 * time.Hour)},
+			repository.Deployment{ID: secondID, ApplicationID: testAppID, Status: repository.DeploymentStatusSucceeded, ImageURI: "registry.helios.internal/my-app:2", CreatedAt: now.Add(-time.Hour)},
+			repository.Deployment{ID: failedID, ApplicationID: testAppID, Status: repository.DeploymentStatusFailed, CreatedAt: now},
+		)
+	}
+
+	testCases := []struct {
+		name               string
+		id                 string
+		body               string
+		role               string
+		neverDeployed      bool
+		expectedStatusCode int
+		expectedRollbackOf string
+	}{
+		{name: "Successful Case - Previous deployment", id: testAppID, expectedStatusCode: http.StatusAccepted, expectedRollbackOf: firstID},
+		{name: "Successful Case - Named deployment", id: testAppID, body: `{"deployment_id": "` + firstID + `"}`, role: repository.RoleMember, expectedStatusCode: http.StatusAccepted, expectedRollbackOf: firstID},
+		{name: "Failure Case - Current deployment", id: testAppID, body: `{"deployment_id": "` + secondID + `"}`, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Failed deployment", id: testAppID, body: `{"deployment_id": "` + failedID + `"}`, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Unknown deployment", id: testAppID, body: `{"deployment_id": "` + testMissingID + `"}`, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Never deployed", id: testAppID, neverDeployed: true, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Malformed deployment ID", id: testAppID, body: `{"deployment_id": "dep_1"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Viewer", id: testAppID, role: repository.RoleViewer, expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Unknown application", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			if !tc.neverDeployed {
+				seed(store)
+			}
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPost, "/applications/"+tc.id+"/rollback", strings.NewReader(tc.body)), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.RollbackApplicationHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedStatusCode == http.StatusAccepted {
+				var deployment repository.Deployment
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deployment), "Could not parse response body")
+				assert.Equal(t, testRollbackID, deployment.ID)
+				assert.Equal(t, tc.expectedRollbackOf, deployment.RollbackOf)
+				assert.Equal(t, repository.DeploymentStatusPending, deployment.Status)
+			}
+		})
+	}
+}
+
+func TestCreateDeploymentHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		role               string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testAppID, role: repository.RoleMember, expectedStatusCode: http.StatusAccepted},
+		{name: "Failure Case - Viewer", id: testAppID, role: repository.RoleViewer, expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Unknown application", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Malformed ID", id: "app_1", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPost, "/applications/"+tc.id+"/deployments", nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.CreateDeploymentHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedStatusCode == http.StatusAccepted {
+				var deployment repository.Deployment
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deployment), "Could not parse response body")
+				assert.Equal(t, testRedeployID, deployment.ID)
+				assert.Equal(t, repository.DeploymentStatusPending, deployment.Status)
+			}
+		})
+	}
+}
+
+func TestCancelDeploymentHandler(t *testing.T) {
+	const succeededID = "1b2c3d4e-5f60-4718-8293-a4b5c6d7e8f9"
+
+	testCases := []struct {
+		name               string
+		id                 string
+		role               string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testDeploymentID, role: repository.RoleMember, expectedStatusCode: http.StatusAccepted},
+		{name: "Failure Case - Finished deployment", id: succeededID, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Viewer", id: testDeploymentID, role: repository.RoleViewer, expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Malformed ID", id: "dep_1", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			store.Deployments = append(store.Deployments, repository.Deployment{ID: succeededID, ApplicationID: testAppID, Status: repository.DeploymentStatusSucceeded, CreatedAt: time.Now()})
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPost, "/deployments/"+tc.id+"/cancel", nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.CancelDeploymentHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedStatusCode == http.StatusAccepted {
+				var deployment repository.Deployment
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &deployment), "Could not parse response body")
+				assert.Equal(t, testDeploymentID, deployment.ID)
+				assert.Equal(t, repository.DeploymentStatusPending, deployment.Status, "the deployment should be cancelled by the workers")
+			}
+		})
+	}
+}
@@ -1,122 +1,163 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
-	"helios/pkg/events"
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/rs/zerolog"
 )
 
-// NatsPublisher defines the interface for publishing messages to NATS.
-type NatsPublisher interface {
-	Publish(subject string, data []byte) error
+// Store defines the persistence operations required by the HTTP handlers.
+type Store interface {
+	CreateUser(ctx context.Context, email, passwordHash string) (*repository.User, *repository.Team, error)
+	GetUserByEmail(ctx context.Context, email string) (*repository.User, error)
+	CreateAPIToken(ctx context.Context, userID, name, tokenHash string) (*repository.APIToken, error)
+	ListAPITokens(ctx context.Context, userID string) ([]repository.APIToken, error)
+	DeleteAPIToken(ctx context.Context, userID, id string) error
+
+	MemberRole(ctx context.Context, scope repository.Scope, id, userID string) (string, error)
+	CreateTeam(ctx context.Context, name, adminID string) (*repository.Team, error)
+	ListTeams(ctx context.Context, userID string) ([]repository.Team, error)
+	ListMembers(ctx context.Context, teamID string) ([]repository.Member, error)
+	AddMember(ctx context.Context, teamID, email, role string) (*repository.Invitation, error)
+	UpdateMemberRole(ctx context.Context, teamID, userID, role string) (*repository.Member, error)
+	RemoveMember(ctx context.Context, teamID, userID string) error
+
+	CreateProject(ctx context.Context, teamID, name string) (*repository.Project, error)
+	GetProject(ctx context.Context, id string) (*repository.Project, error)
+	ListProjects(ctx context.Context, userID string, opts repository.ListOptions) ([]repository.Project, string, error)
+
+	CreateApplication(ctx context.Context, params repository.CreateApplicationParams) (*repository.Application, *repository.Deployment, error)
+	GetApplication(ctx context.Context, id string) (*repository.Application, error)
+	ListApplications(ctx context.Context, projectID string, opts repository.ListOptions) ([]repository.Application, string, error)
+	UpdateApplication(ctx context.Context, id string, params repository.UpdateApplicationParams) (*repository.Application, error)
+	DeleteApplication(ctx context.Context, id string) error
+	GetVariables(ctx context.Context, appID string) (*repository.Variables, error)
+	SetVariables(ctx context.Context, appID string, vars repository.Variables) (*repository.Variables, error)
+
+	GetDeployment(ctx context.Context, id string) (*repository.Deployment, error)
+	ListDeployments(ctx context.Context, appID string, opts repository.ListOptions) ([]repository.Deployment, string, error)
+	LastSucceededDeployment(ctx context.Context, appID string) (*repository.Deployment, error)
+	CreateDeployment(ctx context.Context, appID string) (*repository.Deployment, error)
+	CreateRollback(ctx context.Context, appID, targetID string) (*repository.Deployment, error)
+	CancelDeployment(ctx context.Context, id string) (*repository.Deployment, error)
+
+	ListDeploymentLogs(ctx context.Context, deploymentID string, opts repository.ListOptions) ([]repository.LogLine, string, error)
 }
 
 // APIHandlers holds dependencies for the HTTP handlers.
 type APIHandlers struct {
-	NATS      NatsPublisher
+	Store     Store
 	Logger    zerolog.Logger
 	Validator *validator.Validate
+	// DeadLetters serves the dead-letter endpoints. If nil, they respond
+	// with 503 Service Unavailable.
+	DeadLetters DeadLetterStore
+	// Planner serves deployment plans. If nil, they respond with 503
+	// Service Unavailable.
+	Planner Planner
+	// LogPollInterval is how often followed deployment logs are polled for
+	// new lines.
+	LogPollInterval time.Duration
 }
 
 // NewAPIHandlers creates a new APIHandlers struct.
-func NewAPIHandlers(nats NatsPublisher, logger zerolog.Logger) *APIHandlers {
+func NewAPIHandlers(store Store, logger zerolog.Logger) *APIHandlers {
 	return &APIHandlers{
-		NATS:      nats,
-		Logger:    logger,
-		Validator: validator.New(),
+		Store:           store,
+		Logger:          logger,
+		Validator:       validator.New(),
+		LogPollInterval: time.Second,
 	}
 }
 
-// CreateProjectHandler simulates creating a new project.
-func (h *APIHandlers) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Simulate creating a project and returning its ID
-	projectID := "proj_12345"
-	h.Logger.Info().Str("project_id", projectID).Msg("Simulating project creation")
-
-	response := map[string]string{"id": projectID, "name": "New Project"}
+// writeJSON encodes v as the response body with the given status code.
+func (h *APIHandlers) writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.Logger.Error().Err(err).Msg("Could not encode response body")
+	}
 }
 
-// CreateApplicationRequest defines the structure for the application creation request body.
-type CreateApplicationRequest struct {
-	Name          string `json:"name" validate:"required"`
-	GitRepository string `json:"git_repository" validate:"required,url"`
-	GitBranch     string `json:"git_branch" validate:"required"`
+// listResponse is the envelope for paginated list responses. NextCursor is
+// omitted on the last page.
+type listResponse struct {
+	Items      any    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
-// CreateApplicationHandler simulates creating a new application and triggers a deployment.
-func (h *APIHandlers) CreateApplicationHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
-		return
+// parseListOptions reads the limit, cursor and name query parameters.
+func parseListOptions(r *http.Request) (repository.ListOptions, error) {
+	q := r.URL.Query()
+	opts := repository.ListOptions{
+		Cursor: q.Get("cursor"),
+		Name:   q.Get("name"),
 	}
-
-	var reqBody CreateApplicationRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		h.Logger.Warn().Err(err).Msg("Could not decode request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > repository.MaxPageSize {
+			return opts, fmt.Errorf("limit must be an integer between 1 and %d", repository.MaxPageSize)
+		}
+		opts.Limit = limit
 	}
+	return opts, nil
+}
 
-	// Validate the request body
-	if err := h.Validator.Struct(&reqBody); err != nil {
-		h.Logger.Warn().Err(err).Msg("Request body validation failed")
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// pathID returns the named URL parameter if it is a valid UUID. Otherwise it
+// writes a 400 response and returns false.
+func (h *APIHandlers) pathID(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	id := chi.URLParam(r, name)
+	if err := h.Validator.Var(id, "required,uuid"); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s: must be a UUID", name), http.StatusBadRequest)
+		return "", false
 	}
+	return id, true
+}
 
-	// Simulate creating an application and getting an ID
-	appID := "app_67890"
-	h.Logger.Info().
-		Str("app_id", appID).
-		Str("app_name", reqBody.Name).
-		Msg("Simulating application creation")
-
-	// Create the deployment request event using the shared package
-	event := events.DeploymentRequest{
-		AppID:         appID,
-		GitRepository: reqBody.GitRepository,
-		GitBranch:     reqBody.GitBranch,
-	}
+// scopeNames names each scope in "not found" responses.
+var scopeNames = map[repository.Scope]string{
+	repository.ScopeTeam:        "Team",
+	repository.ScopeProject:     "Project",
+	repository.ScopeApplication: "Application",
+	repository.ScopeDeployment:  "Deployment",
+}
 
-	eventData, err := json.Marshal(event)
-	if err != nil {
-		h.Logger.Error().Err(err).Msg("Could not marshal event data")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+// authorize checks that the caller holds at least the required role in the
+// team that owns the resource identified by scope and id. Otherwise it writes
+// an error response and returns false. Resources of teams the caller does not
+// belong to are reported as not found.
+func (h *APIHandlers) authorize(w http.ResponseWriter, r *http.Request, scope repository.Scope, id, required string) bool {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
 	}
 
-	// Publish the event to NATS using the interface
-	subject := events.SubjectDeploymentRequested
-	if err := h.NATS.Publish(subject, eventData); err != nil {
-		h.Logger.Error().Err(err).Str("subject", subject).Msg("Failed to publish to NATS")
+	role, err := h.Store.MemberRole(r.Context(), scope, id, user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, scopeNames[scope]+" not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("scope", string(scope)).Str("id", id).Msg("Could not look up team role")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return false
 	}
 
-	h.Logger.Info().
-		Str("subject", subject).
-		Str("app_id", appID).
-		Msg("Successfully published event to NATS")
-
-	// Respond to the client
-	response := map[string]string{
-		"id":     appID,
-		"name":   reqBody.Name,
-		"status": "pending",
+	if !auth.RoleAllows(role, required) {
+		http.Error(w, fmt.Sprintf("Forbidden: requires the %s role", required), http.StatusForbidden)
+		return false
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+	return true
+}
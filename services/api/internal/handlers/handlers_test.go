@@ -1,169 +1,533 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"io"
+	"context"
 	"net/http"
-	"net/http/httptest"
-	"testing"
+	"strconv"
+	"strings"
+	"time"
 
-	"helios/pkg/events"
-	"helios/pkg/testutil"
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
 
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"github.com/go-chi/chi/v5"
 )
 
 // --- Mocks ---
 
-// MockNatsPublisher is a mock implementation of the NatsPublisher interface
-// for testing purposes.
-type MockNatsPublisher struct {
-	PublishedSubject string
-	PublishedData    []byte
-	PublishError     error
-}
-
-// Publish records the subject and data it was called with, then returns any configured error.
-func (m *MockNatsPublisher) Publish(subject string, data []byte) error {
-	m.PublishedSubject = subject
-	m.PublishedData = data
-	return m.PublishError
-}
-
-// --- Tests ---
-
-func TestCreateProjectHandler(t *testing.T) {
-	testCases := []struct {
-		name               string
-		method             string
-		expectedStatusCode int
-	}{
-		{
-			name:               "Successful Case - POST",
-			method:             http.MethodPost,
-			expectedStatusCode: http.StatusCreated,
+// MockStore is an in-memory implementation of the Store interface. Lookups
+// are served from the seeded Projects and Applications; if Err is set, every
+// method except MemberRole returns it instead.
+type MockStore struct {
+	Err          error
+	Members      []repository.Member
+	Projects     []repository.Project
+	Applications []repository.Application
+	Deployments  []repository.Deployment
+	Users        []repository.User
+	Logs         []repository.LogLine
+	NextCursor   string
+
+	// Variables holds the config vars and secrets of each application by
+	// ID. Applications missing from it have none.
+	Variables map[string]repository.Variables
+
+	// TokenHashes maps the hash of every issued API token to its owner.
+	TokenHashes map[string]string
+	Tokens      []repository.APIToken
+
+	// Role is the caller's role in the test team; it defaults to admin. If
+	// NotMember is set, the caller belongs to no team at all.
+	Role      string
+	NotMember bool
+
+	// LastListOptions records the options passed to the most recent list call.
+	LastListOptions repository.ListOptions
+}
+
+// newMockStore returns a MockStore seeded with one project and one application.
+func newMockStore() *MockStore {
+	return &MockStore{
+		Projects: []repository.Project{
+			{ID: testProjectID, TeamID: testTeamID, Name: "My Project", CreatedAt: time.Now()},
+		},
+		Applications: []repository.Application{
+			{
+				ID:             testAppID,
+				ProjectID:      testProjectID,
+				Name:           "my-app",
+				GitRepository:  "https://github.com/example/my-app.git",
+				GitBranch:      "main",
+				CurrentBackend: "docker_compose",
+				CreatedAt:      time.Now(),
+			},
 		},
-		{
-			name:               "Failure Case - GET not allowed",
-			method:             http.MethodGet,
-			expectedStatusCode: http.StatusMethodNotAllowed,
+		Deployments: []repository.Deployment{
+			{
+				ID:            testDeploymentID,
+				ApplicationID: testAppID,
+				Status:        repository.DeploymentStatusPending,
+				CreatedAt:     time.Now(),
+				UpdatedAt:     time.Now(),
+			},
 		},
 	}
+}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			// Setup
-			testLogger := testutil.NewTestLogger()
-			handlers := NewAPIHandlers(nil, testLogger)
+func (m *MockStore) CreateUser(_ context.Context, email, passwordHash string) (*repository.User, *repository.Team, error) {
+	if m.Err != nil {
+		return nil, nil, m.Err
+	}
+	for _, u := range m.Users {
+		if u.Email == email {
+			return nil, nil, repository.ErrEmailTaken
+		}
+	}
+	u := repository.User{ID: testUserID, Email: email, PasswordHash: passwordHash, CreatedAt: time.Now()}
+	m.Users = append(m.Users, u)
+	return &u, &repository.Team{ID: testTeamID, Name: email, CreatedAt: time.Now()}, nil
+}
 
-			req, err := http.NewRequest(tc.method, "/projects", nil)
-			require.NoError(t, err, "Could not create request")
+func (m *MockStore) GetUserByEmail(_ context.Context, email string) (*repository.User, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for _, u := range m.Users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
 
-			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(handlers.CreateProjectHandler)
+func (m *MockStore) CreateAPIToken(_ context.Context, userID, name, tokenHash string) (*repository.APIToken, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.TokenHashes == nil {
+		m.TokenHashes = make(map[string]string)
+	}
+	m.TokenHashes[tokenHash] = userID
+	t := repository.APIToken{ID: testTokenID, UserID: userID, Name: name, CreatedAt: time.Now()}
+	m.Tokens = append(m.Tokens, t)
+	return &t, nil
+}
 
-			// Execute
-			handler.ServeHTTP(rr, req)
+func (m *MockStore) ListAPITokens(_ context.Context, userID string) ([]repository.APIToken, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	tokens := []repository.APIToken{}
+	for _, t := range m.Tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
 
-			// Assert
-			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+func (m *MockStore) DeleteAPIToken(_ context.Context, userID, id string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	for i, t := range m.Tokens {
+		if t.ID == id && t.UserID == userID {
+			m.Tokens = append(m.Tokens[:i], m.Tokens[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
 
-			if tc.expectedStatusCode == http.StatusCreated {
-				var response map[string]string
-				err = json.Unmarshal(rr.Body.Bytes(), &response)
-				require.NoError(t, err, "Could not parse response body")
-				assert.Equal(t, "proj_12345", response["id"], "handler returned unexpected body")
-			}
-		})
-	}
-}
-
-func TestCreateApplicationHandler(t *testing.T) {
-	testCases := []struct {
-		name               string
-		method             string
-		body               io.Reader
-		mockNatsError      error
-		expectedStatusCode int
-		expectNatsPublish  bool
-	}{
-		{
-			name:   "Successful Case",
-			method: http.MethodPost,
-			body: bytes.NewBufferString(`{
-				"name": "my-app",
-				"git_repository": "https://github.com/example/my-app.git",
-				"git_branch": "main"
-			}`),
-			mockNatsError:      nil,
-			expectedStatusCode: http.StatusAccepted,
-			expectNatsPublish:  true,
-		},
-		{
-			name:               "Failure Case - Invalid JSON",
-			method:             http.MethodPost,
-			body:               bytes.NewBufferString(`{"name": "my-app",}`),
-			mockNatsError:      nil,
-			expectedStatusCode: http.StatusBadRequest,
-			expectNatsPublish:  false,
-		},
-		{
-			name:   "Failure Case - NATS Publish Error",
-			method: http.MethodPost,
-			body: bytes.NewBufferString(`{
-				"name": "my-app",
-				"git_repository": "https://github.com/example/my-app.git",
-				"git_branch": "main"
-			}`),
-			mockNatsError:      errors.New("NATS is down"),
-			expectedStatusCode: http.StatusInternalServerError,
-			expectNatsPublish:  true, // It will attempt to publish
-		},
-		{
-			name:               "Failure Case - Method Not Allowed",
-			method:             http.MethodGet,
-			body:               nil,
-			mockNatsError:      nil,
-			expectedStatusCode: http.StatusMethodNotAllowed,
-			expectNatsPublish:  false,
-		},
+func (m *MockStore) MemberRole(_ context.Context, scope repository.Scope, id, _ string) (string, error) {
+	found := false
+	switch scope {
+	case repository.ScopeTeam:
+		found = id == testTeamID
+	case repository.ScopeProject:
+		for _, p := range m.Projects {
+			found = found || p.ID == id
+		}
+	case repository.ScopeApplication:
+		for _, a := range m.Applications {
+			found = found || a.ID == id
+		}
+	case repository.ScopeDeployment:
+		for _, d := range m.Deployments {
+			found = found || d.ID == id
+		}
+	}
+	if !found || m.NotMember {
+		return "", repository.ErrNotFound
+	}
+	if m.Role == "" {
+		return repository.RoleAdmin, nil
+	}
+	return m.Role, nil
+}
+
+func (m *MockStore) CreateTeam(_ context.Context, name, _ string) (*repository.Team, error) {
+	if m.Err != nil {
+		return nil, m.Err
 	}
+	return &repository.Team{ID: testTeamID, Name: name, Role: repository.RoleAdmin, CreatedAt: time.Now()}, nil
+}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			// Setup
-			testLogger := testutil.NewTestLogger()
-			mockNATS := &MockNatsPublisher{PublishError: tc.mockNatsError}
-			handlers := NewAPIHandlers(mockNATS, testLogger)
-
-			req, err := http.NewRequest(tc.method, "/applications", tc.body)
-			require.NoError(t, err, "Could not create request")
-			req.Header.Set("Content-Type", "application/json")
-
-			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(handlers.CreateApplicationHandler)
-
-			// Execute
-			handler.ServeHTTP(rr, req)
-
-			// Assert
-			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
-
-			if tc.expectNatsPublish {
-				assert.NotEmpty(t, mockNATS.PublishedSubject, "handler should have attempted to publish a NATS message")
-				if tc.mockNatsError == nil {
-					assert.Equal(t, events.SubjectDeploymentRequested, mockNATS.PublishedSubject, "handler published to wrong NATS subject")
-					var event events.DeploymentRequest
-					err = json.Unmarshal(mockNATS.PublishedData, &event)
-					require.NoError(t, err, "Could not unmarshal NATS message payload")
-					assert.Equal(t, "app_67890", event.AppID, "NATS event has wrong AppID")
-				}
-			} else {
-				assert.Empty(t, mockNATS.PublishedSubject, "handler should not have published a NATS message")
+func (m *MockStore) ListTeams(_ context.Context, _ string) ([]repository.Team, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.NotMember {
+		return []repository.Team{}, nil
+	}
+	return []repository.Team{{ID: testTeamID, Name: "Test Team", Role: repository.RoleAdmin}}, nil
+}
+
+func (m *MockStore) ListMembers(_ context.Context, _ string) ([]repository.Member, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return m.Members, nil
+}
+
+func (m *MockStore) AddMember(_ context.Context, teamID, email, role string) (*repository.Invitation, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for _, mem := range m.Members {
+		if mem.Email == email {
+			return nil, repository.ErrAlreadyMember
+		}
+	}
+	for _, u := range m.Users {
+		if u.Email == email {
+			m.Members = append(m.Members, repository.Member{TeamID: teamID, UserID: u.ID, Email: email, Role: role})
+		}
+	}
+	return &repository.Invitation{TeamID: teamID, Email: email, Role: role}, nil
+}
+
+func (m *MockStore) UpdateMemberRole(_ context.Context, _, userID, role string) (*repository.Member, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for i := range m.Members {
+		if m.Members[i].UserID == userID {
+			m.Members[i].Role = role
+			mem := m.Members[i]
+			return &mem, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *MockStore) RemoveMember(_ context.Context, _, userID string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	for i, mem := range m.Members {
+		if mem.UserID == userID {
+			m.Members = append(m.Members[:i], m.Members[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (m *MockStore) CreateProject(_ context.Context, teamID, name string) (*repository.Project, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &repository.Project{ID: testProjectID, TeamID: teamID, Name: name, CreatedAt: time.Now()}, nil
+}
+
+func (m *MockStore) GetProject(_ context.Context, id string) (*repository.Project, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for _, p := range m.Projects {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *MockStore) ListProjects(_ context.Context, _ string, opts repository.ListOptions) ([]repository.Project, string, error) {
+	m.LastListOptions = opts
+	if m.Err != nil {
+		return nil, "", m.Err
+	}
+	var out []repository.Project
+	for _, p := range m.Projects {
+		if strings.Contains(strings.ToLower(p.Name), strings.ToLower(opts.Name)) {
+			out = append(out, p)
+		}
+	}
+	return out, m.NextCursor, nil
+}
+
+func (m *MockStore) CreateApplication(_ context.Context, params repository.CreateApplicationParams) (*repository.Application, *repository.Deployment, error) {
+	if m.Err != nil {
+		return nil, nil, m.Err
+	}
+	app := &repository.Application{
+		ID:             testAppID,
+		ProjectID:      params.ProjectID,
+		Name:           params.Name,
+		GitRepository:  params.GitRepository,
+		GitBranch:      params.GitBranch,
+		CurrentBackend: "docker_compose",
+		CreatedAt:      time.Now(),
+	}
+	deployment := &repository.Deployment{
+		ID:            testDeploymentID,
+		ApplicationID: app.ID,
+		Status:        "pending",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	return app, deployment, nil
+}
+
+func (m *MockStore) GetApplication(_ context.Context, id string) (*repository.Application, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for _, a := range m.Applications {
+		if a.ID == id {
+			return &a, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *MockStore) ListApplications(_ context.Context, projectID string, opts repository.ListOptions) ([]repository.Application, string, error) {
+	m.LastListOptions = opts
+	if m.Err != nil {
+		return nil, "", m.Err
+	}
+	var out []repository.Application
+	for _, a := range m.Applications {
+		if a.ProjectID == projectID && strings.Contains(strings.ToLower(a.Name), strings.ToLower(opts.Name)) {
+			out = append(out, a)
+		}
+	}
+	return out, m.NextCursor, nil
+}
+
+func (m *MockStore) UpdateApplication(ctx context.Context, id string, params repository.UpdateApplicationParams) (*repository.Application, error) {
+	a, err := m.GetApplication(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if params.Name != nil {
+		a.Name = *params.Name
+	}
+	if params.GitRepository != nil {
+		a.GitRepository = *params.GitRepository
+	}
+	if params.GitBranch != nil {
+		a.GitBranch = *params.GitBranch
+	}
+	if params.CurrentBackend != nil {
+		a.CurrentBackend = *params.CurrentBackend
+	}
+	return a, nil
+}
+
+func (m *MockStore) DeleteApplication(ctx context.Context, id string) error {
+	_, err := m.GetApplication(ctx, id)
+	return err
+}
+
+func (m *MockStore) GetVariables(_ context.Context, appID string) (*repository.Variables, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	vars, ok := m.Variables[appID]
+	if !ok {
+		return &repository.Variables{Config: map[string]string{}, Secrets: []string{}}, nil
+	}
+	return &vars, nil
+}
+
+func (m *MockStore) SetVariables(ctx context.Context, appID string, vars repository.Variables) (*repository.Variables, error) {
+	if _, err := m.GetApplication(ctx, appID); err != nil {
+		return nil, err
+	}
+	if vars.Config == nil {
+		vars.Config = map[string]string{}
+	}
+	if vars.Secrets == nil {
+		vars.Secrets = []string{}
+	}
+	if m.Variables == nil {
+		m.Variables = map[string]repository.Variables{}
+	}
+	m.Variables[appID] = vars
+	return &vars, nil
+}
+
+func (m *MockStore) GetDeployment(_ context.Context, id string) (*repository.Deployment, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	for _, d := range m.Deployments {
+		if d.ID == id {
+			return &d, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *MockStore) ListDeployments(_ context.Context, appID string, opts repository.ListOptions) ([]repository.Deployment, string, error) {
+	m.LastListOptions = opts
+	if m.Err != nil {
+		return nil, "", m.Err
+	}
+	var out []repository.Deployment
+	for _, d := range m.Deployments {
+		if d.ApplicationID == appID {
+			out = append(out, d)
+		}
+	}
+	return out, m.NextCursor, nil
+}
+
+func (m *MockStore) LastSucceededDeployment(_ context.Context, appID string) (*repository.Deployment, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var last *repository.Deployment
+	for _, d := range m.Deployments {
+		if d.ApplicationID == appID && d.Status == repository.DeploymentStatusSucceeded && (last == nil || d.CreatedAt.After(last.CreatedAt)) {
+			last = &d
+		}
+	}
+	if last == nil {
+		return nil, repository.ErrNotFound
+	}
+	return last, nil
+}
+
+func (m *MockStore) CreateDeployment(ctx context.Context, appID string) (*repository.Deployment, error) {
+	if _, err := m.GetApplication(ctx, appID); err != nil {
+		return nil, err
+	}
+	d := repository.Deployment{
+		ID:            testRedeployID,
+		ApplicationID: appID,
+		Status:        repository.DeploymentStatusPending,
+		CreatedAt:     time.Now(),
+	}
+	m.Deployments = append(m.Deployments, d)
+	return &d, nil
+}
+
+func (m *MockStore) CreateRollback(_ context.Context, appID, targetID string) (*repository.Deployment, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	current, err := m.LastSucceededDeployment(context.Background(), appID)
+	if err != nil {
+		return nil, repository.ErrNothingToRollBack
+	}
+	var target *repository.Deployment
+	for _, d := range m.Deployments {
+		if d.ApplicationID != appID {
+			continue
+		}
+		switch {
+		case targetID != "" && d.ID == targetID:
+			if d.Status != repository.DeploymentStatusSucceeded || d.ID == current.ID {
+				return nil, repository.ErrInvalidRollbackTarget
 			}
-		})
+			target = &d
+		case targetID == "" && d.Status == repository.DeploymentStatusSucceeded && d.CreatedAt.Before(current.CreatedAt) &&
+			(target == nil || d.CreatedAt.After(target.CreatedAt)):
+			target = &d
+		}
+	}
+	if target == nil && targetID != "" {
+		return nil, repository.ErrNotFound
+	}
+	if target == nil {
+		return nil, repository.ErrNothingToRollBack
+	}
+	d := repository.Deployment{
+		ID:            testRollbackID,
+		ApplicationID: appID,
+		ImageURI:      target.ImageURI,
+		Manifest:      target.Manifest,
+		RollbackOf:    target.ID,
+		Status:        repository.DeploymentStatusPending,
+		CreatedAt:     time.Now(),
+	}
+	m.Deployments = append(m.Deployments, d)
+	return &d, nil
+}
+
+func (m *MockStore) CancelDeployment(ctx context.Context, id string) (*repository.Deployment, error) {
+	d, err := m.GetDeployment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Finished() {
+		return nil, repository.ErrDeploymentFinished
 	}
-}
\ No newline at end of file
+	return d, nil
+}
+
+func (m *MockStore) ListDeploymentLogs(_ context.Context, deploymentID string, opts repository.ListOptions) ([]repository.LogLine, string, error) {
+	m.LastListOptions = opts
+	if m.Err != nil {
+		return nil, "", m.Err
+	}
+	after := int64(0)
+	if opts.Cursor != "" {
+		var err error
+		if after, err = strconv.ParseInt(opts.Cursor, 10, 64); err != nil {
+			return nil, "", repository.ErrInvalidCursor
+		}
+	}
+	out := []repository.LogLine{}
+	for _, l := range m.Logs {
+		if l.DeploymentID == deploymentID && l.ID > after {
+			out = append(out, l)
+		}
+	}
+	return out, "", nil
+}
+
+const (
+	testUserID       = "7e6d5c4b-3a29-4180-9f6e-5d4c3b2a1908"
+	testTokenID      = "1f2e3d4c-5b6a-4798-8a7b-6c5d4e3f2a1b"
+	testTeamID       = "4b7f0c1e-6a55-4f43-9d0e-1f8d2c3b4a59"
+	testProjectID    = "0d3c1b6e-2f4a-4e59-8c7d-6b5a4f3e2d1c"
+	testAppID        = "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	testDeploymentID = "5c4b3a29-1807-4f6e-9d5c-4b3a29180f6e"
+	testRollbackID   = "3a291807-4f6e-4d5c-8b3a-29180f6e5d4c"
+	testRedeployID   = "6d5c4b3a-2918-4f6e-8d5c-4b3a29180f6e"
+
+	// testMissingID is a well-formed UUID that matches no seeded record.
+	testMissingID = "00000000-0000-4000-8000-000000000000"
+)
+
+// asTestUser returns a copy of req authenticated as the test user, as the
+// auth middleware would.
+func asTestUser(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithUser(req.Context(), &repository.User{ID: testUserID, Email: "dev@example.com"}))
+}
+
+// withURLParam returns a copy of req carrying a chi route parameter, as the
+// router would set it.
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.RouteContext(req.Context())
+	if rctx == nil {
+		rctx = chi.NewRouteContext()
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+	rctx.URLParams.Add(key, value)
+	return req
+}
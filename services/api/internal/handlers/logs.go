@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"helios/api/internal/repository"
+)
+
+// DeploymentLogsHandler returns the lines the workers logged while building
+// and deploying a deployment, oldest first.
+//
+// By default it returns a page of lines like other lists. With ?follow=true,
+// or if the client accepts text/event-stream, it streams the lines as
+// Server-Sent Events instead, and keeps polling for new ones until the
+// deployment has finished. Each line is a "log" event whose ID is the line's
+// ID, so a reconnecting client resumes after the last line it received with
+// Last-Event-ID. An "end" event with the finished deployment closes the
+// stream.
+func (h *APIHandlers) DeploymentLogsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeDeployment, id, repository.RoleViewer) {
+		return
+	}
+
+	if wantsEventStream(r) {
+		h.streamDeploymentLogs(w, r, id, opts)
+		return
+	}
+
+	lines, next, err := h.Store.ListDeploymentLogs(r.Context(), id, opts)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("deployment_id", id).Msg("Could not list deployment logs")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: lines, NextCursor: next})
+}
+
+// wantsEventStream reports whether the client asked to follow the logs.
+func wantsEventStream(r *http.Request) bool {
+	return r.URL.Query().Get("follow") == "true" ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamDeploymentLogs writes the deployment's lines as Server-Sent Events
+// until the deployment has finished or the client goes away.
+func (h *APIHandlers) streamDeploymentLogs(w http.ResponseWriter, r *http.Request, id string, opts repository.ListOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.Logger.Error().Msg("Response writer does not support streaming")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		opts.Cursor = last
+	}
+	opts.Limit = repository.MaxPageSize
+	ctx := r.Context()
+
+	// The first poll happens before the response starts, so that errors
+	// can still be reported with a status code.
+	deployment, lines, next, err := h.pollDeploymentLogs(ctx, id, opts)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrInvalidCursor):
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	case err != nil:
+		h.Logger.Error().Err(err).Str("deployment_id", id).Msg("Could not list deployment logs")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(h.LogPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, line := range lines {
+			if err := writeEvent(w, strconv.FormatInt(line.ID, 10), "log", line); err != nil {
+				h.Logger.Warn().Err(err).Str("deployment_id", id).Msg("Could not write deployment log line")
+				return
+			}
+		}
+
+		// The lines of the last steps may be stored just after the
+		// deployment has finished, so the stream ends on the first poll
+		// after that without new lines.
+		if len(lines) == 0 && deployment.Finished() {
+			if err := writeEvent(w, "", "end", deployment); err != nil {
+				h.Logger.Warn().Err(err).Str("deployment_id", id).Msg("Could not write end of deployment logs")
+			}
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		if len(lines) > 0 {
+			opts.Cursor = strconv.FormatInt(lines[len(lines)-1].ID, 10)
+		}
+		if next == "" {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		deployment, lines, next, err = h.pollDeploymentLogs(ctx, id, opts)
+		if err != nil {
+			if ctx.Err() == nil {
+				h.Logger.Error().Err(err).Str("deployment_id", id).Msg("Could not list deployment logs")
+			}
+			return
+		}
+	}
+}
+
+// pollDeploymentLogs returns the deployment and its lines after the cursor.
+// The deployment is read first, so the lines stored before it finished are
+// among the lines returned with it.
+func (h *APIHandlers) pollDeploymentLogs(ctx context.Context, id string, opts repository.ListOptions) (*repository.Deployment, []repository.LogLine, string, error) {
+	deployment, err := h.Store.GetDeployment(ctx, id)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	lines, next, err := h.Store.ListDeploymentLogs(ctx, id, opts)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return deployment, lines, next, nil
+}
+
+// writeEvent writes v as a Server-Sent Event with the given ID and name. The
+// ID is omitted if empty.
+func writeEvent(w http.ResponseWriter, id, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
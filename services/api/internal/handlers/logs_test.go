@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedLogs adds two lines to the test deployment and sets its status.
+func seedLogs(store *MockStore, status string) {
+	store.Deployments[0].Status = status
+	store.Logs = []repository.LogLine{
+		{ID: 1, DeploymentID: testDeploymentID, Source: "build", Level: "info", Message: "Checked out commit a1b2c3d4"},
+		{ID: 2, DeploymentID: testDeploymentID, Source: "deploy", Level: "info", Message: "Applied deployment"},
+	}
+}
+
+func TestDeploymentLogsHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		query              string
+		expectedStatusCode int
+		expectedCount      int
+	}{
+		{name: "Successful Case", id: testDeploymentID, expectedStatusCode: http.StatusOK, expectedCount: 2},
+		{name: "Successful Case - After cursor", id: testDeploymentID, query: "?cursor=1", expectedStatusCode: http.StatusOK, expectedCount: 1},
+		{name: "Failure Case - Invalid cursor", id: testDeploymentID, query: "?cursor=abc", expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Malformed ID", id: "dep_1", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = repository.RoleViewer
+			seedLogs(store, repository.DeploymentStatusBuilding)
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/deployments/"+tc.id+"/logs"+tc.query, nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.DeploymentLogsHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedStatusCode == http.StatusOK {
+				var response struct {
+					Items []repository.LogLine `json:"items"`
+				}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+				assert.Len(t, response.Items, tc.expectedCount)
+			}
+		})
+	}
+}
+
+func TestDeploymentLogsHandlerFollow(t *testing.T) {
+	line := func(l repository.LogLine) string {
+		data, err := json.Marshal(l)
+		require.NoError(t, err)
+		return "event: log\ndata: " + string(data) + "\n\n"
+	}
+	store := newMockStore()
+	seedLogs(store, repository.DeploymentStatusSucceeded)
+	first, second := "id: 1\n"+line(store.Logs[0]), "id: 2\n"+line(store.Logs[1])
+
+	testCases := []struct {
+		name               string
+		status             string
+		lastEventID        string
+		accept             string
+		query              string
+		expectedStatusCode int
+		expectedLines      string
+		expectEnd          bool
+	}{
+		{name: "Successful Case - Finished deployment", status: repository.DeploymentStatusSucceeded, query: "?follow=true", expectedStatusCode: http.StatusOK, expectedLines: first + second, expectEnd: true},
+		{name: "Successful Case - Accept header", status: repository.DeploymentStatusFailed, accept: "text/event-stream", expectedStatusCode: http.StatusOK, expectedLines: first + second, expectEnd: true},
+		{name: "Successful Case - Resumed", status: repository.DeploymentStatusSucceeded, lastEventID: "1", query: "?follow=true", expectedStatusCode: http.StatusOK, expectedLines: second, expectEnd: true},
+		{name: "Successful Case - Running deployment", status: repository.DeploymentStatusDeploying, query: "?follow=true", expectedStatusCode: http.StatusOK, expectedLines: first + second},
+		{name: "Failure Case - Invalid Last-Event-ID", status: repository.DeploymentStatusSucceeded, lastEventID: "abc", query: "?follow=true", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			seedLogs(store, tc.status)
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+			handlers.LogPollInterval = time.Millisecond
+
+			// A running deployment is followed until the client goes away.
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			req := asTestUser(withURLParam(httptest.NewRequestWithContext(ctx, http.MethodGet, "/deployments/"+testDeploymentID+"/logs"+tc.query, nil), "id", testDeploymentID))
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			handlers.DeploymentLogsHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedStatusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+			end := ""
+			if tc.expectEnd {
+				deployment := store.Deployments[0]
+				data, err := json.Marshal(&deployment)
+				require.NoError(t, err)
+				end = "event: end\ndata: " + string(data) + "\n\n"
+			}
+			assert.Equal(t, tc.expectedLines+end, rr.Body.String())
+		})
+	}
+}
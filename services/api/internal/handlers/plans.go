@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"helios/api/internal/planner"
+	"helios/api/internal/repository"
+	"helios/pkg/events"
+)
+
+// Planner defines the operations on deployment plans required by the HTTP
+// handlers.
+type Planner interface {
+	Plan(ctx context.Context, request events.PlanRequest) (*events.PlanReply, error)
+}
+
+// PlanApplicationRequest defines the structure for the plan request body.
+// Both fields are optional.
+type PlanApplicationRequest struct {
+	// Manifest is the Heliosfile.yml to plan. If omitted, the manifest of the
+	// last successful deployment is kept; an empty string plans the default
+	// manifest.
+	Manifest *string `json:"manifest"`
+	// ImageURI is the image to plan. If empty, images are not compared.
+	ImageURI string `json:"image_uri"`
+}
+
+// planResponse lists what deploying the planned release would change.
+// BaseDeploymentID is the deployment it is compared with; it is omitted if
+// the application was never deployed successfully.
+type planResponse struct {
+	ApplicationID    string          `json:"application_id"`
+	BaseDeploymentID string          `json:"base_deployment_id,omitempty"`
+	Changes          []events.Change `json:"changes"`
+}
+
+// PlanApplicationHandler returns the changes deploying a manifest and image
+// would make compared with the application's last successful deployment,
+// with its current config vars and secrets. Nothing is deployed.
+func (h *APIHandlers) PlanApplicationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody PlanApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		h.Logger.Warn().Err(err).Msg("Could not decode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleViewer) {
+		return
+	}
+	if h.Planner == nil {
+		http.Error(w, "Plans are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	vars, err := h.Store.GetVariables(r.Context(), id)
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not get variables")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	request := events.PlanRequest{AppID: id, Config: vars.Config, Secrets: vars.Secrets}
+	response := planResponse{ApplicationID: id}
+	base, err := h.Store.LastSucceededDeployment(r.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+	case err != nil:
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not get last succeeded deployment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	default:
+		request.Current = &events.Release{ImageURI: base.ImageURI, Manifest: base.Manifest}
+		request.Desired.Manifest = base.Manifest
+		response.BaseDeploymentID = base.ID
+	}
+	if reqBody.Manifest != nil {
+		request.Desired.Manifest = *reqBody.Manifest
+	}
+	request.Desired.ImageURI = reqBody.ImageURI
+
+	reply, err := h.Planner.Plan(r.Context(), request)
+	if errors.Is(err, planner.ErrUnavailable) {
+		http.Error(w, "Plans are not available: no oal-worker is running", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, planner.ErrTimeout) {
+		http.Error(w, "Plan request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not plan deployment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if reply.Error != "" {
+		http.Error(w, reply.Error, http.StatusUnprocessableEntity)
+		return
+	}
+
+	response.Changes = reply.Changes
+	if response.Changes == nil {
+		response.Changes = []events.Change{}
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
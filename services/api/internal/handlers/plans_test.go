@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"helios/api/internal/planner"
+	"helios/api/internal/repository"
+	"helios/pkg/events"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- Mocks ---
+
+// MockPlanner records the plan requests it receives and answers them with
+// Reply, or fails with Err.
+type MockPlanner struct {
+	Requests []events.PlanRequest
+	Reply    events.PlanReply
+	Err      error
+}
+
+func (m *MockPlanner) Plan(_ context.Context, request events.PlanRequest) (*events.PlanReply, error) {
+	m.Requests = append(m.Requests, request)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &m.Reply, nil
+}
+
+// --- Tests ---
+
+func TestPlanApplicationHandler(t *testing.T) {
+	const (
+		baseManifest = "name: my-app\nservices:\n  web: {}\n"
+		newManifest  = "name: my-app\nservices:\n  web: {}\n  worker: {}\n"
+		baseImage    = "registry.helios.internal/my-app:a1b2c3d4"
+	)
+	baseID := "6d5c4b3a-2918-4f07-8e6d-5c4b3a291807"
+	config, secrets := map[string]string{"LOG_LEVEL": "debug"}, []string{"API_KEY"}
+	changes := []events.Change{
+		{Kind: "service", Name: "web", Action: events.ChangeNone},
+		{Kind: "service", Name: "worker", Action: events.ChangeAdd},
+	}
+
+	testCases := []struct {
+		name               string
+		id                 string
+		body               string
+		neverDeployed      bool
+		role               string
+		planner            *MockPlanner
+		expectedStatusCode int
+		expectedRequest    *events.PlanRequest
+		expectedBaseID     string
+	}{
+		{
+			name:               "Successful Case - New manifest",
+			id:                 testAppID,
+			body:               `{"manifest": "name: my-app\nservices:\n  web: {}\n  worker: {}\n"}`,
+			role:               repository.RoleViewer,
+			planner:            &MockPlanner{Reply: events.PlanReply{Changes: changes}},
+			expectedStatusCode: http.StatusOK,
+			expectedRequest: &events.PlanRequest{
+				AppID:   testAppID,
+				Config:  config,
+				Secrets: secrets,
+				Current: &events.Release{ImageURI: baseImage, Manifest: baseManifest},
+				Desired: events.Release{Manifest: newManifest},
+			},
+			expectedBaseID: baseID,
+		},
+		{
+			name:               "Successful Case - New image keeps the manifest",
+			id:                 testAppID,
+			body:               `{"image_uri": "registry.helios.internal/my-app:e5f6a7b8"}`,
+			planner:            &MockPlanner{Reply: events.PlanReply{Changes: changes}},
+			expectedStatusCode: http.StatusOK,
+			expectedRequest: &events.PlanRequest{
+				AppID:   testAppID,
+				Config:  config,
+				Secrets: secrets,
+				Current: &events.Release{ImageURI: baseImage, Manifest: baseManifest},
+				Desired: events.Release{ImageURI: "registry.helios.internal/my-app:e5f6a7b8", Manifest: baseManifest},
+			},
+			expectedBaseID: baseID,
+		},
+		{
+			name:               "Successful Case - Never deployed",
+			id:                 testAppID,
+			neverDeployed:      true,
+			planner:            &MockPlanner{Reply: events.PlanReply{Changes: changes}},
+			expectedStatusCode: http.StatusOK,
+			expectedRequest:    &events.PlanRequest{AppID: testAppID, Config: config, Secrets: secrets},
+		},
+		{
+			name:               "Failure Case - Invalid Heliosfile",
+			id:                 testAppID,
+			body:               `{"manifest": "services: {}"}`,
+			planner:            &MockPlanner{Reply: events.PlanReply{Error: "invalid Heliosfile.yml: line 1, column 1: name: is required"}},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:               "Failure Case - No oal-worker",
+			id:                 testAppID,
+			planner:            &MockPlanner{Err: planner.ErrUnavailable},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:               "Failure Case - Plan request timed out",
+			id:                 testAppID,
+			planner:            &MockPlanner{Err: planner.ErrTimeout},
+			expectedStatusCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:               "Failure Case - Plan request failed",
+			id:                 testAppID,
+			planner:            &MockPlanner{Err: errors.New("nats: connection closed")},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "Failure Case - Plans not available",
+			id:                 testAppID,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:               "Failure Case - Invalid body",
+			id:                 testAppID,
+			body:               `{"manifest": 1}`,
+			planner:            &MockPlanner{},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Unknown application",
+			id:                 testMissingID,
+			planner:            &MockPlanner{},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			store.Variables = map[string]repository.Variables{testAppID: {Config: config, Secrets: secrets}}
+			if !tc.neverDeployed {
+				store.Deployments = append(store.Deployments,
+					repository.Deployment{ID: testMissingID, ApplicationID: testAppID, Status: repository.DeploymentStatusSucceeded, Manifest: "old", CreatedAt: time.Now().Add(-time.Hour)},
+					repository.Deployment{ID: baseID, ApplicationID: testAppID, Status: repository.DeploymentStatusSucceeded, ImageURI: baseImage, Manifest: baseManifest, CreatedAt: time.Now()},
+				)
+			}
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+			if tc.planner != nil {
+				handlers.Planner = tc.planner
+			}
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPost, "/applications/"+tc.id+"/plan", strings.NewReader(tc.body)), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.PlanApplicationHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedRequest != nil {
+				require.Len(t, tc.planner.Requests, 1)
+				assert.Equal(t, *tc.expectedRequest, tc.planner.Requests[0])
+			}
+			if tc.expectedStatusCode == http.StatusOK {
+				var response planResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+				assert.Equal(t, testAppID, response.ApplicationID)
+				assert.Equal(t, tc.expectedBaseID, response.BaseDeploymentID)
+				assert.Equal(t, changes, response.Changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
+)
+
+// CreateProjectRequest defines the structure for the project creation request body.
+type CreateProjectRequest struct {
+	Name   string `json:"name" validate:"required"`
+	TeamID string `json:"team_id" validate:"required,uuid"`
+}
+
+// CreateProjectHandler creates a new project for an existing team.
+func (h *APIHandlers) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reqBody CreateProjectRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeTeam, reqBody.TeamID, repository.RoleMember) {
+		return
+	}
+
+	project, err := h.Store.CreateProject(r.Context(), reqBody.TeamID, reqBody.Name)
+	if errors.Is(err, repository.ErrTeamNotFound) {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not create project")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("project_id", project.ID).Str("team_id", project.TeamID).Msg("Project created")
+
+	h.writeJSON(w, http.StatusCreated, project)
+}
+
+// ListProjectsHandler returns a page of the projects of the caller's teams,
+// optionally filtered by name.
+func (h *APIHandlers) ListProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	projects, next, err := h.Store.ListProjects(r.Context(), user.ID, opts)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not list projects")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: projects, NextCursor: next})
+}
+
+// GetProjectHandler returns a single project.
+func (h *APIHandlers) GetProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeProject, id, repository.RoleViewer) {
+		return
+	}
+
+	project, err := h.Store.GetProject(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("project_id", id).Msg("Could not get project")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, project)
+}
+
+// ListProjectApplicationsHandler returns a page of a project's applications,
+// optionally filtered by name.
+func (h *APIHandlers) ListProjectApplicationsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Authorizing also resolves the project, so an unknown project is a 404
+	// rather than an empty list.
+	if !h.authorize(w, r, repository.ScopeProject, id, repository.RoleViewer) {
+		return
+	}
+
+	apps, next, err := h.Store.ListApplications(r.Context(), id, opts)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("project_id", id).Msg("Could not list applications")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: apps, NextCursor: next})
+}
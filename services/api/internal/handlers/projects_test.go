@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateProjectHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		method             string
+		body               io.Reader
+		storeError         error
+		expectedStatusCode int
+	}{
+		{
+			name:               "Successful Case - POST",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(`{"name": "My Project", "team_id": "` + testTeamID + `"}`),
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "Failure Case - GET not allowed",
+			method:             http.MethodGet,
+			expectedStatusCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:               "Failure Case - Missing team",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(`{"name": "My Project"}`),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Unknown team",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(`{"name": "My Project", "team_id": "` + testTeamID + `"}`),
+			storeError:         repository.ErrTeamNotFound,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Failure Case - Database error",
+			method:             http.MethodPost,
+			body:               bytes.NewBufferString(`{"name": "My Project", "team_id": "` + testTeamID + `"}`),
+			storeError:         errors.New("connection refused"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Setup
+			testLogger := testutil.NewTestLogger()
+			store := newMockStore()
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testLogger)
+
+			req, err := http.NewRequest(tc.method, "/projects", tc.body)
+			require.NoError(t, err, "Could not create request")
+			req = asTestUser(req)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(handlers.CreateProjectHandler)
+
+			// Execute
+			handler.ServeHTTP(rr, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+
+			if tc.expectedStatusCode == http.StatusCreated {
+				var response map[string]string
+				err = json.Unmarshal(rr.Body.Bytes(), &response)
+				require.NoError(t, err, "Could not parse response body")
+				assert.Equal(t, testProjectID, response["id"], "handler returned unexpected body")
+				assert.Equal(t, testTeamID, response["team_id"], "handler returned unexpected body")
+			}
+		})
+	}
+}
+
+func TestListProjectsHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		query              string
+		storeError         error
+		expectedStatusCode int
+		expectedCount      int
+		expectedOptions    repository.ListOptions
+	}{
+		{
+			name:               "Successful Case",
+			expectedStatusCode: http.StatusOK,
+			expectedCount:      1,
+		},
+		{
+			name:               "Successful Case - Filters and pagination",
+			query:              "?name=other&limit=5&cursor=abc",
+			expectedStatusCode: http.StatusOK,
+			expectedCount:      0,
+			expectedOptions:    repository.ListOptions{Name: "other", Limit: 5, Cursor: "abc"},
+		},
+		{
+			name:               "Failure Case - Invalid limit",
+			query:              "?limit=1000",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Invalid cursor",
+			query:              "?cursor=abc",
+			storeError:         repository.ErrInvalidCursor,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(httptest.NewRequest(http.MethodGet, "/projects"+tc.query, nil))
+			rr := httptest.NewRecorder()
+
+			handlers.ListProjectsHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var response struct {
+					Items []repository.Project `json:"items"`
+				}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+				assert.Len(t, response.Items, tc.expectedCount)
+				assert.Equal(t, tc.expectedOptions, store.LastListOptions)
+			}
+		})
+	}
+}
+
+func TestGetProjectHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testProjectID, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Malformed ID", id: "proj_12345", expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/projects/"+tc.id, nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.GetProjectHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+		})
+	}
+}
+
+func TestListProjectApplicationsHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+		expectedCount      int
+	}{
+		{name: "Successful Case", id: testProjectID, expectedStatusCode: http.StatusOK, expectedCount: 1},
+		{name: "Failure Case - Unknown project", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.NextCursor = "next"
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/projects/"+tc.id+"/applications", nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.ListProjectApplicationsHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var response struct {
+					Items      []repository.Application `json:"items"`
+					NextCursor string                   `json:"next_cursor"`
+				}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+				assert.Len(t, response.Items, tc.expectedCount)
+				assert.Equal(t, "next", response.NextCursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"helios/api/internal/auth"
+	"helios/api/internal/repository"
+)
+
+// CreateTeamRequest defines the structure for the team creation request body.
+type CreateTeamRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// AddMemberRequest defines the structure for the member invitation request body.
+type AddMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=admin member viewer"`
+}
+
+// UpdateMemberRequest defines the structure for the member update request body.
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin member viewer"`
+}
+
+// ListTeamsHandler returns the teams the caller belongs to, with the caller's
+// role in each.
+func (h *APIHandlers) ListTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	teams, err := h.Store.ListTeams(r.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not list teams")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: teams})
+}
+
+// CreateTeamHandler creates a team administered by the caller.
+func (h *APIHandlers) CreateTeamHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody CreateTeamRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+
+	team, err := h.Store.CreateTeam(r.Context(), reqBody.Name, user.ID)
+	if err != nil {
+		h.Logger.Error().Err(err).Msg("Could not create team")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("team_id", team.ID).Str("user_id", user.ID).Msg("Team created")
+
+	h.writeJSON(w, http.StatusCreated, team)
+}
+
+// ListMembersHandler returns the members of a team.
+func (h *APIHandlers) ListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, repository.ScopeTeam, teamID, repository.RoleViewer) {
+		return
+	}
+
+	members, err := h.Store.ListMembers(r.Context(), teamID)
+	if err != nil {
+		h.Logger.Error().Err(err).Str("team_id", teamID).Msg("Could not list members")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, listResponse{Items: members})
+}
+
+// AddMemberHandler invites an email to a team with the given role. The
+// response is the same whether or not the email is registered, so that it
+// cannot be used to find out who has an account.
+func (h *APIHandlers) AddMemberHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody AddMemberRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+	if !h.authorize(w, r, repository.ScopeTeam, teamID, repository.RoleAdmin) {
+		return
+	}
+
+	invitation, err := h.Store.AddMember(r.Context(), teamID, normalizeEmail(reqBody.Email), reqBody.Role)
+	if errors.Is(err, repository.ErrAlreadyMember) {
+		http.Error(w, "User is already a member of the team", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("team_id", teamID).Msg("Could not add member")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("team_id", teamID).Str("role", invitation.Role).Msg("Team member invited")
+
+	h.writeJSON(w, http.StatusAccepted, invitation)
+}
+
+// UpdateMemberHandler changes a team member's role.
+func (h *APIHandlers) UpdateMemberHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID, ok := h.pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	var reqBody UpdateMemberRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+	if !h.authorize(w, r, repository.ScopeTeam, teamID, repository.RoleAdmin) {
+		return
+	}
+
+	member, err := h.Store.UpdateMemberRole(r.Context(), teamID, userID, reqBody.Role)
+	if !h.memberChangeOK(w, err, teamID) {
+		return
+	}
+
+	h.Logger.Info().Str("team_id", teamID).Str("user_id", userID).Str("role", member.Role).Msg("Team member role changed")
+
+	h.writeJSON(w, http.StatusOK, member)
+}
+
+// RemoveMemberHandler removes a member from a team. Admins can remove anyone;
+// other members can only remove themselves.
+func (h *APIHandlers) RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	userID, ok := h.pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	required := repository.RoleAdmin
+	if user, ok := auth.UserFromContext(r.Context()); ok && user.ID == userID {
+		required = repository.RoleViewer
+	}
+	if !h.authorize(w, r, repository.ScopeTeam, teamID, required) {
+		return
+	}
+
+	err := h.Store.RemoveMember(r.Context(), teamID, userID)
+	if !h.memberChangeOK(w, err, teamID) {
+		return
+	}
+
+	h.Logger.Info().Str("team_id", teamID).Str("user_id", userID).Msg("Team member removed")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memberChangeOK maps the error from a membership change onto a response. It
+// returns true if err is nil.
+func (h *APIHandlers) memberChangeOK(w http.ResponseWriter, err error, teamID string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		http.Error(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrLastAdmin):
+		http.Error(w, "A team must keep at least one admin", http.StatusConflict)
+	default:
+		h.Logger.Error().Err(err).Str("team_id", teamID).Msg("Could not change team membership")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+	return false
+}
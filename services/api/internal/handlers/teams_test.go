@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMemberID = "2a3b4c5d-6e7f-4809-9a1b-2c3d4e5f6a7b"
+
+// newStoreWithMembers returns a MockStore whose test team has the caller as
+// admin and one viewer, plus a registered user who is not a member.
+func newStoreWithMembers() *MockStore {
+	store := newMockStore()
+	store.Users = []repository.User{
+		{ID: testUserID, Email: "dev@example.com"},
+		{ID: testMemberID, Email: "viewer@example.com"},
+		{ID: testMissingID, Email: "new@example.com"},
+	}
+	store.Members = []repository.Member{
+		{TeamID: testTeamID, UserID: testUserID, Email: "dev@example.com", Role: repository.RoleAdmin},
+		{TeamID: testTeamID, UserID: testMemberID, Email: "viewer@example.com", Role: repository.RoleViewer},
+	}
+	return store
+}
+
+func TestListTeamsHandler(t *testing.T) {
+	handlers := NewAPIHandlers(newMockStore(), testutil.NewTestLogger())
+
+	req := asTestUser(httptest.NewRequest(http.MethodGet, "/teams", nil))
+	rr := httptest.NewRecorder()
+
+	handlers.ListTeamsHandler(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Items []repository.Team `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), "Could not parse response body")
+	require.Len(t, response.Items, 1)
+	assert.Equal(t, repository.RoleAdmin, response.Items[0].Role)
+}
+
+func TestListMembersHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		teamID             string
+		role               string
+		notMember          bool
+		expectedStatusCode int
+	}{
+		{name: "Successful Case - Viewer can list", teamID: testTeamID, role: repository.RoleViewer, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not a member", teamID: testTeamID, notMember: true, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Unknown team", teamID: testMissingID, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStoreWithMembers()
+			store.Role = tc.role
+			store.NotMember = tc.notMember
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/teams/"+tc.teamID+"/members", nil), "id", tc.teamID))
+			rr := httptest.NewRecorder()
+
+			handlers.ListMembersHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+		})
+	}
+}
+
+func TestAddMemberHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		body               string
+		role               string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", body: `{"email": "New@example.com", "role": "viewer"}`, expectedStatusCode: http.StatusAccepted},
+		{name: "Successful Case - Unknown user looks the same", body: `{"email": "nobody@example.com", "role": "viewer"}`, expectedStatusCode: http.StatusAccepted},
+		{name: "Failure Case - Already a member", body: `{"email": "viewer@example.com", "role": "member"}`, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Unknown role", body: `{"email": "new@example.com", "role": "owner"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Failure Case - Member cannot invite", body: `{"email": "new@example.com", "role": "viewer"}`, role: repository.RoleMember, expectedStatusCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStoreWithMembers()
+			store.Role = tc.role
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPost, "/teams/"+testTeamID+"/members", bytes.NewBufferString(tc.body)), "id", testTeamID))
+			rr := httptest.NewRecorder()
+
+			handlers.AddMemberHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusAccepted {
+				var invitation repository.Invitation
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &invitation), "Could not parse response body")
+				assert.Equal(t, testTeamID, invitation.TeamID)
+				assert.Equal(t, repository.RoleViewer, invitation.Role)
+			}
+		})
+	}
+}
+
+func TestUpdateMemberHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		userID             string
+		body               string
+		storeError         error
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", userID: testMemberID, body: `{"role": "member"}`, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not a member", userID: testMissingID, body: `{"role": "member"}`, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Last admin", userID: testUserID, body: `{"role": "viewer"}`, storeError: repository.ErrLastAdmin, expectedStatusCode: http.StatusConflict},
+		{name: "Failure Case - Missing role", userID: testMemberID, body: `{}`, expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStoreWithMembers()
+			store.Err = tc.storeError
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := httptest.NewRequest(http.MethodPatch, "/teams/"+testTeamID+"/members/"+tc.userID, bytes.NewBufferString(tc.body))
+			req = asTestUser(withURLParam(withURLParam(req, "id", testTeamID), "user_id", tc.userID))
+			rr := httptest.NewRecorder()
+
+			handlers.UpdateMemberHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+		})
+	}
+}
+
+func TestRemoveMemberHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		userID             string
+		role               string
+		expectedStatusCode int
+	}{
+		{name: "Successful Case - Admin removes member", userID: testMemberID, expectedStatusCode: http.StatusNoContent},
+		{name: "Successful Case - Viewer leaves", userID: testUserID, role: repository.RoleViewer, expectedStatusCode: http.StatusNoContent},
+		{name: "Failure Case - Viewer removes someone else", userID: testMemberID, role: repository.RoleViewer, expectedStatusCode: http.StatusForbidden},
+		{name: "Failure Case - Not a member", userID: testMissingID, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStoreWithMembers()
+			store.Role = tc.role
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := httptest.NewRequest(http.MethodDelete, "/teams/"+testTeamID+"/members/"+tc.userID, nil)
+			req = asTestUser(withURLParam(withURLParam(req, "id", testTeamID), "user_id", tc.userID))
+			rr := httptest.NewRecorder()
+
+			handlers.RemoveMemberHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+		})
+	}
+}
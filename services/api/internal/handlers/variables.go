@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"helios/api/internal/repository"
+)
+
+// variableName matches the names of config vars and secrets, which are
+// provided to the services as environment variables.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetVariablesRequest defines the structure for the variables request body.
+// It replaces all the config vars and secrets of the application.
+type SetVariablesRequest struct {
+	Config map[string]string `json:"config"`
+	// Secrets names the secrets; their values are set on the backends.
+	Secrets []string `json:"secrets" validate:"unique"`
+}
+
+// GetVariablesHandler returns the config vars and secret names of an
+// application.
+func (h *APIHandlers) GetVariablesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleViewer) {
+		return
+	}
+
+	vars, err := h.Store.GetVariables(r.Context(), id)
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not get variables")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, vars)
+}
+
+// SetVariablesHandler replaces the config vars and secret names of an
+// application. They apply from its next deployment or rollback.
+func (h *APIHandlers) SetVariablesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !h.authorize(w, r, repository.ScopeApplication, id, repository.RoleMember) {
+		return
+	}
+
+	var reqBody SetVariablesRequest
+	if !h.decodeBody(w, r, &reqBody) {
+		return
+	}
+	for name := range reqBody.Config {
+		if !variableName.MatchString(name) {
+			http.Error(w, "Invalid config var name: "+name, http.StatusBadRequest)
+			return
+		}
+	}
+	for _, name := range reqBody.Secrets {
+		if !variableName.MatchString(name) {
+			http.Error(w, "Invalid secret name: "+name, http.StatusBadRequest)
+			return
+		}
+	}
+
+	vars, err := h.Store.SetVariables(r.Context(), id, repository.Variables{Config: reqBody.Config, Secrets: reqBody.Secrets})
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Logger.Error().Err(err).Str("app_id", id).Msg("Could not set variables")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.Logger.Info().Str("app_id", id).Int("config_vars", len(vars.Config)).Int("secrets", len(vars.Secrets)).Msg("Application variables updated")
+
+	h.writeJSON(w, http.StatusOK, vars)
+}
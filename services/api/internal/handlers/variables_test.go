@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVariablesHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		notMember          bool
+		expectedStatusCode int
+	}{
+		{name: "Successful Case", id: testAppID, expectedStatusCode: http.StatusOK},
+		{name: "Failure Case - Not a team member", id: testAppID, notMember: true, expectedStatusCode: http.StatusNotFound},
+		{name: "Failure Case - Not found", id: testMissingID, expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = repository.RoleViewer
+			store.NotMember = tc.notMember
+			store.Variables = map[string]repository.Variables{testAppID: {Config: map[string]string{"LOG_LEVEL": "debug"}, Secrets: []string{"API_KEY"}}}
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodGet, "/applications/"+tc.id+"/variables", nil), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.GetVariablesHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code")
+			if tc.expectedStatusCode == http.StatusOK {
+				var vars repository.Variables
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &vars), "Could not parse response body")
+				assert.Equal(t, map[string]string{"LOG_LEVEL": "debug"}, vars.Config)
+				assert.Equal(t, []string{"API_KEY"}, vars.Secrets)
+			}
+		})
+	}
+}
+
+func TestSetVariablesHandler(t *testing.T) {
+	testCases := []struct {
+		name               string
+		id                 string
+		body               string
+		role               string
+		expectedStatusCode int
+		expectedVariables  *repository.Variables
+	}{
+		{
+			name:               "Successful Case",
+			id:                 testAppID,
+			body:               `{"config": {"LOG_LEVEL": "debug"}, "secrets": ["API_KEY"]}`,
+			expectedStatusCode: http.StatusOK,
+			expectedVariables:  &repository.Variables{Config: map[string]string{"LOG_LEVEL": "debug"}, Secrets: []string{"API_KEY"}},
+		},
+		{
+			name:               "Successful Case - Remove all",
+			id:                 testAppID,
+			body:               `{}`,
+			expectedStatusCode: http.StatusOK,
+			expectedVariables:  &repository.Variables{Config: map[string]string{}, Secrets: []string{}},
+		},
+		{
+			name:               "Failure Case - Invalid config var name",
+			id:                 testAppID,
+			body:               `{"config": {"LOG-LEVEL": "debug"}}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Invalid secret name",
+			id:                 testAppID,
+			body:               `{"secrets": ["1KEY"]}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Duplicate secret",
+			id:                 testAppID,
+			body:               `{"secrets": ["API_KEY", "API_KEY"]}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Failure Case - Viewer cannot set",
+			id:                 testAppID,
+			body:               `{"config": {"LOG_LEVEL": "debug"}}`,
+			role:               repository.RoleViewer,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Failure Case - Not found",
+			id:                 testMissingID,
+			body:               `{}`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newMockStore()
+			store.Role = tc.role
+			handlers := NewAPIHandlers(store, testutil.NewTestLogger())
+
+			req := asTestUser(withURLParam(httptest.NewRequest(http.MethodPut, "/applications/"+tc.id+"/variables", strings.NewReader(tc.body)), "id", tc.id))
+			rr := httptest.NewRecorder()
+
+			handlers.SetVariablesHandler(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "handler returned wrong status code: %s", rr.Body.String())
+			if tc.expectedVariables != nil {
+				var vars repository.Variables
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &vars), "Could not parse response body")
+				assert.Equal(t, *tc.expectedVariables, vars)
+				assert.Equal(t, *tc.expectedVariables, store.Variables[testAppID])
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package outbox relays events written to the transactional outbox table to
+// NATS, giving at-least-once delivery that stays consistent with the database.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"helios/api/internal/repository"
+	"helios/pkg/config"
+
+	"github.com/rs/zerolog"
+)
+
+// Publisher defines the interface for publishing messages to NATS with a
+// message ID used for deduplication.
+type Publisher interface {
+	PublishMsg(subject, msgID string, data []byte) error
+}
+
+// Store defines the persistence operation required by the relay.
+type Store interface {
+	RelayOutbox(ctx context.Context, limit int, publish func(repository.OutboxMessage) error) (int, error)
+}
+
+// Config holds the configuration for the outbox relay.
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// NewConfig creates a relay configuration from environment variables.
+func NewConfig() Config {
+	return Config{
+		PollInterval: config.GetenvDuration("OUTBOX_POLL_INTERVAL", 1*time.Second),
+		BatchSize:    config.GetenvInt("OUTBOX_BATCH_SIZE", 100),
+	}
+}
+
+// Relay periodically publishes pending outbox messages and marks them as sent.
+type Relay struct {
+	Store     Store
+	Publisher Publisher
+	Logger    zerolog.Logger
+	Config    Config
+}
+
+// NewRelay creates a new Relay configured from the environment.
+func NewRelay(store Store, publisher Publisher, logger zerolog.Logger) *Relay {
+	return &Relay{
+		Store:     store,
+		Publisher: publisher,
+		Logger:    logger,
+		Config:    NewConfig(),
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	r.Logger.Info().Dur("poll_interval", r.Config.PollInterval).Msg("Starting outbox relay")
+
+	ticker := time.NewTicker(r.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.drain(ctx)
+		select {
+		case <-ctx.Done():
+			r.Logger.Info().Msg("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain relays batches until the outbox is empty, an error occurs or ctx is
+// cancelled.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		sent, err := r.Store.RelayOutbox(ctx, r.Config.BatchSize, func(m repository.OutboxMessage) error {
+			// The outbox ID is the event ID, so the stream drops the copy
+			// published again if marking the message as sent fails.
+			return r.Publisher.PublishMsg(m.Subject, m.ID, m.Payload)
+		})
+		if sent > 0 {
+			r.Logger.Debug().Int("count", sent).Msg("Relayed outbox messages")
+		}
+		if err != nil {
+			r.Logger.Error().Err(err).Msg("Failed to relay outbox messages")
+			return
+		}
+		if sent < r.Config.BatchSize {
+			return
+		}
+	}
+}
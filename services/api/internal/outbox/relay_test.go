@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"helios/api/internal/repository"
+	"helios/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// --- Mocks ---
+
+// mockStore serves a fixed queue of outbox messages in batches.
+type mockStore struct {
+	pending []repository.OutboxMessage
+	calls   int
+}
+
+func (m *mockStore) RelayOutbox(_ context.Context, limit int, publish func(repository.OutboxMessage) error) (int, error) {
+	m.calls++
+	sent := 0
+	for len(m.pending) > 0 && sent < limit {
+		if err := publish(m.pending[0]); err != nil {
+			return sent, err
+		}
+		m.pending = m.pending[1:]
+		sent++
+	}
+	return sent, nil
+}
+
+// mockPublisher records published subjects and fails once failAfter messages
+// have been published, if failAfter is positive.
+type mockPublisher struct {
+	subjects  []string
+	failAfter int
+}
+
+func (m *mockPublisher) PublishMsg(subject, _ string, _ []byte) error {
+	if m.failAfter > 0 && len(m.subjects) >= m.failAfter {
+		return errors.New("NATS is down")
+	}
+	m.subjects = append(m.subjects, subject)
+	return nil
+}
+
+// --- Tests ---
+
+func TestRelayDrain(t *testing.T) {
+	messages := func(n int) []repository.OutboxMessage {
+		var out []repository.OutboxMessage
+		for i := 0; i < n; i++ {
+			out = append(out, repository.OutboxMessage{ID: string(rune('a' + i)), Subject: "v1.test"})
+		}
+		return out
+	}
+
+	testCases := []struct {
+		name              string
+		pending           int
+		failAfter         int
+		expectedPublished int
+		expectedCalls     int
+		expectedRemaining int
+	}{
+		{
+			name:              "Drains several full batches",
+			pending:           5,
+			expectedPublished: 5,
+			expectedCalls:     3,
+		},
+		{
+			name:              "Stops on publish error",
+			pending:           5,
+			failAfter:         3,
+			expectedPublished: 3,
+			expectedCalls:     2,
+			expectedRemaining: 2,
+		},
+		{
+			name:          "Empty outbox",
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockStore{pending: messages(tc.pending)}
+			publisher := &mockPublisher{failAfter: tc.failAfter}
+			relay := &Relay{
+				Store:     store,
+				Publisher: publisher,
+				Logger:    testutil.NewTestLogger(),
+				Config:    Config{PollInterval: time.Second, BatchSize: 2},
+			}
+
+			relay.drain(context.Background())
+
+			assert.Len(t, publisher.subjects, tc.expectedPublished)
+			assert.Equal(t, tc.expectedCalls, store.calls)
+			assert.Len(t, store.pending, tc.expectedRemaining)
+		})
+	}
+}
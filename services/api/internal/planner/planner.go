@@ -0,0 +1,66 @@
+// Package planner asks the oal-worker which changes a deployment would make,
+// without deploying anything.
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"helios/pkg/config"
+	"helios/pkg/events"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	// ErrUnavailable is returned when no oal-worker answers plan requests.
+	ErrUnavailable = errors.New("no oal-worker is answering plan requests")
+	// ErrTimeout is returned when the oal-worker does not reply in time.
+	ErrTimeout = errors.New("plan request timed out")
+)
+
+// Client sends plan requests to the oal-worker with NATS request-reply.
+type Client struct {
+	NATS *nats.Conn
+	// Timeout limits how long to wait for a reply.
+	Timeout time.Duration
+}
+
+// NewClient creates a Client on nc, reading its timeout from PLAN_TIMEOUT.
+func NewClient(nc *nats.Conn) *Client {
+	return &Client{
+		NATS:    nc,
+		Timeout: config.GetenvDuration("PLAN_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Plan returns the oal-worker's reply to request. A reply with an Error is
+// not an error of Plan; it means the request could not be planned.
+func (c *Client) Plan(ctx context.Context, request events.PlanRequest) (*events.PlanReply, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	msg, err := c.NATS.RequestWithContext(ctx, events.SubjectPlanRequest, data)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return nil, ErrUnavailable
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+		return nil, ErrTimeout
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plan request failed: %w", err)
+	}
+
+	var reply events.PlanReply
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return nil, fmt.Errorf("failed to decode plan reply: %w", err)
+	}
+	return &reply, nil
+}
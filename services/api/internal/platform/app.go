@@ -8,13 +8,23 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"helios/api/internal/auth"
+	"helios/api/internal/consumer"
 	"helios/api/internal/handlers"
+	"helios/api/internal/outbox"
+	"helios/api/internal/planner"
+	"helios/api/internal/repository"
+	"helios/pkg/bootstrap"
+	"helios/pkg/deadletter"
+	"helios/pkg/events"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 )
 
@@ -23,16 +33,20 @@ type App struct {
 	Logger zerolog.Logger
 	Router *chi.Mux
 	NATS   *nats.Conn
+	JS     jetstream.JetStream
 	DB     *sql.DB
+	Repo   *repository.Repository
 }
 
 // NewApp creates and configures a new application instance.
-func NewApp(logger zerolog.Logger, natsConn *nats.Conn, db *sql.DB) *App {
+func NewApp(logger zerolog.Logger, natsConn *nats.Conn, js jetstream.JetStream, db *sql.DB) *App {
 	app := &App{
 		Logger: logger,
 		Router: chi.NewRouter(),
 		NATS:   natsConn,
+		JS:     js,
 		DB:     db,
+		Repo:   repository.New(db),
 	}
 
 	// Register routes
@@ -46,14 +60,127 @@ func (a *App) registerRoutes() {
 	// The handlers now need access to the app's dependencies, which can be
 	// passed via methods on the App struct or by passing the app itself.
 	// For simplicity, we'll create handlers that have access to the app.
-	apiHandlers := handlers.NewAPIHandlers(a.NATS, a.Logger)
+	apiHandlers := handlers.NewAPIHandlers(a.Repo, a.Logger)
+	apiHandlers.DeadLetters = deadletter.NewStore(a.JS)
+	apiHandlers.Planner = planner.NewClient(a.NATS)
+
+	a.Router.Post("/auth/register", apiHandlers.RegisterHandler)
+	a.Router.Post("/auth/login", apiHandlers.LoginHandler)
+
+	// Every other route requires a valid API token.
+	a.Router.Group(func(r chi.Router) {
+		r.Use(auth.Middleware(a.Repo, a.Logger))
+
+		r.Get("/auth/me", apiHandlers.MeHandler)
+		r.Post("/auth/tokens", apiHandlers.CreateTokenHandler)
+		r.Get("/auth/tokens", apiHandlers.ListTokensHandler)
+		r.Delete("/auth/tokens/{id}", apiHandlers.RevokeTokenHandler)
+
+		r.Route("/teams", func(r chi.Router) {
+			r.Get("/", apiHandlers.ListTeamsHandler)
+			r.Post("/", apiHandlers.CreateTeamHandler)
+			r.Get("/{id}/members", apiHandlers.ListMembersHandler)
+			r.Post("/{id}/members", apiHandlers.AddMemberHandler)
+			r.Patch("/{id}/members/{user_id}", apiHandlers.UpdateMemberHandler)
+			r.Delete("/{id}/members/{user_id}", apiHandlers.RemoveMemberHandler)
+		})
+
+		r.Route("/projects", func(r chi.Router) {
+			r.Post("/", apiHandlers.CreateProjectHandler)
+			r.Get("/", apiHandlers.ListProjectsHandler)
+			r.Get("/{id}", apiHandlers.GetProjectHandler)
+			r.Get("/{id}/applications", apiHandlers.ListProjectApplicationsHandler)
+		})
+
+		r.Route("/applications", func(r chi.Router) {
+			r.Post("/", apiHandlers.CreateApplicationHandler)
+			r.Get("/{id}", apiHandlers.GetApplicationHandler)
+			r.Patch("/{id}", apiHandlers.UpdateApplicationHandler)
+			r.Delete("/{id}", apiHandlers.DeleteApplicationHandler)
+			r.Get("/{id}/variables", apiHandlers.GetVariablesHandler)
+			r.Put("/{id}/variables", apiHandlers.SetVariablesHandler)
+			r.Get("/{id}/deployments", apiHandlers.ListApplicationDeploymentsHandler)
+			r.Post("/{id}/deployments", apiHandlers.CreateDeploymentHandler)
+			r.Post("/{id}/plan", apiHandlers.PlanApplicationHandler)
+			r.Post("/{id}/rollback", apiHandlers.RollbackApplicationHandler)
+		})
+
+		r.Get("/deployments/{id}", apiHandlers.GetDeploymentHandler)
+		r.Post("/deployments/{id}/cancel", apiHandlers.CancelDeploymentHandler)
+		r.Get("/deployments/{id}/logs", apiHandlers.DeploymentLogsHandler)
+
+		// Dead letters are restricted to platform admins.
+		r.Route("/dead-letters", func(r chi.Router) {
+			r.Get("/", apiHandlers.ListDeadLettersHandler)
+			r.Get("/{seq}", apiHandlers.GetDeadLetterHandler)
+			r.Post("/{seq}/replay", apiHandlers.ReplayDeadLetterHandler)
+		})
+	})
+}
+
+// consumerSubscriptions lists the worker events the API consumes, each with
+// its own durable JetStream consumer.
+var consumerSubscriptions = []struct {
+	durable string
+	subject string
+	handle  func(*consumer.Consumer, jetstream.Msg)
+}{
+	{"api-build-started", events.SubjectBuildStarted, (*consumer.Consumer).HandleBuildStarted},
+	{"api-build-succeeded", events.SubjectBuildSucceeded, (*consumer.Consumer).HandleBuildSucceeded},
+	{"api-build-failed", events.SubjectBuildFailed, (*consumer.Consumer).HandleBuildFailed},
+	{"api-deployment-started", events.SubjectDeploymentStarted, (*consumer.Consumer).HandleDeploymentStarted},
+	{"api-deployment-succeeded", events.SubjectDeploymentSucceeded, (*consumer.Consumer).HandleDeploymentSucceeded},
+	{"api-deployment-failed", events.SubjectDeploymentFailed, (*consumer.Consumer).HandleDeploymentFailed},
+	{"api-deployment-cancelled", events.SubjectDeploymentCancelled, (*consumer.Consumer).HandleDeploymentCancelled},
+	{"api-deployment-log", events.SubjectDeploymentLog, (*consumer.Consumer).HandleDeploymentLog},
+}
+
+// consume creates a durable JetStream consumer for subject and processes its
+// messages with handle on a background goroutine tracked by wg, until ctx is
+// cancelled.
+func (a *App) consume(ctx context.Context, wg *sync.WaitGroup, durable, subject string, handle func(jetstream.Msg)) {
+	consumer, err := bootstrap.EnsureConsumer(a.JS, durable, subject)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not create JetStream consumer")
+	}
 
-	a.Router.Post("/projects", apiHandlers.CreateProjectHandler)
-	a.Router.Post("/applications", apiHandlers.CreateApplicationHandler)
+	a.Logger.Info().Str("subject", subject).Str("durable", durable).Msg("Listening for events")
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := bootstrap.Consume(ctx, consumer, a.Logger, handle); err != nil {
+			a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not consume from JetStream")
+		}
+	}()
 }
 
-// Run starts the HTTP server and handles graceful shutdown.
+// Run starts the HTTP server, the outbox relay and the event consumers, and
+// handles graceful shutdown.
 func (a *App) Run() {
+	// Start the outbox relay, which publishes events committed alongside
+	// database writes.
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		outbox.NewRelay(a.Repo, bootstrap.NewPublisher(a.JS), a.Logger).Run(relayCtx)
+	}()
+
+	// Consume worker events that move deployments through their lifecycle.
+	consumeCtx, stopConsumers := context.WithCancel(context.Background())
+	var consumers sync.WaitGroup
+	publisher := bootstrap.NewPublisher(a.JS)
+	for _, sub := range consumerSubscriptions {
+		c := consumer.NewConsumer(a.Repo, a.Logger)
+		c.DeadLetters = deadletter.NewQueue(publisher, sub.durable, a.Logger)
+		if err := c.DeadLetters.WatchMaxDeliveries(consumeCtx, a.NATS, a.JS); err != nil {
+			a.Logger.Fatal().Err(err).Str("durable", sub.durable).Msg("FATAL: Could not watch for messages that run out of deliveries")
+		}
+		a.consume(consumeCtx, &consumers, sub.durable, sub.subject, func(m jetstream.Msg) { sub.handle(c, m) })
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -84,5 +211,12 @@ func (a *App) Run() {
 		a.Logger.Fatal().Err(err).Msg("FATAL: Server forced to shutdown")
 	}
 
+	// Stop background workers once no new requests can arrive.
+	stopRelay()
+	background.Wait()
+
+	stopConsumers()
+	consumers.Wait()
+
 	a.Logger.Info().Msg("Server exiting")
 }
\ No newline at end of file
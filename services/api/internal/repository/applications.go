@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"helios/pkg/events"
+)
+
+// CreateApplicationParams holds the fields required to create an application.
+type CreateApplicationParams struct {
+	ProjectID     string
+	Name          string
+	GitRepository string
+	GitBranch     string
+}
+
+// CreateApplication inserts a new application into an existing project along
+// with its first pending deployment, and queues the matching
+// events.DeploymentRequest in the outbox. All three writes share one
+// transaction. It returns ErrProjectNotFound if the project does not exist.
+func (r *Repository) CreateApplication(ctx context.Context, params CreateApplicationParams) (*Application, *Deployment, error) {
+	query := `
+		INSERT INTO applications (project_id, name, git_repository, git_branch)
+		SELECT id, $2, $3, $4 FROM projects WHERE id = $1
+		RETURNING ` + applicationColumns
+
+	var a *Application
+	var d *Deployment
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		a, err = scanApplication(tx.QueryRowContext(ctx, query, params.ProjectID, params.Name, params.GitRepository, params.GitBranch))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProjectNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert application: %w", err)
+		}
+
+		d, err = insertDeployment(ctx, tx, a.ID)
+		if err != nil {
+			return err
+		}
+
+		return insertOutbox(ctx, tx, events.SubjectDeploymentRequested, events.DeploymentRequest{
+			DeploymentID:  d.ID,
+			AppID:         a.ID,
+			GitRepository: a.GitRepository,
+			GitBranch:     a.GitBranch,
+			Backend:       a.CurrentBackend,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, d, nil
+}
+
+// applicationColumns lists the columns scanned by scanApplication.
+const applicationColumns = `id, project_id, name, git_repository, git_branch, current_backend, created_at`
+
+// scanApplication scans a row selected with applicationColumns.
+func scanApplication(row rowScanner) (*Application, error) {
+	var a Application
+	if err := row.Scan(&a.ID, &a.ProjectID, &a.Name, &a.GitRepository, &a.GitBranch, &a.CurrentBackend, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetApplication returns the application with the given ID, or ErrNotFound.
+func (r *Repository) GetApplication(ctx context.Context, id string) (*Application, error) {
+	query := `SELECT ` + applicationColumns + ` FROM applications WHERE id = $1`
+
+	a, err := scanApplication(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query application: %w", err)
+	}
+	return a, nil
+}
+
+// ListApplications returns a page of a project's applications ordered by
+// creation time, and the cursor for the next page, which is empty on the last
+// page.
+func (r *Repository) ListApplications(ctx context.Context, projectID string, opts ListOptions) ([]Application, string, error) {
+	query := `
+		SELECT ` + applicationColumns + ` FROM applications
+		WHERE project_id = $1
+		  AND ($2 = '' OR name ILIKE $3)
+		  AND ($4::timestamp IS NULL OR (created_at, id) > ($4, $5::uuid))
+		ORDER BY created_at, id
+		LIMIT $6`
+
+	afterTime, afterID, err := opts.keysetArgs()
+	if err != nil {
+		return nil, "", err
+	}
+	limit := opts.limit()
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, opts.Name, opts.namePattern(), afterTime, afterID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query applications: %w", err)
+	}
+	defer rows.Close()
+
+	apps := []Application{}
+	for rows.Next() {
+		a, err := scanApplication(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan application: %w", err)
+		}
+		apps = append(apps, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read applications: %w", err)
+	}
+
+	// One extra row was requested to detect whether another page exists.
+	next := ""
+	if len(apps) > limit {
+		apps = apps[:limit]
+		last := apps[limit-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return apps, next, nil
+}
+
+// UpdateApplicationParams holds the fields of an application that can be
+// changed. Nil fields are left unchanged.
+type UpdateApplicationParams struct {
+	Name           *string
+	GitRepository  *string
+	GitBranch      *string
+	CurrentBackend *string
+}
+
+// UpdateApplication applies params to the application with the given ID and
+// returns the updated record, or ErrNotFound.
+func (r *Repository) UpdateApplication(ctx context.Context, id string, params UpdateApplicationParams) (*Application, error) {
+	query := `
+		UPDATE applications SET
+			name = COALESCE($2, name),
+			git_repository = COALESCE($3, git_repository),
+			git_branch = COALESCE($4, git_branch),
+			current_backend = COALESCE($5, current_backend)
+		WHERE id = $1
+		RETURNING ` + applicationColumns
+
+	a, err := scanApplication(r.db.QueryRowContext(ctx, query, id, params.Name, params.GitRepository, params.GitBranch, params.CurrentBackend))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update application: %w", err)
+	}
+	return a, nil
+}
+
+// DeleteApplication deletes the application with the given ID together with
+// its deployments. It returns ErrNotFound if no application was deleted.
+func (r *Repository) DeleteApplication(ctx context.Context, id string) error {
+	const query = `DELETE FROM applications WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete application: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete application: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
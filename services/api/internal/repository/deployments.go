@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"helios/pkg/events"
+)
+
+// deploymentColumns lists the columns scanned by scanDeployment.
+const deploymentColumns = `id, application_id, COALESCE(git_commit_sha, ''), COALESCE(image_uri, ''), status, COALESCE(failure_reason, ''), COALESCE(manifest, ''), COALESCE(rollback_of::text, ''), created_at, updated_at`
+
+// scanDeployment scans a row selected with deploymentColumns.
+func scanDeployment(row rowScanner) (*Deployment, error) {
+	var d Deployment
+	if err := row.Scan(&d.ID, &d.ApplicationID, &d.GitCommitSHA, &d.ImageURI, &d.Status, &d.FailureReason, &d.Manifest, &d.RollbackOf, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// deploymentPredecessors maps each status to the statuses a deployment may
+// move to it from. Transitions only go forward, but may skip a step if an
+// intermediate event was lost or arrives late; terminal statuses never change.
+var deploymentPredecessors = map[string][]string{
+	DeploymentStatusBuilding:  {DeploymentStatusPending},
+	DeploymentStatusDeploying: {DeploymentStatusPending, DeploymentStatusBuilding},
+	DeploymentStatusSucceeded: {DeploymentStatusPending, DeploymentStatusBuilding, DeploymentStatusDeploying},
+	DeploymentStatusFailed:    {DeploymentStatusPending, DeploymentStatusBuilding, DeploymentStatusDeploying},
+	DeploymentStatusCancelled: {DeploymentStatusPending, DeploymentStatusBuilding, DeploymentStatusDeploying},
+}
+
+// insertDeployment creates a pending deployment for an application.
+func insertDeployment(ctx context.Context, tx *sql.Tx, appID string) (*Deployment, error) {
+	query := `
+		INSERT INTO deployments (application_id)
+		VALUES ($1)
+		RETURNING ` + deploymentColumns
+
+	d, err := scanDeployment(tx.QueryRowContext(ctx, query, appID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert deployment: %w", err)
+	}
+	return d, nil
+}
+
+// GetDeployment returns the deployment with the given ID, or ErrNotFound.
+func (r *Repository) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+	query := `SELECT ` + deploymentColumns + ` FROM deployments WHERE id = $1`
+
+	d, err := scanDeployment(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployment: %w", err)
+	}
+	return d, nil
+}
+
+// LastSucceededDeployment returns the application's newest succeeded
+// deployment, or ErrNotFound if it has none.
+func (r *Repository) LastSucceededDeployment(ctx context.Context, appID string) (*Deployment, error) {
+	return lastSucceededDeployment(ctx, r.db, appID)
+}
+
+// lastSucceededDeployment is LastSucceededDeployment on db, which may be a
+// transaction.
+func lastSucceededDeployment(ctx context.Context, db queryer, appID string) (*Deployment, error) {
+	query := `
+		SELECT ` + deploymentColumns + ` FROM deployments
+		WHERE application_id = $1 AND status = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+
+	d, err := scanDeployment(db.QueryRowContext(ctx, query, appID, DeploymentStatusSucceeded))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last succeeded deployment: %w", err)
+	}
+	return d, nil
+}
+
+// CreateDeployment records a new pending deployment of the head of the
+// application's branch, and queues the matching events.DeploymentRequest in
+// the outbox, in one transaction. The request carries the application's
+// current config vars and secret names, and the release of the last
+// successful deployment, if any, to plan against. It returns ErrNotFound if
+// the application does not exist.
+func (r *Repository) CreateDeployment(ctx context.Context, appID string) (*Deployment, error) {
+	var d *Deployment
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		app, err := scanApplication(tx.QueryRowContext(ctx, `SELECT `+applicationColumns+` FROM applications WHERE id = $1 FOR UPDATE`, appID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query application: %w", err)
+		}
+
+		var previous *events.Release
+		current, err := lastSucceededDeployment(ctx, tx, appID)
+		switch {
+		case err == nil:
+			previous = &events.Release{ImageURI: current.ImageURI, Manifest: current.Manifest}
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+
+		vars, err := variables(ctx, tx, appID)
+		if err != nil {
+			return err
+		}
+
+		d, err = insertDeployment(ctx, tx, appID)
+		if err != nil {
+			return err
+		}
+
+		return insertOutbox(ctx, tx, events.SubjectDeploymentRequested, events.DeploymentRequest{
+			DeploymentID:  d.ID,
+			AppID:         appID,
+			GitRepository: app.GitRepository,
+			GitBranch:     app.GitBranch,
+			Backend:       app.CurrentBackend,
+			Config:        vars.Config,
+			Secrets:       vars.Secrets,
+			Previous:      previous,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CreateRollback records a deployment of the release of the application's
+// deployment targetID again, and queues the matching events.RollbackRequest
+// in the outbox, in one transaction. If targetID is empty, the newest
+// successful deployment before the current one that is not itself a
+// rollback is the target. Only the release is rolled back: the request
+// carries the application's current config vars and secret names.
+//
+// It returns ErrNotFound if the application or target does not exist,
+// ErrNothingToRollBack if there is no default target and
+// ErrInvalidRollbackTarget if the target did not succeed or is the current
+// deployment.
+func (r *Repository) CreateRollback(ctx context.Context, appID, targetID string) (*Deployment, error) {
+	var d *Deployment
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		// Locking the application serializes concurrent rollbacks.
+		app, err := scanApplication(tx.QueryRowContext(ctx, `SELECT `+applicationColumns+` FROM applications WHERE id = $1 FOR UPDATE`, appID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query application: %w", err)
+		}
+
+		current, err := lastSucceededDeployment(ctx, tx, appID)
+		if errors.Is(err, ErrNotFound) {
+			return ErrNothingToRollBack
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := rollbackTarget(ctx, tx, current, targetID)
+		if err != nil {
+			return err
+		}
+
+		vars, err := variables(ctx, tx, appID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO deployments (application_id, git_commit_sha, image_uri, manifest, rollback_of)
+			VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5)
+			RETURNING ` + deploymentColumns
+		d, err = scanDeployment(tx.QueryRowContext(ctx, query, appID, target.GitCommitSHA, target.ImageURI, target.Manifest, target.ID))
+		if err != nil {
+			return fmt.Errorf("failed to insert deployment: %w", err)
+		}
+
+		return insertOutbox(ctx, tx, events.SubjectRollbackRequested, events.RollbackRequest{
+			DeploymentID: d.ID,
+			AppID:        appID,
+			RollbackOf:   target.ID,
+			ImageURI:     target.ImageURI,
+			GitCommitSHA: target.GitCommitSHA,
+			Manifest:     target.Manifest,
+			Backend:      app.CurrentBackend,
+			Config:       vars.Config,
+			Secrets:      vars.Secrets,
+			Previous:     &events.Release{ImageURI: current.ImageURI, Manifest: current.Manifest},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// rollbackTarget returns the deployment a rollback from current deploys
+// again: targetID, or the newest successful deployment before the release
+// current runs if targetID is empty. Rollbacks are skipped then, and a
+// current rollback counts as the deployment it re-deployed, so that rolling
+// back twice goes further back instead of returning to the release rolled
+// back from.
+func rollbackTarget(ctx context.Context, tx *sql.Tx, current *Deployment, targetID string) (*Deployment, error) {
+	if targetID == "" {
+		release := current
+		if current.RollbackOf != "" {
+			var err error
+			release, err = scanDeployment(tx.QueryRowContext(ctx, `SELECT `+deploymentColumns+` FROM deployments WHERE id = $1`, current.RollbackOf))
+			if err != nil {
+				return nil, fmt.Errorf("failed to query rolled back deployment: %w", err)
+			}
+		}
+
+		query := `
+			SELECT ` + deploymentColumns + ` FROM deployments
+			WHERE application_id = $1 AND status = $2 AND rollback_of IS NULL
+				AND (created_at, id) < ($3, $4::uuid)
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1`
+
+		target, err := scanDeployment(tx.QueryRowContext(ctx, query, current.ApplicationID, DeploymentStatusSucceeded, release.CreatedAt, release.ID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNothingToRollBack
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query rollback target: %w", err)
+		}
+		return target, nil
+	}
+
+	query := `SELECT ` + deploymentColumns + ` FROM deployments WHERE id = $1 AND application_id = $2`
+	target, err := scanDeployment(tx.QueryRowContext(ctx, query, targetID, current.ApplicationID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollback target: %w", err)
+	}
+	if target.Status != DeploymentStatusSucceeded || target.ID == current.ID || target.ImageURI == "" {
+		return nil, ErrInvalidRollbackTarget
+	}
+	return target, nil
+}
+
+// CancelDeployment queues an events.CancellationRequest for the deployment in
+// the outbox. The deployment keeps its status until a worker reports that it
+// stopped, since it may finish before the request reaches the workers.
+//
+// It returns ErrNotFound if the deployment does not exist and
+// ErrDeploymentFinished if it has already finished.
+func (r *Repository) CancelDeployment(ctx context.Context, id string) (*Deployment, error) {
+	var d *Deployment
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		d, err = scanDeployment(tx.QueryRowContext(ctx, `SELECT `+deploymentColumns+` FROM deployments WHERE id = $1 FOR UPDATE`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query deployment: %w", err)
+		}
+
+		if d.Finished() {
+			return ErrDeploymentFinished
+		}
+
+		return insertOutbox(ctx, tx, events.SubjectCancellationRequested, events.CancellationRequest{
+			DeploymentID: d.ID,
+			AppID:        d.ApplicationID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListDeployments returns a page of an application's deployments, newest
+// first, and the cursor for the next page, which is empty on the last page.
+// The Name option is ignored.
+func (r *Repository) ListDeployments(ctx context.Context, appID string, opts ListOptions) ([]Deployment, string, error) {
+	query := `
+		SELECT ` + deploymentColumns + ` FROM deployments
+		WHERE application_id = $1
+		  AND ($2::timestamp IS NULL OR (created_at, id) < ($2, $3::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4`
+
+	beforeTime, beforeID, err := opts.keysetArgs()
+	if err != nil {
+		return nil, "", err
+	}
+	limit := opts.limit()
+
+	rows, err := r.db.QueryContext(ctx, query, appID, beforeTime, beforeID, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query deployments: %w", err)
+	}
+	defer rows.Close()
+
+	deployments := []Deployment{}
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan deployment: %w", err)
+		}
+		deployments = append(deployments, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read deployments: %w", err)
+	}
+
+	// One extra row was requested to detect whether another page exists.
+	next := ""
+	if len(deployments) > limit {
+		deployments = deployments[:limit]
+		last := deployments[limit-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return deployments, next, nil
+}
+
+// DeploymentUpdate holds optional fields recorded alongside a status change.
+// Nil fields are left unchanged.
+type DeploymentUpdate struct {
+	GitCommitSHA  *string
+	ImageURI      *string
+	FailureReason *string
+	Manifest      *string
+}
+
+// UpdateDeploymentStatus moves a deployment to status and applies update. It
+// returns ErrNotFound if the deployment does not exist and
+// ErrInvalidTransition if its current status does not allow the change.
+//
+// Events are consumed concurrently, so a build succeeded event may only be
+// handled after the deployment started or even succeeded. The commit, image
+// and manifest in update are therefore recorded even when the transition is
+// rejected, unless the deployment already has them.
+func (r *Repository) UpdateDeploymentStatus(ctx context.Context, id, status string, update DeploymentUpdate) (*Deployment, error) {
+	from, ok := deploymentPredecessors[status]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, status)
+	}
+
+	// The statuses are package constants, so inlining them is safe.
+	query := `
+		UPDATE deployments SET
+			status = $2,
+			git_commit_sha = COALESCE($3, git_commit_sha),
+			image_uri = COALESCE($4, image_uri),
+			failure_reason = COALESCE($5, failure_reason),
+			manifest = COALESCE($6, manifest)
+		WHERE id = $1 AND status IN ('` + strings.Join(from, "', '") + `')
+		RETURNING ` + deploymentColumns
+
+	d, err := scanDeployment(r.db.QueryRowContext(ctx, query, id, status, update.GitCommitSHA, update.ImageURI, update.FailureReason, update.Manifest))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.recordBuildResults(ctx, id, update)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update deployment status: %w", err)
+	}
+	return d, nil
+}
+
+// recordBuildResults fills in the commit, image and manifest of a deployment
+// whose status change was rejected. It returns ErrInvalidTransition, or
+// ErrNotFound if the deployment does not exist.
+func (r *Repository) recordBuildResults(ctx context.Context, id string, update DeploymentUpdate) error {
+	const query = `
+		UPDATE deployments SET
+			git_commit_sha = COALESCE(git_commit_sha, $2),
+			image_uri = COALESCE(image_uri, $3),
+			manifest = COALESCE(manifest, $4)
+		WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, id, update.GitCommitSHA, update.ImageURI, update.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to record build results: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to record build results: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return ErrInvalidTransition
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"helios/pkg/events"
+)
+
+// InsertDeploymentLog stores a line of a deployment's log, published as the
+// event eventID. Lines that were already stored, and lines of deployments
+// that do not exist, are ignored, so redelivered events are harmless.
+func (r *Repository) InsertDeploymentLog(ctx context.Context, eventID string, line events.DeploymentLog) error {
+	query := `
+		INSERT INTO deployment_logs (event_id, deployment_id, source, level, message, logged_at)
+		SELECT $1, id, $3, $4, $5, $6 FROM deployments WHERE id = $2
+		ON CONFLICT (event_id) DO NOTHING`
+
+	if _, err := r.db.ExecContext(ctx, query, eventID, line.DeploymentID, line.Source, line.Level, line.Message, line.Time); err != nil {
+		return fmt.Errorf("failed to insert deployment log line: %w", err)
+	}
+	return nil
+}
+
+// ListDeploymentLogs returns a page of a deployment's log lines in the order
+// they were stored, and the cursor for the next page, which is empty on the
+// last page. The cursor is the ID of the last line returned, so it also
+// resumes a stream of lines. The Name option is ignored.
+func (r *Repository) ListDeploymentLogs(ctx context.Context, deploymentID string, opts ListOptions) ([]LogLine, string, error) {
+	query := `
+		SELECT id, deployment_id, source, level, message, logged_at FROM deployment_logs
+		WHERE deployment_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`
+
+	after := int64(0)
+	if opts.Cursor != "" {
+		var err error
+		if after, err = strconv.ParseInt(opts.Cursor, 10, 64); err != nil || after < 0 {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+	limit := opts.limit()
+
+	rows, err := r.db.QueryContext(ctx, query, deploymentID, after, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query deployment logs: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []LogLine{}
+	for rows.Next() {
+		var l LogLine
+		if err := rows.Scan(&l.ID, &l.DeploymentID, &l.Source, &l.Level, &l.Message, &l.LoggedAt); err != nil {
+			return nil, "", fmt.Errorf("failed to scan deployment log line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read deployment logs: %w", err)
+	}
+
+	// One extra row was requested to detect whether another page exists.
+	next := ""
+	if len(lines) > limit {
+		lines = lines[:limit]
+		next = strconv.FormatInt(lines[limit-1].ID, 10)
+	}
+	return lines, next, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import "time"
+
+// User is a person who can sign in to Helios.
+type User struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"-"`
+	// IsAdmin marks platform operators, who can manage dead letters.
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Team roles as stored in the team_members.role column. Each role includes
+// the permissions of the ones before it: viewers can read, members can also
+// deploy, and admins can also manage the team's members.
+const (
+	RoleViewer = "viewer"
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
+// Team groups users who share projects.
+type Team struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Role is the caller's role in the team, when listing the caller's teams.
+	Role string `json:"role,omitempty"`
+}
+
+// Member is a user's membership of a team.
+type Member struct {
+	TeamID string `json:"team_id"`
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// Invitation is an invitation of an email to join a team with a role. It is
+// what inviting returns, whether or not the email is registered yet.
+type Invitation struct {
+	TeamID string `json:"team_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// APIToken is a long-lived personal access token. The plaintext token is
+// only available when it is created; the database stores its hash.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Project groups applications that belong to the same team.
+type Project struct {
+	ID        string    `json:"id"`
+	TeamID    string    `json:"team_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Application is a deployable unit built from a git repository.
+type Application struct {
+	ID             string    `json:"id"`
+	ProjectID      string    `json:"project_id"`
+	Name           string    `json:"name"`
+	GitRepository  string    `json:"git_repository"`
+	GitBranch      string    `json:"git_branch"`
+	CurrentBackend string    `json:"current_backend"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Variables are the config vars of an application and the names of its
+// secrets, which its Heliosfile refers to as ${config.<NAME>} and
+// ${secrets.<NAME>}. Secret values are not stored; the backends provide them.
+type Variables struct {
+	Config  map[string]string `json:"config"`
+	Secrets []string          `json:"secrets"`
+}
+
+// Deployment statuses as stored in the deployments.status column. A
+// deployment moves forward through pending, building and deploying, and ends
+// in succeeded, failed or cancelled.
+const (
+	DeploymentStatusPending   = "pending"
+	DeploymentStatusBuilding  = "building"
+	DeploymentStatusDeploying = "deploying"
+	DeploymentStatusSucceeded = "succeeded"
+	DeploymentStatusFailed    = "failed"
+	DeploymentStatusCancelled = "cancelled"
+)
+
+// Deployment records a single attempt to build and release an application.
+type Deployment struct {
+	ID            string    `json:"id"`
+	ApplicationID string    `json:"application_id"`
+	GitCommitSHA  string    `json:"git_commit_sha,omitempty"`
+	ImageURI      string    `json:"image_uri,omitempty"`
+	Status        string    `json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	Manifest      string    `json:"manifest,omitempty"`
+	RollbackOf    string    `json:"rollback_of,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Finished reports whether the deployment has reached a terminal status,
+// which never changes.
+func (d *Deployment) Finished() bool {
+	switch d.Status {
+	case DeploymentStatusSucceeded, DeploymentStatusFailed, DeploymentStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// LogLine is a line logged by a worker while it worked on a deployment.
+type LogLine struct {
+	ID           int64     `json:"id"`
+	DeploymentID string    `json:"deployment_id"`
+	Source       string    `json:"source"`
+	Level        string    `json:"level"`
+	Message      string    `json:"message"`
+	LoggedAt     time.Time `json:"logged_at"`
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"helios/pkg/events"
+)
+
+// OutboxMessage is an event waiting to be relayed to NATS.
+type OutboxMessage struct {
+	// ID is also the ID of the event in Payload.
+	ID      string
+	Subject string
+	Payload []byte
+}
+
+// insertOutbox wraps event in an envelope and queues it for publication on
+// subject. The outbox message shares the event's ID. It must be called inside
+// the transaction that writes the state the event describes.
+func insertOutbox[T any](ctx context.Context, tx *sql.Tx, subject string, event T) error {
+	envelope := events.New(subject, event)
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+
+	const query = `INSERT INTO outbox (id, subject, payload) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, envelope.ID, subject, payload); err != nil {
+		return fmt.Errorf("failed to insert outbox message: %w", err)
+	}
+	return nil
+}
+
+// RelayOutbox locks up to limit pending outbox messages, passes each one to
+// publish in creation order and marks it as sent. Processing stops at the
+// first publish error; messages handled before it are still committed. Rows
+// are locked with SKIP LOCKED so several API instances can relay concurrently.
+// It returns the number of messages marked as sent and any publish error.
+func (r *Repository) RelayOutbox(ctx context.Context, limit int, publish func(OutboxMessage) error) (int, error) {
+	const selectQuery = `
+		SELECT id, subject, payload FROM outbox
+		WHERE sent_at IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+	const markQuery = `UPDATE outbox SET sent_at = now() WHERE id = $1`
+
+	sent := 0
+	var publishErr error
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, selectQuery, limit)
+		if err != nil {
+			return fmt.Errorf("failed to query outbox: %w", err)
+		}
+		var messages []OutboxMessage
+		for rows.Next() {
+			var m OutboxMessage
+			if err := rows.Scan(&m.ID, &m.Subject, &m.Payload); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan outbox message: %w", err)
+			}
+			messages = append(messages, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read outbox: %w", err)
+		}
+
+		for _, m := range messages {
+			if err := publish(m); err != nil {
+				// Keep what was already published; the rest is retried later.
+				publishErr = fmt.Errorf("failed to publish outbox message %s: %w", m.ID, err)
+				return nil
+			}
+			if _, err := tx.ExecContext(ctx, markQuery, m.ID); err != nil {
+				return fmt.Errorf("failed to mark outbox message %s as sent: %w", m.ID, err)
+			}
+			sent++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, publishErr
+}
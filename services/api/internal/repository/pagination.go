@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Pagination limits applied to list queries.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions controls filtering and keyset pagination for list queries.
+type ListOptions struct {
+	// Limit is the maximum number of records to return. Values outside
+	// (0, MaxPageSize] are replaced by DefaultPageSize or MaxPageSize.
+	Limit int
+	// Cursor is the opaque next_cursor value returned by a previous page.
+	Cursor string
+	// Name filters records whose name contains the value, case-insensitively.
+	Name string
+}
+
+// uuidPattern matches the IDs cursors point at. Anything else would only fail
+// later, as a cast error in the query.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// cursor is the decoded position of the last record on a page. Lists are
+// ordered by (created_at, id), which is unique and stable under inserts.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// encodeCursor returns the opaque cursor pointing after the given record.
+func encodeCursor(createdAt time.Time, id string) string {
+	data, _ := json.Marshal(cursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// keysetArgs decodes the cursor in opts into nullable query arguments. Both
+// are NULL when no cursor was given, so queries can use a single statement.
+func (opts ListOptions) keysetArgs() (sql.NullTime, sql.NullString, error) {
+	if opts.Cursor == "" {
+		return sql.NullTime{}, sql.NullString{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return sql.NullTime{}, sql.NullString{}, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || !uuidPattern.MatchString(c.ID) {
+		return sql.NullTime{}, sql.NullString{}, ErrInvalidCursor
+	}
+	return sql.NullTime{Time: c.CreatedAt, Valid: true}, sql.NullString{String: c.ID, Valid: true}, nil
+}
+
+// limit returns the page size, clamped to the allowed range.
+func (opts ListOptions) limit() int {
+	switch {
+	case opts.Limit <= 0:
+		return DefaultPageSize
+	case opts.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return opts.Limit
+	}
+}
+
+// namePattern returns an ILIKE pattern matching names that contain the
+// filter, with LIKE wildcards in the filter escaped.
+func (opts ListOptions) namePattern() string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(opts.Name)
+	return "%" + escaped + "%"
+}
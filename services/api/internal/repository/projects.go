@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// projectColumns lists the columns scanned by scanProject.
+const projectColumns = `id, team_id, name, created_at`
+
+// scanProject scans a row selected with projectColumns.
+func scanProject(row rowScanner) (*Project, error) {
+	var p Project
+	if err := row.Scan(&p.ID, &p.TeamID, &p.Name, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateProject inserts a new project owned by the given team. It returns
+// ErrTeamNotFound if the team does not exist.
+func (r *Repository) CreateProject(ctx context.Context, teamID, name string) (*Project, error) {
+	// Selecting from teams makes the insert a no-op for unknown teams, which
+	// lets us distinguish that case without parsing driver-specific errors.
+	query := `
+		INSERT INTO projects (team_id, name)
+		SELECT id, $2 FROM teams WHERE id = $1
+		RETURNING ` + projectColumns
+
+	p, err := scanProject(r.db.QueryRowContext(ctx, query, teamID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTeamNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert project: %w", err)
+	}
+	return p, nil
+}
+
+// GetProject returns the project with the given ID, or ErrNotFound.
+func (r *Repository) GetProject(ctx context.Context, id string) (*Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE id = $1`
+
+	p, err := scanProject(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project: %w", err)
+	}
+	return p, nil
+}
+
+// ListProjects returns a page of the projects owned by the user's teams,
+// ordered by creation time, and the cursor for the next page, which is empty
+// on the last page.
+func (r *Repository) ListProjects(ctx context.Context, userID string, opts ListOptions) ([]Project, string, error) {
+	query := `
+		SELECT ` + projectColumns + ` FROM projects
+		WHERE team_id IN (SELECT team_id FROM team_members WHERE user_id = $6)
+		  AND ($1 = '' OR name ILIKE $2)
+		  AND ($3::timestamp IS NULL OR (created_at, id) > ($3, $4::uuid))
+		ORDER BY created_at, id
+		LIMIT $5`
+
+	afterTime, afterID, err := opts.keysetArgs()
+	if err != nil {
+		return nil, "", err
+	}
+	limit := opts.limit()
+
+	rows, err := r.db.QueryContext(ctx, query, opts.Name, opts.namePattern(), afterTime, afterID, limit+1, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := []Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan project: %w", err)
+		}
+		projects = append(projects, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read projects: %w", err)
+	}
+
+	// One extra row was requested to detect whether another page exists.
+	next := ""
+	if len(projects) > limit {
+		projects = projects[:limit]
+		last := projects[limit-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return projects, next, nil
+}
@@ -0,0 +1,81 @@
+// Package repository provides the PostgreSQL-backed persistence layer for the
+// API service.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the repository. Handlers map these onto HTTP
+// status codes, so callers should compare with errors.Is.
+var (
+	ErrNotFound        = errors.New("record not found")
+	ErrTeamNotFound    = errors.New("team not found")
+	ErrProjectNotFound = errors.New("project not found")
+	ErrEmailTaken      = errors.New("email already registered")
+	ErrAlreadyMember   = errors.New("user is already a team member")
+
+	// ErrLastAdmin is returned when a change would leave a team without an
+	// admin.
+	ErrLastAdmin = errors.New("team must keep at least one admin")
+
+	// ErrInvalidTransition is returned when a deployment cannot move to the
+	// requested status from its current one, for example because an event
+	// was redelivered or arrived out of order.
+	ErrInvalidTransition = errors.New("invalid deployment status transition")
+
+	// ErrNothingToRollBack is returned when an application has no successful
+	// deployment before its current one.
+	ErrNothingToRollBack = errors.New("no earlier successful deployment to roll back to")
+
+	// ErrInvalidRollbackTarget is returned when a rollback names a
+	// deployment that did not succeed or is the current one.
+	ErrInvalidRollbackTarget = errors.New("can only roll back to an earlier successful deployment")
+
+	// ErrDeploymentFinished is returned when cancelling a deployment that
+	// has already succeeded, failed or been cancelled.
+	ErrDeploymentFinished = errors.New("deployment has already finished")
+)
+
+// Repository provides access to the Helios database.
+type Repository struct {
+	db *sql.DB
+}
+
+// New creates a new Repository backed by the given database connection pool.
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// withTx runs fn inside a database transaction, committing if fn succeeds and
+// rolling back otherwise.
+func (r *Repository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,1274 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"helios/pkg/events"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errAny marks test cases that expect an error without caring which one.
+var errAny = errors.New("any error")
+
+// deploymentRequestedArg matches an outbox payload holding a
+// v1.deployment.requested envelope for deploymentID on backend, with config
+// and secrets.
+type deploymentRequestedArg struct {
+	deploymentID string
+	backend      string
+	config       map[string]string
+	secrets      []string
+}
+
+func (a deploymentRequestedArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	e, err := events.Decode[events.DeploymentRequest](data)
+	return err == nil &&
+		e.Type == events.SubjectDeploymentRequested &&
+		e.CorrelationID == e.ID &&
+		e.Data.DeploymentID == a.deploymentID &&
+		e.Data.Backend == a.backend &&
+		maps.Equal(e.Data.Config, a.config) &&
+		slices.Equal(e.Data.Secrets, a.secrets)
+}
+
+// rollbackRequestedArg matches the outbox payload of a RollbackRequest.
+type rollbackRequestedArg struct {
+	deploymentID, rollbackOf, imageURI, previousImageURI string
+	config                                               map[string]string
+	secrets                                              []string
+}
+
+func (a rollbackRequestedArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	e, err := events.Decode[events.RollbackRequest](data)
+	return err == nil &&
+		e.Type == events.SubjectRollbackRequested &&
+		e.Data.DeploymentID == a.deploymentID &&
+		e.Data.RollbackOf == a.rollbackOf &&
+		e.Data.ImageURI == a.imageURI &&
+		e.Data.Previous != nil && e.Data.Previous.ImageURI == a.previousImageURI &&
+		maps.Equal(e.Data.Config, a.config) &&
+		slices.Equal(e.Data.Secrets, a.secrets)
+}
+
+// cancellationRequestedArg matches the outbox payload of a
+// CancellationRequest.
+type cancellationRequestedArg struct {
+	deploymentID, appID string
+}
+
+func (a cancellationRequestedArg) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	e, err := events.Decode[events.CancellationRequest](data)
+	return err == nil &&
+		e.Type == events.SubjectCancellationRequested &&
+		e.Data.DeploymentID == a.deploymentID &&
+		e.Data.AppID == a.appID
+}
+
+// newMockRepository returns a Repository backed by sqlmock.
+// expectVariables expects the config vars and secrets of app-1 to be read.
+func expectVariables(mock sqlmock.Sqlmock, config map[string]string, secrets []string) {
+	configRows := sqlmock.NewRows([]string{"name", "value"})
+	for name, value := range config {
+		configRows.AddRow(name, value)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM application_config_vars WHERE application_id = $1")).
+		WithArgs("app-1").
+		WillReturnRows(configRows)
+	secretRows := sqlmock.NewRows([]string{"name"})
+	for _, name := range secrets {
+		secretRows.AddRow(name)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM application_secrets WHERE application_id = $1")).
+		WithArgs("app-1").
+		WillReturnRows(secretRows)
+}
+
+func newMockRepository(t *testing.T) (*Repository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err, "Could not create sqlmock")
+	t.Cleanup(func() { db.Close() })
+	return New(db), mock
+}
+
+func TestCreateProject(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO projects")).
+					WithArgs("team-1", "My Project").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "team_id", "name", "created_at"}).
+						AddRow("proj-1", "team-1", "My Project", now))
+			},
+		},
+		{
+			name: "Failure Case - Unknown team",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO projects")).
+					WithArgs("team-1", "My Project").
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: ErrTeamNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			project, err := repo.CreateProject(context.Background(), "team-1", "My Project")
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "proj-1", project.ID)
+				assert.Equal(t, "team-1", project.TeamID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateApplication(t *testing.T) {
+	now := time.Now()
+	params := CreateApplicationParams{
+		ProjectID:     "proj-1",
+		Name:          "my-app",
+		GitRepository: "https://github.com/example/my-app.git",
+		GitBranch:     "main",
+	}
+	appColumns := []string{"id", "project_id", "name", "git_repository", "git_branch", "current_backend", "created_at"}
+	deploymentColumns := []string{"id", "application_id", "git_commit_sha", "image_uri", "status", "failure_reason", "manifest", "rollback_of", "created_at", "updated_at"}
+
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO applications")).
+					WithArgs(params.ProjectID, params.Name, params.GitRepository, params.GitBranch).
+					WillReturnRows(sqlmock.NewRows(appColumns).
+						AddRow("app-1", "proj-1", "my-app", params.GitRepository, "main", "docker_compose", now))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO deployments")).
+					WithArgs("app-1").
+					WillReturnRows(sqlmock.NewRows(deploymentColumns).
+						AddRow("dep-1", "app-1", "", "", "pending", "", "", "", now, now))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+					WithArgs(sqlmock.AnyArg(), "v1.deployment.requested", deploymentRequestedArg{deploymentID: "dep-1", backend: "docker_compose"}).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Failure Case - Unknown project",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO applications")).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrProjectNotFound,
+		},
+		{
+			name: "Failure Case - Outbox insert fails",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO applications")).
+					WillReturnRows(sqlmock.NewRows(appColumns).
+						AddRow("app-1", "proj-1", "my-app", params.GitRepository, "main", "docker_compose", now))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO deployments")).
+					WillReturnRows(sqlmock.NewRows(deploymentColumns).
+						AddRow("dep-1", "app-1", "", "", "pending", "", "", "", now, now))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+					WillReturnError(errors.New("disk full"))
+				mock.ExpectRollback()
+			},
+			expectedErr: errAny,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			app, deployment, err := repo.CreateApplication(context.Background(), params)
+
+			switch {
+			case tc.expectedErr == errAny:
+				assert.Error(t, err)
+			case tc.expectedErr != nil:
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "app-1", app.ID)
+				assert.Equal(t, "docker_compose", app.CurrentBackend)
+				assert.Equal(t, "dep-1", deployment.ID)
+				assert.Equal(t, "pending", deployment.Status)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRelayOutbox(t *testing.T) {
+	outboxColumns := []string{"id", "subject", "payload"}
+
+	testCases := []struct {
+		name          string
+		publishErrOn  string
+		setup         func(mock sqlmock.Sqlmock)
+		expectedSent  int
+		expectPubErr  bool
+		expectPublish []string
+	}{
+		{
+			name: "Successful Case - All messages sent",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FROM outbox")).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows(outboxColumns).
+						AddRow("msg-1", "v1.a", []byte(`{}`)).
+						AddRow("msg-2", "v1.b", []byte(`{}`)))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET sent_at")).
+					WithArgs("msg-1").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET sent_at")).
+					WithArgs("msg-2").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedSent:  2,
+			expectPublish: []string{"v1.a", "v1.b"},
+		},
+		{
+			name:         "Partial Case - Publish error stops the batch but keeps progress",
+			publishErrOn: "v1.b",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FROM outbox")).
+					WillReturnRows(sqlmock.NewRows(outboxColumns).
+						AddRow("msg-1", "v1.a", []byte(`{}`)).
+						AddRow("msg-2", "v1.b", []byte(`{}`)).
+						AddRow("msg-3", "v1.c", []byte(`{}`)))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET sent_at")).
+					WithArgs("msg-1").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedSent:  1,
+			expectPubErr:  true,
+			expectPublish: []string{"v1.a", "v1.b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			var published []string
+			sent, err := repo.RelayOutbox(context.Background(), 10, func(m OutboxMessage) error {
+				published = append(published, m.Subject)
+				if m.Subject == tc.publishErrOn {
+					return errors.New("NATS is down")
+				}
+				return nil
+			})
+
+			assert.Equal(t, tc.expectedSent, sent)
+			assert.Equal(t, tc.expectPubErr, err != nil, "unexpected error state: %v", err)
+			assert.Equal(t, tc.expectPublish, published)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListProjects(t *testing.T) {
+	now := time.Now().UTC()
+	columns := []string{"id", "team_id", "name", "created_at"}
+
+	t.Run("Returns a cursor when more rows exist", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(regexp.QuoteMeta("FROM projects")).
+			WithArgs("web", "%web%", sql.NullTime{}, sql.NullString{}, 3, "user-1").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("proj-1", "team-1", "web-a", now).
+				AddRow("5b0e7a9c-3f1d-4c6e-9a2b-8d7f6e5c4b3a", "team-1", "web-b", now.Add(time.Second)).
+				AddRow("proj-3", "team-1", "web-c", now.Add(2*time.Second)))
+
+		projects, next, err := repo.ListProjects(context.Background(), "user-1", ListOptions{Limit: 2, Name: "web"})
+		require.NoError(t, err)
+		assert.Len(t, projects, 2)
+		require.NotEmpty(t, next)
+
+		// The cursor must resume after the last returned row.
+		afterTime, afterID, err := ListOptions{Cursor: next}.keysetArgs()
+		require.NoError(t, err)
+		assert.True(t, afterTime.Time.Equal(now.Add(time.Second)))
+		assert.Equal(t, "5b0e7a9c-3f1d-4c6e-9a2b-8d7f6e5c4b3a", afterID.String)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Last page has no cursor", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+		mock.ExpectQuery(regexp.QuoteMeta("FROM projects")).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("proj-1", "team-1", "web-a", now))
+
+		projects, next, err := repo.ListProjects(context.Background(), "user-1", ListOptions{})
+		require.NoError(t, err)
+		assert.Len(t, projects, 1)
+		assert.Empty(t, next)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rejects a malformed cursor", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+
+		_, _, err := repo.ListProjects(context.Background(), "user-1", ListOptions{Cursor: "not-a-cursor!"})
+		assert.True(t, errors.Is(err, ErrInvalidCursor), "unexpected error: %v", err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rejects a cursor whose ID is not a UUID", func(t *testing.T) {
+		repo, mock := newMockRepository(t)
+
+		tampered := encodeCursor(now, "1' OR '1'='1")
+		_, _, err := repo.ListProjects(context.Background(), "user-1", ListOptions{Cursor: tampered})
+		assert.True(t, errors.Is(err, ErrInvalidCursor), "unexpected error: %v", err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListOptionsNamePattern(t *testing.T) {
+	assert.Equal(t, `%%`, ListOptions{}.namePattern())
+	assert.Equal(t, `%web%`, ListOptions{Name: "web"}.namePattern())
+	assert.Equal(t, `%50\%\_off%`, ListOptions{Name: "50%_off"}.namePattern())
+}
+
+func TestUpdateApplication(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	branch := "develop"
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE applications SET")).
+		WithArgs("app-1", nil, nil, &branch, nil).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.UpdateApplication(context.Background(), "app-1", UpdateApplicationParams{GitBranch: &branch})
+	assert.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteApplication(t *testing.T) {
+	testCases := []struct {
+		name        string
+		affected    int64
+		expectedErr error
+	}{
+		{name: "Successful Case", affected: 1},
+		{name: "Failure Case - Not found", affected: 0, expectedErr: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM applications")).
+				WithArgs("app-1").
+				WillReturnResult(sqlmock.NewResult(0, tc.affected))
+
+			err := repo.DeleteApplication(context.Background(), "app-1")
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetVariables(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	expectVariables(mock, map[string]string{"LOG_LEVEL": "debug"}, []string{"API_KEY", "STRIPE_KEY"})
+
+	vars, err := repo.GetVariables(context.Background(), "app-1")
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "debug"}, vars.Config)
+	assert.Equal(t, []string{"API_KEY", "STRIPE_KEY"}, vars.Secrets)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetVariables(t *testing.T) {
+	vars := Variables{Config: map[string]string{"LOG_LEVEL": "debug", "FEATURE_X": "on"}, Secrets: []string{"STRIPE_KEY", "API_KEY"}}
+
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM applications WHERE id = $1 FOR UPDATE")).
+					WithArgs("app-1").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("app-1"))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM application_config_vars")).
+					WithArgs("app-1").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO application_config_vars")).
+					WithArgs("app-1", "FEATURE_X", "on").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO application_config_vars")).
+					WithArgs("app-1", "LOG_LEVEL", "debug").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM application_secrets")).
+					WithArgs("app-1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO application_secrets")).
+					WithArgs("app-1", "STRIPE_KEY").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO application_secrets")).
+					WithArgs("app-1", "API_KEY").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Failure Case - Unknown application",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM applications WHERE id = $1 FOR UPDATE")).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			stored, err := repo.SetVariables(context.Background(), "app-1", vars)
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, vars.Config, stored.Config)
+				assert.Equal(t, []string{"API_KEY", "STRIPE_KEY"}, stored.Secrets)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateDeploymentStatus(t *testing.T) {
+	now := time.Now()
+	columns := []string{"id", "application_id", "git_commit_sha", "image_uri", "status", "failure_reason", "manifest", "rollback_of", "created_at", "updated_at"}
+	sha, image := "a1b2c3d", "registry.helios.internal/app-1:a1b2c3d"
+	reason := "image pull failed"
+	manifest := "name: my-app\nservices:\n  web: {}\n"
+
+	testCases := []struct {
+		name        string
+		status      string
+		update      DeploymentUpdate
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name:   "Successful Case",
+			status: DeploymentStatusDeploying,
+			update: DeploymentUpdate{GitCommitSHA: &sha, ImageURI: &image, Manifest: &manifest},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE id = $1 AND status IN ('pending', 'building')")).
+					WithArgs("dep-1", DeploymentStatusDeploying, &sha, &image, nil, &manifest).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", sha, image, "deploying", "", manifest, "", now, now))
+			},
+		},
+		{
+			name:   "Successful Case - Failure reason",
+			status: DeploymentStatusFailed,
+			update: DeploymentUpdate{FailureReason: &reason},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE id = $1 AND status IN ('pending', 'building', 'deploying')")).
+					WithArgs("dep-1", DeploymentStatusFailed, nil, nil, &reason, nil).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", sha, image, "failed", reason, "", "", now, now))
+			},
+		},
+		{
+			name:   "Failure Case - Transition rejected",
+			status: DeploymentStatusDeploying,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE deployments SET")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(regexp.QuoteMeta("git_commit_sha = COALESCE(git_commit_sha, $2)")).
+					WithArgs("dep-1", nil, nil, nil).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedErr: ErrInvalidTransition,
+		},
+		{
+			name:   "Failure Case - Transition rejected, build results recorded",
+			status: DeploymentStatusDeploying,
+			update: DeploymentUpdate{GitCommitSHA: &sha, ImageURI: &image, Manifest: &manifest},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE deployments SET")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(regexp.QuoteMeta("git_commit_sha = COALESCE(git_commit_sha, $2)")).
+					WithArgs("dep-1", &sha, &image, &manifest).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedErr: ErrInvalidTransition,
+		},
+		{
+			name:   "Failure Case - Unknown deployment",
+			status: DeploymentStatusDeploying,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE deployments SET")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(regexp.QuoteMeta("git_commit_sha = COALESCE(git_commit_sha, $2)")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedErr: ErrNotFound,
+		},
+		{
+			name:        "Failure Case - Unknown status",
+			status:      DeploymentStatusPending,
+			setup:       func(mock sqlmock.Sqlmock) {},
+			expectedErr: ErrInvalidTransition,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			d, err := repo.UpdateDeploymentStatus(context.Background(), "dep-1", tc.status, tc.update)
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.status, d.Status)
+				assert.Equal(t, image, d.ImageURI)
+				if tc.update.FailureReason != nil {
+					assert.Equal(t, reason, d.FailureReason)
+				}
+				if tc.update.Manifest != nil {
+					assert.Equal(t, manifest, d.Manifest)
+				}
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLastSucceededDeployment(t *testing.T) {
+	now := time.Now()
+	columns := []string{"id", "application_id", "git_commit_sha", "image_uri", "status", "failure_reason", "manifest", "rollback_of", "created_at", "updated_at"}
+	manifest := "name: my-app\nservices:\n  web: {}\n"
+
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2")).
+					WithArgs("app-1", DeploymentStatusSucceeded).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "a1b2c3d", "registry.helios.internal/app-1:a1b2c3d", "succeeded", "", manifest, "", now, now))
+			},
+		},
+		{
+			name: "Failure Case - Never deployed",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2")).WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			d, err := repo.LastSucceededDeployment(context.Background(), "app-1")
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "dep-1", d.ID)
+				assert.Equal(t, manifest, d.Manifest)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateDeployment(t *testing.T) {
+	now := time.Now()
+	appColumns := []string{"id", "project_id", "name", "git_repository", "git_branch", "current_backend", "created_at"}
+	columns := []string{"id", "application_id", "git_commit_sha", "image_uri", "status", "failure_reason", "manifest", "rollback_of", "created_at", "updated_at"}
+
+	expectApplication := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM applications WHERE id = $1 FOR UPDATE")).
+			WithArgs("app-1").
+			WillReturnRows(sqlmock.NewRows(appColumns).AddRow("app-1", "proj-1", "my-app", "https://github.com/example/my-app.git", "main", "k3s", now))
+	}
+	config, secrets := map[string]string{"LOG_LEVEL": "debug"}, []string{"API_KEY"}
+	expectInsert := func(mock sqlmock.Sqlmock) {
+		expectVariables(mock, config, secrets)
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO deployments")).
+			WithArgs("app-1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-2", "app-1", "", "", "pending", "", "", "", now, now))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+			WithArgs(sqlmock.AnyArg(), events.SubjectDeploymentRequested, deploymentRequestedArg{deploymentID: "dep-2", backend: "k3s", config: config, secrets: secrets}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case - Deployed before",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2")).
+					WithArgs("app-1", DeploymentStatusSucceeded).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "a1b2c3d", "registry.helios.internal/app-1:a1b2c3d", "succeeded", "", "name: my-app\n", "", now, now))
+				expectInsert(mock)
+			},
+		},
+		{
+			name: "Successful Case - First deployment",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2")).
+					WillReturnError(sql.ErrNoRows)
+				expectInsert(mock)
+			},
+		},
+		{
+			name: "Failure Case - Unknown application",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FROM applications WHERE id = $1 FOR UPDATE")).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			d, err := repo.CreateDeployment(context.Background(), "app-1")
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "dep-2", d.ID)
+				assert.Equal(t, DeploymentStatusPending, d.Status)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateRollback(t *testing.T) {
+	now := time.Now()
+	earlier, later := now.Add(-time.Hour), now.Add(time.Hour)
+	appColumns := []string{"id", "project_id", "name", "git_repository", "git_branch", "current_backend", "created_at"}
+	columns := []string{"id", "application_id", "git_commit_sha", "image_uri", "status", "failure_reason", "manifest", "rollback_of", "created_at", "updated_at"}
+	oldImage, newImage := "registry.helios.internal/app-1:a1b2c3d", "registry.helios.internal/app-1:e4f5a6b"
+	manifest := "name: my-app\nservices:\n  web: {}\n"
+
+	expectApplication := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM applications WHERE id = $1 FOR UPDATE")).
+			WithArgs("app-1").
+			WillReturnRows(sqlmock.NewRows(appColumns).AddRow("app-1", "proj-1", "my-app", "https://github.com/example/my-app.git", "main", "k3s", now))
+	}
+	expectCurrent := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2\n")).
+			WithArgs("app-1", DeploymentStatusSucceeded).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-2", "app-1", "e4f5a6b", newImage, "succeeded", "", manifest, "", now, now))
+	}
+	config := map[string]string{"LOG_LEVEL": "debug"}
+	expectInsert := func(mock sqlmock.Sqlmock) {
+		expectVariables(mock, config, nil)
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO deployments (application_id, git_commit_sha, image_uri, manifest, rollback_of)")).
+			WithArgs("app-1", "a1b2c3d", oldImage, manifest, "dep-1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-3", "app-1", "a1b2c3d", oldImage, "pending", "", manifest, "dep-1", now, now))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+			WithArgs(sqlmock.AnyArg(), events.SubjectRollbackRequested, rollbackRequestedArg{deploymentID: "dep-3", rollbackOf: "dep-1", imageURI: oldImage, previousImageURI: newImage, config: config}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	testCases := []struct {
+		name        string
+		targetID    string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case - Previous deployment",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				expectCurrent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("AND rollback_of IS NULL\n\t\t\t\tAND (created_at, id) < ($3, $4::uuid)")).
+					WithArgs("app-1", DeploymentStatusSucceeded, now, "dep-2").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "a1b2c3d", oldImage, "succeeded", "", manifest, "", now, now))
+				expectInsert(mock)
+			},
+		},
+		{
+			name: "Successful Case - Roll back twice",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				// dep-4 rolled back from dep-2 to dep-3, so rolling back again
+				// goes to dep-1, before dep-3, rather than to dep-2.
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2\n")).
+					WithArgs("app-1", DeploymentStatusSucceeded).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-4", "app-1", "c7d8e9f", newImage, "succeeded", "", manifest, "dep-3", later, later))
+				mock.ExpectQuery(regexp.QuoteMeta("FROM deployments WHERE id = $1")).
+					WithArgs("dep-3").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-3", "app-1", "c7d8e9f", newImage, "succeeded", "", manifest, "", earlier, earlier))
+				mock.ExpectQuery(regexp.QuoteMeta("AND rollback_of IS NULL\n\t\t\t\tAND (created_at, id) < ($3, $4::uuid)")).
+					WithArgs("app-1", DeploymentStatusSucceeded, earlier, "dep-3").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "a1b2c3d", oldImage, "succeeded", "", manifest, "", earlier, earlier))
+				expectInsert(mock)
+			},
+		},
+		{
+			name:     "Successful Case - Named deployment",
+			targetID: "dep-1",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				expectCurrent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("FROM deployments WHERE id = $1 AND application_id = $2")).
+					WithArgs("dep-1", "app-1").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "a1b2c3d", oldImage, "succeeded", "", manifest, "", now, now))
+				expectInsert(mock)
+			},
+		},
+		{
+			name: "Failure Case - Unknown application",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FROM applications WHERE id = $1 FOR UPDATE")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNotFound,
+		},
+		{
+			name: "Failure Case - Never deployed",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE application_id = $1 AND status = $2\n")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNothingToRollBack,
+		},
+		{
+			name: "Failure Case - No earlier deployment",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				expectCurrent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("AND rollback_of IS NULL\n\t\t\t\tAND (created_at, id) < ($3, $4::uuid)")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNothingToRollBack,
+		},
+		{
+			name:     "Failure Case - Failed deployment",
+			targetID: "dep-1",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				expectCurrent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("FROM deployments WHERE id = $1 AND application_id = $2")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "a1b2c3d", oldImage, "failed", "boom", manifest, "", now, now))
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrInvalidRollbackTarget,
+		},
+		{
+			name:     "Failure Case - Current deployment",
+			targetID: "dep-2",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				expectCurrent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("FROM deployments WHERE id = $1 AND application_id = $2")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-2", "app-1", "e4f5a6b", newImage, "succeeded", "", manifest, "", now, now))
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrInvalidRollbackTarget,
+		},
+		{
+			name:     "Failure Case - Deployment of another application",
+			targetID: "dep-9",
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApplication(mock)
+				expectCurrent(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("FROM deployments WHERE id = $1 AND application_id = $2")).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			d, err := repo.CreateRollback(context.Background(), "app-1", tc.targetID)
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "dep-3", d.ID)
+				assert.Equal(t, "dep-1", d.RollbackOf)
+				assert.Equal(t, oldImage, d.ImageURI)
+				assert.Equal(t, DeploymentStatusPending, d.Status)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCancelDeployment(t *testing.T) {
+	now := time.Now()
+	columns := []string{"id", "application_id", "git_commit_sha", "image_uri", "status", "failure_reason", "manifest", "rollback_of", "created_at", "updated_at"}
+
+	testCases := []struct {
+		name        string
+		status      string
+		missing     bool
+		expectedErr error
+	}{
+		{name: "Successful Case - Building", status: DeploymentStatusBuilding},
+		{name: "Successful Case - Pending", status: DeploymentStatusPending},
+		{name: "Failure Case - Succeeded", status: DeploymentStatusSucceeded, expectedErr: ErrDeploymentFinished},
+		{name: "Failure Case - Already cancelled", status: DeploymentStatusCancelled, expectedErr: ErrDeploymentFinished},
+		{name: "Failure Case - Unknown deployment", missing: true, expectedErr: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(regexp.QuoteMeta("FROM deployments WHERE id = $1 FOR UPDATE")).WithArgs("dep-1")
+			if tc.missing {
+				query.WillReturnError(sql.ErrNoRows)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows(columns).AddRow("dep-1", "app-1", "", "", tc.status, "", "", "", now, now))
+			}
+			if tc.expectedErr != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+					WithArgs(sqlmock.AnyArg(), events.SubjectCancellationRequested, cancellationRequestedArg{deploymentID: "dep-1", appID: "app-1"}).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			d, err := repo.CancelDeployment(context.Background(), "dep-1")
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.status, d.Status, "the status should only change once a worker stops")
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestInsertDeploymentLog(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	now := time.Now()
+	line := events.DeploymentLog{DeploymentID: "dep-1", AppID: "app-1", Source: events.LogSourceBuild, Level: "info", Message: "Checked out commit a1b2c3d", Time: now}
+
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (event_id) DO NOTHING")).
+		WithArgs("event-1", "dep-1", events.LogSourceBuild, "info", "Checked out commit a1b2c3d", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, repo.InsertDeploymentLog(context.Background(), "event-1", line))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListDeploymentLogs(t *testing.T) {
+	now := time.Now()
+	columns := []string{"id", "deployment_id", "source", "level", "message", "logged_at"}
+
+	testCases := []struct {
+		name          string
+		opts          ListOptions
+		expectedAfter int64
+		rows          int
+		expectedLen   int
+		expectedNext  string
+		expectedErr   error
+	}{
+		{name: "Successful Case - First page", opts: ListOptions{Limit: 2}, rows: 3, expectedLen: 2, expectedNext: "2"},
+		{name: "Successful Case - Last page", opts: ListOptions{Limit: 2, Cursor: "2"}, expectedAfter: 2, rows: 1, expectedLen: 1},
+		{name: "Failure Case - Invalid cursor", opts: ListOptions{Cursor: "abc"}, expectedErr: ErrInvalidCursor},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			if tc.expectedErr == nil {
+				rows := sqlmock.NewRows(columns)
+				for i := 1; i <= tc.rows; i++ {
+					rows.AddRow(tc.expectedAfter+int64(i), "dep-1", "build", "info", "line", now)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("WHERE deployment_id = $1 AND id > $2")).
+					WithArgs("dep-1", tc.expectedAfter, tc.opts.Limit+1).
+					WillReturnRows(rows)
+			}
+
+			lines, next, err := repo.ListDeploymentLogs(context.Background(), "dep-1", tc.opts)
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, lines, tc.expectedLen)
+				assert.Equal(t, tc.expectedNext, next)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	now := time.Now()
+	userColumns := []string{"id", "email", "password_hash", "is_admin", "created_at"}
+
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users")).
+					WithArgs("dev@example.com", "hash").
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow("user-1", "dev@example.com", "hash", false, now))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO teams")).
+					WithArgs("dev@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at"}).AddRow("team-1", "dev@example.com", now))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO team_members")).
+					WithArgs("team-1", "user-1", RoleAdmin).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM team_invitations")).
+					WithArgs("dev@example.com", "user-1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Failure Case - Email taken",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users")).
+					WithArgs("dev@example.com", "hash").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrEmailTaken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			user, team, err := repo.CreateUser(context.Background(), "dev@example.com", "hash")
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "user-1", user.ID)
+				assert.Equal(t, "team-1", team.ID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetUserByTokenHash(t *testing.T) {
+	repo, mock := newMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE api_tokens SET last_used_at")).
+		WithArgs("deadbeef").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.GetUserByTokenHash(context.Background(), "deadbeef")
+	assert.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAPITokens(t *testing.T) {
+	now := time.Now()
+	repo, mock := newMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM api_tokens")).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "last_used_at"}).
+			AddRow("token-2", "user-1", "ci", now, nil).
+			AddRow("token-1", "user-1", "login", now.Add(-time.Hour), now))
+
+	tokens, err := repo.ListAPITokens(context.Background(), "user-1")
+
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Nil(t, tokens[0].LastUsedAt)
+	require.NotNil(t, tokens[1].LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAPIToken(t *testing.T) {
+	testCases := []struct {
+		name        string
+		affected    int64
+		expectedErr error
+	}{
+		{name: "Successful Case", affected: 1},
+		{name: "Failure Case - Not the user's token", affected: 0, expectedErr: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_tokens")).
+				WithArgs("token-1", "user-1").
+				WillReturnResult(sqlmock.NewResult(0, tc.affected))
+
+			err := repo.DeleteAPIToken(context.Background(), "user-1", "token-1")
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMemberRole(t *testing.T) {
+	testCases := []struct {
+		name         string
+		scope        Scope
+		setup        func(mock sqlmock.Sqlmock)
+		expectedRole string
+		expectedErr  error
+	}{
+		{
+			name:  "Successful Case",
+			scope: ScopeDeployment,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM deployments d")).
+					WithArgs("dep-1", "user-1").
+					WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(RoleViewer))
+			},
+			expectedRole: RoleViewer,
+		},
+		{
+			name:  "Failure Case - Not a member",
+			scope: ScopeProject,
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM projects p")).
+					WithArgs("dep-1", "user-1").
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: ErrNotFound,
+		},
+		{
+			name:        "Failure Case - Unknown scope",
+			scope:       Scope("cluster"),
+			setup:       func(mock sqlmock.Sqlmock) {},
+			expectedErr: errAny,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			role, err := repo.MemberRole(context.Background(), tc.scope, "dep-1", "user-1")
+
+			switch {
+			case tc.expectedErr == errAny:
+				assert.Error(t, err)
+			case tc.expectedErr != nil:
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedRole, role)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	testCases := []struct {
+		name        string
+		admins      []string
+		expectedErr error
+	}{
+		{name: "Successful Case - Another admin remains", admins: []string{"user-1", "user-2"}},
+		{name: "Successful Case - Removing a non-admin", admins: []string{"user-2"}},
+		{name: "Failure Case - Last admin", admins: []string{"user-1"}, expectedErr: ErrLastAdmin},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			rows := sqlmock.NewRows([]string{"user_id"})
+			for _, id := range tc.admins {
+				rows.AddRow(id)
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).
+				WithArgs("team-1", RoleAdmin).
+				WillReturnRows(rows)
+			if tc.expectedErr == nil {
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM team_members")).
+					WithArgs("team-1", "user-1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			err := repo.RemoveMember(context.Background(), "team-1", "user-1")
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	testCases := []struct {
+		name        string
+		setup       func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Successful Case - Registered user",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users")).
+					WithArgs("dev@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-2"))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO team_members")).
+					WithArgs("team-1", "user-2", RoleViewer).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Successful Case - Unregistered email is invited",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users")).
+					WithArgs("dev@example.com").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO team_invitations")).
+					WithArgs("team-1", "dev@example.com", RoleViewer).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Failure Case - Already a member",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users")).
+					WithArgs("dev@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-2"))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO team_members")).
+					WithArgs("team-1", "user-2", RoleViewer).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrAlreadyMember,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock := newMockRepository(t)
+			tc.setup(mock)
+
+			invitation, err := repo.AddMember(context.Background(), "team-1", "dev@example.com", RoleViewer)
+
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error: %v", err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, &Invitation{TeamID: "team-1", Email: "dev@example.com", Role: RoleViewer}, invitation)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Scope identifies the kind of resource whose owning team MemberRole looks up.
+type Scope string
+
+// Resource scopes accepted by MemberRole.
+const (
+	ScopeTeam        Scope = "team"
+	ScopeProject     Scope = "project"
+	ScopeApplication Scope = "application"
+	ScopeDeployment  Scope = "deployment"
+)
+
+// memberRoleQueries resolve a resource ID ($1) and a user ID ($2) to the
+// user's role in the team that owns the resource.
+var memberRoleQueries = map[Scope]string{
+	ScopeTeam: `
+		SELECT tm.role FROM team_members tm
+		WHERE tm.team_id = $1 AND tm.user_id = $2`,
+	ScopeProject: `
+		SELECT tm.role FROM projects p
+		JOIN team_members tm ON tm.team_id = p.team_id
+		WHERE p.id = $1 AND tm.user_id = $2`,
+	ScopeApplication: `
+		SELECT tm.role FROM applications a
+		JOIN projects p ON p.id = a.project_id
+		JOIN team_members tm ON tm.team_id = p.team_id
+		WHERE a.id = $1 AND tm.user_id = $2`,
+	ScopeDeployment: `
+		SELECT tm.role FROM deployments d
+		JOIN applications a ON a.id = d.application_id
+		JOIN projects p ON p.id = a.project_id
+		JOIN team_members tm ON tm.team_id = p.team_id
+		WHERE d.id = $1 AND tm.user_id = $2`,
+}
+
+// MemberRole returns the user's role in the team that owns the resource
+// identified by scope and id. It returns ErrNotFound both when the resource
+// does not exist and when the user is not a member of its team, so callers
+// do not reveal the existence of other teams' resources.
+func (r *Repository) MemberRole(ctx context.Context, scope Scope, id, userID string) (string, error) {
+	query, ok := memberRoleQueries[scope]
+	if !ok {
+		return "", fmt.Errorf("unknown scope %q", scope)
+	}
+
+	var role string
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s role: %w", scope, err)
+	}
+	return role, nil
+}
+
+// insertTeam creates a team administered by the given user.
+func insertTeam(ctx context.Context, tx *sql.Tx, name, adminID string) (*Team, error) {
+	const teamQuery = `INSERT INTO teams (name) VALUES ($1) RETURNING id, name, created_at`
+	const memberQuery = `INSERT INTO team_members (team_id, user_id, role) VALUES ($1, $2, $3)`
+
+	var t Team
+	if err := tx.QueryRowContext(ctx, teamQuery, name).Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to insert team: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, memberQuery, t.ID, adminID, RoleAdmin); err != nil {
+		return nil, fmt.Errorf("failed to insert team membership: %w", err)
+	}
+	t.Role = RoleAdmin
+	return &t, nil
+}
+
+// CreateTeam creates a team administered by the given user.
+func (r *Repository) CreateTeam(ctx context.Context, name, adminID string) (*Team, error) {
+	var t *Team
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		t, err = insertTeam(ctx, tx, name, adminID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListTeams returns the teams the user belongs to, with the user's role in
+// each, ordered by name.
+func (r *Repository) ListTeams(ctx context.Context, userID string) ([]Team, error) {
+	const query = `
+		SELECT t.id, t.name, t.created_at, tm.role
+		FROM teams t JOIN team_members tm ON tm.team_id = t.id
+		WHERE tm.user_id = $1
+		ORDER BY t.name, t.id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query teams: %w", err)
+	}
+	defer rows.Close()
+
+	teams := []Team{}
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt, &t.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan team: %w", err)
+		}
+		teams = append(teams, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read teams: %w", err)
+	}
+	return teams, nil
+}
+
+// memberColumns lists the columns scanned by scanMember. Queries selecting
+// them must join users as u and team_members as tm.
+const memberColumns = `tm.team_id, tm.user_id, u.email, tm.role`
+
+// scanMember scans a row selected with memberColumns.
+func scanMember(row rowScanner) (*Member, error) {
+	var m Member
+	if err := row.Scan(&m.TeamID, &m.UserID, &m.Email, &m.Role); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListMembers returns the members of a team ordered by email.
+func (r *Repository) ListMembers(ctx context.Context, teamID string) ([]Member, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM team_members tm JOIN users u ON u.id = tm.user_id
+		WHERE tm.team_id = $1
+		ORDER BY u.email`
+
+	rows, err := r.db.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read members: %w", err)
+	}
+	return members, nil
+}
+
+// AddMember invites email to a team with role. A registered user joins the
+// team straight away; for an email that is not registered yet, the
+// invitation is kept until it is, and replaces any earlier invitation to the
+// team. Both return the same Invitation, so that inviting does not reveal
+// who has an account. It returns ErrAlreadyMember if the user already
+// belongs to the team.
+func (r *Repository) AddMember(ctx context.Context, teamID, email, role string) (*Invitation, error) {
+	const userQuery = `SELECT id FROM users WHERE email = $1`
+	const memberQuery = `
+		INSERT INTO team_members (team_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (team_id, user_id) DO NOTHING`
+	const invitationQuery = `
+		INSERT INTO team_invitations (team_id, email, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (team_id, email) DO UPDATE SET role = EXCLUDED.role`
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var userID string
+		err := tx.QueryRowContext(ctx, userQuery, email).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := tx.ExecContext(ctx, invitationQuery, teamID, email, role); err != nil {
+				return fmt.Errorf("failed to insert team invitation: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query user: %w", err)
+		}
+
+		res, err := tx.ExecContext(ctx, memberQuery, teamID, userID, role)
+		if err != nil {
+			return fmt.Errorf("failed to insert team membership: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to insert team membership: %w", err)
+		}
+		if n == 0 {
+			return ErrAlreadyMember
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Invitation{TeamID: teamID, Email: email, Role: role}, nil
+}
+
+// acceptInvitations makes a user who just registered a member of the teams
+// their email was invited to.
+func acceptInvitations(ctx context.Context, tx *sql.Tx, email, userID string) error {
+	const query = `
+		WITH accepted AS (
+			DELETE FROM team_invitations WHERE email = $1
+			RETURNING team_id, role
+		)
+		INSERT INTO team_members (team_id, user_id, role)
+		SELECT team_id, $2, role FROM accepted
+		ON CONFLICT (team_id, user_id) DO NOTHING`
+
+	if _, err := tx.ExecContext(ctx, query, email, userID); err != nil {
+		return fmt.Errorf("failed to accept team invitations: %w", err)
+	}
+	return nil
+}
+
+// UpdateMemberRole changes a member's role. It returns ErrNotFound if the
+// user is not a member of the team and ErrLastAdmin if the change would
+// demote the team's only admin.
+func (r *Repository) UpdateMemberRole(ctx context.Context, teamID, userID, role string) (*Member, error) {
+	query := `
+		UPDATE team_members tm SET role = $3
+		FROM users u
+		WHERE u.id = tm.user_id AND tm.team_id = $1 AND tm.user_id = $2
+		RETURNING ` + memberColumns
+
+	var m *Member
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		if role != RoleAdmin {
+			if err := ensureOtherAdmin(ctx, tx, teamID, userID); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		m, err = scanMember(tx.QueryRowContext(ctx, query, teamID, userID, role))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update member: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RemoveMember removes a user from a team. It returns ErrNotFound if the user
+// is not a member and ErrLastAdmin if the user is the team's only admin.
+func (r *Repository) RemoveMember(ctx context.Context, teamID, userID string) error {
+	const query = `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`
+
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if err := ensureOtherAdmin(ctx, tx, teamID, userID); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, query, teamID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete member: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to delete member: %w", err)
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// ensureOtherAdmin returns ErrLastAdmin if userID is the team's only admin.
+// The admin rows are locked so concurrent changes cannot both pass the check.
+func ensureOtherAdmin(ctx context.Context, tx *sql.Tx, teamID, userID string) error {
+	const query = `
+		SELECT user_id FROM team_members
+		WHERE team_id = $1 AND role = $2
+		FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, teamID, RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("failed to query team admins: %w", err)
+	}
+	defer rows.Close()
+
+	isAdmin, others := false, 0
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan team admin: %w", err)
+		}
+		if id == userID {
+			isAdmin = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read team admins: %w", err)
+	}
+
+	if isAdmin && others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
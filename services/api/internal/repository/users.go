@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// userColumns lists the columns scanned by scanUser.
+const userColumns = `id, email, password_hash, is_admin, created_at`
+
+// scanUser scans a row selected with userColumns.
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.IsAdmin, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateUser registers a new user together with a personal team that the user
+// administers, so they can create projects straight away. The user also
+// joins the teams their email was invited to. It returns
+// ErrEmailTaken if the email address is already registered.
+func (r *Repository) CreateUser(ctx context.Context, email, passwordHash string) (*User, *Team, error) {
+	userQuery := `
+		INSERT INTO users (email, password_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (email) DO NOTHING
+		RETURNING ` + userColumns
+
+	var u *User
+	var t *Team
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		u, err = scanUser(tx.QueryRowContext(ctx, userQuery, email, passwordHash))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEmailTaken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert user: %w", err)
+		}
+
+		if t, err = insertTeam(ctx, tx, email, u.ID); err != nil {
+			return err
+		}
+		return acceptInvitations(ctx, tx, email, u.ID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, t, nil
+}
+
+// GetUserByEmail returns the user registered with the given email address,
+// or ErrNotFound.
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+
+	u, err := scanUser(r.db.QueryRowContext(ctx, query, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return u, nil
+}
+
+// CreateAPIToken stores the hash of a new API token for a user.
+func (r *Repository) CreateAPIToken(ctx context.Context, userID, name, tokenHash string) (*APIToken, error) {
+	const query = `
+		INSERT INTO api_tokens (user_id, name, token_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, user_id, name, created_at`
+
+	var t APIToken
+	err := r.db.QueryRowContext(ctx, query, userID, name, tokenHash).Scan(&t.ID, &t.UserID, &t.Name, &t.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert API token: %w", err)
+	}
+	return &t, nil
+}
+
+// ListAPITokens returns the API tokens of a user, newest first.
+func (r *Repository) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	const query = `
+		SELECT id, user_id, name, created_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var t APIToken
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes one of a user's API tokens. It returns ErrNotFound
+// if the user has no token with that ID.
+func (r *Repository) DeleteAPIToken(ctx context.Context, userID, id string) error {
+	const query = `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+
+	res, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUserByTokenHash returns the owner of the API token with the given hash
+// and records that the token was used. It returns ErrNotFound if no token
+// matches.
+func (r *Repository) GetUserByTokenHash(ctx context.Context, tokenHash string) (*User, error) {
+	const query = `
+		WITH t AS (
+			UPDATE api_tokens SET last_used_at = now()
+			WHERE token_hash = $1
+			RETURNING user_id
+		)
+		SELECT u.id, u.email, u.password_hash, u.is_admin, u.created_at
+		FROM users u JOIN t ON t.user_id = u.id`
+
+	u, err := scanUser(r.db.QueryRowContext(ctx, query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user by token: %w", err)
+	}
+	return u, nil
+}
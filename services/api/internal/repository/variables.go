@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// GetVariables returns the config vars and secret names of the application.
+// They are empty if it has none.
+func (r *Repository) GetVariables(ctx context.Context, appID string) (*Variables, error) {
+	return variables(ctx, r.db, appID)
+}
+
+// variables is GetVariables on db, which may be a transaction.
+func variables(ctx context.Context, db queryer, appID string) (*Variables, error) {
+	vars := &Variables{Config: map[string]string{}, Secrets: []string{}}
+
+	configRows, err := db.QueryContext(ctx, `SELECT name, value FROM application_config_vars WHERE application_id = $1`, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config vars: %w", err)
+	}
+	defer configRows.Close()
+	for configRows.Next() {
+		var name, value string
+		if err := configRows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan config var: %w", err)
+		}
+		vars.Config[name] = value
+	}
+	if err := configRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config vars: %w", err)
+	}
+
+	secretRows, err := db.QueryContext(ctx, `SELECT name FROM application_secrets WHERE application_id = $1 ORDER BY name`, appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secrets: %w", err)
+	}
+	defer secretRows.Close()
+	for secretRows.Next() {
+		var name string
+		if err := secretRows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		vars.Secrets = append(vars.Secrets, name)
+	}
+	if err := secretRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	return vars, nil
+}
+
+// SetVariables replaces the config vars and secret names of the application
+// with vars in one transaction, and returns them as GetVariables would. They
+// apply to the deployments created afterwards. It returns ErrNotFound if the
+// application does not exist.
+func (r *Repository) SetVariables(ctx context.Context, appID string, vars Variables) (*Variables, error) {
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		// Locking the application keeps deployments from reading half of
+		// the change.
+		var id string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM applications WHERE id = $1 FOR UPDATE`, appID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query application: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM application_config_vars WHERE application_id = $1`, appID); err != nil {
+			return fmt.Errorf("failed to delete config vars: %w", err)
+		}
+		for _, name := range sortedNames(vars.Config) {
+			const query = `INSERT INTO application_config_vars (application_id, name, value) VALUES ($1, $2, $3)`
+			if _, err := tx.ExecContext(ctx, query, appID, name, vars.Config[name]); err != nil {
+				return fmt.Errorf("failed to insert config var: %w", err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM application_secrets WHERE application_id = $1`, appID); err != nil {
+			return fmt.Errorf("failed to delete secrets: %w", err)
+		}
+		for _, name := range vars.Secrets {
+			const query = `INSERT INTO application_secrets (application_id, name) VALUES ($1, $2)`
+			if _, err := tx.ExecContext(ctx, query, appID, name); err != nil {
+				return fmt.Errorf("failed to insert secret: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &Variables{Config: map[string]string{}, Secrets: append([]string{}, vars.Secrets...)}
+	for name, value := range vars.Config {
+		stored.Config[name] = value
+	}
+	sort.Strings(stored.Secrets)
+	return stored, nil
+}
+
+// sortedNames returns the names of m in order, so that they are written in
+// a stable order.
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -26,6 +26,12 @@ func main() {
 	}
 	defer natsConn.Close()
 
+	// Provision the HELIOS stream that carries all events.
+	js, err := bootstrap.SetupJetStream(natsConn, log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("FATAL: Could not set up JetStream")
+	}
+
 	// Initialize database connection with resilient retry logic.
 	db, err := database.NewDB(log)
 	if err != nil {
@@ -34,6 +40,6 @@ func main() {
 	defer db.Close()
 
 	// --- Create and Run Application ---
-	app := platform.NewApp(log, natsConn, db)
+	app := platform.NewApp(log, natsConn, js, db)
 	app.Run()
 }
\ No newline at end of file
@@ -0,0 +1,118 @@
+// Package git checks out application source code with the git command-line
+// client.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"helios/pkg/config"
+)
+
+// Config holds the configuration for checkouts.
+type Config struct {
+	// Binary is the git executable to run.
+	Binary string
+	// WorkspaceDir is the directory each checkout gets its own temporary
+	// directory in. If empty, the system temporary directory is used.
+	WorkspaceDir string
+	// Timeout bounds a whole checkout, including resolving the commit.
+	Timeout time.Duration
+}
+
+// NewConfig creates a checkout configuration from environment variables.
+func NewConfig() Config {
+	return Config{
+		Binary:       config.Getenv("GIT_BINARY", "git"),
+		WorkspaceDir: config.Getenv("BUILD_WORKSPACE_DIR", ""),
+		Timeout:      config.GetenvDuration("GIT_CLONE_TIMEOUT", 5*time.Minute),
+	}
+}
+
+// Checkout is a working copy of a repository at a single commit.
+type Checkout struct {
+	// Dir is the root of the working copy.
+	Dir string
+	// CommitSHA is the full SHA of the checked out commit.
+	CommitSHA string
+}
+
+// Remove deletes the working copy.
+func (c *Checkout) Remove() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// CLI clones repositories by running git.
+type CLI struct {
+	Config Config
+}
+
+// NewCLI creates a CLI configured from the environment.
+func NewCLI() *CLI {
+	return &CLI{Config: NewConfig()}
+}
+
+// Clone makes a shallow clone of branch of repository in a new directory
+// under the workspace and resolves the commit it checked out. The caller must
+// Remove the checkout when done. Any repository URL git understands can be
+// used, including file:// URLs.
+func (c *CLI) Clone(ctx context.Context, repository, branch string) (*Checkout, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Config.Timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp(c.Config.WorkspaceDir, "checkout-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	checkout := &Checkout{Dir: dir}
+
+	// "--" stops a repository or branch starting with "-" from being read
+	// as an option.
+	if _, err := c.run(ctx, "", "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", branch, "--", repository, dir); err != nil {
+		checkout.Remove()
+		return nil, fmt.Errorf("git clone of %s at %s failed: %w", repository, branch, err)
+	}
+
+	sha, err := c.run(ctx, dir, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		checkout.Remove()
+		return nil, fmt.Errorf("could not resolve the checked out commit: %w", err)
+	}
+	checkout.CommitSHA = sha
+	return checkout, nil
+}
+
+// run runs git with args in dir and returns its trimmed standard output. The
+// error includes git's standard error, or reports the timeout or
+// cancellation.
+func (c *CLI) run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, c.Config.Binary, args...)
+	cmd.Dir = dir
+	// Fail instead of waiting for credentials on a terminal that is not
+	// there.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("timed out after %s", c.Config.Timeout)
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return "", ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository creates a repository with two commits on main and one on
+// a feature branch, and returns its file:// URL and the SHA of main.
+func newTestRepository(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	gitCmd := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Helios", "GIT_AUTHOR_EMAIL=helios@example.com",
+			"GIT_COMMITTER_NAME=Helios", "GIT_COMMITTER_EMAIL=helios@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, out)
+		return strings.TrimSpace(string(out))
+	}
+
+	gitCmd("init", "--quiet", "--initial-branch", "main")
+	for _, content := range []string{"v1", "v2"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "VERSION"), []byte(content), 0o644))
+		gitCmd("add", "VERSION")
+		gitCmd("commit", "--quiet", "-m", content)
+	}
+	sha := gitCmd("rev-parse", "HEAD")
+
+	gitCmd("checkout", "--quiet", "-b", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "VERSION"), []byte("feature"), 0o644))
+	gitCmd("commit", "--quiet", "-am", "feature")
+	gitCmd("checkout", "--quiet", "main")
+
+	return "file://" + dir, sha
+}
+
+func newTestCLI(t *testing.T) (*CLI, string) {
+	workspace := t.TempDir()
+	return &CLI{Config: Config{Binary: "git", WorkspaceDir: workspace, Timeout: 30 * time.Second}}, workspace
+}
+
+func TestClone(t *testing.T) {
+	repo, mainSHA := newTestRepository(t)
+	cli, workspace := newTestCLI(t)
+
+	checkout, err := cli.Clone(context.Background(), repo, "main")
+	require.NoError(t, err)
+
+	assert.Equal(t, mainSHA, checkout.CommitSHA, "checkout should resolve the head of the branch")
+	assert.Equal(t, workspace, filepath.Dir(checkout.Dir), "checkout should be created in the workspace")
+	content, err := os.ReadFile(filepath.Join(checkout.Dir, "VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(content))
+
+	// The clone is shallow.
+	out, err := exec.Command("git", "-C", checkout.Dir, "rev-list", "--count", "HEAD").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "1", strings.TrimSpace(string(out)))
+
+	require.NoError(t, checkout.Remove())
+	assert.NoDirExists(t, checkout.Dir)
+}
+
+func TestCloneBranch(t *testing.T) {
+	repo, mainSHA := newTestRepository(t)
+	cli, _ := newTestCLI(t)
+
+	checkout, err := cli.Clone(context.Background(), repo, "feature")
+	require.NoError(t, err)
+	defer checkout.Remove()
+
+	assert.NotEqual(t, mainSHA, checkout.CommitSHA)
+	assert.Len(t, checkout.CommitSHA, 40)
+	content, err := os.ReadFile(filepath.Join(checkout.Dir, "VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "feature", string(content))
+}
+
+func TestCloneFailure(t *testing.T) {
+	repo, _ := newTestRepository(t)
+
+	testCases := []struct {
+		name          string
+		repository    string
+		branch        string
+		expectedError string
+	}{
+		{name: "Unknown branch", repository: repo, branch: "missing", expectedError: "missing"},
+		{name: "Unknown repository", repository: repo + "-missing", branch: "main", expectedError: "git clone"},
+		{name: "Option-like branch", repository: repo, branch: "--upload-pack=touch", expectedError: "git clone"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli, workspace := newTestCLI(t)
+
+			checkout, err := cli.Clone(context.Background(), tc.repository, tc.branch)
+			require.Error(t, err)
+			assert.Nil(t, checkout)
+			assert.Contains(t, err.Error(), tc.expectedError)
+
+			entries, err := os.ReadDir(workspace)
+			require.NoError(t, err)
+			assert.Empty(t, entries, "a failed clone should not leave a checkout behind")
+		})
+	}
+}
+
+func TestCloneTimeout(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	cli, _ := newTestCLI(t)
+	cli.Config.Timeout = time.Nanosecond
+
+	_, err := cli.Clone(context.Background(), repo, "main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestCloneCancelled(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	cli, workspace := newTestCLI(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cli.Clone(ctx, repo, "main")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	entries, err := os.ReadDir(workspace)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a cancelled clone should not leave a checkout behind")
+}
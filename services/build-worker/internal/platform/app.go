@@ -3,65 +3,77 @@
 package platform
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
+	"helios/build-worker/internal/git"
 	"helios/build-worker/internal/worker"
+	"helios/pkg/bootstrap"
+	"helios/pkg/deadletter"
 	"helios/pkg/events"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 )
 
+// durableName is the JetStream consumer shared by all build-worker replicas.
+const durableName = "build-workers"
+
 // App represents the central application container, holding all dependencies.
 type App struct {
 	Logger zerolog.Logger
 	NATS   *nats.Conn
+	JS     jetstream.JetStream
 }
 
 // NewApp creates and configures a new application instance.
-func NewApp(logger zerolog.Logger, natsConn *nats.Conn) *App {
+func NewApp(logger zerolog.Logger, natsConn *nats.Conn, js jetstream.JetStream) *App {
 	return &App{
 		Logger: logger,
 		NATS:   natsConn,
+		JS:     js,
 	}
 }
 
-// Run starts the worker, subscribes to NATS, and handles graceful shutdown.
+// Run starts the worker, consumes from JetStream, and handles graceful shutdown.
 func (a *App) Run() {
-	w := worker.NewWorker(a.NATS, a.Logger)
+	publisher := bootstrap.NewPublisher(a.JS)
+	w := worker.NewWorker(publisher, git.NewCLI(), a.Logger)
+	w.DeadLetters = deadletter.NewQueue(publisher, durableName, a.Logger)
 
-	// Create a synchronous queue subscriber for manual acknowledgement.
+	// Create a durable pull consumer with explicit acknowledgement.
 	subject := events.SubjectDeploymentRequested
-	sub, err := a.NATS.QueueSubscribeSync(subject, "build-workers")
+	consumer, err := bootstrap.EnsureConsumer(a.JS, durableName, subject)
 	if err != nil {
-		a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not create queue subscription")
+		a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not create JetStream consumer")
 	}
 
-	a.Logger.Info().Str("subject", subject).Str("queue_group", "build-workers").Msg("Listening for events")
+	ctx, stop := context.WithCancel(context.Background())
+
+	// Every replica receives every cancellation request, since any of them
+	// may be building the deployment. The requests kept in the stream are
+	// handled before any deployment request, so a replica that was down does
+	// not build a deployment cancelled meanwhile.
+	caughtUp, err := bootstrap.ConsumeAll(ctx, a.JS, events.SubjectCancellationRequested, a.Logger, w.HandleCancellationRequest)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Str("subject", events.SubjectCancellationRequested).Msg("FATAL: Could not consume cancellation requests")
+	}
+	<-caughtUp
+	a.Logger.Info().Str("subject", events.SubjectCancellationRequested).Msg("Listening for cancellation requests")
+	if err := w.DeadLetters.WatchMaxDeliveries(ctx, a.NATS, a.JS); err != nil {
+		a.Logger.Fatal().Err(err).Str("durable", durableName).Msg("FATAL: Could not watch for messages that run out of deliveries")
+	}
 
 	// Start a goroutine for message processing.
+	a.Logger.Info().Str("subject", subject).Str("durable", durableName).Msg("Listening for events")
 	processingDone := make(chan struct{})
 	go func() {
 		defer close(processingDone)
-		for {
-			msg, err := sub.NextMsg(10 * time.Second)
-			if err != nil {
-				// ErrTimeout is expected when no messages are pending.
-				if err == nats.ErrTimeout {
-					continue
-				}
-				// ErrConnectionClosed or ErrBadSubscription indicate the subscription is done.
-				if err == nats.ErrConnectionClosed || err == nats.ErrBadSubscription {
-					a.Logger.Info().Msg("Subscription closed, stopping message processing.")
-					return
-				}
-				a.Logger.Error().Err(err).Msg("Error receiving message from NATS")
-				continue
-			}
-			w.HandleDeploymentRequest(msg)
+		if err := bootstrap.Consume(ctx, consumer, a.Logger, w.HandleDeploymentRequest); err != nil {
+			a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not consume from JetStream")
 		}
 	}()
 
@@ -69,15 +81,11 @@ func (a *App) Run() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	a.Logger.Warn().Msg("Shutdown signal received, draining NATS subscription...")
-
-	// Drain the subscription. This will wait for the processing goroutine to finish.
-	if err := sub.Drain(); err != nil {
-		a.Logger.Error().Err(err).Msg("Error draining NATS subscription")
-	}
+	a.Logger.Warn().Msg("Shutdown signal received, stopping JetStream consumer...")
 
-	// Wait for the processing goroutine to exit cleanly.
+	// Stop consuming and wait for the message in flight to be handled.
+	stop()
 	<-processingDone
 
 	a.Logger.Info().Msg("Worker exiting")
-}
\ No newline at end of file
+}
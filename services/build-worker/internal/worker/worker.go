@@ -1,99 +1,365 @@
 package worker
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"time"
 
+	"helios/build-worker/internal/git"
+	"helios/pkg/bootstrap"
+	"helios/pkg/deadletter"
+	"helios/pkg/deploylog"
 	"helios/pkg/events"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 )
 
-// NatsPublisher defines the interface for publishing messages to NATS.
+// natsMsg defines the interface for a NATS message, allowing for easier testing.
+type natsMsg interface {
+	GetData() []byte
+	Ack() error
+	Nak() error
+	// Term stops redelivery of a message that can never be processed.
+	Term(reason string) error
+	// InProgress resets the ack wait of a message that is still being
+	// processed.
+	InProgress() error
+}
+
+// natsMsgAdapter adapts a jetstream.Msg to the natsMsg interface. Messages
+// that are terminated, or nakked on their last delivery, are dead-lettered.
+type natsMsgAdapter struct {
+	msg jetstream.Msg
+	dlq *deadletter.Queue
+}
+
+func (a *natsMsgAdapter) GetData() []byte {
+	return a.msg.Data()
+}
+
+func (a *natsMsgAdapter) Ack() error {
+	return a.msg.Ack()
+}
+
+func (a *natsMsgAdapter) Nak() error {
+	if a.dlq == nil {
+		return a.msg.Nak()
+	}
+	return a.dlq.Nak(a.msg)
+}
+
+func (a *natsMsgAdapter) InProgress() error {
+	return a.msg.InProgress()
+}
+
+func (a *natsMsgAdapter) Term(reason string) error {
+	if a.dlq == nil {
+		return a.msg.TermWithReason(reason)
+	}
+	return a.dlq.Term(a.msg, reason)
+}
+
+// NatsPublisher defines the interface for publishing messages to NATS with a
+// message ID used for deduplication.
 type NatsPublisher interface {
-	Publish(subject string, data []byte) error
+	PublishMsg(subject, msgID string, data []byte) error
+}
+
+// BuildResult describes the image produced by a successful build.
+type BuildResult struct {
+	ImageURI     string
+	GitCommitSHA string
+	// Manifest is the content of the Heliosfile at the built commit, or
+	// empty if there is none.
+	Manifest string
+}
+
+// Builder builds a container image for a deployment request. An error means
+// the build itself failed and is reported as the deployment's failure reason.
+// Builds stop when ctx is cancelled.
+type Builder interface {
+	Build(ctx context.Context, log zerolog.Logger, request events.DeploymentRequest) (*BuildResult, error)
+}
+
+// Cloner checks out the source code of a deployment.
+type Cloner interface {
+	Clone(ctx context.Context, repository, branch string) (*git.Checkout, error)
+}
+
+// sourceBuilder checks out the requested branch and tags the image with the
+// commit it resolved to. Building the image itself is still simulated.
+type sourceBuilder struct {
+	cloner Cloner
+}
+
+func (b sourceBuilder) Build(ctx context.Context, log zerolog.Logger, request events.DeploymentRequest) (*BuildResult, error) {
+	checkout, err := b.cloner.Clone(ctx, request.GitRepository, request.GitBranch)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := checkout.Remove(); err != nil {
+			log.Warn().Err(err).Str("dir", checkout.Dir).Msg("Could not remove checkout")
+		}
+	}()
+
+	log.Info().Str("commit_sha", checkout.CommitSHA).Msgf("Checked out commit %s", checkout.CommitSHA)
+
+	manifest, err := readManifest(checkout.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Image tags use the abbreviated commit, like `git log --oneline`.
+	tag := checkout.CommitSHA
+	if len(tag) > 12 {
+		tag = tag[:12]
+	}
+	return &BuildResult{
+		ImageURI:     fmt.Sprintf("registry.helios.internal/%s:%s", request.AppID, tag),
+		GitCommitSHA: checkout.CommitSHA,
+		Manifest:     manifest,
+	}, nil
+}
+
+// manifestFile is the manifest the oal-worker deploys from, at the root of
+// the repository.
+const manifestFile = "Heliosfile.yml"
+
+// maxManifestSize bounds the manifest carried in a BuildSucceeded event, well
+// below the NATS payload limit.
+const maxManifestSize = 256 << 10
+
+// readManifest returns the manifest in dir, or an empty string if there is
+// none. The oal-worker parses it, so it is not validated here.
+func readManifest(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", manifestFile, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxManifestSize+1))
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", manifestFile, err)
+	}
+	if len(data) > maxManifestSize {
+		return "", fmt.Errorf("%s is larger than %d KiB", manifestFile, maxManifestSize>>10)
+	}
+	return string(data), nil
 }
 
 // Worker holds dependencies for the message handler.
 type Worker struct {
 	NATS      NatsPublisher
+	Builder   Builder
 	Logger    zerolog.Logger
 	Validator *validator.Validate
+	// DeadLetters receives messages the worker gives up on. If nil, they are
+	// terminated without being kept.
+	DeadLetters *deadletter.Queue
+	// Handled remembers recently handled event IDs, so redelivered events
+	// are acknowledged without building again.
+	Handled *events.Deduplicator
+	// Cancellations aborts the builds of cancelled deployments.
+	Cancellations *events.Cancellations
+	// Heartbeat is how often a message is reported in progress while it is
+	// built, so that a build, or a clone of up to GIT_CLONE_TIMEOUT, that
+	// takes longer than JS_ACK_WAIT is not redelivered.
+	Heartbeat time.Duration
 }
 
-// NewWorker creates a new Worker.
-func NewWorker(nats NatsPublisher, logger zerolog.Logger) *Worker {
+// NewWorker creates a new Worker that builds from the source checked out by
+// cloner.
+func NewWorker(nats NatsPublisher, cloner Cloner, logger zerolog.Logger) *Worker {
 	return &Worker{
-		NATS:      nats,
-		Logger:    logger,
-		Validator: validator.New(),
+		NATS:          nats,
+		Builder:       sourceBuilder{cloner: cloner},
+		Logger:        logger,
+		Validator:     validator.New(),
+		Handled:       events.NewDeduplicator(1024),
+		Cancellations: events.NewCancellations(1024),
+		Heartbeat:     bootstrap.NewJetStreamConfig().AckWait / 2,
 	}
 }
 
-// HandleDeploymentRequest processes incoming deployment request events.
-func (w *Worker) HandleDeploymentRequest(m *nats.Msg) {
-	var request events.DeploymentRequest
-	if err := json.Unmarshal(m.Data, &request); err != nil {
-		w.Logger.Error().Err(err).Msg("Could not unmarshal deployment request, terminating message")
-		if err := m.Term(); err != nil {
+// HandleDeploymentRequest is the public handler for JetStream messages. It
+// wraps the real message and passes it to the testable internal handler.
+func (w *Worker) HandleDeploymentRequest(m jetstream.Msg) {
+	w.handleDeploymentRequestInternal(&natsMsgAdapter{msg: m, dlq: w.DeadLetters})
+}
+
+// HandleCancellationRequest is the public handler for cancellation requests,
+// which every replica receives. It passes the message data to the testable
+// internal handler.
+func (w *Worker) HandleCancellationRequest(m jetstream.Msg) {
+	w.handleCancellationRequestInternal(m.Data())
+}
+
+// handleCancellationRequestInternal records a cancelled deployment and
+// aborts its build if it is in progress. Cancellation requests are not
+// acknowledged, so invalid ones are only logged.
+func (w *Worker) handleCancellationRequestInternal(data []byte) {
+	envelope, err := events.Decode[events.CancellationRequest](data)
+	if err == nil {
+		err = w.Validator.Struct(&envelope.Data)
+	}
+	if err != nil {
+		w.Logger.Error().Err(err).Msg("Ignoring invalid cancellation request")
+		return
+	}
+
+	w.Logger.Info().
+		Str("event_id", envelope.ID).
+		Str("correlation_id", envelope.CorrelationID).
+		Str("app_id", envelope.Data.AppID).
+		Str("deployment_id", envelope.Data.DeploymentID).
+		Msg("Received cancellation request")
+	w.Cancellations.Cancel(envelope.Data.DeploymentID)
+}
+
+// handleDeploymentRequestInternal processes incoming deployment request events.
+func (w *Worker) handleDeploymentRequestInternal(m natsMsg) {
+	envelope, err := events.Decode[events.DeploymentRequest](m.GetData())
+	if err != nil {
+		w.Logger.Error().Err(err).Msg("Could not decode deployment request, terminating message")
+		if err := m.Term("invalid event: " + err.Error()); err != nil {
 			w.Logger.Error().Err(err).Msg("Failed to terminate NATS message")
 		}
 		return
 	}
 
 	// Validate the event payload
+	request := envelope.Data
 	if err := w.Validator.Struct(&request); err != nil {
 		w.Logger.Error().Err(err).Msg("Invalid deployment request payload, terminating message")
-		if err := m.Term(); err != nil {
+		if err := m.Term("invalid payload: " + err.Error()); err != nil {
 			w.Logger.Error().Err(err).Msg("Failed to terminate NATS message")
 		}
 		return
 	}
 
-	log := w.Logger.With().Str("app_id", request.AppID).Logger()
-
-	log.Info().Str("repo", request.GitRepository).Msg("Received deployment request")
+	log := w.Logger.With().
+		Str("event_id", envelope.ID).
+		Str("correlation_id", envelope.CorrelationID).
+		Str("app_id", request.AppID).
+		Str("deployment_id", request.DeploymentID).
+		Logger()
 
-	// Simulate the build process
-	log.Info().Msg("Simulating build process...")
-	time.Sleep(1 * time.Second) // Reduced for faster tests
-	log.Info().Msg("Build simulation complete")
-
-	// Publish Build Succeeded Event
-	commitSHA := "a1b2c3d4e5f6" // Placeholder
-	imageURI := fmt.Sprintf("registry.helios.internal/%s:%s", request.AppID, commitSHA)
-
-	event := events.BuildSucceeded{
-		AppID:        request.AppID,
-		ImageURI:     imageURI,
-		GitCommitSHA: commitSHA,
+	if w.Handled.Seen(envelope.ID) {
+		log.Warn().Msg("Ignoring duplicate deployment request")
+		if err := m.Ack(); err != nil {
+			log.Error().Err(err).Msg("Failed to acknowledge NATS message")
+		}
+		return
 	}
 
-	eventData, err := json.Marshal(event)
-	if err != nil {
-		log.Error().Err(err).Msg("Could not marshal build succeeded event, terminating message")
-		if err := m.Term(); err != nil {
-			log.Error().Err(err).Msg("Failed to terminate NATS message")
+	// deployLog also publishes what it logs for the users of the deployment.
+	// Messaging details stay on log.
+	deployLog := log.Hook(deploylog.NewHook(w.NATS, w.Logger, envelope.Metadata, events.LogSourceBuild, request.DeploymentID, request.AppID))
+	deployLog.Info().Str("repo", request.GitRepository).Msgf("Received deployment request for %s at %s", request.GitRepository, request.GitBranch)
+
+	if w.Cancellations.Cancelled(request.DeploymentID) {
+		deployLog.Info().Msg("Deployment was cancelled, not building it")
+		if !w.publishCancelled(log, m, envelope.Metadata, request) {
+			return
+		}
+		w.Handled.Mark(envelope.ID)
+		if err := m.Ack(); err != nil {
+			log.Error().Err(err).Msg("Failed to acknowledge NATS message")
 		}
 		return
 	}
 
-	subject := events.SubjectBuildSucceeded
-	if err := w.NATS.Publish(subject, eventData); err != nil {
-		log.Error().Err(err).Str("subject", subject).Msg("Failed to publish to NATS, nakking message for redelivery")
-		if err := m.Nak(); err != nil {
-			log.Error().Err(err).Msg("Failed to nak NATS message")
-		}
+	started := events.NewCausedBy(envelope.Metadata, events.SubjectBuildStarted, events.BuildStarted{
+		DeploymentID: request.DeploymentID,
+		AppID:        request.AppID,
+	})
+	if !publish(log, w.NATS, m, started) {
 		return
 	}
 
-	log.Info().Str("subject", subject).Msg("Successfully published event to NATS")
+	ctx, done := w.Cancellations.Start(context.Background(), request.DeploymentID)
+	stopHeartbeat := bootstrap.KeepInProgress(w.Heartbeat, m.InProgress, log)
+	result, err := w.Builder.Build(ctx, deployLog, request)
+	stopHeartbeat()
+	cancelled := ctx.Err() != nil
+	done()
+
+	if cancelled {
+		// The build was aborted, or finished as the cancellation arrived;
+		// either way the image is not released.
+		deployLog.Info().Msg("Build cancelled")
+		if !w.publishCancelled(log, m, envelope.Metadata, request) {
+			return
+		}
+	} else if err != nil {
+		// A failed build is the outcome of the request, not a reason to
+		// redeliver it.
+		deployLog.Error().Err(err).Msgf("Build failed: %v", err)
+		failed := events.NewCausedBy(envelope.Metadata, events.SubjectBuildFailed, events.BuildFailed{
+			DeploymentID: request.DeploymentID,
+			AppID:        request.AppID,
+			Reason:       err.Error(),
+		})
+		if !publish(log, w.NATS, m, failed) {
+			return
+		}
+	} else {
+		deployLog.Info().Str("image_uri", result.ImageURI).Msgf("Built image %s", result.ImageURI)
+		succeeded := events.NewCausedBy(envelope.Metadata, events.SubjectBuildSucceeded, events.BuildSucceeded{
+			DeploymentID: request.DeploymentID,
+			AppID:        request.AppID,
+			ImageURI:     result.ImageURI,
+			GitCommitSHA: result.GitCommitSHA,
+			Manifest:     result.Manifest,
+			Backend:      request.Backend,
+			Config:       request.Config,
+			Secrets:      request.Secrets,
+			Previous:     request.Previous,
+		})
+		if !publish(log, w.NATS, m, succeeded) {
+			return
+		}
+	}
+	w.Handled.Mark(envelope.ID)
 
 	// Acknowledge the message now that processing is complete
 	if err := m.Ack(); err != nil {
 		log.Error().Err(err).Msg("Failed to acknowledge NATS message")
 	}
+}
+
+// publishCancelled reports that the deployment of request was cancelled. If
+// that fails, it nakks m for redelivery and returns false.
+func (w *Worker) publishCancelled(log zerolog.Logger, m natsMsg, cause events.Metadata, request events.DeploymentRequest) bool {
+	cancelled := events.NewCausedBy(cause, events.SubjectDeploymentCancelled, events.DeploymentCancelled{
+		DeploymentID: request.DeploymentID,
+		AppID:        request.AppID,
+	})
+	return publish(log, w.NATS, m, cancelled)
+}
+
+// publish publishes event on p. If that fails, it nakks m for redelivery and
+// returns false.
+func publish[T any](log zerolog.Logger, p NatsPublisher, m natsMsg, event events.Envelope[T]) bool {
+	if err := events.Publish(p, event); err != nil {
+		log.Error().Err(err).Str("subject", event.Type).Msg("Failed to publish to NATS, nakking message for redelivery")
+		if err := m.Nak(); err != nil {
+			log.Error().Err(err).Msg("Failed to nak NATS message")
+		}
+		return false
+	}
+	log.Info().Str("subject", event.Type).Str("published_event_id", event.ID).Msg("Successfully published event to NATS")
+	return true
 }
\ No newline at end of file
@@ -1,13 +1,20 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"helios/build-worker/internal/git"
 	"helios/pkg/events"
 	"helios/pkg/testutil"
-	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,29 +23,102 @@ import (
 
 // MockNatsPublisher is a mock implementation of the NatsPublisher interface.
 type MockNatsPublisher struct {
-	PublishedSubject string
-	PublishedData    []byte
-	PublishError     error
+	PublishedSubjects []string
+	PublishedMessages [][]byte
+	PublishedSubject  string
+	PublishedMsgID    string
+	PublishedData     []byte
+	PublishError      error
 }
 
-// Publish records the subject and data it was called with, then returns any configured error.
-func (m *MockNatsPublisher) Publish(subject string, data []byte) error {
+// PublishMsg records the subject, message ID and data it was called with, then returns any configured error.
+func (m *MockNatsPublisher) PublishMsg(subject, msgID string, data []byte) error {
+	m.PublishedSubjects = append(m.PublishedSubjects, subject)
+	m.PublishedMessages = append(m.PublishedMessages, data)
 	m.PublishedSubject = subject
+	m.PublishedMsgID = msgID
 	m.PublishedData = data
 	return m.PublishError
 }
 
+// testCommitSHA is the commit every mockCloner checkout resolves to.
+const testCommitSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// mockCloner creates a checkout directory holding the configured files, or
+// returns the configured error, without running git.
+type mockCloner struct {
+	err      error
+	files    map[string]string
+	checkout *git.Checkout
+}
+
+func (c *mockCloner) Clone(_ context.Context, _, _ string) (*git.Checkout, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	dir, err := os.MkdirTemp("", "checkout-")
+	if err != nil {
+		return nil, err
+	}
+	for name, content := range c.files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+	}
+	c.checkout = &git.Checkout{Dir: dir, CommitSHA: testCommitSHA}
+	return c.checkout, nil
+}
+
+// mockNatsMsg is a mock implementation of the natsMsg interface for testing.
+type mockNatsMsg struct {
+	data       []byte
+	acked      bool
+	nakked     bool
+	termed     bool
+	termReason string
+	inProgress atomic.Int32
+}
+
+func (m *mockNatsMsg) GetData() []byte {
+	return m.data
+}
+
+func (m *mockNatsMsg) Ack() error {
+	m.acked = true
+	return nil
+}
+
+func (m *mockNatsMsg) Nak() error {
+	m.nakked = true
+	return nil
+}
+
+func (m *mockNatsMsg) InProgress() error {
+	m.inProgress.Add(1)
+	return nil
+}
+
+func (m *mockNatsMsg) Term(reason string) error {
+	m.termed = true
+	m.termReason = reason
+	return nil
+}
+
 // --- Tests ---
 
 func TestHandleDeploymentRequest(t *testing.T) {
 	// Setup a valid deployment request for reuse
 	validRequest := events.DeploymentRequest{
+		DeploymentID:  "dep-456",
 		AppID:         "app-123",
 		GitRepository: "https://github.com/example/app.git",
 		GitBranch:     "develop",
 	}
-	validRequestData, err := json.Marshal(validRequest)
+	requestEnvelope := events.New(events.SubjectDeploymentRequested, validRequest)
+	validRequestData, err := json.Marshal(requestEnvelope)
 	require.NoError(t, err, "Setup failed: could not marshal valid request")
+	invalidRequestData, err := json.Marshal(events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{AppID: "app-123"}))
+	require.NoError(t, err, "Setup failed: could not marshal invalid request")
 
 	testCases := []struct {
 		name              string
@@ -58,6 +138,18 @@ func TestHandleDeploymentRequest(t *testing.T) {
 			mockNatsError:     nil,
 			expectNatsPublish: false,
 		},
+		{
+			name:              "Failure Case - Validation Error",
+			natsMsgData:       invalidRequestData,
+			mockNatsError:     nil,
+			expectNatsPublish: false,
+		},
+		{
+			name:              "Failure Case - Payload Without Envelope",
+			natsMsgData:       []byte(`{"deployment_id": "dep-456", "app_id": "app-123", "git_repository": "https://github.com/example/app.git", "git_branch": "develop"}`),
+			mockNatsError:     nil,
+			expectNatsPublish: false,
+		},
 		{
 			name:              "Failure Case - NATS Publish Error",
 			natsMsgData:       validRequestData,
@@ -71,31 +163,284 @@ func TestHandleDeploymentRequest(t *testing.T) {
 			// Setup
 			testLogger := testutil.NewTestLogger()
 			mockNATS := &MockNatsPublisher{PublishError: tc.mockNatsError}
-			worker := NewWorker(mockNATS, testLogger)
+			worker := NewWorker(mockNATS, &mockCloner{}, testLogger)
 
-			msg := &nats.Msg{
-				Subject: events.SubjectDeploymentRequested,
-				Data:    tc.natsMsgData,
-			}
+			msg := &mockNatsMsg{data: tc.natsMsgData}
 
 			// Execute
-			worker.HandleDeploymentRequest(msg)
+			worker.handleDeploymentRequestInternal(msg)
 
 			// Assert
 			if tc.expectNatsPublish {
 				assert.NotEmpty(t, mockNATS.PublishedSubject, "worker should have attempted to publish a NATS message")
 				if tc.mockNatsError == nil {
 					assert.Equal(t, events.SubjectBuildSucceeded, mockNATS.PublishedSubject, "worker published to wrong NATS subject")
-					var publishedEvent events.BuildSucceeded
-					err := json.Unmarshal(mockNATS.PublishedData, &publishedEvent)
-					require.NoError(t, err, "Could not unmarshal published NATS message payload")
+					published, err := events.Decode[events.BuildSucceeded](mockNATS.PublishedData)
+					require.NoError(t, err, "Could not decode published NATS message payload")
+					assert.Equal(t, published.ID, mockNATS.PublishedMsgID, "NATS message ID should be the event ID")
+					assert.Equal(t, requestEnvelope.ID, published.CausationID, "NATS event should be caused by the request")
+					assert.Equal(t, requestEnvelope.CorrelationID, published.CorrelationID, "NATS event should keep the request's correlation ID")
+					publishedEvent := published.Data
 					assert.Equal(t, validRequest.AppID, publishedEvent.AppID, "NATS event has wrong AppID")
-					assert.NotEmpty(t, publishedEvent.GitCommitSHA, "NATS event is missing GitCommitSHA")
-					assert.NotEmpty(t, publishedEvent.ImageURI, "NATS event is missing ImageURI")
+					assert.Equal(t, validRequest.DeploymentID, publishedEvent.DeploymentID, "NATS event has wrong DeploymentID")
+					assert.Equal(t, testCommitSHA, publishedEvent.GitCommitSHA, "NATS event should carry the checked out commit")
+					assert.Equal(t, "registry.helios.internal/app-123:0123456789ab", publishedEvent.ImageURI, "NATS event has wrong ImageURI")
+					assert.True(t, msg.acked, "message should be acked after publishing")
+				} else {
+					assert.True(t, msg.nakked, "message should be nakked for redelivery when publishing fails")
 				}
 			} else {
 				assert.Empty(t, mockNATS.PublishedSubject, "worker should not have published a NATS message")
+				assert.True(t, msg.termed, "invalid message should be terminated")
+				assert.NotEmpty(t, msg.termReason, "terminated message should carry a reason for the dead letter")
+			}
+		})
+	}
+}
+
+func TestHandleDeploymentRequestDuplicate(t *testing.T) {
+	data, err := json.Marshal(events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{
+		DeploymentID:  "dep-456",
+		AppID:         "app-123",
+		GitRepository: "https://github.com/example/app.git",
+		GitBranch:     "main",
+	}))
+	require.NoError(t, err, "Setup failed: could not marshal request")
+
+	mockNATS := &MockNatsPublisher{}
+	cloner := &mockCloner{}
+	worker := NewWorker(mockNATS, cloner, testutil.NewTestLogger())
+
+	first := &mockNatsMsg{data: data}
+	worker.handleDeploymentRequestInternal(first)
+	redelivered := &mockNatsMsg{data: data}
+	worker.handleDeploymentRequestInternal(redelivered)
+
+	assert.Equal(t, []string{events.SubjectBuildStarted, events.SubjectBuildSucceeded}, mockNATS.PublishedSubjects, "a redelivered request should not be built again")
+	assert.True(t, first.acked)
+	assert.True(t, redelivered.acked, "a redelivered request should be acknowledged")
+	assert.NoDirExists(t, cloner.checkout.Dir, "the checkout should be removed after the build")
+}
+
+func TestHandleDeploymentRequestBuildFailure(t *testing.T) {
+	request := events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{
+		DeploymentID:  "dep-456",
+		AppID:         "app-123",
+		GitRepository: "https://github.com/example/app.git",
+		GitBranch:     "main",
+	})
+	data, err := json.Marshal(request)
+	require.NoError(t, err, "Setup failed: could not marshal request")
+
+	mockNATS := &MockNatsPublisher{}
+	worker := NewWorker(mockNATS, &mockCloner{err: errors.New("repository not found")}, testutil.NewTestLogger())
+
+	msg := &mockNatsMsg{data: data}
+	worker.handleDeploymentRequestInternal(msg)
+
+	assert.Equal(t, []string{events.SubjectBuildStarted, events.SubjectBuildFailed}, mockNATS.PublishedSubjects)
+	failed, err := events.Decode[events.BuildFailed](mockNATS.PublishedData)
+	require.NoError(t, err, "Could not decode published NATS message payload")
+	assert.Equal(t, "dep-456", failed.Data.DeploymentID)
+	assert.Equal(t, "repository not found", failed.Data.Reason)
+	assert.Equal(t, request.ID, failed.CausationID)
+	assert.True(t, msg.acked, "a failed build should be acknowledged, not redelivered")
+	assert.False(t, msg.nakked)
+}
+
+func TestHandleDeploymentRequestManifest(t *testing.T) {
+	const manifest = "name: app\nservices:\n  web: {}\n"
+
+	testCases := []struct {
+		name             string
+		files            map[string]string
+		expectedSubject  string
+		expectedManifest string
+		expectedReason   string
+	}{
+		{
+			name:             "Heliosfile is forwarded",
+			files:            map[string]string{"Heliosfile.yml": manifest},
+			expectedSubject:  events.SubjectBuildSucceeded,
+			expectedManifest: manifest,
+		},
+		{
+			name:            "No Heliosfile",
+			expectedSubject: events.SubjectBuildSucceeded,
+		},
+		{
+			name:            "Heliosfile too large",
+			files:           map[string]string{"Heliosfile.yml": strings.Repeat("#", maxManifestSize+1)},
+			expectedSubject: events.SubjectBuildFailed,
+			expectedReason:  "Heliosfile.yml is larger than 256 KiB",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{
+				DeploymentID:  "dep-456",
+				AppID:         "app-123",
+				GitRepository: "https://github.com/example/app.git",
+				GitBranch:     "main",
+			}))
+			require.NoError(t, err, "Setup failed: could not marshal request")
+
+			mockNATS := &MockNatsPublisher{}
+			worker := NewWorker(mockNATS, &mockCloner{files: tc.files}, testutil.NewTestLogger())
+			worker.handleDeploymentRequestInternal(&mockNatsMsg{data: data})
+
+			require.Equal(t, tc.expectedSubject, mockNATS.PublishedSubject)
+			if tc.expectedReason != "" {
+				failed, err := events.Decode[events.BuildFailed](mockNATS.PublishedData)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedReason, failed.Data.Reason)
+				return
 			}
+			succeeded, err := events.Decode[events.BuildSucceeded](mockNATS.PublishedData)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedManifest, succeeded.Data.Manifest)
 		})
 	}
-}
\ No newline at end of file
+}
+
+// blockingCloner blocks every clone until its context is cancelled.
+type blockingCloner struct {
+	started chan struct{}
+}
+
+func (c *blockingCloner) Clone(ctx context.Context, _, _ string) (*git.Checkout, error) {
+	close(c.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestHandleCancellationRequest(t *testing.T) {
+	request := events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{
+		DeploymentID:  "dep-456",
+		AppID:         "app-123",
+		GitRepository: "https://github.com/example/app.git",
+		GitBranch:     "main",
+	})
+	data, err := json.Marshal(request)
+	require.NoError(t, err, "Setup failed: could not marshal request")
+	cancellation, err := json.Marshal(events.New(events.SubjectCancellationRequested, events.CancellationRequest{DeploymentID: "dep-456", AppID: "app-123"}))
+	require.NoError(t, err, "Setup failed: could not marshal cancellation")
+
+	t.Run("Build in progress", func(t *testing.T) {
+		mockNATS := &MockNatsPublisher{}
+		cloner := &blockingCloner{started: make(chan struct{})}
+		worker := NewWorker(mockNATS, cloner, testutil.NewTestLogger())
+
+		msg := &mockNatsMsg{data: data}
+		handled := make(chan struct{})
+		go func() {
+			defer close(handled)
+			worker.handleDeploymentRequestInternal(msg)
+		}()
+		<-cloner.started
+		worker.handleCancellationRequestInternal(cancellation)
+		<-handled
+
+		assert.Equal(t, []string{events.SubjectBuildStarted, events.SubjectDeploymentCancelled}, mockNATS.PublishedSubjects)
+		cancelled, err := events.Decode[events.DeploymentCancelled](mockNATS.PublishedData)
+		require.NoError(t, err)
+		assert.Equal(t, "dep-456", cancelled.Data.DeploymentID)
+		assert.Equal(t, request.ID, cancelled.CausationID)
+		assert.True(t, msg.acked, "a cancelled build should be acknowledged")
+	})
+
+	t.Run("Cancelled before the request arrives", func(t *testing.T) {
+		mockNATS := &MockNatsPublisher{}
+		cloner := &mockCloner{}
+		worker := NewWorker(mockNATS, cloner, testutil.NewTestLogger())
+
+		worker.handleCancellationRequestInternal(cancellation)
+		msg := &mockNatsMsg{data: data}
+		worker.handleDeploymentRequestInternal(msg)
+
+		assert.Equal(t, []string{events.SubjectDeploymentCancelled}, mockNATS.PublishedSubjects)
+		assert.Nil(t, cloner.checkout, "a cancelled deployment should not be checked out")
+		assert.True(t, msg.acked)
+	})
+
+	t.Run("Other deployments are built", func(t *testing.T) {
+		mockNATS := &MockNatsPublisher{}
+		worker := NewWorker(mockNATS, &mockCloner{}, testutil.NewTestLogger())
+
+		other, err := json.Marshal(events.New(events.SubjectCancellationRequested, events.CancellationRequest{DeploymentID: "dep-789", AppID: "app-123"}))
+		require.NoError(t, err)
+		worker.handleCancellationRequestInternal(other)
+		worker.handleCancellationRequestInternal([]byte(`{"deployment_id":`))
+		worker.handleDeploymentRequestInternal(&mockNatsMsg{data: data})
+
+		assert.Equal(t, []string{events.SubjectBuildStarted, events.SubjectBuildSucceeded}, mockNATS.PublishedSubjects)
+	})
+}
+
+func TestHandleDeploymentRequestHeartbeat(t *testing.T) {
+	data, err := json.Marshal(events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{
+		DeploymentID:  "dep-456",
+		AppID:         "app-123",
+		GitRepository: "https://github.com/example/app.git",
+		GitBranch:     "main",
+	}))
+	require.NoError(t, err, "Setup failed: could not marshal request")
+	cancellation, err := json.Marshal(events.New(events.SubjectCancellationRequested, events.CancellationRequest{DeploymentID: "dep-456", AppID: "app-123"}))
+	require.NoError(t, err, "Setup failed: could not marshal cancellation")
+
+	cloner := &blockingCloner{started: make(chan struct{})}
+	worker := NewWorker(&MockNatsPublisher{}, cloner, testutil.NewTestLogger())
+	worker.Heartbeat = time.Millisecond
+
+	msg := &mockNatsMsg{data: data}
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		worker.handleDeploymentRequestInternal(msg)
+	}()
+	<-cloner.started
+
+	assert.Eventually(t, func() bool { return msg.inProgress.Load() >= 2 }, time.Second, time.Millisecond, "a long build should be reported in progress")
+	worker.handleCancellationRequestInternal(cancellation)
+	<-handled
+
+	reported := msg.inProgress.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, reported, msg.inProgress.Load(), "the heartbeat should stop when the build ends")
+	assert.True(t, msg.acked)
+}
+
+func TestHandleDeploymentRequestLogs(t *testing.T) {
+	request := events.New(events.SubjectDeploymentRequested, events.DeploymentRequest{
+		DeploymentID:  "dep-456",
+		AppID:         "app-123",
+		GitRepository: "https://github.com/example/app.git",
+		GitBranch:     "main",
+	})
+	data, err := json.Marshal(request)
+	require.NoError(t, err, "Setup failed: could not marshal request")
+
+	mockNATS := &MockNatsPublisher{}
+	worker := NewWorker(mockNATS, &mockCloner{}, testutil.NewTestLoggerWithOutput(io.Discard))
+	worker.handleDeploymentRequestInternal(&mockNatsMsg{data: data})
+
+	var messages []string
+	for i, subject := range mockNATS.PublishedSubjects {
+		if subject != events.SubjectDeploymentLog {
+			continue
+		}
+		line, err := events.Decode[events.DeploymentLog](mockNATS.PublishedMessages[i])
+		require.NoError(t, err, "Could not decode published log line")
+		assert.Equal(t, "dep-456", line.Data.DeploymentID)
+		assert.Equal(t, events.LogSourceBuild, line.Data.Source)
+		assert.Equal(t, request.CorrelationID, line.CorrelationID, "log lines should keep the request's correlation ID")
+		messages = append(messages, line.Data.Message)
+	}
+	assert.Equal(t, []string{
+		"Received deployment request for https://github.com/example/app.git at main",
+		"Checked out commit " + testCommitSHA,
+		"Built image registry.helios.internal/app-123:0123456789ab",
+	}, messages)
+	assert.Equal(t, events.SubjectBuildSucceeded, mockNATS.PublishedSubject, "the outcome should still be published last")
+}
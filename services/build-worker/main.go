@@ -24,7 +24,13 @@ func main() {
 	}
 	defer natsConn.Close()
 
+	// Provision the HELIOS stream so no events are lost while workers are down.
+	js, err := bootstrap.SetupJetStream(natsConn, log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("FATAL: Could not set up JetStream")
+	}
+
 	// --- Create and Run Application ---
-	app := platform.NewApp(log, natsConn)
+	app := platform.NewApp(log, natsConn, js)
 	app.Run()
 }
\ No newline at end of file
@@ -6,6 +6,7 @@ toolchain go1.24.3
 
 require (
 	github.com/nats-io/nats.go v1.45.0
+	gopkg.in/yaml.v3 v3.0.1
 	helios v0.0.0-00010101000000-000000000000
 )
 
@@ -16,7 +17,6 @@ require (
 	github.com/leodido/go-urn v1.2.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 require (
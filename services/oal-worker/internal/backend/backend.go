@@ -0,0 +1,149 @@
+// Package backend defines how the oal-worker releases an application on the
+// infrastructure selected by its current_backend, and the registry of the
+// backends it knows.
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"helios/pkg/heliosfile"
+)
+
+// Default is the backend of applications that do not name one, matching the
+// default of applications.current_backend.
+const Default = "docker_compose"
+
+// Release is a built image to run as the services of a manifest.
+type Release struct {
+	AppID        string
+	DeploymentID string
+	Image        string
+	Manifest     *heliosfile.Heliosfile
+	// Env is the interpolated env of the manifest's services.
+	Env heliosfile.Environment
+}
+
+// Plan is the configuration a backend would apply for a release.
+type Plan struct {
+	// Files maps the name of each rendered artifact to its content.
+	Files map[string][]byte
+}
+
+// FileNames returns the names of the rendered artifacts in order.
+func (p *Plan) FileNames() []string {
+	names := make([]string, 0, len(p.Files))
+	for name := range p.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServiceStatus is the state of one service or database of an application.
+type ServiceStatus struct {
+	Name string
+	// State is the backend's description of the service, such as
+	// "running".
+	State string
+	Ready bool
+}
+
+// Status is the state of the services and databases of an application.
+type Status struct {
+	Services []ServiceStatus
+}
+
+// Backend releases applications on one kind of infrastructure. Applications
+// are identified by their ID, which stays the same across deployments.
+type Backend interface {
+	// Plan renders the configuration for release without changing anything.
+	Plan(ctx context.Context, release Release) (*Plan, error)
+	// Apply makes release the running version of its application, and
+	// returns once its services are ready. Services that are no longer in
+	// the manifest are removed.
+	Apply(ctx context.Context, release Release) error
+	// Status reports the state of the application's services.
+	Status(ctx context.Context, appID string) (*Status, error)
+	// Destroy removes the application and all of its data, including the
+	// volumes of its databases.
+	Destroy(ctx context.Context, appID string) error
+}
+
+// ErrUnknownBackend is returned for a backend name that is not registered.
+var ErrUnknownBackend = errors.New("unknown backend")
+
+// Registry maps the names used in applications.current_backend to backends.
+type Registry struct {
+	backends map[string]Backend
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{backends: map[string]Backend{}}
+}
+
+// Register makes b available as name, replacing any backend registered
+// before under that name.
+func (r *Registry) Register(name string, b Backend) {
+	r.backends[name] = b
+}
+
+// Get returns the backend registered as name, or the Default backend if name
+// is empty.
+func (r *Registry) Get(name string) (Backend, error) {
+	if name == "" {
+		name = Default
+	}
+	b, ok := r.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of %s", ErrUnknownBackend, name, strings.Join(r.Names(), ", "))
+	}
+	return b, nil
+}
+
+// Names returns the registered names in order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run runs cmd, which was created with ctx, and returns its standard output.
+// If it fails, the error is the command's standard error, or reports that ctx
+// ran out of time. The backends that drive a command-line tool use it.
+func Run(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.New("timed out")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// NewPassword returns a random password for a managed database.
+func NewPassword() string {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("backend: failed to read random bytes: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubBackend is a Backend that does nothing.
+type stubBackend struct{}
+
+func (stubBackend) Plan(context.Context, Release) (*Plan, error)    { return &Plan{}, nil }
+func (stubBackend) Apply(context.Context, Release) error            { return nil }
+func (stubBackend) Status(context.Context, string) (*Status, error) { return &Status{}, nil }
+func (stubBackend) Destroy(context.Context, string) error           { return nil }
+
+func TestRegistry(t *testing.T) {
+	compose, k3s := &stubBackend{}, &stubBackend{}
+	r := NewRegistry()
+	r.Register(Default, compose)
+	r.Register("k3s", k3s)
+
+	assert.Equal(t, []string{"docker_compose", "k3s"}, r.Names())
+
+	b, err := r.Get("")
+	require.NoError(t, err)
+	assert.Same(t, compose, b, "an empty name should select the default backend")
+
+	b, err = r.Get("k3s")
+	require.NoError(t, err)
+	assert.Same(t, k3s, b)
+
+	_, err = r.Get("nomad")
+	assert.True(t, errors.Is(err, ErrUnknownBackend))
+	assert.EqualError(t, err, `unknown backend "nomad", expected one of docker_compose, k3s`)
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name          string
+		script        string
+		timeout       time.Duration
+		expectedOut   string
+		expectedError string
+	}{
+		{name: "Success", script: "echo out; echo err >&2", timeout: time.Minute, expectedOut: "out\n"},
+		{name: "Failure with stderr", script: "echo out; echo '  no such project ' >&2; exit 1", timeout: time.Minute, expectedError: "no such project"},
+		{name: "Failure without stderr", script: "exit 3", timeout: time.Minute, expectedError: "exit status 3"},
+		{name: "Timeout", script: "exec sleep 5", timeout: 50 * time.Millisecond, expectedError: "timed out"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			out, err := Run(ctx, exec.CommandContext(ctx, "sh", "-c", tc.script))
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOut, string(out))
+		})
+	}
+}
+
+func TestNewPassword(t *testing.T) {
+	a, b := NewPassword(), NewPassword()
+	assert.Len(t, a, 48)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,123 @@
+// Package dry implements a backend that renders deployments to the local
+// filesystem instead of running them, for trying Helios out and for
+// reviewing what the other backends would apply.
+package dry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/config"
+	"helios/pkg/heliosfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// currentFile names the deployment that was applied last.
+const currentFile = "current"
+
+// Backend writes the plans of other backends to a directory. Every
+// deployment of an application gets its own directory, holding the files of
+// each plan and the Heliosfile it was rendered from.
+type Backend struct {
+	// Dir is the directory the applications' directories are created in.
+	Dir string
+	// Planners render the files that are written.
+	Planners []backend.Backend
+}
+
+// NewBackend creates a Backend that writes to DRY_RUN_DIR the files rendered
+// by planners.
+func NewBackend(planners ...backend.Backend) *Backend {
+	return &Backend{
+		Dir:      config.Getenv("DRY_RUN_DIR", filepath.Join(os.TempDir(), "helios", "dry")),
+		Planners: planners,
+	}
+}
+
+// Plan merges the plans of the planners with the Heliosfile of the release.
+func (b *Backend) Plan(ctx context.Context, release backend.Release) (*backend.Plan, error) {
+	manifest, err := yaml.Marshal(release.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", heliosfile.FileName, err)
+	}
+	plan := &backend.Plan{Files: map[string][]byte{heliosfile.FileName: manifest}}
+
+	for _, p := range b.Planners {
+		rendered, err := p.Plan(ctx, release)
+		if err != nil {
+			return nil, err
+		}
+		for name, data := range rendered.Files {
+			if _, ok := plan.Files[name]; ok {
+				return nil, fmt.Errorf("more than one planner renders %s", name)
+			}
+			plan.Files[name] = data
+		}
+	}
+	return plan, nil
+}
+
+// Apply writes the plan of the release to <Dir>/<app ID>/<deployment ID> and
+// records it as the current deployment of the application.
+func (b *Backend) Apply(ctx context.Context, release backend.Release) error {
+	plan, err := b.Plan(ctx, release)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(b.Dir, release.AppID, release.DeploymentID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for name, data := range plan.Files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(b.Dir, release.AppID, currentFile), []byte(release.DeploymentID+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to record the current deployment: %w", err)
+	}
+	return nil
+}
+
+// Status lists the services and databases of the current deployment as
+// rendered. An application that was never applied has none.
+func (b *Backend) Status(_ context.Context, appID string) (*backend.Status, error) {
+	current, err := os.ReadFile(filepath.Join(b.Dir, appID, currentFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &backend.Status{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the current deployment: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(b.Dir, appID, strings.TrimSpace(string(current)), heliosfile.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", heliosfile.FileName, err)
+	}
+	manifest, err := heliosfile.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", heliosfile.FileName, err)
+	}
+
+	status := &backend.Status{}
+	for _, name := range append(manifest.DatabaseNames(), manifest.ServiceNames()...) {
+		status.Services = append(status.Services, backend.ServiceStatus{Name: name, State: "rendered", Ready: true})
+	}
+	return status, nil
+}
+
+// Destroy removes the directory of the application.
+func (b *Backend) Destroy(_ context.Context, appID string) error {
+	if err := os.RemoveAll(filepath.Join(b.Dir, appID)); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", appID, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package dry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/heliosfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filePlanner is a planner that renders fixed files.
+type filePlanner struct {
+	files map[string][]byte
+}
+
+func (p filePlanner) Plan(context.Context, backend.Release) (*backend.Plan, error) {
+	return &backend.Plan{Files: p.files}, nil
+}
+func (filePlanner) Apply(context.Context, backend.Release) error            { return nil }
+func (filePlanner) Status(context.Context, string) (*backend.Status, error) { return nil, nil }
+func (filePlanner) Destroy(context.Context, string) error                   { return nil }
+
+func testRelease(t *testing.T, deploymentID string) backend.Release {
+	manifest, err := heliosfile.Parse([]byte(`
+version: 1
+name: shop
+services:
+  web:
+    http_port: 8080
+  worker:
+    command: bin/worker
+databases:
+  postgres:
+    type: postgresql
+`))
+	require.NoError(t, err)
+	return backend.Release{AppID: "app-123", DeploymentID: deploymentID, Image: "shop:1", Manifest: manifest}
+}
+
+func TestApplyStatusDestroy(t *testing.T) {
+	b := &Backend{
+		Dir:      t.TempDir(),
+		Planners: []backend.Backend{filePlanner{files: map[string][]byte{"docker-compose.yml": []byte("services: {}\n")}}},
+	}
+	ctx := context.Background()
+
+	status, err := b.Status(ctx, "app-123")
+	require.NoError(t, err)
+	assert.Empty(t, status.Services, "an application that was never applied has no services")
+
+	require.NoError(t, b.Apply(ctx, testRelease(t, "dep-1")))
+	require.NoError(t, b.Apply(ctx, testRelease(t, "dep-2")))
+
+	for _, dep := range []string{"dep-1", "dep-2"} {
+		entries, err := os.ReadDir(filepath.Join(b.Dir, "app-123", dep))
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		assert.Equal(t, []string{"Heliosfile.yml", "docker-compose.yml"}, names)
+	}
+	current, err := os.ReadFile(filepath.Join(b.Dir, "app-123", "current"))
+	require.NoError(t, err)
+	assert.Equal(t, "dep-2\n", string(current))
+
+	status, err = b.Status(ctx, "app-123")
+	require.NoError(t, err)
+	assert.Equal(t, []backend.ServiceStatus{
+		{Name: "postgres", State: "rendered", Ready: true},
+		{Name: "web", State: "rendered", Ready: true},
+		{Name: "worker", State: "rendered", Ready: true},
+	}, status.Services)
+
+	require.NoError(t, b.Destroy(ctx, "app-123"))
+	assert.NoDirExists(t, filepath.Join(b.Dir, "app-123"))
+}
+
+func TestPlanConflict(t *testing.T) {
+	files := map[string][]byte{"docker-compose.yml": nil}
+	b := &Backend{Dir: t.TempDir(), Planners: []backend.Backend{filePlanner{files: files}, filePlanner{files: files}}}
+
+	_, err := b.Plan(context.Background(), testRelease(t, "dep-1"))
+	assert.EqualError(t, err, "more than one planner renders docker-compose.yml")
+}
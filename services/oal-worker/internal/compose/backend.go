@@ -0,0 +1,231 @@
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/config"
+	"helios/pkg/heliosfile"
+)
+
+const (
+	// composeFile is the name of the rendered Compose file.
+	composeFile = "docker-compose.yml"
+	// envFile holds the database passwords of a project. It is kept across
+	// deployments, since a database only reads its password when its volume
+	// is created.
+	envFile = "helios.env"
+)
+
+// Config holds the configuration of the Compose backend.
+type Config struct {
+	// Binary is the docker executable. Set DOCKER_HOST to deploy to a
+	// remote server, for example over ssh://.
+	Binary string
+	// ProjectsDir is where each application gets a directory with its
+	// Compose file and database passwords.
+	ProjectsDir string
+	// Timeout bounds each docker compose command, including waiting for the
+	// services to be healthy.
+	Timeout time.Duration
+}
+
+// NewConfig creates a Compose backend configuration from environment
+// variables.
+func NewConfig() Config {
+	return Config{
+		Binary:      config.Getenv("DOCKER_BINARY", "docker"),
+		ProjectsDir: config.Getenv("COMPOSE_PROJECTS_DIR", filepath.Join(os.TempDir(), "helios", "compose")),
+		Timeout:     config.GetenvDuration("COMPOSE_TIMEOUT", 10*time.Minute),
+	}
+}
+
+// Backend deploys applications with docker compose, one Compose project per
+// application.
+type Backend struct {
+	Config Config
+}
+
+// NewBackend creates a Backend configured from the environment.
+func NewBackend() *Backend {
+	return &Backend{Config: NewConfig()}
+}
+
+// project returns the Compose project name of an application.
+func project(appID string) string {
+	return "helios-" + appID
+}
+
+func (b *Backend) dir(appID string) string {
+	return filepath.Join(b.Config.ProjectsDir, project(appID))
+}
+
+// Plan renders the Compose file of the release.
+func (b *Backend) Plan(_ context.Context, release backend.Release) (*backend.Plan, error) {
+	data, err := Translate(release.Manifest, release.Env, release.Image).Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &backend.Plan{Files: map[string][]byte{composeFile: data}}, nil
+}
+
+// Apply writes the Compose file of the release and brings the project up,
+// waiting for its services to be running and healthy.
+func (b *Backend) Apply(ctx context.Context, release backend.Release) error {
+	plan, err := b.Plan(ctx, release)
+	if err != nil {
+		return err
+	}
+
+	dir := b.dir(release.AppID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, composeFile), plan.Files[composeFile], 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", composeFile, err)
+	}
+	if err := writePasswords(filepath.Join(dir, envFile), release.Manifest); err != nil {
+		return err
+	}
+
+	_, err = b.compose(ctx, release.AppID,
+		"--project-directory", dir, "--file", filepath.Join(dir, composeFile), "--env-file", filepath.Join(dir, envFile),
+		"up", "--detach", "--wait", "--remove-orphans")
+	if err != nil {
+		return fmt.Errorf("docker compose up failed: %w", err)
+	}
+	return nil
+}
+
+// psEntry is a container in the output of `docker compose ps --format json`.
+type psEntry struct {
+	Service string `json:"Service"`
+	State   string `json:"State"`
+	Health  string `json:"Health"`
+}
+
+// Status lists the containers of the application's project.
+func (b *Backend) Status(ctx context.Context, appID string) (*backend.Status, error) {
+	out, err := b.compose(ctx, appID, "ps", "--all", "--format", "json")
+	if err != nil {
+		return nil, fmt.Errorf("docker compose ps failed: %w", err)
+	}
+	entries, err := parsePS(out)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse docker compose ps output: %w", err)
+	}
+
+	status := &backend.Status{}
+	for _, e := range entries {
+		state := e.State
+		if e.Health != "" {
+			state += " (" + e.Health + ")"
+		}
+		status.Services = append(status.Services, backend.ServiceStatus{
+			Name:  e.Service,
+			State: state,
+			Ready: e.State == "running" && (e.Health == "" || e.Health == "healthy"),
+		})
+	}
+	return status, nil
+}
+
+// Destroy takes the project down, removing its volumes, and deletes its
+// directory.
+func (b *Backend) Destroy(ctx context.Context, appID string) error {
+	if _, err := b.compose(ctx, appID, "down", "--volumes", "--remove-orphans"); err != nil {
+		return fmt.Errorf("docker compose down failed: %w", err)
+	}
+	if err := os.RemoveAll(b.dir(appID)); err != nil {
+		return fmt.Errorf("failed to remove project directory: %w", err)
+	}
+	return nil
+}
+
+// compose runs a docker compose command on the project of an application.
+func (b *Backend) compose(ctx context.Context, appID string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.Config.Timeout)
+	defer cancel()
+
+	args = append([]string{"compose", "--project-name", project(appID)}, args...)
+	return backend.Run(ctx, exec.CommandContext(ctx, b.Config.Binary, args...))
+}
+
+// parsePS parses the output of `docker compose ps --format json`, which is a
+// JSON array in older releases of Compose and one object per line in newer
+// ones.
+func parsePS(out []byte) ([]psEntry, error) {
+	out = bytes.TrimSpace(out)
+	var entries []psEntry
+	if bytes.HasPrefix(out, []byte("[")) {
+		err := json.Unmarshal(out, &entries)
+		return entries, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e psEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// writePasswords makes sure the env file at path sets the password variable
+// of every database in manifest, generating the passwords that are missing.
+// Existing passwords are never changed.
+func writePasswords(path string, manifest *heliosfile.Heliosfile) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", envFile, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	set := map[string]bool{}
+	for _, line := range lines {
+		if name, _, ok := strings.Cut(line, "="); ok {
+			set[name] = true
+		}
+	}
+
+	changed := false
+	for _, name := range manifest.DatabaseNames() {
+		variable := heliosfile.PasswordVariable(name)
+		if manifest.Databases[name].Engine().PasswordEnv == "" || set[variable] {
+			continue
+		}
+		lines = append(lines, variable+"="+backend.NewPassword())
+		changed = true
+	}
+	if !changed && data != nil {
+		return nil
+	}
+
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", envFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/heliosfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocker writes a docker executable that appends its arguments to a log
+// and prints output, and returns a Backend using it and the path of the log.
+func fakeDocker(t *testing.T, output string) (*Backend, string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\ncat <<'EOF'\n" + output + "\nEOF\n"
+	binary := filepath.Join(dir, "docker")
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0o755))
+
+	return &Backend{Config: Config{Binary: binary, ProjectsDir: filepath.Join(dir, "projects"), Timeout: time.Minute}}, log
+}
+
+func readArgs(t *testing.T, log string) []string {
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestBackendApply(t *testing.T) {
+	b, log := fakeDocker(t, "")
+	manifest, err := heliosfile.Parse([]byte("version: 1\nname: shop\nservices:\n  web: {}\ndatabases:\n  postgres:\n    type: postgresql\n"))
+	require.NoError(t, err)
+
+	release := backend.Release{AppID: "app-123", DeploymentID: "dep-1", Image: testImage, Manifest: manifest}
+	require.NoError(t, b.Apply(context.Background(), release))
+
+	dir := filepath.Join(b.Config.ProjectsDir, "helios-app-123")
+	assert.Equal(t, []string{
+		"compose --project-name helios-app-123 --project-directory " + dir +
+			" --file " + filepath.Join(dir, "docker-compose.yml") + " --env-file " + filepath.Join(dir, "helios.env") +
+			" up --detach --wait --remove-orphans",
+	}, readArgs(t, log))
+
+	plan, err := b.Plan(context.Background(), release)
+	require.NoError(t, err)
+	written, err := os.ReadFile(filepath.Join(dir, "docker-compose.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, string(plan.Files["docker-compose.yml"]), string(written))
+
+	info, err := os.Stat(filepath.Join(dir, "helios.env"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the env file holds passwords")
+}
+
+func TestBackendStatus(t *testing.T) {
+	b, log := fakeDocker(t, `{"Service":"postgres","State":"running","Health":"healthy"}
+{"Service":"web","State":"running","Health":"starting"}
+{"Service":"worker","State":"exited","Health":""}`)
+
+	status, err := b.Status(context.Background(), "app-123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"compose --project-name helios-app-123 ps --all --format json"}, readArgs(t, log))
+	assert.Equal(t, []backend.ServiceStatus{
+		{Name: "postgres", State: "running (healthy)", Ready: true},
+		{Name: "web", State: "running (starting)", Ready: false},
+		{Name: "worker", State: "exited", Ready: false},
+	}, status.Services)
+}
+
+func TestBackendDestroy(t *testing.T) {
+	b, log := fakeDocker(t, "")
+	dir := filepath.Join(b.Config.ProjectsDir, "helios-app-123")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+
+	require.NoError(t, b.Destroy(context.Background(), "app-123"))
+	assert.Equal(t, []string{"compose --project-name helios-app-123 down --volumes --remove-orphans"}, readArgs(t, log))
+	assert.NoDirExists(t, dir)
+}
+
+func TestParsePS(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expected []psEntry
+	}{
+		{name: "Empty", output: "\n", expected: nil},
+		{
+			name:     "Array",
+			output:   `[{"Service":"web","State":"running","Health":""},{"Service":"db","State":"exited","Health":""}]`,
+			expected: []psEntry{{Service: "web", State: "running"}, {Service: "db", State: "exited"}},
+		},
+		{
+			name:     "Lines",
+			output:   "{\"Service\":\"web\",\"State\":\"running\"}\n\n{\"Service\":\"db\",\"State\":\"exited\"}\n",
+			expected: []psEntry{{Service: "web", State: "running"}, {Service: "db", State: "exited"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := parsePS([]byte(tc.output))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, entries)
+		})
+	}
+
+	_, err := parsePS([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestWritePasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), envFile)
+	parse := func(data string) *heliosfile.Heliosfile {
+		manifest, err := heliosfile.Parse([]byte(data))
+		require.NoError(t, err)
+		return manifest
+	}
+
+	require.NoError(t, writePasswords(path, parse("version: 1\nname: shop\nservices:\n  web: {}\ndatabases:\n  postgres:\n    type: postgresql\n  cache:\n    type: redis\n")))
+	first, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(first)), "\n")
+	require.Len(t, lines, 1, "redis has no password")
+	assert.True(t, strings.HasPrefix(lines[0], "HELIOS_POSTGRES_PASSWORD="))
+
+	require.NoError(t, writePasswords(path, parse("version: 1\nname: shop\nservices:\n  web: {}\ndatabases:\n  postgres:\n    type: postgresql\n  orders:\n    type: mysql\n")))
+	second, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(string(second)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, strings.TrimSpace(string(first)), lines[0], "existing passwords should not change")
+	assert.True(t, strings.HasPrefix(lines[1], "HELIOS_ORDERS_PASSWORD="))
+}
@@ -0,0 +1,194 @@
+// Package compose translates a Heliosfile into a Docker Compose file for the
+// docker_compose backend.
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"helios/pkg/heliosfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileVersion is the Compose file format version that is written.
+const FileVersion = "3.8"
+
+// File is a Compose file.
+type File struct {
+	Version  string             `yaml:"version"`
+	Services map[string]Service `yaml:"services"`
+	Volumes  map[string]Volume  `yaml:"volumes,omitempty"`
+}
+
+// Service is a Compose service.
+type Service struct {
+	Image       string            `yaml:"image"`
+	Command     string            `yaml:"command,omitempty"`
+	Restart     string            `yaml:"restart,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Ports       []PortMapping     `yaml:"ports,omitempty"`
+	Volumes     []string          `yaml:"volumes,omitempty"`
+	DependsOn   []string          `yaml:"depends_on,omitempty"`
+	Healthcheck *Healthcheck      `yaml:"healthcheck,omitempty"`
+	Deploy      *Deploy           `yaml:"deploy,omitempty"`
+}
+
+// PortMapping publishes a container port on the host, as "host:container".
+type PortMapping string
+
+// MarshalYAML quotes the mapping, since YAML 1.1 parsers read an unquoted
+// mapping like 22:22 as a base 60 number.
+func (p PortMapping) MarshalYAML() (any, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: string(p)}, nil
+}
+
+// Healthcheck is the command Compose runs to tell whether a container is
+// healthy.
+type Healthcheck struct {
+	Test        []string `yaml:"test,flow"`
+	Interval    string   `yaml:"interval"`
+	Timeout     string   `yaml:"timeout"`
+	Retries     int      `yaml:"retries"`
+	StartPeriod string   `yaml:"start_period"`
+}
+
+// Deploy holds the resources of a service.
+type Deploy struct {
+	Resources Resources `yaml:"resources"`
+}
+
+// Resources holds the resource limits of a service.
+type Resources struct {
+	Limits Limits `yaml:"limits"`
+}
+
+// Limits caps the CPU and memory of a service.
+type Limits struct {
+	CPUs   string `yaml:"cpus,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+}
+
+// Volume is a named volume managed by Compose.
+type Volume struct{}
+
+// restartPolicy keeps services running across crashes and server reboots.
+const restartPolicy = "unless-stopped"
+
+// Translate renders manifest as a Compose file in which every service runs
+// image with its interpolated env. Managed databases become services of their
+// own, with their data in a named volume, and the application services depend
+// on them. Database passwords and application secrets are left as variables
+// for the backend to set; see heliosfile.Secret.Variable.
+func Translate(manifest *heliosfile.Heliosfile, env heliosfile.Environment, image string) *File {
+	f := &File{
+		Version:  FileVersion,
+		Services: map[string]Service{},
+	}
+
+	databases := manifest.DatabaseNames()
+	for _, name := range databases {
+		db := manifest.Databases[name]
+		engine := db.Engine()
+		volume := name + "-data"
+
+		svc := Service{
+			Image:   db.Image(),
+			Restart: restartPolicy,
+			Volumes: []string{volume + ":" + engine.DataDir},
+			Healthcheck: &Healthcheck{
+				Test:        append([]string{"CMD"}, engine.Healthcheck...),
+				Interval:    "10s",
+				Timeout:     "5s",
+				Retries:     5,
+				StartPeriod: "30s",
+			},
+		}
+		if engine.Env != nil {
+			svc.Environment = engine.Env(manifest.Name)
+		}
+		if engine.PasswordEnv != "" {
+			svc.Environment[engine.PasswordEnv] = variable(heliosfile.Secret{Database: name})
+		}
+		f.Services[name] = svc
+
+		if f.Volumes == nil {
+			f.Volumes = map[string]Volume{}
+		}
+		f.Volumes[volume] = Volume{}
+	}
+
+	for _, name := range manifest.ServiceNames() {
+		s := manifest.Services[name]
+		svc := Service{
+			Image:     image,
+			Command:   escape(s.Command),
+			Restart:   restartPolicy,
+			DependsOn: databases,
+		}
+		if len(env[name]) > 0 {
+			svc.Environment = make(map[string]string, len(env[name]))
+			for k, v := range env[name] {
+				svc.Environment[k] = v.Format(escape, variable)
+			}
+		}
+		if s.HTTPPort != 0 {
+			port := strconv.Itoa(s.HTTPPort)
+			svc.Ports = []PortMapping{PortMapping(port + ":" + port)}
+		}
+		if h := s.Healthcheck; h != nil {
+			url := fmt.Sprintf("http://localhost:%d%s", h.Port, h.Path)
+			svc.Healthcheck = &Healthcheck{
+				Test:        []string{"CMD-SHELL", "curl -fsS " + escape(url) + " || exit 1"},
+				Interval:    "10s",
+				Timeout:     "5s",
+				Retries:     3,
+				StartPeriod: "10s",
+			}
+		}
+		if s.CPU != 0 || s.Memory != 0 {
+			var limits Limits
+			if s.CPU != 0 {
+				limits.CPUs = strconv.FormatFloat(s.CPU, 'f', -1, 64)
+			}
+			if s.Memory != 0 {
+				limits.Memory = strconv.Itoa(s.Memory) + "M"
+			}
+			svc.Deploy = &Deploy{Resources: Resources{Limits: limits}}
+		}
+		f.Services[name] = svc
+	}
+	return f
+}
+
+// Marshal renders f as YAML. Map keys are sorted, so a manifest always
+// renders to the same bytes.
+func (f *File) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to render compose file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to render compose file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// variable references the variable a secret is set in, failing the
+// deployment if it is not.
+func variable(s heliosfile.Secret) string {
+	if s.Database != "" {
+		return fmt.Sprintf("${%s:?database password is not set}", s.Variable())
+	}
+	return fmt.Sprintf("${%s:?secret %s is not set}", s.Variable(), s.Name)
+}
+
+// escape stops Compose from interpolating variables in a value taken from
+// the manifest.
+func escape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
@@ -0,0 +1,85 @@
+package compose
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helios/pkg/heliosfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const testImage = "registry.helios.internal/app-123:0123456789ab"
+
+// testVariables are the config vars and secrets of the test application.
+var testVariables = heliosfile.Variables{Config: map[string]string{"PLAN": "pro"}, Secrets: []string{"STRIPE_KEY"}}
+
+func translate(t *testing.T, manifest *heliosfile.Heliosfile) *File {
+	t.Helper()
+	env, err := heliosfile.Interpolate(manifest, testVariables)
+	require.NoError(t, err)
+	return Translate(manifest, env, testImage)
+}
+
+// TestTranslate renders each testdata/*.heliosfile.yml and compares it with
+// the matching .compose.yml golden file. Run with -update to rewrite them.
+func TestTranslate(t *testing.T) {
+	manifests, err := filepath.Glob(filepath.Join("testdata", "*.heliosfile.yml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, manifests)
+
+	for _, path := range manifests {
+		name := strings.TrimSuffix(filepath.Base(path), ".heliosfile.yml")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			manifest, err := heliosfile.Parse(data)
+			require.NoError(t, err)
+
+			out, err := translate(t, manifest).Marshal()
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", name+".compose.yml")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, out, 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(out))
+		})
+	}
+}
+
+func TestTranslateIsDeterministic(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "databases.heliosfile.yml"))
+	require.NoError(t, err)
+	manifest, err := heliosfile.Parse(data)
+	require.NoError(t, err)
+
+	first, err := translate(t, manifest).Marshal()
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		out, err := translate(t, manifest).Marshal()
+		require.NoError(t, err)
+		assert.Equal(t, string(first), string(out))
+	}
+}
+
+func TestTranslateDefault(t *testing.T) {
+	f := translate(t, heliosfile.Default("app-123"))
+
+	assert.Equal(t, map[string]Service{
+		"web": {
+			Image:   testImage,
+			Restart: "unless-stopped",
+			Ports:   []PortMapping{"8080:8080"},
+		},
+	}, f.Services)
+	assert.Empty(t, f.Volumes)
+}
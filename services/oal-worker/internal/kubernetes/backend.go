@@ -0,0 +1,223 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestsFile is the name of the rendered manifests in a plan.
+const manifestsFile = "kubernetes.yml"
+
+// managedSelector selects the objects the backend created.
+const managedSelector = "app.kubernetes.io/managed-by=helios"
+
+// pruneAllowlist lists the kinds of objects that are deleted when they are
+// no longer rendered. PersistentVolumeClaims are not, so removing a database
+// from the Heliosfile does not delete its data.
+var pruneAllowlist = []string{
+	"core/v1/ConfigMap",
+	"core/v1/Service",
+	"apps/v1/Deployment",
+	"apps/v1/StatefulSet",
+	"networking.k8s.io/v1/Ingress",
+}
+
+// Config holds the configuration of the Kubernetes backend.
+type Config struct {
+	// Binary is the kubectl executable. It uses KUBECONFIG, or the
+	// in-cluster configuration, to reach the cluster.
+	Binary string
+	// Domain is the domain Ingress hosts are created under. If empty,
+	// Ingresses match every host.
+	Domain string
+	// Timeout bounds each kubectl command, including waiting for a rollout.
+	Timeout time.Duration
+}
+
+// NewConfig creates a Kubernetes backend configuration from environment
+// variables.
+func NewConfig() Config {
+	return Config{
+		Binary:  config.Getenv("KUBECTL_BINARY", "kubectl"),
+		Domain:  config.Getenv("KUBE_INGRESS_DOMAIN", ""),
+		Timeout: config.GetenvDuration("KUBECTL_TIMEOUT", 10*time.Minute),
+	}
+}
+
+// Backend deploys applications to a Kubernetes cluster such as k3s with
+// kubectl, one namespace per application.
+type Backend struct {
+	Config Config
+}
+
+// NewBackend creates a Backend configured from the environment.
+func NewBackend() *Backend {
+	return &Backend{Config: NewConfig()}
+}
+
+// Namespace returns the namespace of an application.
+func Namespace(appID string) string {
+	return "helios-" + appID
+}
+
+// Plan renders the manifests of the release.
+func (b *Backend) Plan(_ context.Context, release backend.Release) (*backend.Plan, error) {
+	list, err := Translate(release.Manifest, release.Env, release.Image, b.Config.Domain)
+	if err != nil {
+		return nil, err
+	}
+	data, err := list.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &backend.Plan{Files: map[string][]byte{manifestsFile: data}}, nil
+}
+
+// Apply creates the namespace and database Secrets of the application if
+// they do not exist, applies the manifests of the release, deleting objects
+// that are no longer rendered, and waits for every rollout to finish.
+func (b *Backend) Apply(ctx context.Context, release backend.Release) error {
+	list, err := Translate(release.Manifest, release.Env, release.Image, b.Config.Domain)
+	if err != nil {
+		return err
+	}
+	manifests, err := list.Marshal()
+	if err != nil {
+		return err
+	}
+	ns := Namespace(release.AppID)
+
+	namespace, err := yaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]any{"name": ns, "labels": map[string]string{"app.kubernetes.io/managed-by": "helios"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render namespace: %w", err)
+	}
+	if _, err := b.kubectl(ctx, bytes.NewReader(namespace), "apply", "--filename", "-"); err != nil {
+		return fmt.Errorf("failed to create namespace %s: %w", ns, err)
+	}
+
+	for _, name := range release.Manifest.DatabaseNames() {
+		if release.Manifest.Databases[name].Engine().PasswordEnv == "" {
+			continue
+		}
+		if err := b.ensureSecret(ctx, ns, SecretName(name)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := b.kubectl(ctx, bytes.NewReader(manifests), "apply", "--namespace", ns, "--filename", "-",
+		"--prune", "--selector", managedSelector, "--prune-allowlist", strings.Join(pruneAllowlist, ",")); err != nil {
+		return fmt.Errorf("kubectl apply failed: %w", err)
+	}
+
+	for _, o := range list {
+		typeMeta, meta := o.meta()
+		if typeMeta.Kind != "Deployment" && typeMeta.Kind != "StatefulSet" {
+			continue
+		}
+		resource := strings.ToLower(typeMeta.Kind) + "/" + meta.Name
+		if _, err := b.kubectl(ctx, nil, "rollout", "status", "--namespace", ns, resource); err != nil {
+			return fmt.Errorf("rollout of %s did not finish: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+// ensureSecret creates the Secret holding a new database password, unless
+// it exists. The password is passed on standard input, so it does not show
+// up in the process list.
+func (b *Backend) ensureSecret(ctx context.Context, ns, name string) error {
+	out, err := b.kubectl(ctx, nil, "get", "secret", name, "--namespace", ns, "--ignore-not-found", "--output", "name")
+	if err != nil {
+		return fmt.Errorf("failed to look up secret %s: %w", name, err)
+	}
+	if len(bytes.TrimSpace(out)) > 0 {
+		return nil
+	}
+
+	secret, err := yaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]any{"name": name},
+		"stringData": map[string]string{PasswordKey: backend.NewPassword()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render secret %s: %w", name, err)
+	}
+	if _, err := b.kubectl(ctx, bytes.NewReader(secret), "create", "--namespace", ns, "--filename", "-"); err != nil {
+		return fmt.Errorf("failed to create secret %s: %w", name, err)
+	}
+	return nil
+}
+
+// workloadList is the part of `kubectl get -o json` the backend reads.
+type workloadList struct {
+	Items []struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Replicas int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			ReadyReplicas int `json:"readyReplicas"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// Status reports the ready replicas of the Deployments and StatefulSets of
+// the application.
+func (b *Backend) Status(ctx context.Context, appID string) (*backend.Status, error) {
+	out, err := b.kubectl(ctx, nil, "get", "deployments,statefulsets", "--namespace", Namespace(appID),
+		"--selector", managedSelector, "--output", "json")
+	if err != nil {
+		return nil, fmt.Errorf("kubectl get failed: %w", err)
+	}
+	var workloads workloadList
+	if err := json.Unmarshal(out, &workloads); err != nil {
+		return nil, fmt.Errorf("could not parse kubectl get output: %w", err)
+	}
+
+	status := &backend.Status{}
+	for _, item := range workloads.Items {
+		status.Services = append(status.Services, backend.ServiceStatus{
+			Name:  item.Metadata.Name,
+			State: fmt.Sprintf("%d/%d ready", item.Status.ReadyReplicas, item.Spec.Replicas),
+			Ready: item.Spec.Replicas > 0 && item.Status.ReadyReplicas >= item.Spec.Replicas,
+		})
+	}
+	return status, nil
+}
+
+// Destroy deletes the namespace of the application with everything in it.
+func (b *Backend) Destroy(ctx context.Context, appID string) error {
+	if _, err := b.kubectl(ctx, nil, "delete", "namespace", Namespace(appID), "--ignore-not-found"); err != nil {
+		return fmt.Errorf("kubectl delete failed: %w", err)
+	}
+	return nil
+}
+
+// kubectl runs kubectl with stdin as its standard input.
+func (b *Backend) kubectl(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.Config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, b.Config.Binary, args...)
+	cmd.Stdin = stdin
+	return backend.Run(ctx, cmd)
+}
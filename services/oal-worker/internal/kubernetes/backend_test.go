@@ -0,0 +1,138 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/heliosfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKubectl writes a kubectl executable that appends its arguments to
+// args.log, saves what it reads from "--filename -" to stdin-<n>.yml, where n
+// is the number of the command, and prints secret for `get secret` and
+// workloads for `get deployments,statefulsets`. It returns a Backend using it
+// and the directory of the files.
+func fakeKubectl(t *testing.T, secret, workloads string) (*Backend, string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte(secret), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "workloads"), []byte(workloads), 0o644))
+
+	script := `#!/bin/sh
+cd "` + dir + `"
+echo "$@" >> args.log
+case "$*" in
+*"--filename -"*) cat > "stdin-$(wc -l < args.log | tr -d ' ').yml" ;;
+"get secret"*) cat secret ;;
+"get deployments,statefulsets"*) cat workloads ;;
+esac
+`
+	binary := filepath.Join(dir, "kubectl")
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0o755))
+
+	return &Backend{Config: Config{Binary: binary, Domain: testDomain, Timeout: time.Minute}}, dir
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestBackendApply(t *testing.T) {
+	apply := "apply --namespace helios-app-123 --filename - --prune --selector app.kubernetes.io/managed-by=helios --prune-allowlist " +
+		strings.Join(pruneAllowlist, ",")
+	rollouts := []string{
+		"rollout status --namespace helios-app-123 deployment/api",
+		"rollout status --namespace helios-app-123 deployment/jobs",
+		"rollout status --namespace helios-app-123 statefulset/cache",
+		"rollout status --namespace helios-app-123 statefulset/orders-db",
+	}
+
+	testCases := []struct {
+		name          string
+		secret        string
+		expectedArgs  []string
+		createsSecret bool
+	}{
+		{
+			name: "New secret",
+			expectedArgs: append([]string{
+				"apply --filename -",
+				"get secret orders-db-credentials --namespace helios-app-123 --ignore-not-found --output name",
+				"create --namespace helios-app-123 --filename -",
+				apply,
+			}, rollouts...),
+			createsSecret: true,
+		},
+		{
+			name:   "Existing secret",
+			secret: "secret/orders-db-credentials\n",
+			expectedArgs: append([]string{
+				"apply --filename -",
+				"get secret orders-db-credentials --namespace helios-app-123 --ignore-not-found --output name",
+				apply,
+			}, rollouts...),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, dir := fakeKubectl(t, tc.secret, "")
+			manifest, err := heliosfile.Parse([]byte(readFile(t, filepath.Join("testdata", "databases.heliosfile.yml"))))
+			require.NoError(t, err)
+
+			release := backend.Release{AppID: "app-123", DeploymentID: "dep-1", Image: testImage, Manifest: manifest}
+			require.NoError(t, b.Apply(context.Background(), release))
+
+			args := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dir, "args.log"))), "\n")
+			assert.Equal(t, tc.expectedArgs, args)
+
+			assert.Contains(t, readFile(t, filepath.Join(dir, "stdin-1.yml")), "name: helios-app-123")
+			applied := "stdin-3.yml"
+			if tc.createsSecret {
+				secret := readFile(t, filepath.Join(dir, "stdin-3.yml"))
+				assert.Contains(t, secret, "kind: Secret")
+				assert.Contains(t, secret, "name: orders-db-credentials")
+				assert.Contains(t, secret, PasswordKey+": ")
+				applied = "stdin-4.yml"
+			}
+			plan, err := b.Plan(context.Background(), release)
+			require.NoError(t, err)
+			assert.Equal(t, string(plan.Files["kubernetes.yml"]), readFile(t, filepath.Join(dir, applied)))
+		})
+	}
+}
+
+func TestBackendStatus(t *testing.T) {
+	b, dir := fakeKubectl(t, "", `{"items": [
+  {"kind": "Deployment", "metadata": {"name": "web"}, "spec": {"replicas": 1}, "status": {"readyReplicas": 1}},
+  {"kind": "Deployment", "metadata": {"name": "worker"}, "spec": {"replicas": 1}, "status": {}},
+  {"kind": "StatefulSet", "metadata": {"name": "postgres"}, "spec": {"replicas": 1}, "status": {"readyReplicas": 1}}
+]}`)
+
+	status, err := b.Status(context.Background(), "app-123")
+	require.NoError(t, err)
+	assert.Equal(t, "get deployments,statefulsets --namespace helios-app-123 --selector app.kubernetes.io/managed-by=helios --output json\n",
+		readFile(t, filepath.Join(dir, "args.log")))
+	assert.Equal(t, []backend.ServiceStatus{
+		{Name: "web", State: "1/1 ready", Ready: true},
+		{Name: "worker", State: "0/1 ready", Ready: false},
+		{Name: "postgres", State: "1/1 ready", Ready: true},
+	}, status.Services)
+}
+
+func TestBackendDestroy(t *testing.T) {
+	b, dir := fakeKubectl(t, "", "")
+
+	require.NoError(t, b.Destroy(context.Background(), "app-123"))
+	assert.Equal(t, "delete namespace helios-app-123 --ignore-not-found\n", readFile(t, filepath.Join(dir, "args.log")))
+}
@@ -0,0 +1,368 @@
+// Package kubernetes translates a Heliosfile into Kubernetes manifests for
+// the k3s backend.
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"helios/pkg/heliosfile"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultStorage is the storage requested for a database that does not set
+// one.
+const DefaultStorage = "1Gi"
+
+// PasswordKey is the key of the password in the Secret of a database.
+const PasswordKey = "password"
+
+// SecretName returns the name of the Secret holding the password of the
+// database called name. The backend creates it when it applies the
+// manifests, so passwords are never written to them.
+func SecretName(name string) string {
+	return name + "-credentials"
+}
+
+// SecretsName is the name of the Secret holding the secrets of the
+// application, one key per secret. Services that refer to a secret read it
+// from there.
+const SecretsName = "secrets"
+
+// configHashAnnotation changes whenever the environment of a service does,
+// so pods are replaced when only their ConfigMap changed.
+const configHashAnnotation = "helios.dev/config-hash"
+
+// kindOrder is the order objects are listed in: those an object refers to
+// come before it, as `kubectl apply` and Helm expect.
+var kindOrder = map[string]int{
+	"ConfigMap":             0,
+	"PersistentVolumeClaim": 1,
+	"Service":               2,
+	"Deployment":            3,
+	"StatefulSet":           4,
+	"Ingress":               5,
+}
+
+// List is the manifests of an application, in a stable order.
+type List []Object
+
+// Translate renders manifest as Kubernetes objects in which every service
+// runs image with its interpolated env:
+//
+//   - every service gets a Deployment, with its environment in a ConfigMap
+//     and its healthcheck as a readiness probe. Values that embed a database
+//     password or an application secret are set on the container instead,
+//     from the Secrets named by SecretName and SecretsName;
+//   - a service with an HTTP port also gets a Service and an Ingress for the
+//     host <service>.<app>.<domain>, or for every host if domain is empty;
+//   - every database gets a StatefulSet, a headless Service and a
+//     PersistentVolumeClaim. Its password is read from the Secret named by
+//     SecretName.
+func Translate(manifest *heliosfile.Heliosfile, env heliosfile.Environment, image, domain string) (List, error) {
+	var list List
+
+	for _, name := range manifest.ServiceNames() {
+		objects, err := service(manifest.Name, name, manifest.Services[name], env[name], image, domain)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, objects...)
+	}
+	for _, name := range manifest.DatabaseNames() {
+		list = append(list, database(manifest.Name, name, manifest.Databases[name])...)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		ti, mi := list[i].meta()
+		tj, mj := list[j].meta()
+		if kindOrder[ti.Kind] != kindOrder[tj.Kind] {
+			return kindOrder[ti.Kind] < kindOrder[tj.Kind]
+		}
+		return mi.Name < mj.Name
+	})
+	return list, nil
+}
+
+// Marshal renders the list as a multi-document YAML stream. Map keys are
+// sorted, so a manifest always renders to the same bytes.
+func (l List) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, o := range l {
+		if err := enc.Encode(o); err != nil {
+			return nil, fmt.Errorf("failed to render kubernetes manifests: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to render kubernetes manifests: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func service(app, name string, s heliosfile.Service, env map[string]heliosfile.Value, image, domain string) ([]Object, error) {
+	args, err := heliosfile.SplitCommand(s.Command)
+	if err != nil {
+		return nil, fmt.Errorf("command of service %s %w", name, err)
+	}
+
+	container := Container{
+		Name:      name,
+		Image:     image,
+		Args:      args,
+		Resources: resources(s),
+	}
+	template := PodTemplateSpec{Metadata: ObjectMeta{Labels: labels(app, name)}}
+
+	var objects []Object
+	data, secretEnv := splitEnv(env)
+	if len(data) > 0 {
+		configMap := &ConfigMap{
+			TypeMeta: TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			Metadata: ObjectMeta{Name: name + "-env", Labels: labels(app, name)},
+			Data:     data,
+		}
+		objects = append(objects, configMap)
+		container.EnvFrom = []EnvFromSource{{ConfigMapRef: LocalObjectReference{Name: configMap.Metadata.Name}}}
+		template.Metadata.Annotations = map[string]string{configHashAnnotation: hashEnv(data)}
+	}
+	container.Env = secretEnv
+	if s.HTTPPort != 0 {
+		container.Ports = []ContainerPort{{Name: "http", ContainerPort: s.HTTPPort}}
+	}
+	if h := s.Healthcheck; h != nil {
+		container.ReadinessProbe = &Probe{
+			HTTPGet:          &HTTPGetAction{Path: h.Path, Port: h.Port},
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 3,
+		}
+	}
+	template.Spec.Containers = []Container{container}
+
+	objects = append(objects, &Deployment{
+		TypeMeta: TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		Metadata: ObjectMeta{Name: name, Labels: labels(app, name)},
+		Spec: DeploymentSpec{
+			Replicas: 1,
+			Selector: LabelSelector{MatchLabels: selector(app, name)},
+			Template: template,
+		},
+	})
+
+	if s.HTTPPort != 0 {
+		host := ""
+		if domain != "" {
+			host = name + "." + app + "." + domain
+		}
+		objects = append(objects,
+			&Service{
+				TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Service"},
+				Metadata: ObjectMeta{Name: name, Labels: labels(app, name)},
+				Spec: ServiceSpec{
+					Selector: selector(app, name),
+					Ports:    []ServicePort{{Name: "http", Port: s.HTTPPort, TargetPort: "http"}},
+				},
+			},
+			&Ingress{
+				TypeMeta: TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+				Metadata: ObjectMeta{Name: name, Labels: labels(app, name)},
+				Spec: IngressSpec{Rules: []IngressRule{{
+					Host: host,
+					HTTP: HTTPIngressRule{Paths: []HTTPIngressPath{{
+						Path:     "/",
+						PathType: "Prefix",
+						Backend: IngressBackend{Service: IngressServiceBackend{
+							Name: name,
+							Port: ServiceBackendPort{Name: "http"},
+						}},
+					}}},
+				}}},
+			},
+		)
+	}
+	return objects, nil
+}
+
+// splitEnv returns the values of env that embed no secrets, for a ConfigMap,
+// and container variables for the others. Those read each secret into a
+// variable from its Secret, and refer to it with $(VARIABLE).
+func splitEnv(env map[string]heliosfile.Value) (map[string]string, []EnvVar) {
+	data := map[string]string{}
+	secrets := map[string]heliosfile.Secret{}
+	var values []EnvVar
+	for _, name := range sortedKeys(env) {
+		value := env[name]
+		if text, ok := value.Text(); ok {
+			data[name] = text
+			continue
+		}
+		for _, s := range value.Secrets() {
+			secrets[s.Variable()] = s
+		}
+		values = append(values, EnvVar{
+			Name:  name,
+			Value: value.Format(escape, func(s heliosfile.Secret) string { return "$(" + s.Variable() + ")" }),
+		})
+	}
+
+	var vars []EnvVar
+	for _, variable := range sortedKeys(secrets) {
+		s := secrets[variable]
+		ref := SecretKeySelector{Name: SecretsName, Key: s.Name}
+		if s.Database != "" {
+			ref = SecretKeySelector{Name: SecretName(s.Database), Key: PasswordKey}
+		}
+		vars = append(vars, EnvVar{Name: variable, ValueFrom: &EnvVarSource{SecretKeyRef: ref}})
+	}
+	return data, append(vars, values...)
+}
+
+// escape stops Kubernetes from expanding $(VARIABLE) in text taken from the
+// manifest.
+func escape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+func database(app, name string, db heliosfile.Database) []Object {
+	engine := db.Engine()
+	claim := name + "-data"
+
+	storage := DefaultStorage
+	if db.Storage != 0 {
+		storage = strconv.Itoa(db.Storage) + "Gi"
+	}
+
+	var env []EnvVar
+	if engine.Env != nil {
+		values := engine.Env(app)
+		for _, k := range sortedKeys(values) {
+			env = append(env, EnvVar{Name: k, Value: values[k]})
+		}
+	}
+	if engine.PasswordEnv != "" {
+		env = append(env, EnvVar{
+			Name:      engine.PasswordEnv,
+			ValueFrom: &EnvVarSource{SecretKeyRef: SecretKeySelector{Name: SecretName(name), Key: PasswordKey}},
+		})
+	}
+
+	return []Object{
+		&PersistentVolumeClaim{
+			TypeMeta: TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+			Metadata: ObjectMeta{Name: claim, Labels: labels(app, name)},
+			Spec: PVCSpec{
+				AccessModes: []string{"ReadWriteOnce"},
+				Resources:   ResourceRequirements{Requests: map[string]string{"storage": storage}},
+			},
+		},
+		&Service{
+			TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Service"},
+			Metadata: ObjectMeta{Name: name, Labels: labels(app, name)},
+			Spec: ServiceSpec{
+				ClusterIP: "None",
+				Selector:  selector(app, name),
+				Ports:     []ServicePort{{Name: "db", Port: engine.Port, TargetPort: "db"}},
+			},
+		},
+		&StatefulSet{
+			TypeMeta: TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			Metadata: ObjectMeta{Name: name, Labels: labels(app, name)},
+			Spec: StatefulSetSpec{
+				ServiceName: name,
+				Replicas:    1,
+				Selector:    LabelSelector{MatchLabels: selector(app, name)},
+				Template: PodTemplateSpec{
+					Metadata: ObjectMeta{Labels: labels(app, name)},
+					Spec: PodSpec{
+						Containers: []Container{{
+							Name:  name,
+							Image: db.Image(),
+							Ports: []ContainerPort{{Name: "db", ContainerPort: engine.Port}},
+							Env:   env,
+							ReadinessProbe: &Probe{
+								Exec:                &ExecAction{Command: engine.Healthcheck},
+								InitialDelaySeconds: 5,
+								PeriodSeconds:       10,
+								TimeoutSeconds:      5,
+								FailureThreshold:    3,
+							},
+							VolumeMounts: []VolumeMount{{Name: "data", MountPath: engine.DataDir}},
+						}},
+						Volumes: []Volume{{
+							Name:                  "data",
+							PersistentVolumeClaim: PersistentVolumeClaimVolumeSource{ClaimName: claim},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// resources reserves the CPU and memory of a service and caps it at the
+// same amounts.
+func resources(s heliosfile.Service) ResourceRequirements {
+	amounts := map[string]string{}
+	if s.CPU != 0 {
+		amounts["cpu"] = cpuQuantity(s.CPU)
+	}
+	if s.Memory != 0 {
+		amounts["memory"] = strconv.Itoa(s.Memory) + "Mi"
+	}
+	if len(amounts) == 0 {
+		return ResourceRequirements{}
+	}
+	return ResourceRequirements{Requests: amounts, Limits: amounts}
+}
+
+// cpuQuantity formats cores as a Kubernetes quantity, in millicores unless
+// it is a whole number of cores.
+func cpuQuantity(cores float64) string {
+	millis := int(math.Round(cores * 1000))
+	if millis%1000 == 0 {
+		return strconv.Itoa(millis / 1000)
+	}
+	return strconv.Itoa(millis) + "m"
+}
+
+func labels(app, name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       name,
+		"app.kubernetes.io/part-of":    app,
+		"app.kubernetes.io/managed-by": "helios",
+	}
+}
+
+func selector(app, name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":    name,
+		"app.kubernetes.io/part-of": app,
+	}
+}
+
+// hashEnv returns a short digest of env that does not depend on map order.
+func hashEnv(env map[string]string) string {
+	h := sha256.New()
+	for _, k := range sortedKeys(env) {
+		fmt.Fprintf(h, "%s=%s\x00", k, env[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,148 @@
+package kubernetes
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helios/pkg/heliosfile"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const (
+	testImage  = "registry.helios.internal/app-123:0123456789ab"
+	testDomain = "apps.example.com"
+)
+
+// testVariables are the config vars and secrets of the test application.
+var testVariables = heliosfile.Variables{Config: map[string]string{"PLAN": "pro"}, Secrets: []string{"STRIPE_KEY"}}
+
+func translate(t *testing.T, manifest *heliosfile.Heliosfile, domain string) List {
+	t.Helper()
+	env, err := heliosfile.Interpolate(manifest, testVariables)
+	require.NoError(t, err)
+	list, err := Translate(manifest, env, testImage, domain)
+	require.NoError(t, err)
+	return list
+}
+
+func translateFile(t *testing.T, path string) List {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	manifest, err := heliosfile.Parse(data)
+	require.NoError(t, err)
+	return translate(t, manifest, testDomain)
+}
+
+// TestTranslate renders each testdata/*.heliosfile.yml and compares it with
+// the matching .k8s.yml golden file. Run with -update to rewrite them.
+func TestTranslate(t *testing.T) {
+	manifests, err := filepath.Glob(filepath.Join("testdata", "*.heliosfile.yml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, manifests)
+
+	for _, path := range manifests {
+		name := strings.TrimSuffix(filepath.Base(path), ".heliosfile.yml")
+		t.Run(name, func(t *testing.T) {
+			out, err := translateFile(t, path).Marshal()
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", name+".k8s.yml")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, out, 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(out))
+		})
+	}
+}
+
+func TestTranslateIsDeterministic(t *testing.T) {
+	path := filepath.Join("testdata", "full.heliosfile.yml")
+	first, err := translateFile(t, path).Marshal()
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		out, err := translateFile(t, path).Marshal()
+		require.NoError(t, err)
+		assert.Equal(t, string(first), string(out))
+	}
+}
+
+func TestTranslateObjects(t *testing.T) {
+	list := translateFile(t, filepath.Join("testdata", "full.heliosfile.yml"))
+
+	var objects []string
+	for _, o := range list {
+		typeMeta, meta := o.meta()
+		objects = append(objects, typeMeta.Kind+"/"+meta.Name)
+	}
+	assert.Equal(t, []string{
+		"ConfigMap/web-env",
+		"ConfigMap/worker-env",
+		"PersistentVolumeClaim/postgres-data",
+		"Service/postgres",
+		"Service/web",
+		"Deployment/web",
+		"Deployment/worker",
+		"StatefulSet/postgres",
+		"Ingress/web",
+	}, objects, "objects should be ordered by kind, then name")
+
+	// Every document is valid YAML with a kind and a name.
+	out, err := list.Marshal()
+	require.NoError(t, err)
+	dec := yaml.NewDecoder(strings.NewReader(string(out)))
+	count := 0
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := dec.Decode(&doc); err != nil {
+			break
+		}
+		assert.NotEmpty(t, doc.Kind)
+		assert.NotEmpty(t, doc.Metadata.Name)
+		count++
+	}
+	assert.Equal(t, len(list), count)
+}
+
+func TestTranslateConfigHash(t *testing.T) {
+	manifest := &heliosfile.Heliosfile{
+		Name:     "app",
+		Services: map[string]heliosfile.Service{"web": {Env: map[string]string{"A": "1"}}},
+	}
+	hash := func() string {
+		for _, o := range translate(t, manifest, "") {
+			if d, ok := o.(*Deployment); ok {
+				return d.Spec.Template.Metadata.Annotations[configHashAnnotation]
+			}
+		}
+		t.Fatal("no deployment rendered")
+		return ""
+	}
+
+	before := hash()
+	manifest.Services["web"].Env["A"] = "2"
+	assert.NotEqual(t, before, hash(), "changing the environment should replace the pods")
+}
+
+func TestCPUQuantity(t *testing.T) {
+	testCases := map[float64]string{0.5: "500m", 1: "1", 2: "2", 0.25: "250m", 1.5: "1500m"}
+	for cores, expected := range testCases {
+		assert.Equal(t, expected, cpuQuantity(cores))
+	}
+}
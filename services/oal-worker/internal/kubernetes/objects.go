@@ -0,0 +1,231 @@
+package kubernetes
+
+// The types below are the subset of the Kubernetes API the translator
+// renders. Field names and order follow the API, so the output reads like
+// hand-written manifests.
+
+// TypeMeta identifies the kind of an object.
+type TypeMeta struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+}
+
+// ObjectMeta names and labels an object.
+type ObjectMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Object is a Kubernetes object the translator renders.
+type Object interface {
+	meta() (TypeMeta, ObjectMeta)
+}
+
+// ConfigMap holds the environment of a service.
+type ConfigMap struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta        `yaml:"metadata"`
+	Data     map[string]string `yaml:"data"`
+}
+
+func (o *ConfigMap) meta() (TypeMeta, ObjectMeta) { return o.TypeMeta, o.Metadata }
+
+// PersistentVolumeClaim requests the storage of a database.
+type PersistentVolumeClaim struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     PVCSpec    `yaml:"spec"`
+}
+
+func (o *PersistentVolumeClaim) meta() (TypeMeta, ObjectMeta) { return o.TypeMeta, o.Metadata }
+
+type PVCSpec struct {
+	AccessModes []string             `yaml:"accessModes"`
+	Resources   ResourceRequirements `yaml:"resources"`
+}
+
+// Service gives the pods of a service or database a stable address.
+type Service struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta  `yaml:"metadata"`
+	Spec     ServiceSpec `yaml:"spec"`
+}
+
+func (o *Service) meta() (TypeMeta, ObjectMeta) { return o.TypeMeta, o.Metadata }
+
+type ServiceSpec struct {
+	// ClusterIP is "None" for the headless services of databases.
+	ClusterIP string            `yaml:"clusterIP,omitempty"`
+	Selector  map[string]string `yaml:"selector"`
+	Ports     []ServicePort     `yaml:"ports"`
+}
+
+type ServicePort struct {
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	TargetPort string `yaml:"targetPort"`
+}
+
+// Deployment runs the pods of a service.
+type Deployment struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta     `yaml:"metadata"`
+	Spec     DeploymentSpec `yaml:"spec"`
+}
+
+func (o *Deployment) meta() (TypeMeta, ObjectMeta) { return o.TypeMeta, o.Metadata }
+
+type DeploymentSpec struct {
+	Replicas int             `yaml:"replicas"`
+	Selector LabelSelector   `yaml:"selector"`
+	Template PodTemplateSpec `yaml:"template"`
+}
+
+// StatefulSet runs the pod of a database.
+type StatefulSet struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta      `yaml:"metadata"`
+	Spec     StatefulSetSpec `yaml:"spec"`
+}
+
+func (o *StatefulSet) meta() (TypeMeta, ObjectMeta) { return o.TypeMeta, o.Metadata }
+
+type StatefulSetSpec struct {
+	ServiceName string          `yaml:"serviceName"`
+	Replicas    int             `yaml:"replicas"`
+	Selector    LabelSelector   `yaml:"selector"`
+	Template    PodTemplateSpec `yaml:"template"`
+}
+
+type LabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type PodTemplateSpec struct {
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     PodSpec    `yaml:"spec"`
+}
+
+type PodSpec struct {
+	Containers []Container `yaml:"containers"`
+	Volumes    []Volume    `yaml:"volumes,omitempty"`
+}
+
+type Container struct {
+	Name           string               `yaml:"name"`
+	Image          string               `yaml:"image"`
+	Args           []string             `yaml:"args,omitempty"`
+	Ports          []ContainerPort      `yaml:"ports,omitempty"`
+	EnvFrom        []EnvFromSource      `yaml:"envFrom,omitempty"`
+	Env            []EnvVar             `yaml:"env,omitempty"`
+	Resources      ResourceRequirements `yaml:"resources,omitempty"`
+	ReadinessProbe *Probe               `yaml:"readinessProbe,omitempty"`
+	VolumeMounts   []VolumeMount        `yaml:"volumeMounts,omitempty"`
+}
+
+type ContainerPort struct {
+	Name          string `yaml:"name"`
+	ContainerPort int    `yaml:"containerPort"`
+}
+
+type EnvFromSource struct {
+	ConfigMapRef LocalObjectReference `yaml:"configMapRef"`
+}
+
+type LocalObjectReference struct {
+	Name string `yaml:"name"`
+}
+
+type EnvVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *EnvVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type EnvVarSource struct {
+	SecretKeyRef SecretKeySelector `yaml:"secretKeyRef"`
+}
+
+type SecretKeySelector struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type ResourceRequirements struct {
+	Requests map[string]string `yaml:"requests,omitempty"`
+	Limits   map[string]string `yaml:"limits,omitempty"`
+}
+
+type Probe struct {
+	HTTPGet             *HTTPGetAction `yaml:"httpGet,omitempty"`
+	Exec                *ExecAction    `yaml:"exec,omitempty"`
+	InitialDelaySeconds int            `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int            `yaml:"periodSeconds"`
+	TimeoutSeconds      int            `yaml:"timeoutSeconds"`
+	FailureThreshold    int            `yaml:"failureThreshold"`
+}
+
+type HTTPGetAction struct {
+	Path string `yaml:"path"`
+	Port int    `yaml:"port"`
+}
+
+type ExecAction struct {
+	Command []string `yaml:"command"`
+}
+
+type Volume struct {
+	Name                  string                            `yaml:"name"`
+	PersistentVolumeClaim PersistentVolumeClaimVolumeSource `yaml:"persistentVolumeClaim"`
+}
+
+type PersistentVolumeClaimVolumeSource struct {
+	ClaimName string `yaml:"claimName"`
+}
+
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+}
+
+// Ingress routes public HTTP traffic to a service.
+type Ingress struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta  `yaml:"metadata"`
+	Spec     IngressSpec `yaml:"spec"`
+}
+
+func (o *Ingress) meta() (TypeMeta, ObjectMeta) { return o.TypeMeta, o.Metadata }
+
+type IngressSpec struct {
+	Rules []IngressRule `yaml:"rules"`
+}
+
+type IngressRule struct {
+	Host string          `yaml:"host,omitempty"`
+	HTTP HTTPIngressRule `yaml:"http"`
+}
+
+type HTTPIngressRule struct {
+	Paths []HTTPIngressPath `yaml:"paths"`
+}
+
+type HTTPIngressPath struct {
+	Path     string         `yaml:"path"`
+	PathType string         `yaml:"pathType"`
+	Backend  IngressBackend `yaml:"backend"`
+}
+
+type IngressBackend struct {
+	Service IngressServiceBackend `yaml:"service"`
+}
+
+type IngressServiceBackend struct {
+	Name string             `yaml:"name"`
+	Port ServiceBackendPort `yaml:"port"`
+}
+
+type ServiceBackendPort struct {
+	Name string `yaml:"name"`
+}
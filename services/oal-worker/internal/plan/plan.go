@@ -0,0 +1,182 @@
+// Package plan compares two releases of an application, to show what
+// deploying one over the other changes before anything is applied.
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"helios/oal-worker/internal/backend"
+	"helios/pkg/events"
+	"helios/pkg/heliosfile"
+)
+
+// Diff lists what deploying desired changes in an application running
+// current, which is nil for the first deployment. Services come before
+// databases, each in order of name, and unchanged ones are listed too.
+// Images are only compared if desired has one.
+func Diff(current *backend.Release, desired backend.Release) []events.Change {
+	var changes []events.Change
+	compareImages := desired.Image != ""
+
+	services := func(r *backend.Release) map[string][]field {
+		fields := map[string][]field{}
+		if r == nil {
+			return fields
+		}
+		for name, s := range r.Manifest.Services {
+			image := ""
+			if compareImages {
+				image = r.Image
+			}
+			fields[name] = serviceFields(image, s, r.Env[name])
+		}
+		return fields
+	}
+	changes = append(changes, diff("service", services(current), services(&desired))...)
+
+	databases := func(r *backend.Release) map[string][]field {
+		fields := map[string][]field{}
+		if r == nil {
+			return fields
+		}
+		for name, db := range r.Manifest.Databases {
+			fields[name] = databaseFields(db)
+		}
+		return fields
+	}
+	changes = append(changes, diff("database", databases(current), databases(&desired))...)
+	return changes
+}
+
+// Count returns how many changes have each action.
+func Count(changes []events.Change) map[string]int {
+	counts := map[string]int{}
+	for _, c := range changes {
+		counts[c.Action]++
+	}
+	return counts
+}
+
+// field is a setting of a service or database, rendered as text. Empty
+// settings are left out.
+type field struct {
+	name, value string
+}
+
+func serviceFields(image string, s heliosfile.Service, env map[string]heliosfile.Value) []field {
+	fields := []field{
+		{"image", image},
+		{"command", s.Command},
+		{"http_port", formatInt(s.HTTPPort)},
+		{"cpu", formatFloat(s.CPU)},
+		{"memory", formatInt(s.Memory)},
+	}
+	if h := s.Healthcheck; h != nil {
+		fields = append(fields, field{"healthcheck", fmt.Sprintf("GET %s on port %d", h.Path, h.Port)})
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, field{"env." + name, env[name].Format(func(s string) string { return s }, reference)})
+	}
+	return fields
+}
+
+func databaseFields(db heliosfile.Database) []field {
+	return []field{
+		{"type", db.Type},
+		{"image", db.Image()},
+		{"storage", formatInt(db.Storage)},
+	}
+}
+
+// reference renders a secret as the reference it was written as, so a plan
+// never shows a password or secret.
+func reference(s heliosfile.Secret) string {
+	if s.Database != "" {
+		return "${" + s.Database + ".PASSWORD}"
+	}
+	return "${" + heliosfile.SecretsReference + "." + s.Name + "}"
+}
+
+// diff compares the fields of the services or databases of one kind, by
+// name.
+func diff(kind string, current, desired map[string][]field) []events.Change {
+	names := map[string]bool{}
+	for name := range current {
+		names[name] = true
+	}
+	for name := range desired {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []events.Change
+	for _, name := range sorted {
+		old, inCurrent := current[name]
+		new, inDesired := desired[name]
+		change := events.Change{Kind: kind, Name: name}
+		switch {
+		case !inCurrent:
+			change.Action = events.ChangeAdd
+		case !inDesired:
+			change.Action = events.ChangeRemove
+		}
+		change.Fields = diffFields(old, new)
+		if change.Action == "" {
+			change.Action = events.ChangeNone
+			if len(change.Fields) > 0 {
+				change.Action = events.ChangeUpdate
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// diffFields lists the fields whose value differs, in the order of desired,
+// followed by the fields only current has.
+func diffFields(current, desired []field) []events.FieldChange {
+	old := map[string]string{}
+	for _, f := range current {
+		old[f.name] = f.value
+	}
+	seen := map[string]bool{}
+
+	var changes []events.FieldChange
+	for _, f := range desired {
+		seen[f.name] = true
+		if old[f.name] != f.value {
+			changes = append(changes, events.FieldChange{Field: f.name, Old: old[f.name], New: f.value})
+		}
+	}
+	for _, f := range current {
+		if !seen[f.name] && f.value != "" {
+			changes = append(changes, events.FieldChange{Field: f.name, Old: f.value})
+		}
+	}
+	return changes
+}
+
+func formatInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func formatFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -3,81 +3,135 @@
 package platform
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
-	"time"
 
+	"helios/oal-worker/internal/backend"
+	"helios/oal-worker/internal/backend/dry"
+	"helios/oal-worker/internal/compose"
+	"helios/oal-worker/internal/kubernetes"
 	"helios/oal-worker/internal/worker"
+	"helios/pkg/bootstrap"
+	"helios/pkg/deadletter"
 	"helios/pkg/events"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 )
 
+// The JetStream consumers shared by all oal-worker replicas, which also name
+// them in the dead letters of their messages. durableName also names the
+// queue group of plan requests.
+const (
+	durableName         = "oal-workers"
+	rollbackDurableName = "oal-workers-rollback"
+)
+
 // App represents the central application container, holding all dependencies.
 type App struct {
 	Logger zerolog.Logger
 	NATS   *nats.Conn
+	JS     jetstream.JetStream
 }
 
 // NewApp creates and configures a new application instance.
-func NewApp(logger zerolog.Logger, natsConn *nats.Conn) *App {
+func NewApp(logger zerolog.Logger, natsConn *nats.Conn, js jetstream.JetStream) *App {
 	return &App{
 		Logger: logger,
 		NATS:   natsConn,
+		JS:     js,
 	}
 }
 
-// Run starts the worker, subscribes to NATS, and handles graceful shutdown.
+// Run starts the worker, consumes from JetStream, and handles graceful shutdown.
 func (a *App) Run() {
-	w := worker.NewWorker(a.Logger)
+	publisher := bootstrap.NewPublisher(a.JS)
+	w := worker.NewWorker(publisher, newBackends(), a.Logger)
+	w.DeadLetters = deadletter.NewQueue(publisher, durableName, a.Logger)
+	w.RollbackDeadLetters = deadletter.NewQueue(publisher, rollbackDurableName, a.Logger)
 
-	// Create a synchronous queue subscriber for manual acknowledgement.
-	subject := events.SubjectBuildSucceeded
-	sub, err := a.NATS.QueueSubscribeSync(subject, "oal-workers")
+	ctx, stop := context.WithCancel(context.Background())
+
+	// Every replica receives every cancellation request, since any of them
+	// may be about to apply the deployment. The requests kept in the stream
+	// are handled before any release, so a replica that was down does not
+	// apply a deployment cancelled meanwhile.
+	caughtUp, err := bootstrap.ConsumeAll(ctx, a.JS, events.SubjectCancellationRequested, a.Logger, w.HandleCancellationRequest)
 	if err != nil {
-		a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not create queue subscription")
+		a.Logger.Fatal().Err(err).Str("subject", events.SubjectCancellationRequested).Msg("FATAL: Could not consume cancellation requests")
 	}
+	<-caughtUp
+	a.Logger.Info().Str("subject", events.SubjectCancellationRequested).Msg("Listening for cancellation requests")
 
-	a.Logger.Info().Str("subject", subject).Str("queue_group", "oal-workers").Msg("Listening for events")
-
-	// Start a goroutine for message processing.
-	processingDone := make(chan struct{})
-	go func() {
-		defer close(processingDone)
-		for {
-			msg, err := sub.NextMsg(10 * time.Second)
-			if err != nil {
-				// ErrTimeout is expected when no messages are pending.
-				if err == nats.ErrTimeout {
-					continue
-				}
-				// ErrConnectionClosed or ErrBadSubscription indicate the subscription is done.
-				if err == nats.ErrConnectionClosed || err == nats.ErrBadSubscription {
-					a.Logger.Info().Msg("Subscription closed, stopping message processing.")
-					return
-				}
-				a.Logger.Error().Err(err).Msg("Error receiving message from NATS")
-				continue
-			}
-			w.HandleBuildSucceeded(msg)
+	for durable, dlq := range map[string]*deadletter.Queue{durableName: w.DeadLetters, rollbackDurableName: w.RollbackDeadLetters} {
+		if err := dlq.WatchMaxDeliveries(ctx, a.NATS, a.JS); err != nil {
+			a.Logger.Fatal().Err(err).Str("durable", durable).Msg("FATAL: Could not watch for messages that run out of deliveries")
 		}
-	}()
+	}
+
+	// Consume the events that ask for a release, each with its own durable
+	// pull consumer with explicit acknowledgement.
+	var consumers sync.WaitGroup
+	a.consume(ctx, &consumers, durableName, events.SubjectBuildSucceeded, w.HandleBuildSucceeded)
+	a.consume(ctx, &consumers, rollbackDurableName, events.SubjectRollbackRequested, w.HandleRollbackRequested)
+
+	// Answer plan requests, sharing them among the replicas like events.
+	planSub, err := a.NATS.QueueSubscribe(events.SubjectPlanRequest, durableName, w.HandlePlanRequest)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Str("subject", events.SubjectPlanRequest).Msg("FATAL: Could not subscribe to plan requests")
+	}
+	a.Logger.Info().Str("subject", events.SubjectPlanRequest).Msg("Listening for plan requests")
 
 	// Wait for interrupt signal to gracefully shut down the worker.
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	a.Logger.Warn().Msg("Shutdown signal received, draining NATS subscription...")
+	a.Logger.Warn().Msg("Shutdown signal received, stopping JetStream consumers...")
 
-	// Drain the subscription. This will wait for the processing goroutine to finish.
-	if err := sub.Drain(); err != nil {
-		a.Logger.Error().Err(err).Msg("Error draining NATS subscription")
+	// Stop consuming and wait for the message in flight to be handled.
+	if err := planSub.Drain(); err != nil {
+		a.Logger.Error().Err(err).Msg("Failed to drain plan requests")
 	}
-
-	// Wait for the processing goroutine to exit cleanly.
-	<-processingDone
+	stop()
+	consumers.Wait()
 
 	a.Logger.Info().Msg("Worker exiting")
-}
\ No newline at end of file
+}
+
+// consume creates a durable JetStream consumer for subject and processes its
+// messages with handle on a background goroutine tracked by wg, until ctx is
+// cancelled.
+func (a *App) consume(ctx context.Context, wg *sync.WaitGroup, durable, subject string, handle func(jetstream.Msg)) {
+	consumer, err := bootstrap.EnsureConsumer(a.JS, durable, subject)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not create JetStream consumer")
+	}
+
+	a.Logger.Info().Str("subject", subject).Str("durable", durable).Msg("Listening for events")
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := bootstrap.Consume(ctx, consumer, a.Logger, handle); err != nil {
+			a.Logger.Fatal().Err(err).Str("subject", subject).Msg("FATAL: Could not consume from JetStream")
+		}
+	}()
+}
+
+// newBackends registers the backend of every value of
+// applications.current_backend. A new backend only needs to be registered
+// here.
+func newBackends() *backend.Registry {
+	composeBackend := compose.NewBackend()
+	kubernetesBackend := kubernetes.NewBackend()
+
+	backends := backend.NewRegistry()
+	backends.Register("docker_compose", composeBackend)
+	backends.Register("k3s", kubernetesBackend)
+	backends.Register("dry", dry.NewBackend(composeBackend, kubernetesBackend))
+	return backends
+}
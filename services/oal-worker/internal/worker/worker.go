@@ -1,13 +1,25 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"helios/oal-worker/internal/backend"
+	"helios/oal-worker/internal/plan"
+	"helios/pkg/bootstrap"
+	"helios/pkg/config"
+	"helios/pkg/deadletter"
+	"helios/pkg/deploylog"
 	"helios/pkg/events"
+	"helios/pkg/heliosfile"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 )
 
@@ -16,16 +28,22 @@ type natsMsg interface {
 	GetData() []byte
 	Ack() error
 	Nak() error
-	Term() error
+	// Term stops redelivery of a message that can never be processed.
+	Term(reason string) error
+	// InProgress resets the ack wait of a message that is still being
+	// processed.
+	InProgress() error
 }
 
-// natsMsgAdapter adapts a *nats.Msg to the natsMsg interface.
+// natsMsgAdapter adapts a jetstream.Msg to the natsMsg interface. Messages
+// that are terminated, or nakked on their last delivery, are dead-lettered.
 type natsMsgAdapter struct {
-	msg *nats.Msg
+	msg jetstream.Msg
+	dlq *deadletter.Queue
 }
 
 func (a *natsMsgAdapter) GetData() []byte {
-	return a.msg.Data
+	return a.msg.Data()
 }
 
 func (a *natsMsgAdapter) Ack() error {
@@ -33,70 +51,410 @@ func (a *natsMsgAdapter) Ack() error {
 }
 
 func (a *natsMsgAdapter) Nak() error {
-	return a.msg.Nak()
+	if a.dlq == nil {
+		return a.msg.Nak()
+	}
+	return a.dlq.Nak(a.msg)
+}
+
+func (a *natsMsgAdapter) InProgress() error {
+	return a.msg.InProgress()
+}
+
+func (a *natsMsgAdapter) Term(reason string) error {
+	if a.dlq == nil {
+		return a.msg.TermWithReason(reason)
+	}
+	return a.dlq.Term(a.msg, reason)
+}
+
+// natsRequest defines the interface for a NATS request, allowing for easier
+// testing.
+type natsRequest interface {
+	GetData() []byte
+	Respond(data []byte) error
+}
+
+// natsRequestAdapter adapts a core NATS message to the natsRequest interface.
+type natsRequestAdapter struct {
+	msg *nats.Msg
+}
+
+func (a *natsRequestAdapter) GetData() []byte {
+	return a.msg.Data
+}
+
+func (a *natsRequestAdapter) Respond(data []byte) error {
+	return a.msg.Respond(data)
 }
 
-func (a *natsMsgAdapter) Term() error {
-	return a.msg.Term()
+// NatsPublisher defines the interface for publishing messages to NATS with a
+// message ID used for deduplication.
+type NatsPublisher interface {
+	PublishMsg(subject, msgID string, data []byte) error
 }
 
 // Worker holds dependencies for the message handler.
 type Worker struct {
+	NATS NatsPublisher
+	// Backends holds the backend of every current_backend an application
+	// can have.
+	Backends  *backend.Registry
 	Logger    zerolog.Logger
 	Validator *validator.Validate
+	// DeadLetters receives build succeeded events the worker gives up on,
+	// and RollbackDeadLetters rollback requests, each as the consumer that
+	// delivered them. If nil, they are terminated without being kept.
+	DeadLetters         *deadletter.Queue
+	RollbackDeadLetters *deadletter.Queue
+	// Handled remembers recently handled event IDs, so redelivered events
+	// are acknowledged without deploying again.
+	Handled *events.Deduplicator
+	// Cancellations remembers cancelled deployments, which are not applied.
+	Cancellations *events.Cancellations
+	// ApplyTimeout bounds deploying a release, so that a backend that hangs
+	// fails the deployment instead of holding on to its event.
+	ApplyTimeout time.Duration
+	// Heartbeat is how often an event is reported in progress while its
+	// release is deployed, so that it is not redelivered meanwhile.
+	Heartbeat time.Duration
 }
 
-// NewWorker creates a new Worker.
-func NewWorker(logger zerolog.Logger) *Worker {
+// errCancelled is returned by deploy when the deployment was cancelled
+// before it was applied.
+var errCancelled = errors.New("deployment was cancelled")
+
+// NewWorker creates a new Worker that deploys with the backends registered
+// in backends.
+func NewWorker(nats NatsPublisher, backends *backend.Registry, logger zerolog.Logger) *Worker {
 	return &Worker{
-		Logger:    logger,
-		Validator: validator.New(),
+		NATS:          nats,
+		Backends:      backends,
+		Logger:        logger,
+		Validator:     validator.New(),
+		Handled:       events.NewDeduplicator(1024),
+		Cancellations: events.NewCancellations(1024),
+		ApplyTimeout:  config.GetenvDuration("APPLY_TIMEOUT", 15*time.Minute),
+		Heartbeat:     bootstrap.NewJetStreamConfig().AckWait / 2,
 	}
 }
 
-// HandleBuildSucceeded is the public handler for NATS messages. It wraps the
-// real message and passes it to the testable internal handler.
-func (w *Worker) HandleBuildSucceeded(m *nats.Msg) {
-	w.handleBuildSucceededInternal(&natsMsgAdapter{msg: m})
+// HandleBuildSucceeded is the public handler for JetStream messages. It wraps
+// the real message and passes it to the testable internal handler.
+func (w *Worker) HandleBuildSucceeded(m jetstream.Msg) {
+	w.handleBuildSucceededInternal(&natsMsgAdapter{msg: m, dlq: w.DeadLetters})
 }
 
-// handleBuildSucceededInternal contains the core logic for processing events.
-func (w *Worker) handleBuildSucceededInternal(m natsMsg) {
-	var event events.BuildSucceeded
-	if err := json.Unmarshal(m.GetData(), &event); err != nil {
-		w.Logger.Error().Err(err).Msg("Could not unmarshal build succeeded event, terminating message")
-		if err := m.Term(); err != nil {
+// HandleRollbackRequested is the public handler for JetStream messages. It
+// wraps the real message and passes it to the testable internal handler.
+func (w *Worker) HandleRollbackRequested(m jetstream.Msg) {
+	w.handleRollbackRequestedInternal(&natsMsgAdapter{msg: m, dlq: w.RollbackDeadLetters})
+}
+
+// HandleCancellationRequest is the public handler for cancellation requests,
+// which every replica receives. It passes the message data to the testable
+// internal handler.
+func (w *Worker) HandleCancellationRequest(m jetstream.Msg) {
+	w.handleCancellationRequestInternal(m.Data())
+}
+
+// handleCancellationRequestInternal records a cancelled deployment, so it is
+// not applied. Cancellation requests are not acknowledged, so invalid ones
+// are only logged.
+func (w *Worker) handleCancellationRequestInternal(data []byte) {
+	envelope, err := events.Decode[events.CancellationRequest](data)
+	if err == nil {
+		err = w.Validator.Struct(&envelope.Data)
+	}
+	if err != nil {
+		w.Logger.Error().Err(err).Msg("Ignoring invalid cancellation request")
+		return
+	}
+
+	w.Logger.Info().
+		Str("event_id", envelope.ID).
+		Str("correlation_id", envelope.CorrelationID).
+		Str("app_id", envelope.Data.AppID).
+		Str("deployment_id", envelope.Data.DeploymentID).
+		Msg("Received cancellation request")
+	w.Cancellations.Cancel(envelope.Data.DeploymentID)
+}
+
+// decode decodes and validates an event. Invalid events are terminated,
+// since redelivering them cannot help, and false is returned.
+func decode[T any](w *Worker, m natsMsg, name string) (events.Envelope[T], bool) {
+	envelope, err := events.Decode[T](m.GetData())
+	if err != nil {
+		w.Logger.Error().Err(err).Msgf("Could not decode %s event, terminating message", name)
+		if err := m.Term("invalid event: " + err.Error()); err != nil {
 			w.Logger.Error().Err(err).Msg("Failed to terminate NATS message")
 		}
-		return
+		return envelope, false
 	}
 
 	// Validate the event payload
-	if err := w.Validator.Struct(&event); err != nil {
-		w.Logger.Error().Err(err).Msg("Invalid build succeeded event payload, terminating message")
-		if err := m.Term(); err != nil {
+	if err := w.Validator.Struct(&envelope.Data); err != nil {
+		w.Logger.Error().Err(err).Msgf("Invalid %s event payload, terminating message", name)
+		if err := m.Term("invalid payload: " + err.Error()); err != nil {
 			w.Logger.Error().Err(err).Msg("Failed to terminate NATS message")
 		}
+		return envelope, false
+	}
+	return envelope, true
+}
+
+// handleBuildSucceededInternal deploys a freshly built image.
+func (w *Worker) handleBuildSucceededInternal(m natsMsg) {
+	envelope, ok := decode[events.BuildSucceeded](w, m, "build succeeded")
+	if !ok {
+		return
+	}
+	log := w.Logger.With().Str("event_id", envelope.ID).Logger()
+	w.handleRelease(log, m, "build succeeded", envelope.Metadata, envelope.Data)
+}
+
+// handleRollbackRequestedInternal deploys the release of an earlier
+// deployment again. Apart from the event it starts from, a rollback is
+// deployed like a new build.
+func (w *Worker) handleRollbackRequestedInternal(m natsMsg) {
+	envelope, ok := decode[events.RollbackRequest](w, m, "rollback requested")
+	if !ok {
 		return
 	}
+	rollback := envelope.Data
+	log := w.Logger.With().Str("event_id", envelope.ID).Str("rollback_of", rollback.RollbackOf).Logger()
+	w.handleRelease(log, m, "rollback requested", envelope.Metadata, events.BuildSucceeded{
+		DeploymentID: rollback.DeploymentID,
+		AppID:        rollback.AppID,
+		ImageURI:     rollback.ImageURI,
+		GitCommitSHA: rollback.GitCommitSHA,
+		Manifest:     rollback.Manifest,
+		Backend:      rollback.Backend,
+		Config:       rollback.Config,
+		Secrets:      rollback.Secrets,
+		Previous:     rollback.Previous,
+	})
+}
 
-	log := w.Logger.With().
+// handleRelease contains the core logic for deploying a release: it reports
+// the deployment as started, deploys it and reports the outcome. cause is
+// the event that asked for the release, and name names it in logs.
+func (w *Worker) handleRelease(log zerolog.Logger, m natsMsg, name string, cause events.Metadata, event events.BuildSucceeded) {
+	log = log.With().
+		Str("correlation_id", cause.CorrelationID).
 		Str("app_id", event.AppID).
+		Str("deployment_id", event.DeploymentID).
 		Str("image_uri", event.ImageURI).
+		Str("backend", event.Backend).
 		Logger()
 
-	log.Info().Msg("Received build succeeded event")
+	if w.Handled.Seen(cause.ID) {
+		log.Warn().Msgf("Ignoring duplicate %s event", name)
+		if err := m.Ack(); err != nil {
+			log.Error().Err(err).Msg("Failed to acknowledge NATS message")
+		}
+		return
+	}
+
+	log.Info().Msgf("Received %s event", name)
+
+	// deployLog also publishes what it logs for the users of the deployment.
+	// Messaging details stay on log.
+	deployLog := log.Hook(deploylog.NewHook(w.NATS, w.Logger, cause, events.LogSourceDeploy, event.DeploymentID, event.AppID))
+
+	if !w.Cancellations.Cancelled(event.DeploymentID) {
+		started := events.NewCausedBy(cause, events.SubjectDeploymentStarted, events.DeploymentStarted{
+			DeploymentID: event.DeploymentID,
+			AppID:        event.AppID,
+			ImageURI:     event.ImageURI,
+		})
+		if !publish(log, w.NATS, m, started) {
+			return
+		}
+	}
 
-	// Simulate the deployment process
-	log.Info().Msg("Simulating deployment...")
-	time.Sleep(1 * time.Second) // Reduced for faster tests
-	log.Info().Msg("Deployment simulation complete")
+	ctx, cancel := context.WithTimeout(context.Background(), w.ApplyTimeout)
+	stopHeartbeat := bootstrap.KeepInProgress(w.Heartbeat, m.InProgress, log)
+	err := w.deploy(ctx, deployLog, event)
+	stopHeartbeat()
+	cancel()
 
-	// In a real implementation, we would publish a "deployment.succeeded" event here.
+	if errors.Is(err, errCancelled) {
+		deployLog.Info().Msg("Deployment cancelled before it was applied")
+		cancelled := events.NewCausedBy(cause, events.SubjectDeploymentCancelled, events.DeploymentCancelled{
+			DeploymentID: event.DeploymentID,
+			AppID:        event.AppID,
+		})
+		if !publish(log, w.NATS, m, cancelled) {
+			return
+		}
+	} else if err != nil {
+		// A failed release, or an invalid manifest, is the outcome of the
+		// deployment, not a reason to redeliver the event.
+		deployLog.Error().Err(err).Msgf("Deployment failed: %v", err)
+		failed := events.NewCausedBy(cause, events.SubjectDeploymentFailed, events.DeploymentFailed{
+			DeploymentID: event.DeploymentID,
+			AppID:        event.AppID,
+			Reason:       err.Error(),
+		})
+		if !publish(log, w.NATS, m, failed) {
+			return
+		}
+	} else {
+		succeeded := events.NewCausedBy(cause, events.SubjectDeploymentSucceeded, events.DeploymentSucceeded{
+			DeploymentID: event.DeploymentID,
+			AppID:        event.AppID,
+			ImageURI:     event.ImageURI,
+		})
+		if !publish(log, w.NATS, m, succeeded) {
+			return
+		}
+	}
 	log.Info().Msg("End of workflow")
+	w.Handled.Mark(cause.ID)
 
 	// Acknowledge the message now that processing is complete
 	if err := m.Ack(); err != nil {
 		log.Error().Err(err).Msg("Failed to acknowledge NATS message")
 	}
-}
\ No newline at end of file
+}
+
+// deploy parses the manifest the image was built with and applies the image
+// with it on the application's backend. Repositories without a Heliosfile get
+// the default one. A deployment cancelled before it is applied returns
+// errCancelled; once applying has started, it is no longer stopped, but it
+// fails when ctx expires.
+func (w *Worker) deploy(ctx context.Context, log zerolog.Logger, event events.BuildSucceeded) error {
+	if w.Cancellations.Cancelled(event.DeploymentID) {
+		return errCancelled
+	}
+
+	b, err := w.Backends.Get(event.Backend)
+	if err != nil {
+		return err
+	}
+
+	vars := heliosfile.Variables{Config: event.Config, Secrets: event.Secrets}
+	release, err := load(event.AppID, events.Release{ImageURI: event.ImageURI, Manifest: event.Manifest}, vars)
+	if err != nil {
+		return err
+	}
+	release.DeploymentID = event.DeploymentID
+	services := release.Manifest.ServiceNames()
+	log.Info().Str("app", release.Manifest.Name).Strs("services", services).Msgf("Loaded Heliosfile of %s with services %s", release.Manifest.Name, strings.Join(services, ", "))
+
+	if event.Previous != nil {
+		if previous, err := load(event.AppID, *event.Previous, vars); err != nil {
+			log.Warn().Err(err).Msg("Could not load the previous release, deploying without a plan")
+		} else {
+			counts := plan.Count(plan.Diff(&previous, release))
+			log.Info().
+				Int("added", counts[events.ChangeAdd]).
+				Int("updated", counts[events.ChangeUpdate]).
+				Int("removed", counts[events.ChangeRemove]).
+				Msgf("Planned deployment: %d to add, %d to change, %d to remove", counts[events.ChangeAdd], counts[events.ChangeUpdate], counts[events.ChangeRemove])
+		}
+	}
+
+	if w.Cancellations.Cancelled(event.DeploymentID) {
+		return errCancelled
+	}
+	log.Info().Msgf("Applying %s", release.Image)
+	if err := b.Apply(ctx, release); err != nil {
+		return err
+	}
+	log.Info().Msg("Applied deployment")
+	return nil
+}
+
+// load parses and interpolates the manifest of r. An empty manifest is the
+// default one.
+func load(appID string, r events.Release, vars heliosfile.Variables) (backend.Release, error) {
+	manifest := heliosfile.Default(appID)
+	if r.Manifest != "" {
+		var err error
+		if manifest, err = heliosfile.Parse([]byte(r.Manifest)); err != nil {
+			return backend.Release{}, fmt.Errorf("invalid %s: %w", heliosfile.FileName, err)
+		}
+	}
+	env, err := heliosfile.Interpolate(manifest, vars)
+	if err != nil {
+		return backend.Release{}, fmt.Errorf("invalid %s: %w", heliosfile.FileName, err)
+	}
+	return backend.Release{
+		AppID:    appID,
+		Image:    r.ImageURI,
+		Manifest: manifest,
+		Env:      env,
+	}, nil
+}
+
+// HandlePlanRequest is the public handler for plan requests. It wraps the
+// real message and passes it to the testable internal handler.
+func (w *Worker) HandlePlanRequest(m *nats.Msg) {
+	w.handlePlanRequestInternal(&natsRequestAdapter{msg: m})
+}
+
+// handlePlanRequestInternal answers a plan request with the changes
+// deploying the desired release would make. A plan changes nothing, so
+// requests that cannot be planned are answered with the reason.
+func (w *Worker) handlePlanRequestInternal(m natsRequest) {
+	reply := w.plan(m.GetData())
+	if reply.Error != "" {
+		w.Logger.Warn().Str("reason", reply.Error).Msg("Could not plan deployment")
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		w.Logger.Error().Err(err).Msg("Could not encode plan reply")
+		return
+	}
+	if err := m.Respond(data); err != nil {
+		w.Logger.Error().Err(err).Msg("Failed to respond to plan request")
+	}
+}
+
+// plan compares the releases of a plan request. The current release is
+// loaded with today's config vars and secrets, so only changes to the
+// manifest and the image show up.
+func (w *Worker) plan(data []byte) events.PlanReply {
+	var request events.PlanRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return events.PlanReply{Error: "invalid plan request: " + err.Error()}
+	}
+	if err := w.Validator.Struct(&request); err != nil {
+		return events.PlanReply{Error: "invalid plan request: " + err.Error()}
+	}
+
+	vars := heliosfile.Variables{Config: request.Config, Secrets: request.Secrets}
+	desired, err := load(request.AppID, request.Desired, vars)
+	if err != nil {
+		return events.PlanReply{Error: err.Error()}
+	}
+	var current *backend.Release
+	if request.Current != nil {
+		release, err := load(request.AppID, *request.Current, vars)
+		if err != nil {
+			return events.PlanReply{Error: "last successful deployment: " + err.Error()}
+		}
+		current = &release
+	}
+
+	changes := plan.Diff(current, desired)
+	w.Logger.Info().Str("app_id", request.AppID).Int("changes", len(changes)).Msg("Planned deployment")
+	return events.PlanReply{Changes: changes}
+}
+
+// publish publishes event on p. If that fails, it nakks m for redelivery and
+// returns false.
+func publish[T any](log zerolog.Logger, p NatsPublisher, m natsMsg, event events.Envelope[T]) bool {
+	if err := events.Publish(p, event); err != nil {
+		log.Error().Err(err).Str("subject", event.Type).Msg("Failed to publish to NATS, nakking message for redelivery")
+		if err := m.Nak(); err != nil {
+			log.Error().Err(err).Msg("Failed to nak NATS message")
+		}
+		return false
+	}
+	log.Info().Str("subject", event.Type).Str("published_event_id", event.ID).Msg("Successfully published event to NATS")
+	return true
+}
@@ -2,21 +2,89 @@ package worker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"helios/oal-worker/internal/backend"
 	"helios/pkg/events"
 	"helios/pkg/testutil"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// mockNatsPublisher records the subjects and last payload it publishes.
+// Deployment log lines are recorded separately, in logs.
+type mockNatsPublisher struct {
+	subjects []string
+	data     []byte
+	logs     []events.Envelope[events.DeploymentLog]
+	err      error
+}
+
+func (m *mockNatsPublisher) PublishMsg(subject, _ string, data []byte) error {
+	if subject == events.SubjectDeploymentLog {
+		if line, err := events.Decode[events.DeploymentLog](data); err == nil && m.err == nil {
+			m.logs = append(m.logs, line)
+		}
+		return m.err
+	}
+	m.subjects = append(m.subjects, subject)
+	m.data = data
+	return m.err
+}
+
+// mockBackend records the releases it applies and returns the configured
+// error without deploying. If block is set, Apply waits for its context to
+// expire instead.
+type mockBackend struct {
+	err      error
+	block    bool
+	releases []backend.Release
+}
+
+func (b *mockBackend) Plan(context.Context, backend.Release) (*backend.Plan, error) {
+	return &backend.Plan{}, nil
+}
+
+func (b *mockBackend) Apply(ctx context.Context, release backend.Release) error {
+	b.releases = append(b.releases, release)
+	if b.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return b.err
+}
+
+func (b *mockBackend) Status(context.Context, string) (*backend.Status, error) {
+	return &backend.Status{}, nil
+}
+
+func (b *mockBackend) Destroy(context.Context, string) error {
+	return nil
+}
+
+// newTestWorker creates a Worker whose default backend is a mockBackend.
+func newTestWorker(nats NatsPublisher, logger zerolog.Logger) (*Worker, *mockBackend) {
+	b := &mockBackend{}
+	backends := backend.NewRegistry()
+	backends.Register(backend.Default, b)
+	return NewWorker(nats, backends, logger), b
+}
+
 // mockNatsMsg is a mock implementation of the natsMsg interface for testing.
 type mockNatsMsg struct {
-	data   []byte
-	acked  bool
-	nakked bool
-	termed bool
+	data       []byte
+	acked      bool
+	nakked     bool
+	termed     bool
+	termReason string
+	inProgress atomic.Int32
 }
 
 func (m *mockNatsMsg) GetData() []byte {
@@ -33,28 +101,36 @@ func (m *mockNatsMsg) Nak() error {
 	return nil
 }
 
-func (m *mockNatsMsg) Term() error {
+func (m *mockNatsMsg) InProgress() error {
+	m.inProgress.Add(1)
+	return nil
+}
+
+func (m *mockNatsMsg) Term(reason string) error {
 	m.termed = true
+	m.termReason = reason
 	return nil
 }
 
 func TestHandleBuildSucceededInternal(t *testing.T) {
 	// Setup a valid build succeeded event for reuse
 	validEvent := events.BuildSucceeded{
+		DeploymentID: "dep-456",
 		AppID:        "app-123",
 		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
 		GitCommitSHA: "a1b2c3d4",
 	}
-	validEventData, err := json.Marshal(validEvent)
+	validEventData, err := json.Marshal(events.New(events.SubjectBuildSucceeded, validEvent))
 	require.NoError(t, err, "Setup failed: could not marshal valid event")
 
 	// Setup an invalid event (missing required field)
 	invalidEvent := events.BuildSucceeded{
-		AppID: "app-123",
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
 		// ImageURI is missing
 		GitCommitSHA: "a1b2c3d4",
 	}
-	invalidEventData, err := json.Marshal(invalidEvent)
+	invalidEventData, err := json.Marshal(events.New(events.SubjectBuildSucceeded, invalidEvent))
 	require.NoError(t, err, "Setup failed: could not marshal invalid event")
 
 	testCases := []struct {
@@ -71,8 +147,8 @@ func TestHandleBuildSucceededInternal(t *testing.T) {
 			expectAck:   true,
 			expectedLogContains: []string{
 				"Received build succeeded event",
-				"Simulating deployment...",
-				"Deployment simulation complete",
+				"Loaded Heliosfile",
+				"Applied deployment",
 				"End of workflow",
 			},
 		},
@@ -81,12 +157,20 @@ func TestHandleBuildSucceededInternal(t *testing.T) {
 			natsMsgData: []byte(`{"app_id": "app-123",`),
 			expectTerm:  true,
 			expectedLogContains: []string{
-				"Could not unmarshal build succeeded event, terminating message",
+				"Could not decode build succeeded event, terminating message",
 			},
 			unexpectedLogContains: []string{
 				"Received build succeeded event",
 			},
 		},
+		{
+			name:        "Failure Case - Payload Without Envelope",
+			natsMsgData: []byte(`{"deployment_id": "dep-456", "app_id": "app-123", "image_uri": "registry.helios.internal/app-123:a1b2c3d4", "git_commit_sha": "a1b2c3d4"}`),
+			expectTerm:  true,
+			expectedLogContains: []string{
+				"Could not decode build succeeded event, terminating message",
+			},
+		},
 		{
 			name:        "Failure Case - Validation Error",
 			natsMsgData: invalidEventData,
@@ -105,7 +189,8 @@ func TestHandleBuildSucceededInternal(t *testing.T) {
 			// Setup
 			var logBuffer bytes.Buffer
 			testLogger := testutil.NewTestLoggerWithOutput(&logBuffer)
-			worker := NewWorker(testLogger)
+			mockNATS := &mockNatsPublisher{}
+			worker, _ := newTestWorker(mockNATS, testLogger)
 
 			// Use the mock message
 			mockMsg := &mockNatsMsg{
@@ -126,7 +211,527 @@ func TestHandleBuildSucceededInternal(t *testing.T) {
 			}
 
 			assert.Equal(t, tc.expectAck, mockMsg.acked, "Message acknowledgement state does not match expectation")
+			if tc.expectAck {
+				assert.Equal(t, []string{events.SubjectDeploymentStarted, events.SubjectDeploymentSucceeded}, mockNATS.subjects)
+			} else {
+				assert.Empty(t, mockNATS.subjects, "worker should not have published a NATS message")
+			}
 			assert.Equal(t, tc.expectTerm, mockMsg.termed, "Message termination state does not match expectation")
+			if tc.expectTerm {
+				assert.NotEmpty(t, mockMsg.termReason, "terminated message should carry a reason for the dead letter")
+			}
+		})
+	}
+}
+
+func TestHandleBuildSucceededDuplicate(t *testing.T) {
+	data, err := json.Marshal(events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+	}))
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	var logBuffer bytes.Buffer
+	worker, mock := newTestWorker(&mockNatsPublisher{}, testutil.NewTestLoggerWithOutput(&logBuffer))
+
+	worker.handleBuildSucceededInternal(&mockNatsMsg{data: data})
+	redelivered := &mockNatsMsg{data: data}
+	worker.handleBuildSucceededInternal(redelivered)
+
+	assert.True(t, redelivered.acked, "a redelivered event should be acknowledged")
+	assert.Len(t, mock.releases, 1, "a redelivered event should not be deployed again")
+	assert.Contains(t, logBuffer.String(), "Ignoring duplicate build succeeded event")
+}
+
+func TestHandleBuildSucceededOutcomes(t *testing.T) {
+	build := events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+	})
+	data, err := json.Marshal(build)
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	testCases := []struct {
+		name             string
+		deployErr        error
+		publishErr       error
+		expectedSubjects []string
+		expectAck        bool
+		expectNak        bool
+	}{
+		{
+			name:             "Deployment fails",
+			deployErr:        errors.New("health check timed out"),
+			expectedSubjects: []string{events.SubjectDeploymentStarted, events.SubjectDeploymentFailed},
+			expectAck:        true,
+		},
+		{
+			name:             "Publish fails",
+			publishErr:       errors.New("NATS is down"),
+			expectedSubjects: []string{events.SubjectDeploymentStarted},
+			expectNak:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockNATS := &mockNatsPublisher{err: tc.publishErr}
+			worker, mock := newTestWorker(mockNATS, testutil.NewTestLogger())
+			mock.err = tc.deployErr
+
+			msg := &mockNatsMsg{data: data}
+			worker.handleBuildSucceededInternal(msg)
+
+			assert.Equal(t, tc.expectedSubjects, mockNATS.subjects)
+			assert.Equal(t, tc.expectAck, msg.acked, "Message acknowledgement state does not match expectation")
+			assert.Equal(t, tc.expectNak, msg.nakked, "Message nak state does not match expectation")
+			if tc.deployErr != nil {
+				failed, err := events.Decode[events.DeploymentFailed](mockNATS.data)
+				require.NoError(t, err, "Could not decode published NATS message payload")
+				assert.Equal(t, tc.deployErr.Error(), failed.Data.Reason)
+				assert.Equal(t, build.ID, failed.CausationID)
+				assert.Equal(t, build.CorrelationID, failed.CorrelationID)
+			}
+		})
+	}
+}
+
+func TestHandleBuildSucceededApplyTimeout(t *testing.T) {
+	data, err := json.Marshal(events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+	}))
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	mockNATS := &mockNatsPublisher{}
+	worker, mock := newTestWorker(mockNATS, testutil.NewTestLogger())
+	mock.block = true
+	worker.ApplyTimeout = 50 * time.Millisecond
+	worker.Heartbeat = time.Millisecond
+
+	msg := &mockNatsMsg{data: data}
+	worker.handleBuildSucceededInternal(msg)
+
+	assert.Equal(t, []string{events.SubjectDeploymentStarted, events.SubjectDeploymentFailed}, mockNATS.subjects)
+	failed, err := events.Decode[events.DeploymentFailed](mockNATS.data)
+	require.NoError(t, err, "Could not decode published NATS message payload")
+	assert.Equal(t, context.DeadlineExceeded.Error(), failed.Data.Reason)
+	assert.Positive(t, msg.inProgress.Load(), "the event should be reported in progress while it is applied")
+	assert.True(t, msg.acked)
+}
+
+func TestHandleBuildSucceededManifest(t *testing.T) {
+	testCases := []struct {
+		name             string
+		manifest         string
+		config           map[string]string
+		expectedServices []string
+		expectedEnv      map[string]string
+		expectedReason   string
+	}{
+		{
+			name:             "Heliosfile",
+			manifest:         "name: shop\nservices:\n  web:\n    http_port: 3000\n  worker: {}\n",
+			expectedServices: []string{"web", "worker"},
+		},
+		{
+			name:             "No Heliosfile",
+			expectedServices: []string{"web"},
+		},
+		{
+			name:           "Invalid Heliosfile",
+			manifest:       "name: shop\nservices:\n  web:\n    http_port: web\n    replicas: 2\n",
+			expectedReason: "invalid Heliosfile.yml: line 4, column 16: services.web.http_port: must be an integer; line 5, column 5: services.web.replicas: unknown field, expected one of command, http_port, cpu, memory, healthcheck, env",
+		},
+		{
+			name:             "Interpolated Heliosfile",
+			manifest:         "name: shop\nservices:\n  web:\n    env:\n      PLAN: ${config.PLAN}\n      CACHE: ${cache.URL}\ndatabases:\n  cache:\n    type: redis\n",
+			config:           map[string]string{"PLAN": "pro"},
+			expectedServices: []string{"web"},
+			expectedEnv:      map[string]string{"PLAN": "pro", "CACHE": "redis://cache:6379"},
+		},
+		{
+			name:           "Undefined reference",
+			manifest:       "name: shop\nservices:\n  web:\n    env:\n      PLAN: ${config.PLAN}\n",
+			expectedReason: "invalid Heliosfile.yml: line 5, column 13: services.web.env.PLAN: undefined reference ${config.PLAN}: no config var PLAN",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+				DeploymentID: "dep-456",
+				AppID:        "app-123",
+				ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+				GitCommitSHA: "a1b2c3d4",
+				Manifest:     tc.manifest,
+				Config:       tc.config,
+			}))
+			require.NoError(t, err, "Setup failed: could not marshal event")
+
+			mockNATS := &mockNatsPublisher{}
+			worker, mock := newTestWorker(mockNATS, testutil.NewTestLogger())
+
+			msg := &mockNatsMsg{data: data}
+			worker.handleBuildSucceededInternal(msg)
+
+			assert.True(t, msg.acked)
+			if tc.expectedReason != "" {
+				assert.Empty(t, mock.releases, "an invalid manifest should not be deployed")
+				assert.Equal(t, []string{events.SubjectDeploymentStarted, events.SubjectDeploymentFailed}, mockNATS.subjects)
+				failed, err := events.Decode[events.DeploymentFailed](mockNATS.data)
+				require.NoError(t, err, "Could not decode published NATS message payload")
+				assert.Equal(t, tc.expectedReason, failed.Data.Reason)
+				return
+			}
+			require.Len(t, mock.releases, 1)
+			assert.Equal(t, tc.expectedServices, mock.releases[0].Manifest.ServiceNames())
+			for name, expected := range tc.expectedEnv {
+				value, ok := mock.releases[0].Env["web"][name].Text()
+				assert.True(t, ok)
+				assert.Equal(t, expected, value, "the env should be interpolated")
+			}
+			assert.Equal(t, events.SubjectDeploymentSucceeded, mockNATS.subjects[len(mockNATS.subjects)-1])
+		})
+	}
+}
+
+func TestHandleBuildSucceededBackend(t *testing.T) {
+	testCases := []struct {
+		name           string
+		backend        string
+		expectedApply  string
+		expectedReason string
+	}{
+		{name: "Default backend", backend: "", expectedApply: backend.Default},
+		{name: "Named backend", backend: "k3s", expectedApply: "k3s"},
+		{name: "Unknown backend", backend: "nomad", expectedReason: `unknown backend "nomad", expected one of docker_compose, k3s`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			build := events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+				DeploymentID: "dep-456",
+				AppID:        "app-123",
+				ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+				GitCommitSHA: "a1b2c3d4",
+				Backend:      tc.backend,
+			})
+			data, err := json.Marshal(build)
+			require.NoError(t, err, "Setup failed: could not marshal event")
+
+			mocks := map[string]*mockBackend{backend.Default: {}, "k3s": {}}
+			backends := backend.NewRegistry()
+			for name, b := range mocks {
+				backends.Register(name, b)
+			}
+			mockNATS := &mockNatsPublisher{}
+			worker := NewWorker(mockNATS, backends, testutil.NewTestLogger())
+
+			msg := &mockNatsMsg{data: data}
+			worker.handleBuildSucceededInternal(msg)
+
+			assert.True(t, msg.acked)
+			for name, b := range mocks {
+				if name == tc.expectedApply {
+					require.Len(t, b.releases, 1, "the release should be applied on %s", name)
+					assert.Equal(t, backend.Release{
+						AppID:        "app-123",
+						DeploymentID: "dep-456",
+						Image:        "registry.helios.internal/app-123:a1b2c3d4",
+						Manifest:     b.releases[0].Manifest,
+						Env:          b.releases[0].Env,
+					}, b.releases[0])
+				} else {
+					assert.Empty(t, b.releases, "the release should not be applied on %s", name)
+				}
+			}
+			if tc.expectedReason != "" {
+				failed, err := events.Decode[events.DeploymentFailed](mockNATS.data)
+				require.NoError(t, err, "Could not decode published NATS message payload")
+				assert.Equal(t, tc.expectedReason, failed.Data.Reason)
+			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// mockNatsRequest is a mock implementation of the natsRequest interface for
+// testing.
+type mockNatsRequest struct {
+	data     []byte
+	response []byte
+}
+
+func (m *mockNatsRequest) GetData() []byte {
+	return m.data
+}
+
+func (m *mockNatsRequest) Respond(data []byte) error {
+	m.response = data
+	return nil
+}
+
+func TestHandlePlanRequestInternal(t *testing.T) {
+	const (
+		oldImage = "registry.helios.internal/app-123:a1b2c3d4"
+		newImage = "registry.helios.internal/app-123:e5f6a7b8"
+	)
+	current := &events.Release{ImageURI: oldImage, Manifest: "name: shop\nservices:\n  web:\n    http_port: 3000\n  cron: {}\n"}
+
+	testCases := []struct {
+		name            string
+		request         events.PlanRequest
+		expectedChanges []events.Change
+		expectedError   string
+	}{
+		{
+			name: "Changed release",
+			request: events.PlanRequest{
+				AppID:   "app-123",
+				Current: current,
+				Desired: events.Release{ImageURI: newImage, Manifest: "name: shop\nservices:\n  web:\n    http_port: 8080\n  worker: {}\n"},
+			},
+			expectedChanges: []events.Change{
+				{Kind: "service", Name: "cron", Action: events.ChangeRemove, Fields: []events.FieldChange{{Field: "image", Old: oldImage}}},
+				{Kind: "service", Name: "web", Action: events.ChangeUpdate, Fields: []events.FieldChange{
+					{Field: "image", Old: oldImage, New: newImage},
+					{Field: "http_port", Old: "3000", New: "8080"},
+				}},
+				{Kind: "service", Name: "worker", Action: events.ChangeAdd, Fields: []events.FieldChange{{Field: "image", New: newImage}}},
+			},
+		},
+		{
+			name: "First deployment",
+			request: events.PlanRequest{
+				AppID:   "app-123",
+				Desired: events.Release{},
+			},
+			expectedChanges: []events.Change{
+				{Kind: "service", Name: "web", Action: events.ChangeAdd, Fields: []events.FieldChange{{Field: "http_port", New: "8080"}}},
+			},
+		},
+		{
+			name:          "Missing app ID",
+			request:       events.PlanRequest{},
+			expectedError: "invalid plan request: Key: 'PlanRequest.AppID' Error:Field validation for 'AppID' failed on the 'required' tag",
+		},
+		{
+			name: "Invalid desired Heliosfile",
+			request: events.PlanRequest{
+				AppID:   "app-123",
+				Current: current,
+				Desired: events.Release{Manifest: "name: shop\nservices:\n  web:\n    env:\n      PLAN: ${config.PLAN}\n"},
+			},
+			expectedError: "invalid Heliosfile.yml: line 5, column 13: services.web.env.PLAN: undefined reference ${config.PLAN}: no config var PLAN",
+		},
+		{
+			name: "Invalid current Heliosfile",
+			request: events.PlanRequest{
+				AppID:   "app-123",
+				Current: &events.Release{Manifest: "services: {}\n"},
+				Desired: events.Release{},
+			},
+			expectedError: "last successful deployment: invalid Heliosfile.yml: line 1, column 1: name: is required; line 1, column 11: services: must declare at least one service",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.request)
+			require.NoError(t, err, "Setup failed: could not marshal request")
+
+			worker, mock := newTestWorker(&mockNatsPublisher{}, testutil.NewTestLogger())
+			msg := &mockNatsRequest{data: data}
+			worker.handlePlanRequestInternal(msg)
+
+			require.NotNil(t, msg.response, "every plan request should be answered")
+			var reply events.PlanReply
+			require.NoError(t, json.Unmarshal(msg.response, &reply))
+			assert.Equal(t, tc.expectedError, reply.Error)
+			assert.Equal(t, tc.expectedChanges, reply.Changes)
+			assert.Empty(t, mock.releases, "a plan should not deploy anything")
+		})
+	}
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		worker, _ := newTestWorker(&mockNatsPublisher{}, testutil.NewTestLogger())
+		msg := &mockNatsRequest{data: []byte("{not json}")}
+		worker.handlePlanRequestInternal(msg)
+
+		var reply events.PlanReply
+		require.NoError(t, json.Unmarshal(msg.response, &reply))
+		assert.Contains(t, reply.Error, "invalid plan request")
+	})
+}
+
+func TestHandleBuildSucceededPrevious(t *testing.T) {
+	testCases := []struct {
+		name     string
+		previous *events.Release
+	}{
+		{name: "Previous release", previous: &events.Release{Manifest: "name: shop\nservices:\n  web: {}\n"}},
+		{name: "Invalid previous release", previous: &events.Release{Manifest: "services: {}\n"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+				DeploymentID: "dep-456",
+				AppID:        "app-123",
+				ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+				GitCommitSHA: "a1b2c3d4",
+				Previous:     tc.previous,
+			}))
+			require.NoError(t, err, "Setup failed: could not marshal event")
+
+			mockNATS := &mockNatsPublisher{}
+			worker, mock := newTestWorker(mockNATS, testutil.NewTestLogger())
+			msg := &mockNatsMsg{data: data}
+			worker.handleBuildSucceededInternal(msg)
+
+			assert.True(t, msg.acked)
+			assert.Len(t, mock.releases, 1, "the plan should not stop the deployment")
+			assert.Equal(t, events.SubjectDeploymentSucceeded, mockNATS.subjects[len(mockNATS.subjects)-1])
+		})
+	}
+}
+
+func TestHandleRollbackRequestedInternal(t *testing.T) {
+	rollback := events.RollbackRequest{
+		DeploymentID: "dep-789",
+		AppID:        "app-123",
+		RollbackOf:   "dep-456",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+		Manifest:     "name: shop\nservices:\n  web:\n    http_port: 3000\n",
+		Backend:      "k3s",
+	}
+	validData, err := json.Marshal(events.New(events.SubjectRollbackRequested, rollback))
+	require.NoError(t, err, "Setup failed: could not marshal event")
+	invalidData, err := json.Marshal(events.New(events.SubjectRollbackRequested, events.RollbackRequest{AppID: "app-123"}))
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	t.Run("Successful Case", func(t *testing.T) {
+		mockNATS := &mockNatsPublisher{}
+		b := &mockBackend{}
+		backends := backend.NewRegistry()
+		backends.Register("k3s", b)
+		worker := NewWorker(mockNATS, backends, testutil.NewTestLogger())
+
+		msg := &mockNatsMsg{data: validData}
+		worker.handleRollbackRequestedInternal(msg)
+
+		assert.True(t, msg.acked)
+		require.Len(t, b.releases, 1, "the old release should be applied on the application's backend")
+		assert.Equal(t, "dep-789", b.releases[0].DeploymentID)
+		assert.Equal(t, rollback.ImageURI, b.releases[0].Image)
+		assert.Equal(t, 3000, b.releases[0].Manifest.Services["web"].HTTPPort)
+		assert.Equal(t, []string{events.SubjectDeploymentStarted, events.SubjectDeploymentSucceeded}, mockNATS.subjects)
+
+		succeeded, err := events.Decode[events.DeploymentSucceeded](mockNATS.data)
+		require.NoError(t, err, "Could not decode published NATS message payload")
+		assert.Equal(t, "dep-789", succeeded.Data.DeploymentID)
+	})
+
+	t.Run("Failure Case - Invalid payload", func(t *testing.T) {
+		mockNATS := &mockNatsPublisher{}
+		worker, mock := newTestWorker(mockNATS, testutil.NewTestLogger())
+
+		msg := &mockNatsMsg{data: invalidData}
+		worker.handleRollbackRequestedInternal(msg)
+
+		assert.True(t, msg.termed)
+		assert.Empty(t, mock.releases)
+		assert.Empty(t, mockNATS.subjects)
+	})
+}
+
+func TestHandleCancellationRequestInternal(t *testing.T) {
+	build := events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+	})
+	data, err := json.Marshal(build)
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	testCases := []struct {
+		name             string
+		cancellation     events.CancellationRequest
+		expectedSubjects []string
+		expectApply      bool
+	}{
+		{
+			name:             "Cancelled deployment is not applied",
+			cancellation:     events.CancellationRequest{DeploymentID: "dep-456", AppID: "app-123"},
+			expectedSubjects: []string{events.SubjectDeploymentCancelled},
+		},
+		{
+			name:             "Other deployments are applied",
+			cancellation:     events.CancellationRequest{DeploymentID: "dep-789", AppID: "app-123"},
+			expectedSubjects: []string{events.SubjectDeploymentStarted, events.SubjectDeploymentSucceeded},
+			expectApply:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockNATS := &mockNatsPublisher{}
+			worker, mock := newTestWorker(mockNATS, testutil.NewTestLogger())
+			cancellation, err := json.Marshal(events.New(events.SubjectCancellationRequested, tc.cancellation))
+			require.NoError(t, err, "Setup failed: could not marshal cancellation")
+
+			worker.handleCancellationRequestInternal(cancellation)
+			msg := &mockNatsMsg{data: data}
+			worker.handleBuildSucceededInternal(msg)
+
+			assert.Equal(t, tc.expectedSubjects, mockNATS.subjects)
+			assert.Equal(t, tc.expectApply, len(mock.releases) == 1, "Apply state does not match expectation")
+			assert.True(t, msg.acked, "message should be acknowledged")
+			if !tc.expectApply {
+				cancelled, err := events.Decode[events.DeploymentCancelled](mockNATS.data)
+				require.NoError(t, err, "Could not decode published NATS message payload")
+				assert.Equal(t, "dep-456", cancelled.Data.DeploymentID)
+				assert.Equal(t, build.ID, cancelled.CausationID)
+			}
+		})
+	}
+}
+
+func TestHandleBuildSucceededLogs(t *testing.T) {
+	build := events.New(events.SubjectBuildSucceeded, events.BuildSucceeded{
+		DeploymentID: "dep-456",
+		AppID:        "app-123",
+		ImageURI:     "registry.helios.internal/app-123:a1b2c3d4",
+		GitCommitSHA: "a1b2c3d4",
+		Manifest:     "name: shop\nservices:\n  web: {}\n  worker: {}\n",
+		Previous:     &events.Release{ImageURI: "registry.helios.internal/app-123:a1b2c3d4", Manifest: "name: shop\nservices:\n  web: {}\n"},
+	})
+	data, err := json.Marshal(build)
+	require.NoError(t, err, "Setup failed: could not marshal event")
+
+	mockNATS := &mockNatsPublisher{}
+	worker, _ := newTestWorker(mockNATS, testutil.NewTestLoggerWithOutput(io.Discard))
+	worker.handleBuildSucceededInternal(&mockNatsMsg{data: data})
+
+	var messages []string
+	for _, line := range mockNATS.logs {
+		assert.Equal(t, "dep-456", line.Data.DeploymentID)
+		assert.Equal(t, events.LogSourceDeploy, line.Data.Source)
+		assert.Equal(t, build.ID, line.CausationID, "log lines should be caused by the build event")
+		messages = append(messages, line.Data.Message)
+	}
+	assert.Equal(t, []string{
+		"Loaded Heliosfile of shop with services web, worker",
+		"Planned deployment: 1 to add, 0 to change, 0 to remove",
+		"Applying registry.helios.internal/app-123:a1b2c3d4",
+		"Applied deployment",
+	}, messages, "only the progress of the deployment should be published")
+}
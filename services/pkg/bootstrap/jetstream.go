@@ -0,0 +1,320 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"helios/pkg/config"
+	"helios/pkg/events"
+)
+
+// StreamName is the JetStream stream that stores every Helios event.
+const StreamName = "HELIOS"
+
+// StreamSubjects are the subjects captured by the HELIOS stream. They are
+// listed by domain instead of as v1.>, which would overlap the dead letters
+// on v1.dlq.> that the HELIOS_DLQ stream captures.
+var StreamSubjects = []string{"v1.deployment.>", "v1.build.>", "v1.rollback.>", "v1.cancellation.>", "v1.replay.>"}
+
+// DeadLetterStreamName is the JetStream stream that stores dead letters. It
+// is separate from HELIOS so that dead letters are kept for longer than the
+// events themselves.
+const DeadLetterStreamName = "HELIOS_DLQ"
+
+// DeadLetterStreamSubjects are the subjects captured by the HELIOS_DLQ
+// stream.
+var DeadLetterStreamSubjects = []string{events.SubjectDeadLetterPrefix + ">"}
+
+// JetStreamConfig holds the configuration for the HELIOS stream and its
+// consumers.
+type JetStreamConfig struct {
+	// MaxAge is how long the stream retains messages.
+	MaxAge time.Duration
+	// DeadLetterMaxAge is how long the HELIOS_DLQ stream retains dead
+	// letters that are not replayed.
+	DeadLetterMaxAge time.Duration
+	// MaxDeliver is how many times a message is delivered before the
+	// consumer gives up on it.
+	MaxDeliver int
+	// AckWait is how long a consumer waits for an ack before redelivering.
+	AckWait time.Duration
+	// RequestTimeout bounds stream management and publish requests.
+	RequestTimeout time.Duration
+	// DuplicateWindow is how long the stream remembers message IDs to drop
+	// duplicate publishes of the same event.
+	DuplicateWindow time.Duration
+}
+
+// NewJetStreamConfig creates a JetStream configuration from environment
+// variables.
+func NewJetStreamConfig() JetStreamConfig {
+	return JetStreamConfig{
+		MaxAge:           config.GetenvDuration("JS_STREAM_MAX_AGE", 7*24*time.Hour),
+		DeadLetterMaxAge: config.GetenvDuration("JS_DLQ_MAX_AGE", 30*24*time.Hour),
+		MaxDeliver:       config.GetenvInt("JS_MAX_DELIVER", 5),
+		AckWait:          config.GetenvDuration("JS_ACK_WAIT", 30*time.Second),
+		RequestTimeout:   config.GetenvDuration("JS_REQUEST_TIMEOUT", 5*time.Second),
+		DuplicateWindow:  config.GetenvDuration("JS_DUPLICATE_WINDOW", 2*time.Minute),
+	}
+}
+
+// SetupJetStream creates a JetStream context on natsConn and creates or
+// updates the HELIOS stream, so that events published while no consumer is
+// running are kept until they are processed, and the HELIOS_DLQ stream.
+func SetupJetStream(natsConn *nats.Conn, log zerolog.Logger) (jetstream.JetStream, error) {
+	cfg := NewJetStreamConfig()
+
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.RequestTimeout)
+	defer cancel()
+
+	// Limits retention lets several consumers, such as the oal-worker and the
+	// API, each receive the same event.
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       StreamName,
+		Subjects:   StreamSubjects,
+		Retention:  jetstream.LimitsPolicy,
+		Storage:    jetstream.FileStorage,
+		MaxAge:     cfg.MaxAge,
+		Duplicates: cfg.DuplicateWindow,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream %s: %w", StreamName, err)
+	}
+
+	log.Info().Str("stream", StreamName).Strs("subjects", StreamSubjects).Msg("JetStream stream ready")
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       DeadLetterStreamName,
+		Subjects:   DeadLetterStreamSubjects,
+		Retention:  jetstream.LimitsPolicy,
+		Storage:    jetstream.FileStorage,
+		MaxAge:     cfg.DeadLetterMaxAge,
+		Duplicates: cfg.DuplicateWindow,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream %s: %w", DeadLetterStreamName, err)
+	}
+
+	log.Info().Str("stream", DeadLetterStreamName).Strs("subjects", DeadLetterStreamSubjects).Msg("JetStream stream ready")
+	return js, nil
+}
+
+// EnsureConsumer creates or updates a durable pull consumer on the HELIOS
+// stream that receives messages on subject, and the dead letters replayed to
+// it. Consumers with the same durable name share the work, so every replica
+// of a service uses the same name.
+func EnsureConsumer(js jetstream.JetStream, durable, subject string) (jetstream.Consumer, error) {
+	cfg := NewJetStreamConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.RequestTimeout)
+	defer cancel()
+
+	consumer, err := js.CreateOrUpdateConsumer(ctx, StreamName, jetstream.ConsumerConfig{
+		Durable:        durable,
+		FilterSubjects: []string{subject, events.ReplaySubject(durable, subject)},
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        cfg.AckWait,
+		MaxDeliver:     cfg.MaxDeliver,
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer %s: %w", durable, err)
+	}
+	return consumer, nil
+}
+
+// Bounds of the delay before Consume pulls again after failing to receive a
+// message. The delay doubles after each consecutive failure.
+const (
+	consumeRetryMinDelay = 100 * time.Millisecond
+	consumeRetryMaxDelay = 5 * time.Second
+)
+
+// Consume pulls messages from consumer one at a time and passes them to
+// handle until ctx is cancelled. The message being handled when ctx is
+// cancelled is allowed to finish. Handlers must ack, nak or term each message.
+// If receiving fails, for example while NATS is unreachable, Consume backs off
+// before pulling again.
+func Consume(ctx context.Context, consumer jetstream.Consumer, log zerolog.Logger, handle func(jetstream.Msg)) error {
+	// Pulling a single message at a time keeps unprocessed messages on the
+	// server, where other replicas can receive them.
+	iter, err := consumer.Messages(jetstream.PullMaxMessages(1))
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			iter.Stop()
+		case <-stopped:
+		}
+	}()
+
+	delay := consumeRetryMinDelay
+	for {
+		msg, err := iter.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			log.Info().Msg("Consumer stopped, stopping message processing.")
+			return nil
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Error receiving message from JetStream, retrying in %s", delay)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			delay = min(2*delay, consumeRetryMaxDelay)
+			continue
+		}
+		delay = consumeRetryMinDelay
+		handle(msg)
+	}
+}
+
+// ConsumeAll passes every message on subject in the HELIOS stream to handle,
+// oldest first, and then each new one until ctx is cancelled. Unlike with a
+// durable consumer, every replica of a service receives every message, and
+// receives again after a restart the messages it missed while it was down.
+// The returned channel is closed once the messages that were in the stream
+// when ConsumeAll was called have been handled. Messages need not be acked.
+func ConsumeAll(ctx context.Context, js jetstream.JetStream, subject string, log zerolog.Logger, handle func(jetstream.Msg)) (<-chan struct{}, error) {
+	cfg := NewJetStreamConfig()
+
+	reqCtx, cancel := context.WithTimeout(ctx, cfg.RequestTimeout)
+	defer cancel()
+
+	stream, err := js.Stream(reqCtx, StreamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stream %s: %w", StreamName, err)
+	}
+	var last uint64
+	msg, err := stream.GetLastMsgForSubject(reqCtx, subject)
+	switch {
+	case err == nil:
+		last = msg.Sequence
+	case !errors.Is(err, jetstream.ErrMsgNotFound):
+		return nil, fmt.Errorf("failed to read last message on %s: %w", subject, err)
+	}
+
+	consumer, err := js.OrderedConsumer(reqCtx, StreamName, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subject},
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ordered consumer for %s: %w", subject, err)
+	}
+
+	caughtUp := make(chan struct{})
+	var once sync.Once
+	markCaughtUp := func() { once.Do(func() { close(caughtUp) }) }
+	if last == 0 {
+		markCaughtUp()
+	}
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		handle(msg)
+		if meta, err := msg.Metadata(); err == nil && meta.Sequence.Stream >= last {
+			markCaughtUp()
+		}
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		log.Error().Err(err).Str("subject", subject).Msg("Error receiving message from JetStream")
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start consuming %s: %w", subject, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		consumeCtx.Stop()
+	}()
+	return caughtUp, nil
+}
+
+// KeepInProgress calls inProgress, which should report the message being
+// handled as in progress, every interval until the returned function is
+// called. This resets the message's ack wait, so that a handler that takes
+// longer than AckWait is not redelivered meanwhile. The returned function
+// waits for the last call to return, so the message can be acked after it.
+// If interval is not positive, inProgress is never called.
+func KeepInProgress(interval time.Duration, inProgress func() error, log zerolog.Logger) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := inProgress(); err != nil {
+					log.Warn().Err(err).Msg("Failed to report message in progress")
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Publisher publishes messages to JetStream and waits for the stream to
+// acknowledge that each one was stored.
+type Publisher struct {
+	JS      jetstream.JetStream
+	Timeout time.Duration
+}
+
+// NewPublisher creates a Publisher for js.
+func NewPublisher(js jetstream.JetStream) *Publisher {
+	return &Publisher{
+		JS:      js,
+		Timeout: NewJetStreamConfig().RequestTimeout,
+	}
+}
+
+// Publish stores data on subject. It returns an error if the stream did not
+// acknowledge the message, in which case the caller should retry.
+func (p *Publisher) Publish(subject string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	if _, err := p.JS.Publish(ctx, subject, data); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+	return nil
+}
+
+// PublishMsg stores data on subject with the given message ID. The stream
+// drops a message whose ID it has already stored within the duplicate window,
+// so retrying a publish never stores an event twice.
+func (p *Publisher) PublishMsg(subject, msgID string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	if _, err := p.JS.Publish(ctx, subject, data, jetstream.WithMsgID(msgID)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+	return nil
+}
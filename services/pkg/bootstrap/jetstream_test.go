@@ -0,0 +1,177 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helios/pkg/events"
+	"helios/pkg/testutil"
+)
+
+// captured reports whether any of the stream subjects, which may contain
+// the * and > wildcards, matches subject.
+func captured(streamSubjects []string, subject string) bool {
+	return slices.ContainsFunc(streamSubjects, func(pattern string) bool {
+		patternTokens, subjectTokens := strings.Split(pattern, "."), strings.Split(subject, ".")
+		for i, token := range patternTokens {
+			if token == ">" {
+				return len(subjectTokens) > i
+			}
+			if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+				return false
+			}
+		}
+		return len(patternTokens) == len(subjectTokens)
+	})
+}
+
+func TestStreamSubjects(t *testing.T) {
+	testCases := []struct {
+		name    string
+		subject string
+	}{
+		{name: "Successful Case - Deployment requested", subject: events.SubjectDeploymentRequested},
+		{name: "Successful Case - Build started", subject: events.SubjectBuildStarted},
+		{name: "Successful Case - Build succeeded", subject: events.SubjectBuildSucceeded},
+		{name: "Successful Case - Build failed", subject: events.SubjectBuildFailed},
+		{name: "Successful Case - Deployment started", subject: events.SubjectDeploymentStarted},
+		{name: "Successful Case - Deployment succeeded", subject: events.SubjectDeploymentSucceeded},
+		{name: "Successful Case - Deployment failed", subject: events.SubjectDeploymentFailed},
+		{name: "Successful Case - Deployment cancelled", subject: events.SubjectDeploymentCancelled},
+		{name: "Successful Case - Rollback requested", subject: events.SubjectRollbackRequested},
+		{name: "Successful Case - Cancellation requested", subject: events.SubjectCancellationRequested},
+		{name: "Successful Case - Replayed event", subject: events.ReplaySubject("api-build-succeeded", events.SubjectBuildSucceeded)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deadLetter := events.SubjectDeadLetterPrefix + tc.subject
+
+			assert.True(t, captured(StreamSubjects, tc.subject), "%s is not in the HELIOS stream", tc.subject)
+			assert.False(t, captured(DeadLetterStreamSubjects, tc.subject), "%s is in the HELIOS_DLQ stream", tc.subject)
+			assert.True(t, captured(DeadLetterStreamSubjects, deadLetter), "%s is not in the HELIOS_DLQ stream", deadLetter)
+			assert.False(t, captured(StreamSubjects, deadLetter), "%s is in the HELIOS stream", deadLetter)
+		})
+	}
+}
+
+func TestKeepInProgress(t *testing.T) {
+	testCases := []struct {
+		name          string
+		interval      time.Duration
+		expectedCalls bool
+	}{
+		{name: "Successful Case - Reports in progress", interval: time.Millisecond, expectedCalls: true},
+		{name: "Successful Case - Zero interval", interval: 0},
+		{name: "Successful Case - Negative interval", interval: -time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			stop := KeepInProgress(tc.interval, func() error {
+				calls.Add(1)
+				return nil
+			}, testutil.NewTestLogger())
+			time.Sleep(20 * time.Millisecond)
+			stop()
+
+			stopped := calls.Load()
+			time.Sleep(5 * time.Millisecond)
+			assert.Equal(t, stopped, calls.Load(), "inProgress was called after stop returned")
+			if tc.expectedCalls {
+				assert.Positive(t, stopped)
+			} else {
+				assert.Zero(t, stopped)
+			}
+		})
+	}
+}
+
+// failingConsumer is a consumer whose messages fail to be received failures
+// times, or until they are stopped if failures is negative, and then stop.
+type failingConsumer struct {
+	jetstream.Consumer
+	failures int
+}
+
+func (c *failingConsumer) Messages(...jetstream.PullMessagesOpt) (jetstream.MessagesContext, error) {
+	return &failingMessages{failures: c.failures, stopped: make(chan struct{})}, nil
+}
+
+type failingMessages struct {
+	failures int
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (m *failingMessages) Next() (jetstream.Msg, error) {
+	select {
+	case <-m.stopped:
+		return nil, jetstream.ErrMsgIteratorClosed
+	default:
+	}
+	if m.failures == 0 {
+		return nil, jetstream.ErrMsgIteratorClosed
+	}
+	m.failures--
+	return nil, errors.New("nats: connection closed")
+}
+
+func (m *failingMessages) Stop() { m.stopOnce.Do(func() { close(m.stopped) }) }
+
+func (m *failingMessages) Drain() { m.Stop() }
+
+func TestConsume(t *testing.T) {
+	testCases := []struct {
+		name     string
+		failures int
+		cancelIn time.Duration
+		minTook  time.Duration
+		maxTook  time.Duration
+	}{
+		{
+			name:     "Successful Case - Backs off after failures",
+			failures: 3,
+			// 100ms, 200ms and 400ms.
+			minTook: 700 * time.Millisecond,
+			maxTook: 2 * time.Second,
+		},
+		{
+			name:     "Successful Case - Cancelled while backing off",
+			failures: -1,
+			cancelIn: 50 * time.Millisecond,
+			maxTook:  time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelIn > 0 {
+				time.AfterFunc(tc.cancelIn, cancel)
+			}
+
+			started := time.Now()
+			err := Consume(ctx, &failingConsumer{failures: tc.failures}, testutil.NewTestLogger(), func(jetstream.Msg) {
+				t.Error("unexpected message")
+			})
+			took := time.Since(started)
+
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, took, tc.minTook)
+			assert.Less(t, took, tc.maxTook)
+		})
+	}
+}
@@ -0,0 +1,320 @@
+// Package deadletter keeps events that consumers give up on in the
+// HELIOS_DLQ stream, under the v1.dlq.<subject> subjects, so they can be
+// inspected and replayed instead of being lost.
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"helios/pkg/bootstrap"
+	"helios/pkg/events"
+)
+
+// ErrNotFound is returned when no dead letter has the requested sequence.
+var ErrNotFound = errors.New("dead letter not found")
+
+// Subject returns the subject that dead letters from subject are stored on.
+func Subject(subject string) string {
+	return events.SubjectDeadLetterPrefix + subject
+}
+
+// maxDeliveriesSubject prefixes the subjects of the advisories JetStream
+// publishes when a message runs out of deliveries, followed by the stream
+// and the consumer.
+const maxDeliveriesSubject = "$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES"
+
+// Publisher defines the interface for publishing messages to NATS.
+type Publisher interface {
+	PublishMsg(subject, msgID string, data []byte) error
+}
+
+// Queue dead-letters messages on behalf of a durable consumer.
+type Queue struct {
+	Publisher Publisher
+	Consumer  string
+	Logger    zerolog.Logger
+	// MaxDeliver must match the consumer's MaxDeliver, so the last delivery
+	// of a message can be recognised.
+	MaxDeliver int
+	// Timeout bounds reading a message that ran out of deliveries.
+	Timeout time.Duration
+}
+
+// NewQueue creates a Queue for the named durable consumer.
+func NewQueue(publisher Publisher, consumer string, logger zerolog.Logger) *Queue {
+	cfg := bootstrap.NewJetStreamConfig()
+	return &Queue{
+		Publisher:  publisher,
+		Consumer:   consumer,
+		Logger:     logger,
+		MaxDeliver: cfg.MaxDeliver,
+		Timeout:    cfg.RequestTimeout,
+	}
+}
+
+// Term dead-letters msg with the given reason and terminates it so it is not
+// redelivered. If the dead letter cannot be stored, msg is nakked instead so
+// it is not lost.
+func (q *Queue) Term(msg jetstream.Msg, reason string) error {
+	if err := q.publish(msg, reason); err != nil {
+		q.Logger.Error().Err(err).Str("subject", msg.Subject()).Msg("Could not store dead letter, nakking message for redelivery")
+		return msg.Nak()
+	}
+	return msg.TermWithReason(reason)
+}
+
+// Nak asks for msg to be redelivered. On its last permitted delivery the
+// message is dead-lettered and terminated instead.
+func (q *Queue) Nak(msg jetstream.Msg) error {
+	meta, err := msg.Metadata()
+	if err != nil || q.MaxDeliver <= 0 || meta.NumDelivered < uint64(q.MaxDeliver) {
+		return msg.Nak()
+	}
+	return q.Term(msg, fmt.Sprintf("maximum deliveries (%d) exceeded", q.MaxDeliver))
+}
+
+// maxDeliveriesAdvisory is the advisory JetStream publishes when a message
+// runs out of deliveries.
+type maxDeliveriesAdvisory struct {
+	Stream     string `json:"stream"`
+	Consumer   string `json:"consumer"`
+	StreamSeq  uint64 `json:"stream_seq"`
+	Deliveries uint64 `json:"deliveries"`
+}
+
+// WatchMaxDeliveries dead-letters the messages of the queue's consumer that
+// run out of deliveries without being nakked on the last one, until ctx is
+// cancelled. That happens when a handler does not answer within the ack
+// wait, and JetStream only reports it with an advisory. The replicas of a
+// service share the advisories, so each message is dead-lettered once.
+func (q *Queue) WatchMaxDeliveries(ctx context.Context, nc *nats.Conn, js jetstream.JetStream) error {
+	subject := maxDeliveriesSubject + "." + bootstrap.StreamName + "." + q.Consumer
+	sub, err := nc.QueueSubscribe(subject, q.Consumer, func(m *nats.Msg) {
+		q.handleMaxDeliveries(js, m)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			q.Logger.Error().Err(err).Str("subject", subject).Msg("Failed to unsubscribe from advisories")
+		}
+	}()
+	return nil
+}
+
+// handleMaxDeliveries dead-letters the message an advisory reports. The
+// message is still in the HELIOS stream, where it is read from.
+func (q *Queue) handleMaxDeliveries(js jetstream.JetStream, m *nats.Msg) {
+	var advisory maxDeliveriesAdvisory
+	if err := json.Unmarshal(m.Data, &advisory); err != nil {
+		q.Logger.Error().Err(err).Str("subject", m.Subject).Msg("Could not decode max deliveries advisory")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.Timeout)
+	defer cancel()
+
+	stream, err := js.Stream(ctx, advisory.Stream)
+	if err != nil {
+		q.Logger.Error().Err(err).Str("stream", advisory.Stream).Msg("Could not look up stream of message that ran out of deliveries")
+		return
+	}
+	msg, err := stream.GetMsg(ctx, advisory.StreamSeq)
+	if err != nil {
+		q.Logger.Error().Err(err).Uint64("sequence", advisory.StreamSeq).Msg("Could not read message that ran out of deliveries")
+		return
+	}
+
+	letter := events.DeadLetter{
+		Subject:    events.OriginalSubject(msg.Subject),
+		Consumer:   q.Consumer,
+		Reason:     fmt.Sprintf("maximum deliveries (%d) exceeded without an ack", advisory.Deliveries),
+		Deliveries: advisory.Deliveries,
+		FailedAt:   time.Now().UTC(),
+		Data:       msg.Data,
+	}
+	if err := q.store(letter, advisory.StreamSeq); err != nil {
+		q.Logger.Error().Err(err).Str("subject", letter.Subject).Msg("Could not store dead letter of message that ran out of deliveries")
+	}
+}
+
+// publish stores the dead letter for msg.
+func (q *Queue) publish(msg jetstream.Msg, reason string) error {
+	letter := events.DeadLetter{
+		Subject:  events.OriginalSubject(msg.Subject()),
+		Consumer: q.Consumer,
+		Reason:   reason,
+		FailedAt: time.Now().UTC(),
+		Data:     msg.Data(),
+	}
+	var seq uint64
+	if meta, err := msg.Metadata(); err == nil {
+		letter.Deliveries = meta.NumDelivered
+		seq = meta.Sequence.Stream
+	}
+	return q.store(letter, seq)
+}
+
+// store publishes letter to the HELIOS_DLQ stream. The message ID is derived
+// from the consumer and seq, the message's sequence in the HELIOS stream, so
+// that a message is dead-lettered once even if two replicas report it.
+func (q *Queue) store(letter events.DeadLetter, seq uint64) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	var msgID string
+	if seq != 0 {
+		msgID = fmt.Sprintf("%s:%d", q.Consumer, seq)
+	}
+	if err := q.Publisher.PublishMsg(Subject(letter.Subject), msgID, data); err != nil {
+		return err
+	}
+
+	q.Logger.Warn().
+		Str("subject", letter.Subject).
+		Str("consumer", letter.Consumer).
+		Str("reason", letter.Reason).
+		Msg("Message dead-lettered")
+	return nil
+}
+
+// Record is a stored dead letter and its sequence in the HELIOS_DLQ stream.
+type Record struct {
+	Sequence uint64 `json:"sequence"`
+	events.DeadLetter
+}
+
+// Store reads, replays and removes dead letters.
+type Store struct {
+	JS      jetstream.JetStream
+	Timeout time.Duration
+}
+
+// NewStore creates a Store backed by js.
+func NewStore(js jetstream.JetStream) *Store {
+	return &Store{
+		JS:      js,
+		Timeout: bootstrap.NewJetStreamConfig().RequestTimeout,
+	}
+}
+
+// List returns up to limit dead letters with a sequence of at least from,
+// oldest first, optionally restricted to those given up on from subject. The
+// returned sequence is where the next page starts, or 0 on the last page.
+func (s *Store) List(ctx context.Context, subject string, from uint64, limit int) ([]Record, uint64, error) {
+	stream, err := s.stream(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filter := Subject(">")
+	if subject != "" {
+		filter = Subject(subject)
+	}
+	if from == 0 {
+		from = 1
+	}
+
+	records := []Record{}
+	for len(records) < limit {
+		msg, err := stream.GetMsg(ctx, from, jetstream.WithGetMsgSubject(filter))
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return records, 0, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+		record, err := decode(msg)
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(records, *record)
+		from = msg.Sequence + 1
+	}
+
+	// Peek ahead so the last page does not return a cursor.
+	if _, err := stream.GetMsg(ctx, from, jetstream.WithGetMsgSubject(filter)); errors.Is(err, jetstream.ErrMsgNotFound) {
+		return records, 0, nil
+	}
+	return records, from, nil
+}
+
+// Get returns the dead letter stored at seq.
+func (s *Store) Get(ctx context.Context, seq uint64) (*Record, error) {
+	stream, err := s.stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := stream.GetMsg(ctx, seq)
+	if errors.Is(err, jetstream.ErrMsgNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter: %w", err)
+	}
+	if !strings.HasPrefix(msg.Subject, events.SubjectDeadLetterPrefix) {
+		return nil, ErrNotFound
+	}
+	return decode(msg)
+}
+
+// Replay publishes the original payload of the dead letter at seq to the
+// replay subject of its consumer, so that only the consumer that gave up on
+// it receives it again, and then removes the dead letter.
+func (s *Store) Replay(ctx context.Context, seq uint64) (*Record, error) {
+	record, err := s.Get(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	if _, err := s.JS.Publish(ctx, events.ReplaySubject(record.Consumer, record.Subject), record.Data); err != nil {
+		return nil, fmt.Errorf("failed to republish dead letter: %w", err)
+	}
+
+	stream, err := s.stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.DeleteMsg(ctx, seq); err != nil && !errors.Is(err, jetstream.ErrMsgNotFound) {
+		return nil, fmt.Errorf("failed to remove replayed dead letter: %w", err)
+	}
+	return record, nil
+}
+
+// stream returns a handle on the HELIOS_DLQ stream.
+func (s *Store) stream(ctx context.Context) (jetstream.Stream, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	stream, err := s.JS.Stream(ctx, bootstrap.DeadLetterStreamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stream %s: %w", bootstrap.DeadLetterStreamName, err)
+	}
+	return stream, nil
+}
+
+// decode parses a stored dead letter.
+func decode(msg *jetstream.RawStreamMsg) (*Record, error) {
+	record := Record{Sequence: msg.Sequence}
+	if err := json.Unmarshal(msg.Data, &record.DeadLetter); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letter %d: %w", msg.Sequence, err)
+	}
+	return &record, nil
+}
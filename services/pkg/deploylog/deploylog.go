@@ -0,0 +1,66 @@
+// Package deploylog publishes what the workers log while they work on a
+// deployment as DeploymentLog events, so users can follow the deployment.
+package deploylog
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"helios/pkg/events"
+)
+
+// Hook is a zerolog hook that publishes every line of info level and above
+// logged to a deployment's logger. Only the level and message are published;
+// structured fields stay in the worker's own log.
+type Hook struct {
+	Publisher events.Publisher
+	// Logger reports lines that could not be published. It must not have
+	// the hook itself.
+	Logger zerolog.Logger
+	// Cause is the event the worker is handling. Lines carry its
+	// correlation ID.
+	Cause  events.Metadata
+	Source string
+	// DeploymentID and AppID identify the deployment the lines belong to.
+	DeploymentID string
+	AppID        string
+}
+
+// NewHook creates a Hook that publishes the lines of a deployment on p. logger
+// is the worker's logger without the hook.
+func NewHook(p events.Publisher, logger zerolog.Logger, cause events.Metadata, source, deploymentID, appID string) *Hook {
+	return &Hook{
+		Publisher:    p,
+		Logger:       logger,
+		Cause:        cause,
+		Source:       source,
+		DeploymentID: deploymentID,
+		AppID:        appID,
+	}
+}
+
+// Run publishes a line. A line that cannot be published is dropped, since
+// losing a log line must not fail the deployment.
+func (h *Hook) Run(_ *zerolog.Event, level zerolog.Level, message string) {
+	if level < zerolog.InfoLevel || level == zerolog.NoLevel {
+		return
+	}
+
+	// Every line is a new event, so the ID is not derived from the cause,
+	// but it stays in the cause's chain.
+	event := events.New(events.SubjectDeploymentLog, events.DeploymentLog{
+		DeploymentID: h.DeploymentID,
+		AppID:        h.AppID,
+		Source:       h.Source,
+		Level:        level.String(),
+		Message:      message,
+		Time:         time.Now().UTC(),
+	})
+	event.CorrelationID = h.Cause.CorrelationID
+	event.CausationID = h.Cause.ID
+
+	if err := events.Publish(h.Publisher, event); err != nil {
+		h.Logger.Warn().Err(err).Str("deployment_id", h.DeploymentID).Msg("Could not publish deployment log line")
+	}
+}
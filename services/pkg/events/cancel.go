@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Cancellations remembers the most recently cancelled deployments, so a
+// worker can abort its work on a deployment when a CancellationRequest
+// arrives, and skip deployments whose events arrive after it. It is safe for
+// concurrent use.
+type Cancellations struct {
+	mu        sync.Mutex
+	running   map[string]context.CancelFunc
+	cancelled *Deduplicator
+}
+
+// NewCancellations creates a Cancellations that remembers up to size
+// cancelled deployments.
+func NewCancellations(size int) *Cancellations {
+	return &Cancellations{
+		running:   map[string]context.CancelFunc{},
+		cancelled: NewDeduplicator(size),
+	}
+}
+
+// Start returns a copy of ctx that is cancelled when the deployment is, or
+// already cancelled if it was before. The caller must call done when it stops
+// working on the deployment.
+func (c *Cancellations) Start(ctx context.Context, deploymentID string) (_ context.Context, done func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancelled.Seen(deploymentID) {
+		cancel()
+	} else {
+		c.running[deploymentID] = cancel
+	}
+
+	return ctx, func() {
+		c.mu.Lock()
+		delete(c.running, deploymentID)
+		c.mu.Unlock()
+		cancel()
+	}
+}
+
+// Cancel records that the deployment was cancelled and cancels the context of
+// the work in progress on it, if any.
+func (c *Cancellations) Cancel(deploymentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelled.Mark(deploymentID)
+	if cancel, ok := c.running[deploymentID]; ok {
+		cancel()
+	}
+}
+
+// Cancelled reports whether the deployment was cancelled.
+func (c *Cancellations) Cancelled(deploymentID string) bool {
+	return c.cancelled.Seen(deploymentID)
+}
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the envelope version written by this code. Decode rejects
+// envelopes with a newer version, which this code cannot interpret.
+const SchemaVersion = 1
+
+// Metadata identifies an event and places it in the chain of events that
+// started with a single request.
+type Metadata struct {
+	// ID uniquely identifies the event. Publishers use it as the JetStream
+	// message ID, so the stream drops duplicates of an event.
+	ID string `json:"id"`
+	// Type is the subject the event is published on.
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// CorrelationID is shared by every event that follows from the same
+	// request, so logs can follow a deployment across services.
+	CorrelationID string `json:"correlation_id"`
+	// CausationID is the ID of the event this event was published in
+	// response to. It is empty for the first event of a chain.
+	CausationID string `json:"causation_id,omitempty"`
+}
+
+// Envelope wraps an event payload with its metadata. It is the format of
+// every message published on a v1 subject.
+type Envelope[T any] struct {
+	Metadata
+	Data T `json:"data"`
+}
+
+// New wraps data in an envelope for subject that starts a new chain of
+// events.
+func New[T any](subject string, data T) Envelope[T] {
+	id := newID()
+	return Envelope[T]{
+		Metadata: Metadata{
+			ID:            id,
+			Type:          subject,
+			Version:       SchemaVersion,
+			OccurredAt:    time.Now().UTC(),
+			CorrelationID: id,
+		},
+		Data: data,
+	}
+}
+
+// NewCausedBy wraps data in an envelope for subject that is published in
+// response to the event described by cause. The ID is derived from the cause
+// and subject, so handling a redelivered event publishes an identical ID and
+// the stream drops the duplicate.
+func NewCausedBy[T any](cause Metadata, subject string, data T) Envelope[T] {
+	return Envelope[T]{
+		Metadata: Metadata{
+			ID:            derivedID(cause.ID, subject),
+			Type:          subject,
+			Version:       SchemaVersion,
+			OccurredAt:    time.Now().UTC(),
+			CorrelationID: cause.CorrelationID,
+			CausationID:   cause.ID,
+		},
+		Data: data,
+	}
+}
+
+// Decode parses an envelope whose payload is a T. It does not validate the
+// payload.
+func Decode[T any](data []byte) (Envelope[T], error) {
+	var e Envelope[T]
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, err
+	}
+	if e.ID == "" {
+		return e, errors.New("event has no id")
+	}
+	if e.Version < 1 || e.Version > SchemaVersion {
+		return e, fmt.Errorf("unsupported event version %d", e.Version)
+	}
+	return e, nil
+}
+
+// Publisher defines the interface for publishing messages to NATS with a
+// message ID used for deduplication.
+type Publisher interface {
+	PublishMsg(subject, msgID string, data []byte) error
+}
+
+// Publish marshals e and publishes it on its subject, using the event ID as
+// the message ID.
+func Publish[T any](p Publisher, e Envelope[T]) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", e.Type, err)
+	}
+	return p.PublishMsg(e.Type, e.ID, data)
+}
+
+// Deduplicator remembers the IDs of the most recently handled events, so a
+// consumer can acknowledge a redelivered event without handling it twice. It
+// is safe for concurrent use.
+type Deduplicator struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+	ring []string
+	next int
+}
+
+// NewDeduplicator creates a Deduplicator that remembers up to size event IDs.
+func NewDeduplicator(size int) *Deduplicator {
+	return &Deduplicator{
+		seen: make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// Seen reports whether the event with the given ID was marked as handled.
+func (d *Deduplicator) Seen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.seen[id]
+	return ok
+}
+
+// Mark records that the event with the given ID was handled, forgetting the
+// oldest ID once the Deduplicator is full.
+func (d *Deduplicator) Mark(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[id]; ok || len(d.ring) == 0 {
+		return
+	}
+	delete(d.seen, d.ring[d.next])
+	d.ring[d.next] = id
+	d.seen[id] = struct{}{}
+	d.next = (d.next + 1) % len(d.ring)
+}
+
+// newID returns a random (version 4) UUID.
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("events: failed to read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// derivedID returns a name-based (version 5 style) UUID for the event on
+// subject caused by the event causeID.
+func derivedID(causeID, subject string) string {
+	sum := sha1.Sum([]byte(causeID + "/" + subject))
+	var b [16]byte
+	copy(b[:], sum[:16])
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
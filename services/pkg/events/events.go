@@ -2,24 +2,260 @@
 // published to and consumed from the NATS message queue.
 package events
 
+import (
+	"strings"
+	"time"
+)
+
 // Defines the subjects for NATS messaging.
 const (
 	SubjectDeploymentRequested = "v1.deployment.requested"
+	SubjectBuildStarted        = "v1.build.started"
 	SubjectBuildSucceeded      = "v1.build.succeeded"
+	SubjectBuildFailed         = "v1.build.failed"
+	SubjectDeploymentStarted   = "v1.deployment.started"
+	SubjectDeploymentSucceeded = "v1.deployment.succeeded"
+	SubjectDeploymentFailed    = "v1.deployment.failed"
+	SubjectDeploymentCancelled = "v1.deployment.cancelled"
+	SubjectDeploymentLog       = "v1.deployment.log"
+	SubjectRollbackRequested   = "v1.rollback.requested"
+
+	// SubjectCancellationRequested is received by every worker replica,
+	// since any of them may be working on the deployment.
+	SubjectCancellationRequested = "v1.cancellation.requested"
+
+	// SubjectDeadLetterPrefix prefixes the subject of every dead letter. An
+	// event given up on from v1.build.succeeded is stored on
+	// v1.dlq.v1.build.succeeded, in the HELIOS_DLQ stream rather than the
+	// HELIOS stream.
+	SubjectDeadLetterPrefix = "v1.dlq."
+
+	// SubjectReplayPrefix prefixes the subjects dead letters are replayed
+	// on. A dead letter of the consumer api-build-succeeded is replayed on
+	// v1.replay.api-build-succeeded.v1.build.succeeded, which no other
+	// consumer of v1.build.succeeded receives.
+	SubjectReplayPrefix = "v1.replay."
 )
 
+// ReplaySubject returns the subject that events from subject are replayed on
+// for the durable consumer.
+func ReplaySubject(consumer, subject string) string {
+	return SubjectReplayPrefix + consumer + "." + subject
+}
+
+// OriginalSubject returns the subject an event was first published on, which
+// is subject itself unless the event was replayed.
+func OriginalSubject(subject string) string {
+	if rest, ok := strings.CutPrefix(subject, SubjectReplayPrefix); ok {
+		if _, original, ok := strings.Cut(rest, "."); ok {
+			return original
+		}
+	}
+	return subject
+}
+
+// SubjectPlanRequest is the subject the oal-worker answers PlanRequests on,
+// with NATS request-reply. It is outside the HELIOS stream, since a plan
+// changes nothing and is not an event to keep.
+const SubjectPlanRequest = "rpc.v1.plan"
+
+// DeadLetter is the payload stored when a consumer gives up on an event,
+// either because the event is invalid or because it failed on every delivery.
+type DeadLetter struct {
+	Subject    string    `json:"subject"`
+	Consumer   string    `json:"consumer"`
+	Reason     string    `json:"reason"`
+	Deliveries uint64    `json:"deliveries"`
+	FailedAt   time.Time `json:"failed_at"`
+	// Data is the original payload. It is kept as raw bytes because it may
+	// not be valid JSON.
+	Data []byte `json:"data"`
+}
+
 // DeploymentRequest is the event payload for a new deployment, published by the
 // API service and consumed by the build-worker.
 type DeploymentRequest struct {
+	DeploymentID  string `json:"deployment_id" validate:"required"`
 	AppID         string `json:"app_id" validate:"required"`
 	GitRepository string `json:"git_repository" validate:"required,url"`
 	GitBranch     string `json:"git_branch" validate:"required"`
+	// Backend is the application's current_backend, which the oal-worker
+	// deploys with. If empty, the default backend is used.
+	Backend string `json:"backend,omitempty"`
+	// Config holds the config vars of the application, which its Heliosfile
+	// can refer to as ${config.<NAME>}.
+	Config map[string]string `json:"config,omitempty"`
+	// Secrets names the secrets of the application, which its Heliosfile
+	// can refer to as ${secrets.<NAME>}. Their values are never sent; the
+	// backends provide them.
+	Secrets []string `json:"secrets,omitempty"`
+	// Previous is the release of the application's last successful
+	// deployment, which the oal-worker plans the deployment against. It is
+	// nil if the application was never deployed.
+	Previous *Release `json:"previous,omitempty"`
 }
 
 // BuildSucceeded is the event payload published by the build-worker when it
-// successfully builds a container image. It is consumed by the oal-worker.
+// successfully builds a container image. It is consumed by the oal-worker and
+// by the API service, which records the image on the deployment.
 type BuildSucceeded struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
 	AppID        string `json:"app_id" validate:"required"`
 	ImageURI     string `json:"image_uri" validate:"required"`
 	GitCommitSHA string `json:"git_commit_sha" validate:"required"`
-}
\ No newline at end of file
+	// Manifest is the content of the Heliosfile.yml at the built commit. It
+	// is empty if the repository has none.
+	Manifest string `json:"manifest,omitempty"`
+	// Backend, Config, Secrets and Previous are copied from the
+	// DeploymentRequest.
+	Backend  string            `json:"backend,omitempty"`
+	Config   map[string]string `json:"config,omitempty"`
+	Secrets  []string          `json:"secrets,omitempty"`
+	Previous *Release          `json:"previous,omitempty"`
+}
+
+// BuildStarted is the event payload published by the build-worker when it
+// starts building a deployment.
+type BuildStarted struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+}
+
+// BuildFailed is the event payload published by the build-worker when a
+// build fails. The deployment ends in the failed status.
+type BuildFailed struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+	Reason       string `json:"reason" validate:"required"`
+}
+
+// DeploymentStarted is the event payload published by the oal-worker when it
+// starts releasing a built image.
+type DeploymentStarted struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+	ImageURI     string `json:"image_uri" validate:"required"`
+}
+
+// DeploymentSucceeded is the event payload published by the oal-worker when
+// the image is running. It ends the deployment pipeline.
+type DeploymentSucceeded struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+	ImageURI     string `json:"image_uri" validate:"required"`
+}
+
+// DeploymentFailed is the event payload published by the oal-worker when the
+// image could not be released. The deployment ends in the failed status.
+type DeploymentFailed struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+	Reason       string `json:"reason" validate:"required"`
+}
+
+// DeploymentCancelled is the event payload published by a worker when it
+// stops working on a deployment because it was cancelled. The deployment ends
+// in the cancelled status.
+type DeploymentCancelled struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+}
+
+// DeploymentLog is the event payload for a line logged by a worker while it
+// works on a deployment. The API keeps the lines so users can read them.
+type DeploymentLog struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+	// Source is the step the line was logged by, one of the Log* constants.
+	Source  string    `json:"source" validate:"required"`
+	Level   string    `json:"level" validate:"required"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time" validate:"required"`
+}
+
+// The sources of DeploymentLog lines.
+const (
+	LogSourceBuild  = "build"
+	LogSourceDeploy = "deploy"
+)
+
+// CancellationRequest is the event payload published by the API to cancel a
+// deployment that has not finished. The build-worker aborts its build and the
+// oal-worker skips it if it has not applied it yet.
+type CancellationRequest struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+}
+
+// RollbackRequest is the event payload published by the API to deploy the
+// release of an earlier deployment again. It goes to the oal-worker directly,
+// since the image is already built.
+type RollbackRequest struct {
+	DeploymentID string `json:"deployment_id" validate:"required"`
+	AppID        string `json:"app_id" validate:"required"`
+	// RollbackOf is the earlier deployment whose release is deployed again.
+	RollbackOf   string `json:"rollback_of" validate:"required"`
+	ImageURI     string `json:"image_uri" validate:"required"`
+	GitCommitSHA string `json:"git_commit_sha,omitempty"`
+	Manifest     string `json:"manifest,omitempty"`
+	// Backend, Config, Secrets and Previous are as in a DeploymentRequest.
+	Backend  string            `json:"backend,omitempty"`
+	Config   map[string]string `json:"config,omitempty"`
+	Secrets  []string          `json:"secrets,omitempty"`
+	Previous *Release          `json:"previous,omitempty"`
+}
+
+// Release is a built image and the Heliosfile.yml it was built with.
+type Release struct {
+	ImageURI string `json:"image_uri,omitempty"`
+	// Manifest is the content of the Heliosfile.yml. It is empty if the
+	// repository has none.
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// PlanRequest asks the oal-worker which changes deploying Desired would make
+// to an application running Current.
+type PlanRequest struct {
+	AppID   string            `json:"app_id" validate:"required"`
+	Config  map[string]string `json:"config,omitempty"`
+	Secrets []string          `json:"secrets,omitempty"`
+	// Current is the release of the last successful deployment. It is nil
+	// if the application was never deployed.
+	Current *Release `json:"current,omitempty"`
+	// Desired is the release to plan. If its ImageURI is empty, images are
+	// not compared.
+	Desired Release `json:"desired"`
+}
+
+// PlanReply answers a PlanRequest.
+type PlanReply struct {
+	Changes []Change `json:"changes"`
+	// Error is why no plan could be made, such as an invalid Heliosfile.
+	Error string `json:"error,omitempty"`
+}
+
+// The actions of a Change.
+const (
+	ChangeAdd    = "add"
+	ChangeUpdate = "update"
+	ChangeRemove = "remove"
+	ChangeNone   = "none"
+)
+
+// Change is what a deployment does to one service or database.
+type Change struct {
+	// Kind is "service" or "database".
+	Kind   string        `json:"kind"`
+	Name   string        `json:"name"`
+	Action string        `json:"action"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a setting of a service or database that changes, such as
+// "image", "memory" or "env.NODE_ENV". Old is empty for a new setting and New
+// for a removed one.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
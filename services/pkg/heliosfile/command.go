@@ -0,0 +1,71 @@
+package heliosfile
+
+import (
+	"errors"
+	"strings"
+)
+
+// SplitCommand splits a service command into words the way a POSIX shell
+// does, without expanding anything: words are separated by blanks, and
+// quotes and backslashes stop characters from separating them. Backends that
+// need the command as an argument list, like Kubernetes, use it; Compose
+// splits the command the same way itself.
+func SplitCommand(command string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			// In double quotes, a backslash only escapes characters that
+			// are special there.
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	switch {
+	case escaped:
+		return nil, errors.New("ends with an unfinished escape")
+	case quote != 0:
+		return nil, errors.New("has an unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,51 @@
+package heliosfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{name: "Empty", command: "", expected: nil},
+		{name: "Words", command: "npm run  worker", expected: []string{"npm", "run", "worker"}},
+		{name: "Surrounding blanks", command: "\t./server --port 80 ", expected: []string{"./server", "--port", "80"}},
+		{name: "Single quotes", command: `sh -c 'echo "$HOME" \n'`, expected: []string{"sh", "-c", `echo "$HOME" \n`}},
+		{name: "Double quotes", command: `echo "a \"b\" \n $c"`, expected: []string{"echo", `a "b" \n $c`}},
+		{name: "Escaped blank", command: `cat my\ file`, expected: []string{"cat", "my file"}},
+		{name: "Empty quoted word", command: `run ''`, expected: []string{"run", ""}},
+		{name: "Adjacent quotes", command: `--name="my app"x`, expected: []string{"--name=my appx"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			words, err := SplitCommand(tc.command)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, words)
+		})
+	}
+}
+
+func TestSplitCommandErrors(t *testing.T) {
+	testCases := []struct {
+		command       string
+		expectedError string
+	}{
+		{command: `echo "hello`, expectedError: "has an unterminated quote"},
+		{command: `echo 'hello`, expectedError: "has an unterminated quote"},
+		{command: `echo hello\`, expectedError: "ends with an unfinished escape"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			_, err := SplitCommand(tc.command)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package heliosfile
+
+import "strings"
+
+// Engine describes how a managed database type runs in a container, for the
+// backends that translate a manifest.
+type Engine struct {
+	// Image is the image repository; the tag is the database version.
+	Image          string
+	DefaultVersion string
+	Port           int
+	// Scheme is the scheme of the URLs that clients connect with.
+	Scheme string
+	// DataDir is where the database keeps the data that must be persisted.
+	DataDir string
+	// Env returns the environment that makes the container create database
+	// for DatabaseUser on its first start. It is nil if the engine needs no
+	// credentials.
+	Env func(database string) map[string]string
+	// PasswordEnv is the variable the container reads the password of
+	// DatabaseUser from. It is empty if the engine needs no credentials.
+	PasswordEnv string
+	// Healthcheck is a command that succeeds once the database accepts
+	// connections.
+	Healthcheck []string
+}
+
+// DatabaseUser is the user every managed database is created for.
+const DatabaseUser = "helios"
+
+// Engines describes the engine of each of the DatabaseTypes.
+var Engines = map[string]Engine{
+	"postgresql": {
+		Image:          "postgres",
+		DefaultVersion: "16",
+		Port:           5432,
+		Scheme:         "postgresql",
+		DataDir:        "/var/lib/postgresql/data",
+		Env: func(database string) map[string]string {
+			return map[string]string{
+				"POSTGRES_DB":   database,
+				"POSTGRES_USER": DatabaseUser,
+				// A subdirectory, since a new volume may not be empty.
+				"PGDATA": "/var/lib/postgresql/data/pgdata",
+			}
+		},
+		PasswordEnv: "POSTGRES_PASSWORD",
+		Healthcheck: []string{"pg_isready", "-U", DatabaseUser},
+	},
+	"mysql": {
+		Image:          "mysql",
+		DefaultVersion: "8.4",
+		Port:           3306,
+		Scheme:         "mysql",
+		DataDir:        "/var/lib/mysql",
+		Env: func(database string) map[string]string {
+			return map[string]string{
+				"MYSQL_DATABASE":             database,
+				"MYSQL_USER":                 DatabaseUser,
+				"MYSQL_RANDOM_ROOT_PASSWORD": "yes",
+			}
+		},
+		PasswordEnv: "MYSQL_PASSWORD",
+		Healthcheck: []string{"mysqladmin", "ping", "-h", "127.0.0.1"},
+	},
+	"redis": {
+		Image:          "redis",
+		DefaultVersion: "7",
+		Port:           6379,
+		Scheme:         "redis",
+		DataDir:        "/data",
+		Healthcheck:    []string{"redis-cli", "ping"},
+	},
+}
+
+// Engine returns the engine of the database.
+func (d Database) Engine() Engine {
+	return Engines[d.Type]
+}
+
+// Image returns the image reference the database runs from.
+func (d Database) Image() string {
+	e := d.Engine()
+	version := d.Version
+	if version == "" {
+		version = e.DefaultVersion
+	}
+	return e.Image + ":" + version
+}
+
+// PasswordVariable returns the name of the variable the password of the
+// database called name is provided in, such as HELIOS_POSTGRES_PASSWORD.
+// Backends set it when they apply a deployment, so passwords are never
+// written to the rendered configuration.
+func PasswordVariable(name string) string {
+	return "HELIOS_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_PASSWORD"
+}
@@ -0,0 +1,478 @@
+// Package heliosfile parses and validates Heliosfile.yml, the manifest that
+// describes the services and managed databases of an application.
+package heliosfile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the manifest at the root of a repository.
+const FileName = "Heliosfile.yml"
+
+// Version is the only manifest version this package understands.
+const Version = 1
+
+// DefaultHTTPPort is the port the default web service listens on, which is
+// the port Buildpacks images serve on.
+const DefaultHTTPPort = 8080
+
+// DatabaseTypes lists the managed database types that can be declared.
+var DatabaseTypes = []string{"postgresql", "mysql", "redis"}
+
+// Heliosfile is a parsed and validated manifest.
+type Heliosfile struct {
+	Name    string `yaml:"name"`
+	Version int    `yaml:"version"`
+	// Build overrides how the image is built. If nil, Buildpacks are used.
+	Build     *Build              `yaml:"build,omitempty"`
+	Services  map[string]Service  `yaml:"services"`
+	Databases map[string]Database `yaml:"databases,omitempty"`
+
+	// positions locates the env values of the services by path, for the
+	// errors of Interpolate.
+	positions map[string]position
+}
+
+// position is a line and column in a manifest.
+type position struct {
+	line, column int
+}
+
+// Build selects how the image of an application is built. At most one of
+// the fields is set.
+type Build struct {
+	Builder    string `yaml:"builder,omitempty"`
+	Dockerfile string `yaml:"dockerfile,omitempty"`
+}
+
+// Service is a process started from the application image.
+type Service struct {
+	Command string `yaml:"command,omitempty"`
+	// HTTPPort is the port exposed publicly on 80/443. If zero, the service
+	// is not exposed.
+	HTTPPort int `yaml:"http_port,omitempty"`
+	// CPU is the number of cores reserved. If zero, none are reserved.
+	CPU float64 `yaml:"cpu,omitempty"`
+	// Memory is the memory reserved, in MB. If zero, none is reserved.
+	Memory      int               `yaml:"memory,omitempty"`
+	Healthcheck *Healthcheck      `yaml:"healthcheck,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+}
+
+// Healthcheck is an HTTP endpoint that answers 2xx when a service is ready.
+type Healthcheck struct {
+	Path string `yaml:"path"`
+	// Port defaults to the service's HTTP port.
+	Port int `yaml:"port,omitempty"`
+}
+
+// Database is a managed backing service.
+type Database struct {
+	Type string `yaml:"type"`
+	// Version is the image version. If empty, the backend's default is used.
+	Version string `yaml:"version,omitempty"`
+	// Storage is the persistent storage allocated, in GB. If zero, the
+	// backend's default is used.
+	Storage int `yaml:"storage,omitempty"`
+}
+
+// Default returns the manifest used for an application whose repository has
+// no Heliosfile: a single web service serving HTTP on DefaultHTTPPort.
+func Default(name string) *Heliosfile {
+	return &Heliosfile{
+		Name:     name,
+		Version:  Version,
+		Services: map[string]Service{"web": {HTTPPort: DefaultHTTPPort}},
+	}
+}
+
+// ServiceNames returns the names of the services in order.
+func (f *Heliosfile) ServiceNames() []string {
+	return sortedKeys(f.Services)
+}
+
+// DatabaseNames returns the names of the databases in order.
+func (f *Heliosfile) DatabaseNames() []string {
+	return sortedKeys(f.Databases)
+}
+
+// Error is a problem at a position in a manifest. Line and Column are
+// 1-based; they are zero if the position is unknown.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line == 0:
+		return e.Message
+	case e.Column == 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	default:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+}
+
+// Errors lists every problem found in a manifest, in the order they appear.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// yamlLine matches the position in the syntax errors of the YAML parser.
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// Parse parses and validates a manifest. Unknown fields, values of the wrong
+// type and invalid values are all errors. The returned error is an Errors.
+func Parse(data []byte) (*Heliosfile, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		line := 0
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			msg = strings.TrimPrefix(err.Error(), m[0])
+		}
+		return nil, Errors{{Line: line, Message: msg}}
+	}
+	if len(root.Content) == 0 {
+		return nil, Errors{{Message: "the manifest is empty"}}
+	}
+
+	p := &parser{}
+	f := p.heliosfile(root.Content[0])
+	if len(p.errs) > 0 {
+		sortErrors(p.errs)
+		return nil, p.errs
+	}
+	return f, nil
+}
+
+var (
+	// dnsLabel matches names that can be used as a host name, a Compose
+	// service name and a Kubernetes object name.
+	dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+	envName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// parser walks the YAML nodes of a manifest, collecting every error with its
+// position instead of stopping at the first.
+type parser struct {
+	errs Errors
+}
+
+func (p *parser) errorf(n *yaml.Node, path, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	p.errs = append(p.errs, &Error{Line: n.Line, Column: n.Column, Message: msg})
+}
+
+// fields calls field for each key of the mapping n, reporting keys that are
+// not in known and duplicate keys. If known is nil, any key is accepted.
+func (p *parser) fields(n *yaml.Node, path string, known []string, field func(key, value *yaml.Node)) {
+	n = resolve(n)
+	if n.Kind != yaml.MappingNode {
+		p.errorf(n, path, "must be a mapping")
+		return
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], resolve(n.Content[i+1])
+		name := join(path, key.Value)
+		switch {
+		case seen[key.Value]:
+			p.errorf(key, name, "duplicate field")
+		case known != nil && !contains(known, key.Value):
+			p.errorf(key, name, "unknown field, expected one of %s", strings.Join(known, ", "))
+		default:
+			seen[key.Value] = true
+			field(key, value)
+		}
+	}
+}
+
+func (p *parser) heliosfile(n *yaml.Node) *Heliosfile {
+	root := resolve(n)
+	if root.Kind != yaml.MappingNode {
+		p.errorf(root, "", "the manifest must be a mapping")
+		return nil
+	}
+
+	f := &Heliosfile{Version: Version, positions: map[string]position{}}
+	var nameNode, servicesNode *yaml.Node
+	databaseKeys := map[string]*yaml.Node{}
+	p.fields(n, "", []string{"name", "version", "build", "services", "databases"}, func(k, v *yaml.Node) {
+		key := k.Value
+		switch key {
+		case "name":
+			nameNode = v
+			f.Name = p.str(v, key)
+		case "version":
+			if version, ok := p.int(v, key); ok && version != Version {
+				p.errorf(v, key, "unsupported version %d, expected %d", version, Version)
+			}
+		case "build":
+			f.Build = p.build(v)
+		case "services":
+			servicesNode = v
+			f.Services = map[string]Service{}
+			p.fields(v, key, nil, func(name, sv *yaml.Node) {
+				if p.name(name, join(key, name.Value)) {
+					f.Services[name.Value] = p.service(sv, join(key, name.Value), f.positions)
+				}
+			})
+		case "databases":
+			f.Databases = map[string]Database{}
+			p.fields(v, key, nil, func(name, dv *yaml.Node) {
+				if p.name(name, join(key, name.Value)) {
+					databaseKeys[name.Value] = name
+					f.Databases[name.Value] = p.database(dv, join(key, name.Value))
+				}
+			})
+		}
+	})
+
+	switch {
+	case nameNode == nil:
+		p.errorf(root, "name", "is required")
+	case !dnsLabel.MatchString(f.Name):
+		p.errorf(nameNode, "name", "must be a lowercase DNS label of at most 63 letters, digits and '-'")
+	}
+	switch {
+	case servicesNode == nil:
+		p.errorf(root, "services", "is required")
+	case len(f.Services) == 0 && resolve(servicesNode).Kind == yaml.MappingNode:
+		p.errorf(servicesNode, "services", "must declare at least one service")
+	}
+	for name, key := range databaseKeys {
+		if _, ok := f.Services[name]; ok {
+			p.errorf(key, join("databases", name), "has the same name as a service")
+		}
+	}
+	return f
+}
+
+// name validates the key naming a service or database.
+func (p *parser) name(key *yaml.Node, path string) bool {
+	switch {
+	case !dnsLabel.MatchString(key.Value):
+		p.errorf(key, path, "name must be a lowercase DNS label of at most 63 letters, digits and '-'")
+	case key.Value == ConfigReference || key.Value == SecretsReference:
+		p.errorf(key, path, "name %q is reserved for references to config vars and secrets", key.Value)
+	default:
+		return true
+	}
+	return false
+}
+
+func (p *parser) build(n *yaml.Node) *Build {
+	b := &Build{}
+	p.fields(n, "build", []string{"builder", "dockerfile"}, func(k, v *yaml.Node) {
+		switch k.Value {
+		case "builder":
+			b.Builder = p.str(v, "build.builder")
+		case "dockerfile":
+			b.Dockerfile = p.str(v, "build.dockerfile")
+		}
+	})
+	if b.Builder != "" && b.Dockerfile != "" {
+		p.errorf(resolve(n), "build", "builder and dockerfile cannot both be set")
+	}
+	return b
+}
+
+// service parses a service, recording the position of each env value in
+// positions.
+func (p *parser) service(n *yaml.Node, path string, positions map[string]position) Service {
+	var s Service
+	var healthNode *yaml.Node
+	p.fields(n, path, []string{"command", "http_port", "cpu", "memory", "healthcheck", "env"}, func(k, v *yaml.Node) {
+		field := join(path, k.Value)
+		switch k.Value {
+		case "command":
+			s.Command = p.str(v, field)
+			if _, err := SplitCommand(s.Command); err != nil {
+				p.errorf(v, field, "%s", err)
+			}
+		case "http_port":
+			s.HTTPPort = p.port(v, field)
+		case "cpu":
+			if cpu, ok := p.float(v, field); ok {
+				if cpu <= 0 {
+					p.errorf(v, field, "must be a positive number of cores")
+				}
+				s.CPU = cpu
+			}
+		case "memory":
+			if memory, ok := p.int(v, field); ok {
+				if memory <= 0 {
+					p.errorf(v, field, "must be a positive number of MB")
+				}
+				s.Memory = memory
+			}
+		case "healthcheck":
+			healthNode = v
+			s.Healthcheck = p.healthcheck(v, field)
+		case "env":
+			s.Env = map[string]string{}
+			p.fields(v, field, nil, func(name, ev *yaml.Node) {
+				if !envName.MatchString(name.Value) {
+					p.errorf(name, join(field, name.Value), "is not a valid environment variable name")
+					return
+				}
+				s.Env[name.Value] = p.str(ev, join(field, name.Value))
+				positions[join(field, name.Value)] = position{line: ev.Line, column: ev.Column}
+				if _, err := parseTemplate(s.Env[name.Value]); err != nil {
+					p.errorf(ev, join(field, name.Value), "%s", err)
+				}
+			})
+		}
+	})
+	if h := s.Healthcheck; h != nil && h.Port == 0 {
+		if s.HTTPPort == 0 {
+			p.errorf(healthNode, join(path, "healthcheck.port"), "is required when the service has no http_port")
+		}
+		h.Port = s.HTTPPort
+	}
+	return s
+}
+
+func (p *parser) healthcheck(n *yaml.Node, path string) *Healthcheck {
+	h := &Healthcheck{}
+	var pathNode *yaml.Node
+	p.fields(n, path, []string{"path", "port"}, func(k, v *yaml.Node) {
+		switch k.Value {
+		case "path":
+			pathNode = v
+			h.Path = p.str(v, join(path, "path"))
+		case "port":
+			h.Port = p.port(v, join(path, "port"))
+		}
+	})
+	switch {
+	case pathNode == nil:
+		if resolve(n).Kind == yaml.MappingNode {
+			p.errorf(resolve(n), join(path, "path"), "is required")
+		}
+	case !strings.HasPrefix(h.Path, "/"):
+		p.errorf(pathNode, join(path, "path"), "must start with /")
+	}
+	return h
+}
+
+func (p *parser) database(n *yaml.Node, path string) Database {
+	var d Database
+	var typeNode *yaml.Node
+	p.fields(n, path, []string{"type", "version", "storage"}, func(k, v *yaml.Node) {
+		field := join(path, k.Value)
+		switch k.Value {
+		case "type":
+			typeNode = v
+			d.Type = p.str(v, field)
+		case "version":
+			d.Version = p.str(v, field)
+		case "storage":
+			if storage, ok := p.int(v, field); ok {
+				if storage <= 0 {
+					p.errorf(v, field, "must be a positive number of GB")
+				}
+				d.Storage = storage
+			}
+		}
+	})
+	switch {
+	case typeNode == nil:
+		if resolve(n).Kind == yaml.MappingNode {
+			p.errorf(resolve(n), join(path, "type"), "is required")
+		}
+	case !contains(DatabaseTypes, d.Type):
+		p.errorf(typeNode, join(path, "type"), "unsupported database type %q, expected one of %s", d.Type, strings.Join(DatabaseTypes, ", "))
+	}
+	return d
+}
+
+// str returns the value of a scalar. Numbers and booleans are kept as they
+// are written, so `version: 15` and `version: "15"` are the same.
+func (p *parser) str(n *yaml.Node, path string) string {
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		p.errorf(n, path, "must be a string")
+		return ""
+	}
+	return n.Value
+}
+
+func (p *parser) int(n *yaml.Node, path string) (int, bool) {
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!int" {
+		if v, err := strconv.Atoi(n.Value); err == nil {
+			return v, true
+		}
+	}
+	p.errorf(n, path, "must be an integer")
+	return 0, false
+}
+
+func (p *parser) float(n *yaml.Node, path string) (float64, bool) {
+	if n.Kind == yaml.ScalarNode && (n.Tag == "!!int" || n.Tag == "!!float") {
+		if v, err := strconv.ParseFloat(n.Value, 64); err == nil {
+			return v, true
+		}
+	}
+	p.errorf(n, path, "must be a number")
+	return 0, false
+}
+
+func (p *parser) port(n *yaml.Node, path string) int {
+	port, ok := p.int(n, path)
+	if ok && (port < 1 || port > 65535) {
+		p.errorf(n, path, "must be between 1 and 65535")
+	}
+	return port
+}
+
+// resolve follows an alias to the node it refers to.
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,294 @@
+package heliosfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// designManifest is the example from the architectural design document.
+const designManifest = `# Heliosfile.yml
+name: my-awesome-app
+version: 1
+
+build:
+  builder: "paketo-buildpacks/builder:base"
+
+services:
+  web:
+    command: "npm start"
+    http_port: 3000
+    cpu: 0.5
+    memory: 512
+    healthcheck:
+      path: "/healthz"
+      port: 3000
+    env:
+      NODE_ENV: production
+      DATABASE_URL: ${postgres.URL}
+
+  worker:
+    command: "npm run worker"
+
+databases:
+  postgres:
+    type: postgresql
+    version: "15"
+    storage: 10
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(designManifest))
+	require.NoError(t, err)
+
+	expected := &Heliosfile{
+		Name:    "my-awesome-app",
+		Version: 1,
+		Build:   &Build{Builder: "paketo-buildpacks/builder:base"},
+		Services: map[string]Service{
+			"web": {
+				Command:     "npm start",
+				HTTPPort:    3000,
+				CPU:         0.5,
+				Memory:      512,
+				Healthcheck: &Healthcheck{Path: "/healthz", Port: 3000},
+				Env: map[string]string{
+					"NODE_ENV":     "production",
+					"DATABASE_URL": "${postgres.URL}",
+				},
+			},
+			"worker": {Command: "npm run worker"},
+		},
+		Databases: map[string]Database{
+			"postgres": {Type: "postgresql", Version: "15", Storage: 10},
+		},
+		positions: map[string]position{
+			"services.web.env.NODE_ENV":     {line: 18, column: 17},
+			"services.web.env.DATABASE_URL": {line: 19, column: 21},
+		},
+	}
+	assert.Equal(t, expected, f)
+	assert.Equal(t, []string{"web", "worker"}, f.ServiceNames())
+	assert.Equal(t, []string{"postgres"}, f.DatabaseNames())
+}
+
+func TestParseDefaults(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		check    func(t *testing.T, f *Heliosfile)
+	}{
+		{
+			name:     "Version defaults to 1",
+			manifest: "name: app\nservices:\n  web: {}\n",
+			check: func(t *testing.T, f *Heliosfile) {
+				assert.Equal(t, Version, f.Version)
+				assert.Nil(t, f.Build)
+				assert.Empty(t, f.Databases)
+			},
+		},
+		{
+			name:     "Healthcheck port defaults to the HTTP port",
+			manifest: "name: app\nservices:\n  web:\n    healthcheck:\n      path: /up\n    http_port: 8000\n",
+			check: func(t *testing.T, f *Heliosfile) {
+				assert.Equal(t, &Healthcheck{Path: "/up", Port: 8000}, f.Services["web"].Healthcheck)
+			},
+		},
+		{
+			name:     "Scalars are read as strings",
+			manifest: "name: app\nservices:\n  web:\n    env:\n      WORKERS: 4\n      DEBUG: true\ndatabases:\n  db:\n    type: redis\n    version: 7.2\n",
+			check: func(t *testing.T, f *Heliosfile) {
+				assert.Equal(t, map[string]string{"WORKERS": "4", "DEBUG": "true"}, f.Services["web"].Env)
+				assert.Equal(t, "7.2", f.Databases["db"].Version)
+			},
+		},
+		{
+			name:     "Aliases are resolved",
+			manifest: "name: app\nservices:\n  web:\n    env: &env\n      A: b\n  worker:\n    env: *env\n",
+			check: func(t *testing.T, f *Heliosfile) {
+				assert.Equal(t, map[string]string{"A": "b"}, f.Services["worker"].Env)
+			},
+		},
+		{
+			name:     "Integer CPU",
+			manifest: "name: app\nservices:\n  web:\n    cpu: 2\n",
+			check: func(t *testing.T, f *Heliosfile) {
+				assert.Equal(t, 2.0, f.Services["web"].CPU)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Parse([]byte(tc.manifest))
+			require.NoError(t, err)
+			tc.check(t, f)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name           string
+		manifest       string
+		expectedErrors []string
+	}{
+		{
+			name:           "Empty manifest",
+			manifest:       "",
+			expectedErrors: []string{"the manifest is empty"},
+		},
+		{
+			name:           "Syntax error",
+			manifest:       "name: app\nservices:\n  web: [\n",
+			expectedErrors: []string{"line 3: did not find expected node content"},
+		},
+		{
+			name:           "Not a mapping",
+			manifest:       "- web\n",
+			expectedErrors: []string{"line 1, column 1: the manifest must be a mapping"},
+		},
+		{
+			name:     "Missing required fields",
+			manifest: "version: 1\n",
+			expectedErrors: []string{
+				"line 1, column 1: name: is required",
+				"line 1, column 1: services: is required",
+			},
+		},
+		{
+			name:           "Unknown top-level field",
+			manifest:       "name: app\nservice:\n  web: {}\nservices:\n  web: {}\n",
+			expectedErrors: []string{"line 2, column 1: service: unknown field, expected one of name, version, build, services, databases"},
+		},
+		{
+			name:           "Unknown service field",
+			manifest:       "name: app\nservices:\n  web:\n    port: 80\n",
+			expectedErrors: []string{"line 4, column 5: services.web.port: unknown field, expected one of command, http_port, cpu, memory, healthcheck, env"},
+		},
+		{
+			name:           "Duplicate field",
+			manifest:       "name: app\nservices:\n  web: {}\n  web: {}\n",
+			expectedErrors: []string{"line 4, column 3: services.web: duplicate field"},
+		},
+		{
+			name:           "Unsupported version",
+			manifest:       "name: app\nversion: 2\nservices:\n  web: {}\n",
+			expectedErrors: []string{"line 2, column 10: version: unsupported version 2, expected 1"},
+		},
+		{
+			name:           "Invalid application name",
+			manifest:       "name: My App\nservices:\n  web: {}\n",
+			expectedErrors: []string{"line 1, column 7: name: must be a lowercase DNS label of at most 63 letters, digits and '-'"},
+		},
+		{
+			name:           "No services",
+			manifest:       "name: app\nservices: {}\n",
+			expectedErrors: []string{"line 2, column 11: services: must declare at least one service"},
+		},
+		{
+			name:           "Invalid service name",
+			manifest:       "name: app\nservices:\n  Web_App: {}\n  api: {}\n",
+			expectedErrors: []string{"line 3, column 3: services.Web_App: name must be a lowercase DNS label of at most 63 letters, digits and '-'"},
+		},
+		{
+			name:     "Invalid service values",
+			manifest: "name: app\nservices:\n  web:\n    command: [npm, start]\n    http_port: 70000\n    cpu: lots\n    memory: -1\n",
+			expectedErrors: []string{
+				"line 4, column 14: services.web.command: must be a string",
+				"line 5, column 16: services.web.http_port: must be between 1 and 65535",
+				"line 6, column 10: services.web.cpu: must be a number",
+				"line 7, column 13: services.web.memory: must be a positive number of MB",
+			},
+		},
+		{
+			name:           "Unterminated quote in command",
+			manifest:       "name: app\nservices:\n  web:\n    command: echo \"hi\n",
+			expectedErrors: []string{"line 4, column 14: services.web.command: has an unterminated quote"},
+		},
+		{
+			name:           "Port is not an integer",
+			manifest:       "name: app\nservices:\n  web:\n    http_port: \"80\"\n",
+			expectedErrors: []string{"line 4, column 16: services.web.http_port: must be an integer"},
+		},
+		{
+			name:     "Invalid healthcheck",
+			manifest: "name: app\nservices:\n  web:\n    healthcheck:\n      path: healthz\n  worker:\n    healthcheck:\n      port: 9000\n",
+			expectedErrors: []string{
+				"line 5, column 7: services.web.healthcheck.port: is required when the service has no http_port",
+				"line 5, column 13: services.web.healthcheck.path: must start with /",
+				"line 8, column 7: services.worker.healthcheck.path: is required",
+			},
+		},
+		{
+			name:           "Invalid environment variable",
+			manifest:       "name: app\nservices:\n  web:\n    env:\n      NODE-ENV: production\n      EMPTY:\n",
+			expectedErrors: []string{"line 5, column 7: services.web.env.NODE-ENV: is not a valid environment variable name", "line 6, column 13: services.web.env.EMPTY: must be a string"},
+		},
+		{
+			name:     "Invalid references",
+			manifest: "name: app\nservices:\n  web:\n    env:\n      A: ${web}\n      B: \"${Web.PORT} and ${web.port}\"\n      C: ${web.PORT\n",
+			expectedErrors: []string{
+				"line 5, column 10: services.web.env.A: has an invalid reference ${web}, expected ${<name>.<VARIABLE>}",
+				"line 6, column 10: services.web.env.B: has an invalid reference ${Web.PORT}, expected ${<name>.<VARIABLE>}",
+				"line 7, column 10: services.web.env.C: has an unterminated reference, write $${ for a literal ${",
+			},
+		},
+		{
+			name:     "Reserved names",
+			manifest: "name: app\nservices:\n  config: {}\n  web: {}\ndatabases:\n  secrets:\n    type: redis\n",
+			expectedErrors: []string{
+				"line 3, column 3: services.config: name \"config\" is reserved for references to config vars and secrets",
+				"line 6, column 3: databases.secrets: name \"secrets\" is reserved for references to config vars and secrets",
+			},
+		},
+		{
+			name:           "Conflicting build options",
+			manifest:       "name: app\nbuild:\n  builder: paketo\n  dockerfile: Dockerfile\nservices:\n  web: {}\n",
+			expectedErrors: []string{"line 3, column 3: build: builder and dockerfile cannot both be set"},
+		},
+		{
+			name:     "Invalid databases",
+			manifest: "name: app\nservices:\n  web: {}\ndatabases:\n  cache:\n    type: memcached\n  store:\n    storage: 0\n  web:\n    type: redis\n",
+			expectedErrors: []string{
+				"line 6, column 11: databases.cache.type: unsupported database type \"memcached\", expected one of postgresql, mysql, redis",
+				"line 8, column 5: databases.store.type: is required",
+				"line 8, column 14: databases.store.storage: must be a positive number of GB",
+				"line 9, column 3: databases.web: has the same name as a service",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Parse([]byte(tc.manifest))
+			require.Error(t, err)
+			assert.Nil(t, f)
+
+			var errs Errors
+			require.ErrorAs(t, err, &errs)
+			messages := make([]string, len(errs))
+			for i, e := range errs {
+				messages[i] = e.Error()
+			}
+			assert.Equal(t, tc.expectedErrors, messages)
+		})
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+	errs := Errors{
+		{Line: 2, Column: 3, Message: "name: is required"},
+		{Line: 4, Message: "bad indentation"},
+	}
+	assert.Equal(t, "line 2, column 3: name: is required; line 4: bad indentation", errs.Error())
+}
+
+func TestDefault(t *testing.T) {
+	f := Default("app-123")
+
+	assert.Equal(t, "app-123", f.Name)
+	assert.Equal(t, []string{"web"}, f.ServiceNames())
+	assert.Equal(t, DefaultHTTPPort, f.Services["web"].HTTPPort)
+}
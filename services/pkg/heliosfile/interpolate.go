@@ -0,0 +1,366 @@
+package heliosfile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The names that references use for the application's config vars and
+// secrets. Services and databases cannot be called this.
+const (
+	ConfigReference  = "config"
+	SecretsReference = "secrets"
+)
+
+// Variables are the values of an application that references in its
+// manifest can use besides its services and databases.
+type Variables struct {
+	// Config holds the config vars of the application.
+	Config map[string]string
+	// Secrets names the secrets of the application. Their values are only
+	// known to the backends.
+	Secrets []string
+}
+
+// Secret is a secret embedded in an interpolated value: either the password
+// of a managed database or a secret of the application.
+type Secret struct {
+	// Database is the database whose password this is. It is empty for a
+	// secret of the application.
+	Database string
+	// Name is the name of the application secret.
+	Name string
+}
+
+// Variable returns the name of the variable the backends provide the secret
+// in, such as HELIOS_POSTGRES_PASSWORD or HELIOS_SECRET_API_KEY.
+func (s Secret) Variable() string {
+	if s.Database != "" {
+		return PasswordVariable(s.Database)
+	}
+	return "HELIOS_SECRET_" + s.Name
+}
+
+// Part is a piece of an interpolated value: either literal text or a
+// secret.
+type Part struct {
+	Text   string
+	Secret *Secret
+}
+
+// Value is an environment value after interpolation. Secrets are kept as
+// references, since only the backend that applies a deployment knows them.
+type Value []Part
+
+// Text returns the value and true if it embeds no secrets.
+func (v Value) Text() (string, bool) {
+	if len(v.Secrets()) > 0 {
+		return "", false
+	}
+	return v.Format(func(s string) string { return s }, nil), true
+}
+
+// Format joins the parts of v, passing literal text through escape and
+// rendering each secret with secret.
+func (v Value) Format(escape func(string) string, secret func(Secret) string) string {
+	var b strings.Builder
+	for _, p := range v {
+		if p.Secret != nil {
+			b.WriteString(secret(*p.Secret))
+		} else {
+			b.WriteString(escape(p.Text))
+		}
+	}
+	return b.String()
+}
+
+// Secrets returns the secrets v embeds, in order.
+func (v Value) Secrets() []Secret {
+	var secrets []Secret
+	for _, p := range v {
+		if p.Secret != nil {
+			secrets = append(secrets, *p.Secret)
+		}
+	}
+	return secrets
+}
+
+// add appends parts to v, merging adjacent text.
+func (v Value) add(parts ...Part) Value {
+	for _, p := range parts {
+		if n := len(v); p.Secret == nil && n > 0 && v[n-1].Secret == nil {
+			v[n-1].Text += p.Text
+			continue
+		}
+		if p.Secret == nil && p.Text == "" {
+			continue
+		}
+		v = append(v, p)
+	}
+	return v
+}
+
+func text(s string) Value {
+	return Value{{Text: s}}
+}
+
+// Environment holds the interpolated env of each service that has one, by
+// service name and variable name.
+type Environment map[string]map[string]Value
+
+// Interpolate resolves the references in the env values of the services of
+// f:
+//
+//   - ${<database>.URL}, HOST, PORT, USER, PASSWORD and NAME describe a
+//     managed database. Redis has no USER, PASSWORD or NAME.
+//   - ${<service>.HOST}, PORT and INTERNAL_URL are the address of a service
+//     inside the application. PORT and INTERNAL_URL need an http_port.
+//   - ${<service>.<VARIABLE>} is another env variable of a service, which is
+//     interpolated in turn.
+//   - ${config.<NAME>} is a config var of the application.
+//   - ${secrets.<NAME>} is a secret of the application.
+//
+// $${ is a literal ${. Undefined references and reference cycles are errors;
+// like Parse, the returned error is an Errors.
+func Interpolate(f *Heliosfile, vars Variables) (Environment, error) {
+	in := &interpolator{
+		f:       f,
+		vars:    vars,
+		env:     Environment{},
+		failed:  map[envVar]bool{},
+		onStack: map[envVar]int{},
+	}
+	for _, service := range f.ServiceNames() {
+		for _, name := range sortedKeys(f.Services[service].Env) {
+			in.resolve(envVar{service: service, name: name})
+		}
+	}
+	if len(in.errs) > 0 {
+		sortErrors(in.errs)
+		return nil, in.errs
+	}
+	return in.env, nil
+}
+
+// envVar identifies an env variable of a service.
+type envVar struct {
+	service, name string
+}
+
+func (v envVar) path() string {
+	return "services." + v.service + ".env." + v.name
+}
+
+// interpolator resolves env variables depth first, keeping the variables
+// being resolved on a stack to find cycles.
+type interpolator struct {
+	f       *Heliosfile
+	vars    Variables
+	env     Environment
+	failed  map[envVar]bool
+	stack   []envVar
+	onStack map[envVar]int
+	errs    Errors
+}
+
+func (in *interpolator) errorf(v envVar, format string, args ...any) {
+	pos := in.f.positions[v.path()]
+	msg := v.path() + ": " + fmt.Sprintf(format, args...)
+	in.errs = append(in.errs, &Error{Line: pos.line, Column: pos.column, Message: msg})
+}
+
+// resolve returns the interpolated value of v. If it cannot be resolved,
+// the reason is reported once and false is returned.
+func (in *interpolator) resolve(v envVar) (Value, bool) {
+	if value, ok := in.env[v.service][v.name]; ok {
+		return value, true
+	}
+	if in.failed[v] {
+		return nil, false
+	}
+	if i, ok := in.onStack[v]; ok {
+		var paths []string
+		for _, c := range in.stack[i:] {
+			paths = append(paths, c.path())
+			in.failed[c] = true
+		}
+		in.errorf(v, "reference cycle %s -> %s", strings.Join(paths, " -> "), v.path())
+		return nil, false
+	}
+
+	tokens, err := parseTemplate(in.f.Services[v.service].Env[v.name])
+	if err != nil {
+		in.failed[v] = true
+		in.errorf(v, "%s", err)
+		return nil, false
+	}
+
+	in.onStack[v] = len(in.stack)
+	in.stack = append(in.stack, v)
+	value, ok := Value{}, true
+	for _, t := range tokens {
+		if t.ref == nil {
+			value = value.add(Part{Text: t.text})
+			continue
+		}
+		resolved, found := in.reference(v, *t.ref)
+		ok = ok && found
+		value = value.add(resolved...)
+	}
+	in.stack = in.stack[:len(in.stack)-1]
+	delete(in.onStack, v)
+
+	if !ok || in.failed[v] {
+		in.failed[v] = true
+		return nil, false
+	}
+	if in.env[v.service] == nil {
+		in.env[v.service] = map[string]Value{}
+	}
+	in.env[v.service][v.name] = value
+	return value, true
+}
+
+// reference resolves a reference in the value of from.
+func (in *interpolator) reference(from envVar, ref reference) (Value, bool) {
+	undefined := func(format string, args ...any) (Value, bool) {
+		in.errorf(from, "undefined reference %s: %s", ref, fmt.Sprintf(format, args...))
+		return nil, false
+	}
+
+	switch ref.name {
+	case ConfigReference:
+		value, ok := in.vars.Config[ref.key]
+		if !ok {
+			return undefined("no config var %s", ref.key)
+		}
+		return text(value), true
+	case SecretsReference:
+		if !contains(in.vars.Secrets, ref.key) {
+			return undefined("no secret %s", ref.key)
+		}
+		return Value{{Secret: &Secret{Name: ref.key}}}, true
+	}
+
+	if db, ok := in.f.Databases[ref.name]; ok {
+		attributes := in.databaseAttributes(ref.name, db)
+		value, ok := attributes[ref.key]
+		if !ok {
+			return undefined("database %s has no %s, expected one of %s", ref.name, ref.key, strings.Join(sortedKeys(attributes), ", "))
+		}
+		return value, true
+	}
+
+	s, ok := in.f.Services[ref.name]
+	if !ok {
+		return undefined("no service or database named %s", ref.name)
+	}
+	switch ref.key {
+	case "HOST":
+		return text(ref.name), true
+	case "PORT", "INTERNAL_URL":
+		if s.HTTPPort == 0 {
+			return undefined("service %s has no http_port", ref.name)
+		}
+		if ref.key == "PORT" {
+			return text(strconv.Itoa(s.HTTPPort)), true
+		}
+		return text(fmt.Sprintf("http://%s:%d", ref.name, s.HTTPPort)), true
+	}
+	if _, ok := s.Env[ref.key]; !ok {
+		return undefined("service %s has no %s, expected HOST, PORT, INTERNAL_URL or one of its env variables", ref.name, ref.key)
+	}
+	return in.resolve(envVar{service: ref.name, name: ref.key})
+}
+
+// databaseAttributes returns the values a reference to the database called
+// name can use. Databases are reached at their name inside the application.
+func (in *interpolator) databaseAttributes(name string, db Database) map[string]Value {
+	engine := db.Engine()
+	port := strconv.Itoa(engine.Port)
+	attributes := map[string]Value{
+		"HOST": text(name),
+		"PORT": text(port),
+	}
+	if engine.PasswordEnv == "" {
+		attributes["URL"] = text(engine.Scheme + "://" + name + ":" + port)
+		return attributes
+	}
+
+	password := Part{Secret: &Secret{Database: name}}
+	attributes["USER"] = text(DatabaseUser)
+	attributes["PASSWORD"] = Value{password}
+	attributes["NAME"] = text(in.f.Name)
+	attributes["URL"] = Value{}.add(
+		Part{Text: engine.Scheme + "://" + DatabaseUser + ":"},
+		password,
+		Part{Text: "@" + name + ":" + port + "/" + in.f.Name},
+	)
+	return attributes
+}
+
+// reference is a ${<name>.<key>} in an env value.
+type reference struct {
+	name, key string
+}
+
+func (r reference) String() string {
+	return "${" + r.name + "." + r.key + "}"
+}
+
+// token is literal text or a reference in an env value.
+type token struct {
+	text string
+	ref  *reference
+}
+
+// parseTemplate splits an env value into literal text and references.
+func parseTemplate(s string) ([]token, error) {
+	var tokens []token
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			tokens = append(tokens, token{text: b.String()})
+			b.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, errors.New("has an unterminated reference, write $${ for a literal ${")
+			}
+			expr := s[i+2 : i+end]
+			name, key, ok := strings.Cut(expr, ".")
+			if !ok || !dnsLabel.MatchString(name) || !envName.MatchString(key) {
+				return nil, fmt.Errorf("has an invalid reference ${%s}, expected ${<name>.<VARIABLE>}", expr)
+			}
+			flush()
+			tokens = append(tokens, token{ref: &reference{name: name, key: key}})
+			i += end + 1
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	flush()
+	return tokens, nil
+}
+
+// sortErrors orders errors by their position.
+func sortErrors(errs Errors) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+}
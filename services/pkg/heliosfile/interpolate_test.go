@@ -0,0 +1,155 @@
+package heliosfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plain renders a value with secrets as <VARIABLE>, for comparing.
+func plain(v Value) string {
+	return v.Format(func(s string) string { return s }, func(s Secret) string { return "<" + s.Variable() + ">" })
+}
+
+func TestInterpolate(t *testing.T) {
+	f, err := Parse([]byte(`
+name: shop
+services:
+  web:
+    http_port: 3000
+    env:
+      DATABASE_URL: ${postgres.URL}
+      MYSQL_DSN: "${orders.USER}:${orders.PASSWORD}@tcp(${orders.HOST}:${orders.PORT})/${orders.NAME}"
+      REDIS_URL: ${cache.URL}
+      API_URL: ${api.INTERNAL_URL}/v1
+      QUEUE: ${worker.QUEUE}
+      PLAN: ${config.PLAN}
+      STRIPE_KEY: ${secrets.STRIPE_KEY}
+      LITERAL: $${web.HOST} costs $5
+  api:
+    http_port: 9000
+  worker:
+    env:
+      QUEUE: ${web.HOST}-${config.PLAN}
+databases:
+  postgres:
+    type: postgresql
+  orders:
+    type: mysql
+  cache:
+    type: redis
+`))
+	require.NoError(t, err)
+
+	env, err := Interpolate(f, Variables{Config: map[string]string{"PLAN": "pro"}, Secrets: []string{"STRIPE_KEY"}})
+	require.NoError(t, err)
+
+	rendered := map[string]map[string]string{}
+	for service, vars := range env {
+		rendered[service] = map[string]string{}
+		for name, value := range vars {
+			rendered[service][name] = plain(value)
+		}
+	}
+	assert.Equal(t, map[string]map[string]string{
+		"web": {
+			"DATABASE_URL": "postgresql://helios:<HELIOS_POSTGRES_PASSWORD>@postgres:5432/shop",
+			"MYSQL_DSN":    "helios:<HELIOS_ORDERS_PASSWORD>@tcp(orders:3306)/shop",
+			"REDIS_URL":    "redis://cache:6379",
+			"API_URL":      "http://api:9000/v1",
+			"QUEUE":        "web-pro",
+			"PLAN":         "pro",
+			"STRIPE_KEY":   "<HELIOS_SECRET_STRIPE_KEY>",
+			"LITERAL":      "${web.HOST} costs $5",
+		},
+		"worker": {"QUEUE": "web-pro"},
+	}, rendered)
+
+	assert.Equal(t, Value{
+		{Text: "postgresql://helios:"},
+		{Secret: &Secret{Database: "postgres"}},
+		{Text: "@postgres:5432/shop"},
+	}, env["web"]["DATABASE_URL"])
+	assert.Equal(t, []Secret{{Name: "STRIPE_KEY"}}, env["web"]["STRIPE_KEY"].Secrets())
+
+	text, ok := env["web"]["API_URL"].Text()
+	assert.True(t, ok)
+	assert.Equal(t, "http://api:9000/v1", text)
+	_, ok = env["web"]["DATABASE_URL"].Text()
+	assert.False(t, ok, "a value with a secret has no plain text")
+}
+
+func TestInterpolateNoEnv(t *testing.T) {
+	env, err := Interpolate(Default("app-123"), Variables{})
+	require.NoError(t, err)
+	assert.Empty(t, env)
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            string
+		expectedErrors []string
+	}{
+		{
+			name: "Undefined services and databases",
+			env:  "A: ${db.URL}\n      B: ${postgres.FOO}\n      C: ${cache.PASSWORD}\n",
+			expectedErrors: []string{
+				"line 6, column 10: services.web.env.A: undefined reference ${db.URL}: no service or database named db",
+				"line 7, column 10: services.web.env.B: undefined reference ${postgres.FOO}: database postgres has no FOO, expected one of HOST, NAME, PASSWORD, PORT, URL, USER",
+				"line 8, column 10: services.web.env.C: undefined reference ${cache.PASSWORD}: database cache has no PASSWORD, expected one of HOST, PORT, URL",
+			},
+		},
+		{
+			name: "Undefined service attributes",
+			env:  "A: ${worker.INTERNAL_URL}\n      B: ${worker.MISSING}\n",
+			expectedErrors: []string{
+				"line 6, column 10: services.web.env.A: undefined reference ${worker.INTERNAL_URL}: service worker has no http_port",
+				"line 7, column 10: services.web.env.B: undefined reference ${worker.MISSING}: service worker has no MISSING, expected HOST, PORT, INTERNAL_URL or one of its env variables",
+			},
+		},
+		{
+			name: "Undefined config vars and secrets",
+			env:  "A: ${config.MISSING}\n      B: ${secrets.MISSING}\n",
+			expectedErrors: []string{
+				"line 6, column 10: services.web.env.A: undefined reference ${config.MISSING}: no config var MISSING",
+				"line 7, column 10: services.web.env.B: undefined reference ${secrets.MISSING}: no secret MISSING",
+			},
+		},
+		{
+			name: "Self reference",
+			env:  "A: x${web.A}\n",
+			expectedErrors: []string{
+				"line 6, column 10: services.web.env.A: reference cycle services.web.env.A -> services.web.env.A",
+			},
+		},
+		{
+			name: "Cycle across services",
+			env:  "A: ${worker.B}\n      C: ${web.A}\n",
+			expectedErrors: []string{
+				"line 6, column 10: services.web.env.A: reference cycle services.web.env.A -> services.worker.env.B -> services.web.env.A",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Parse([]byte("name: app\nservices:\n  web:\n    http_port: 8080\n    env:\n      " + tc.env +
+				"  worker:\n    env:\n      B: ${web.A}\ndatabases:\n  postgres:\n    type: postgresql\n  cache:\n    type: redis\n"))
+			require.NoError(t, err)
+
+			env, err := Interpolate(f, Variables{})
+			require.Error(t, err)
+			assert.Nil(t, env)
+
+			var errs Errors
+			require.ErrorAs(t, err, &errs)
+			var msgs []string
+			for _, e := range errs {
+				msgs = append(msgs, e.Error())
+			}
+			assert.Equal(t, tc.expectedErrors, msgs)
+		})
+	}
+}